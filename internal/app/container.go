@@ -0,0 +1,416 @@
+package app
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"github.com/goldcast/gc_auth_service/internal/auth"
+	"github.com/goldcast/gc_auth_service/internal/config"
+	"github.com/goldcast/gc_auth_service/internal/database"
+	"github.com/goldcast/gc_auth_service/internal/oauth"
+	"github.com/goldcast/gc_auth_service/internal/repository"
+	"github.com/goldcast/gc_auth_service/pkg/jwt"
+	"github.com/goldcast/gc_auth_service/pkg/logger"
+	"github.com/goldcast/gc_auth_service/pkg/mailer"
+	"github.com/goldcast/gc_auth_service/pkg/password"
+	"github.com/goldcast/gc_auth_service/pkg/secretbox"
+)
+
+// Container holds the shared dependencies of the service. It is built once
+// at startup and handed to handlers, routes and middleware so that every
+// component uses the same configuration, logger, JWT service and stores.
+type Container struct {
+	Config           *config.Config
+	Logger           *logger.Logger
+	DB               *database.DB // nil when running without a database
+	SecretBox        *secretbox.Box
+	JWT              *jwt.Service
+	Keys             *auth.KeyManager // nil unless JWT_MANAGED_KEYS is enabled
+	Users            repository.UserRepository
+	Sessions         repository.SessionRepository
+	Revocations      repository.RevocationRepository
+	Clients          repository.ClientRepository
+	AuthCodes        repository.AuthorizationCodeRepository
+	DeviceCodes      repository.DeviceCodeRepository
+	MFA              repository.MFARepository
+	MFAChallenges    repository.MFAChallengeRepository
+	Passkeys         repository.PasskeyRepository
+	WebAuthnSessions repository.WebAuthnSessionRepository
+	Verifications    repository.EmailVerificationRepository
+	PasswordResets   repository.PasswordResetRepository
+	PasswordHistory  repository.PasswordHistoryRepository
+	AuditEvents      repository.AuditRepository
+	Mailer           mailer.Mailer
+	Mailbox          *mailer.MemoryMailer // nil unless MAILER is memory
+	Hasher           *password.Hasher
+	Importer         *auth.UserImporter
+	Authenticator    *auth.Authenticator
+	PasskeyService   *auth.PasskeyService
+	MFAService       *auth.MFAService
+	EmailVerifier    *auth.EmailVerifier
+	Passwords        *auth.PasswordService
+	ResetService     *auth.PasswordResetService
+	Tokens           *auth.TokenService
+	Audit            *auth.AuditLog
+	OAuth            *oauth.Server
+	ClientRegistry   *oauth.ClientRegistry
+
+	stop chan struct{}
+}
+
+// New builds a container from the given configuration. It starts nothing in
+// the background, so that one-shot commands can use it too; the server calls
+// Start once the container is built.
+func New(cfg *config.Config, log *logger.Logger) (*Container, error) {
+	c := &Container{
+		Config: cfg,
+		Logger: log,
+		stop:   make(chan struct{}),
+	}
+
+	if cfg.DatabaseURL != "" {
+		db, err := database.Open(cfg.DatabaseURL)
+		if err != nil {
+			return nil, fmt.Errorf("connect to database: %w", err)
+		}
+		c.DB = db
+
+		if cfg.AutoMigrate {
+			if err := c.migrate(); err != nil {
+				db.Close()
+				return nil, err
+			}
+		}
+
+		c.Users = repository.NewSQLUserRepository(db.DB)
+		c.Sessions = repository.NewSQLSessionRepository(db.DB)
+		c.Revocations = repository.NewSQLRevocationRepository(db.DB)
+		c.Clients = repository.NewSQLClientRepository(db.DB)
+		c.AuthCodes = repository.NewSQLAuthorizationCodeRepository(db.DB)
+		c.DeviceCodes = repository.NewSQLDeviceCodeRepository(db.DB)
+		c.MFA = repository.NewSQLMFARepository(db.DB)
+		c.MFAChallenges = repository.NewSQLMFAChallengeRepository(db.DB)
+		c.Passkeys = repository.NewSQLPasskeyRepository(db.DB)
+		c.WebAuthnSessions = repository.NewSQLWebAuthnSessionRepository(db.DB)
+		c.Verifications = repository.NewSQLEmailVerificationRepository(db.DB)
+		c.PasswordResets = repository.NewSQLPasswordResetRepository(db.DB)
+		c.PasswordHistory = repository.NewSQLPasswordHistoryRepository(db.DB)
+		c.AuditEvents = repository.NewSQLAuditRepository(db.DB)
+	} else {
+		log.Warn("DATABASE_URL not set, using in-memory repositories")
+		c.Users = repository.NewMemoryUserRepository()
+		c.Sessions = repository.NewMemorySessionRepository()
+		c.Revocations = repository.NewMemoryRevocationRepository()
+		c.Clients = repository.NewMemoryClientRepository()
+		c.AuthCodes = repository.NewMemoryAuthorizationCodeRepository()
+		c.DeviceCodes = repository.NewMemoryDeviceCodeRepository()
+		c.MFA = repository.NewMemoryMFARepository()
+		c.MFAChallenges = repository.NewMemoryMFAChallengeRepository()
+		c.Passkeys = repository.NewMemoryPasskeyRepository()
+		c.WebAuthnSessions = repository.NewMemoryWebAuthnSessionRepository()
+		c.Verifications = repository.NewMemoryEmailVerificationRepository()
+		c.PasswordResets = repository.NewMemoryPasswordResetRepository()
+		c.PasswordHistory = repository.NewMemoryPasswordHistoryRepository()
+		c.AuditEvents = repository.NewMemoryAuditRepository()
+	}
+
+	encryptionKey, err := cfg.EncryptionKeyBytes()
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	if c.SecretBox, err = secretbox.New(encryptionKey); err != nil {
+		c.Close()
+		return nil, err
+	}
+
+	if err := c.initJWT(); err != nil {
+		c.Close()
+		return nil, err
+	}
+
+	if err := c.initMailer(); err != nil {
+		c.Close()
+		return nil, err
+	}
+
+	hasherConfig := password.HasherConfig{
+		Algorithm: cfg.PasswordHash,
+		Argon2: password.Argon2Params{
+			Memory:      uint32(cfg.Argon2Memory),
+			Iterations:  uint32(cfg.Argon2Iterations),
+			Parallelism: uint8(cfg.Argon2Parallelism),
+		},
+		BcryptCost: cfg.BcryptCost,
+		Pepper:     cfg.PasswordPepper,
+	}
+	if cfg.FirebaseSignerKey != "" {
+		signerKey, err := base64.StdEncoding.DecodeString(cfg.FirebaseSignerKey)
+		if err != nil {
+			c.Close()
+			return nil, fmt.Errorf("decode FIREBASE_SCRYPT_SIGNER_KEY: %w", err)
+		}
+		separator, err := base64.StdEncoding.DecodeString(cfg.FirebaseSeparator)
+		if err != nil {
+			c.Close()
+			return nil, fmt.Errorf("decode FIREBASE_SCRYPT_SALT_SEPARATOR: %w", err)
+		}
+		hasherConfig.Firebase = &password.FirebaseScrypt{
+			SignerKey:     signerKey,
+			SaltSeparator: separator,
+			Rounds:        cfg.FirebaseRounds,
+			MemCost:       cfg.FirebaseMemCost,
+		}
+	}
+	hasher, err := password.NewHasher(hasherConfig)
+	if err != nil {
+		c.Close()
+		return nil, fmt.Errorf("initialize password hasher: %w", err)
+	}
+	c.Hasher = hasher
+	c.Audit = auth.NewAuditLog(c.AuditEvents, log)
+	c.Importer = auth.NewUserImporter(c.Users, c.Hasher)
+	authenticator, err := auth.NewAuthenticator(c.Users, c.Hasher, auth.LoginPolicy{RequireVerifiedEmail: cfg.VerifiedEmailOnly}, log)
+	if err != nil {
+		c.Close()
+		return nil, fmt.Errorf("initialize authenticator: %w", err)
+	}
+	c.Authenticator = authenticator
+	passkeys, err := auth.NewPasskeyService(auth.PasskeyConfig{
+		RPID:    cfg.WebAuthnRPID,
+		RPName:  cfg.WebAuthnRPName,
+		Origins: cfg.WebAuthnOrigins,
+	}, c.Passkeys, c.WebAuthnSessions, c.Users)
+	if err != nil {
+		c.Close()
+		return nil, fmt.Errorf("initialize passkeys: %w", err)
+	}
+	c.PasskeyService = passkeys
+	c.MFAService = auth.NewMFAService(c.MFA, c.MFAChallenges, c.Users, c.PasskeyService, c.SecretBox, cfg.TOTPIssuer)
+	c.EmailVerifier = auth.NewEmailVerifier(c.JWT, c.Verifications, c.Users, c.Mailer, cfg.VerificationURL)
+	c.Tokens = auth.NewTokenService(c.JWT, c.Users, c.Sessions, c.Revocations, c.Clients, time.Duration(cfg.RefreshExpiry)*time.Hour)
+	policy, err := c.passwordPolicy()
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	c.Passwords = auth.NewPasswordService(c.Users, c.PasswordHistory, c.Tokens, c.Mailer, cfg.PasswordHistory, c.Hasher, policy)
+	c.ResetService = auth.NewPasswordResetService(c.PasswordResets, c.Users, c.Tokens, c.Passwords, c.Mailer, cfg.PasswordResetURL, auth.PasswordResetPolicy{
+		TTL:        time.Duration(cfg.PasswordResetTTL) * time.Minute,
+		EmailLimit: cfg.ResetEmailLimit,
+		IPLimit:    cfg.ResetIPLimit,
+	}, log)
+	c.OAuth = oauth.NewServer(c.Clients, c.AuthCodes, c.DeviceCodes, c.Users, c.Revocations, c.Tokens, c.JWT, cfg.IssuerURL)
+	c.ClientRegistry = oauth.NewClientRegistry(c.Clients, cfg.RegistrationScopes, cfg.RegistrationGrants)
+	if !c.JWT.Keyring().Active().IsAsymmetric() {
+		log.Warn("Tokens are signed with a shared secret; OpenID Connect clients need an asymmetric key to verify ID tokens")
+	}
+
+	return c, nil
+}
+
+// Start registers the OAuth clients of OAUTH_CLIENTS_FILE and starts the
+// background work of the server: purging expired records and, with managed
+// keys, rotating the signing keys. It runs until the container is closed.
+func (c *Container) Start() error {
+	if c.Config.OAuthClientsFile != "" {
+		if err := c.seedClients(); err != nil {
+			return err
+		}
+	}
+
+	go c.runJanitor(10 * time.Minute)
+	if c.Keys != nil {
+		go c.Keys.Run(c.stop, time.Minute)
+	}
+	return nil
+}
+
+// initJWT sets up the JWT service from managed keys, a key file or the shared secret
+func (c *Container) initJWT() error {
+	cfg := c.Config
+	switch {
+	case cfg.JWTManagedKeys:
+		var keyRepo repository.SigningKeyRepository
+		if c.DB != nil {
+			keyRepo = repository.NewSQLSigningKeyRepository(c.DB.DB)
+		} else {
+			keyRepo = repository.NewMemorySigningKeyRepository()
+		}
+		c.Keys = auth.NewKeyManager(keyRepo, c.SecretBox, auth.KeyPolicy{
+			Algorithm:        cfg.JWTKeyAlgorithm,
+			RotationInterval: time.Duration(cfg.JWTKeyRotation) * time.Hour,
+			GracePeriod:      time.Duration(cfg.JWTKeyGrace) * time.Hour,
+		}, c.Logger)
+
+		keyring, err := c.Keys.Init(context.Background())
+		if err != nil {
+			return fmt.Errorf("load signing keys: %w", err)
+		}
+		c.JWT = jwt.NewWithKeyring(keyring, cfg.JWTExpiry)
+	case cfg.JWTKeyFile != "":
+		key, err := jwt.LoadPrivateKeyFile(cfg.JWTKeyID, cfg.JWTKeyFile)
+		if err != nil {
+			return fmt.Errorf("load signing key: %w", err)
+		}
+		c.JWT = jwt.NewWithKey(key, cfg.JWTExpiry)
+		c.Logger.WithFields(map[string]interface{}{
+			"kid": key.ID,
+			"alg": key.Algorithm,
+		}).Info("Loaded JWT signing key")
+	default:
+		c.JWT = jwt.New(cfg.JWTSecret, cfg.JWTExpiry)
+	}
+	return nil
+}
+
+// initMailer sets up delivery of outgoing email
+func (c *Container) initMailer() error {
+	cfg := c.Config
+	switch cfg.Mailer {
+	case "smtp":
+		m, err := mailer.NewSMTPMailer(mailer.SMTPConfig{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.MailFrom,
+			Insecure: cfg.SMTPInsecure,
+		})
+		if err != nil {
+			return err
+		}
+		if cfg.SMTPInsecure {
+			c.Logger.Warn("SMTP_INSECURE is set; email is sent in plain text when the SMTP server does not offer STARTTLS")
+		}
+		c.Mailer = m
+	case "memory":
+		c.Mailbox = mailer.NewMemoryMailer()
+		c.Mailer = c.Mailbox
+		c.Logger.Warn("MAILER is memory; email is kept in memory and readable on the admin API")
+	default:
+		c.Mailer = mailer.NewLogMailer(c.Logger)
+		c.Logger.Warn("No SMTP server configured; email is written to the log instead of being delivered")
+	}
+	return nil
+}
+
+// passwordPolicy returns the requirements for new passwords, loading the
+// lists of common and breached passwords if configured
+func (c *Container) passwordPolicy() (password.Policy, error) {
+	cfg := c.Config
+	classes := make([]password.CharClass, len(cfg.PasswordClasses))
+	for i, class := range cfg.PasswordClasses {
+		classes[i] = password.CharClass(class)
+	}
+	policy := password.Policy{
+		MinLength:       cfg.PasswordMinLength,
+		MaxLength:       cfg.PasswordMaxLength,
+		RequiredClasses: classes,
+		MaxRepeated:     cfg.PasswordRepeat,
+		RejectPersonal:  cfg.PasswordNoPersonal,
+		RejectCommon:    cfg.PasswordNoCommon,
+		MinEntropy:      cfg.PasswordEntropy,
+		MaxBytes:        c.Hasher.MaxBytes(),
+	}
+	if cfg.CommonPasswords != "" {
+		common, err := password.LoadCommonList(cfg.CommonPasswords)
+		if err != nil {
+			return policy, fmt.Errorf("load common passwords: %w", err)
+		}
+		c.Logger.Infof("Loaded %d common passwords", common.Len())
+		policy.Common = common
+	}
+	if cfg.BreachedPasswords == "" {
+		return policy, nil
+	}
+
+	breached, err := password.OpenBreachList(cfg.BreachedPasswords)
+	if err != nil {
+		return policy, fmt.Errorf("load breached passwords: %w", err)
+	}
+	if filter, ok := breached.(*password.BloomFilter); ok {
+		c.Logger.Infof("Loaded Bloom filter of %d breached passwords (%.1f MB)", filter.Len(), float64(filter.Size())/(1<<20))
+	} else {
+		c.Logger.Infof("Checking passwords against the breached password corpus in %s", cfg.BreachedPasswords)
+	}
+	policy.Breached = breached
+	return policy, nil
+}
+
+// Close releases resources held by the container
+func (c *Container) Close() error {
+	select {
+	case <-c.stop:
+	default:
+		close(c.stop)
+	}
+	if c.DB != nil {
+		return c.DB.Close()
+	}
+	return nil
+}
+
+// migrate applies pending schema migrations
+func (c *Container) migrate() error {
+	migrator, err := database.NewMigrator(c.DB)
+	if err != nil {
+		return fmt.Errorf("load migrations: %w", err)
+	}
+	applied, err := migrator.Up(context.Background())
+	if err != nil {
+		return fmt.Errorf("apply migrations: %w", err)
+	}
+	c.Logger.Infof("Applied %d database migrations", len(applied))
+	return nil
+}
+
+// seedClients registers the OAuth clients listed in OAUTH_CLIENTS_FILE
+func (c *Container) seedClients() error {
+	clients, err := oauth.LoadClientsFile(c.Config.OAuthClientsFile)
+	if err != nil {
+		return fmt.Errorf("load OAuth clients: %w", err)
+	}
+	n, err := oauth.SeedClients(context.Background(), c.Clients, clients)
+	if err != nil {
+		return fmt.Errorf("register OAuth clients: %w", err)
+	}
+	c.Logger.Infof("Registered %d OAuth clients", n)
+	return nil
+}
+
+// runJanitor periodically removes expired records until the container is closed
+func (c *Container) runJanitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+			c.purge("revoked tokens", c.Tokens.PurgeExpired)
+			c.purge("authorization codes", c.OAuth.PurgeExpiredCodes)
+			c.purge("device codes", c.OAuth.PurgeExpiredDeviceCodes)
+			c.purge("MFA challenges", c.MFAService.PurgeExpired)
+			c.purge("WebAuthn sessions", c.PasskeyService.PurgeExpired)
+			c.purge("email verifications", c.EmailVerifier.PurgeExpired)
+			c.purge("password resets", c.ResetService.PurgeExpired)
+			c.purge("password change limits", c.Passwords.PurgeExpired)
+		}
+	}
+}
+
+// purge runs one janitor task and logs its outcome
+func (c *Container) purge(what string, fn func(context.Context) (int64, error)) {
+	purged, err := fn(context.Background())
+	if err != nil {
+		c.Logger.WithField("error", err.Error()).Errorf("Failed to purge expired %s", what)
+		return
+	}
+	if purged > 0 {
+		c.Logger.WithField("count", purged).Debugf("Purged expired %s", what)
+	}
+}
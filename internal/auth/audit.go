@@ -0,0 +1,50 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"github.com/goldcast/gc_auth_service/internal/models"
+	"github.com/goldcast/gc_auth_service/internal/repository"
+	"github.com/goldcast/gc_auth_service/pkg/logger"
+	"github.com/google/uuid"
+)
+
+// AuditLog records security-relevant events in the audit trail, and logs
+// them with audit=true so that they can also be routed from the logs
+type AuditLog struct {
+	events repository.AuditRepository
+	logger *logger.Logger
+}
+
+// NewAuditLog creates an audit log storing events in the given repository
+func NewAuditLog(events repository.AuditRepository, log *logger.Logger) *AuditLog {
+	return &AuditLog{events: events, logger: log}
+}
+
+// Record stores an event, filling in its ID and time. The action it
+// describes already happened, so an event that cannot be stored is logged
+// as an error rather than returned.
+func (a *AuditLog) Record(ctx context.Context, event *models.AuditEvent) {
+	event.ID = uuid.New()
+	event.CreatedAt = time.Now()
+
+	fields := make(map[string]interface{}, len(event.Metadata)+3)
+	for key, value := range event.Metadata {
+		fields[key] = value
+	}
+	if event.UserID != nil {
+		fields["user_id"] = *event.UserID
+	}
+	if event.IPAddress != "" {
+		fields["ip_address"] = event.IPAddress
+	}
+	a.logger.Audit(event.Type, fields)
+
+	if err := a.events.Create(context.WithoutCancel(ctx), event); err != nil {
+		a.logger.WithFields(map[string]interface{}{
+			"event": event.Type,
+			"error": err.Error(),
+		}).Error("Failed to store audit event")
+	}
+}
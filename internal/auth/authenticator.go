@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/goldcast/gc_auth_service/internal/models"
+	"github.com/goldcast/gc_auth_service/internal/repository"
+	"github.com/goldcast/gc_auth_service/pkg/logger"
+	"github.com/goldcast/gc_auth_service/pkg/password"
+)
+
+// ErrInvalidCredentials is returned for any failed login, regardless of the cause,
+// so that callers cannot tell unknown emails, wrong passwords and inactive accounts apart
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// ErrEmailNotVerified is returned for correct credentials of a user who must
+// verify their email address before signing in
+var ErrEmailNotVerified = errors.New("email address not verified")
+
+// LoginPolicy decides which users may sign in
+type LoginPolicy struct {
+	// RequireVerifiedEmail refuses users who have not verified their email address
+	RequireVerifiedEmail bool
+}
+
+// Authenticator verifies user credentials. Password hashes made with an
+// outdated algorithm or parameters are upgraded when their user signs in.
+type Authenticator struct {
+	users     repository.UserRepository
+	hasher    *password.Hasher
+	policy    LoginPolicy
+	logger    *logger.Logger
+	dummyHash string
+}
+
+// NewAuthenticator creates a new authenticator backed by the given user repository
+func NewAuthenticator(users repository.UserRepository, hasher *password.Hasher, policy LoginPolicy, log *logger.Logger) (*Authenticator, error) {
+	// The dummy hash is compared against when the email is unknown, so that the
+	// response time does not reveal whether an account exists
+	dummyHash, err := hasher.Hash("gc_auth_service-dummy-password")
+	if err != nil {
+		return nil, err
+	}
+
+	return &Authenticator{
+		users:     users,
+		hasher:    hasher,
+		policy:    policy,
+		logger:    log,
+		dummyHash: dummyHash,
+	}, nil
+}
+
+// Authenticate returns the user matching the email and password
+func (a *Authenticator) Authenticate(ctx context.Context, email, plain string) (*models.User, error) {
+	user, err := a.users.GetByEmail(ctx, strings.TrimSpace(email))
+	if err != nil {
+		if !errors.Is(err, repository.ErrNotFound) {
+			return nil, err
+		}
+		a.hasher.Verify(plain, a.dummyHash)
+		return nil, ErrInvalidCredentials
+	}
+
+	// Always verify the hash before looking at the account state so that
+	// inactive accounts take as long to reject as wrong passwords
+	ok, err := a.hasher.Verify(plain, user.Password)
+	if err != nil {
+		a.logger.WithFields(map[string]interface{}{
+			"user_id": user.ID,
+			"error":   err.Error(),
+		}).Warn("Failed to verify password hash")
+	}
+	if !ok {
+		return nil, ErrInvalidCredentials
+	}
+	if !user.IsActive {
+		return nil, ErrInvalidCredentials
+	}
+	a.rehash(ctx, user, plain)
+	if err := a.CheckPolicy(user); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// rehash upgrades the user's password hash if it was made with an outdated
+// algorithm or parameters. The login succeeds even if this fails; the hash
+// is upgraded at a later login.
+func (a *Authenticator) rehash(ctx context.Context, user *models.User, plain string) {
+	if !a.hasher.NeedsRehash(user.Password) {
+		return
+	}
+	hash, err := a.hasher.Hash(plain)
+	if err == nil {
+		err = a.users.UpdatePassword(ctx, user.ID, hash, time.Now())
+	}
+	if err != nil {
+		a.logger.WithFields(map[string]interface{}{
+			"user_id": user.ID,
+			"error":   err.Error(),
+		}).Warn("Failed to upgrade password hash")
+		return
+	}
+	user.Password = hash
+	a.logger.WithField("user_id", user.ID).Info("Upgraded password hash")
+}
+
+// CheckPolicy checks that the login policy allows an authenticated user to
+// sign in
+func (a *Authenticator) CheckPolicy(user *models.User) error {
+	if a.policy.RequireVerifiedEmail && !user.EmailVerified {
+		return ErrEmailNotVerified
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/goldcast/gc_auth_service/internal/repository"
+	"github.com/goldcast/gc_auth_service/pkg/logger"
+	"github.com/goldcast/gc_auth_service/pkg/password"
+)
+
+func TestAuthenticateRehash(t *testing.T) {
+	ctx := context.Background()
+	argon2 := password.Argon2Params{Memory: 64, Iterations: 1, Parallelism: 1}
+	hasher, err := password.NewHasher(password.HasherConfig{Algorithm: password.Argon2id, Argon2: argon2})
+	if err != nil {
+		t.Fatalf("new hasher: %v", err)
+	}
+	old, err := password.NewHasher(password.HasherConfig{Algorithm: password.Bcrypt, BcryptCost: 4})
+	if err != nil {
+		t.Fatalf("new hasher: %v", err)
+	}
+
+	users := repository.NewMemoryUserRepository()
+	user := createTestUser(t, users)
+	hash, err := old.Hash(testPassword)
+	if err != nil {
+		t.Fatalf("hash password: %v", err)
+	}
+	if err := users.UpdatePassword(ctx, user.ID, hash, time.Now()); err != nil {
+		t.Fatalf("set password: %v", err)
+	}
+	authenticator, err := NewAuthenticator(users, hasher, LoginPolicy{}, logger.New("error", "test"))
+	if err != nil {
+		t.Fatalf("new authenticator: %v", err)
+	}
+
+	// A wrong password leaves the hash alone
+	if _, err := authenticator.Authenticate(ctx, user.Email, "wrong-password"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("wrong password: %v, want %v", err, ErrInvalidCredentials)
+	}
+	if stored, _ := users.GetByID(ctx, user.ID); stored.Password != hash {
+		t.Fatal("hash changed after a wrong password")
+	}
+
+	if _, err := authenticator.Authenticate(ctx, user.Email, testPassword); err != nil {
+		t.Fatalf("login with the bcrypt hash: %v", err)
+	}
+	stored, err := users.GetByID(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("get user: %v", err)
+	}
+	if !strings.HasPrefix(stored.Password, "$argon2id$") || hasher.NeedsRehash(stored.Password) {
+		t.Fatalf("hash after login %s, want a current argon2id hash", stored.Password)
+	}
+
+	// The upgraded hash keeps working and is not rehashed again
+	if _, err := authenticator.Authenticate(ctx, user.Email, testPassword); err != nil {
+		t.Fatalf("login with the upgraded hash: %v", err)
+	}
+	if again, _ := users.GetByID(ctx, user.ID); again.Password != stored.Password {
+		t.Error("current hash was rehashed")
+	}
+}
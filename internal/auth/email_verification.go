@@ -0,0 +1,178 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/goldcast/gc_auth_service/internal/models"
+	"github.com/goldcast/gc_auth_service/internal/repository"
+	"github.com/goldcast/gc_auth_service/pkg/jwt"
+	"github.com/goldcast/gc_auth_service/pkg/mailer"
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrInvalidVerificationToken is returned for verification links that are
+	// forged, expired, already used or sent to an address the user no longer has
+	ErrInvalidVerificationToken = errors.New("invalid email verification token")
+	// ErrVerificationThrottled is returned when a verification link was sent
+	// to the user too recently to send another
+	ErrVerificationThrottled = errors.New("email verification sent too recently")
+)
+
+const (
+	// EmailVerificationTTL is how long verification links remain valid
+	EmailVerificationTTL = 24 * time.Hour
+
+	// verificationResendInterval is the minimum time between two verification
+	// emails to the same user
+	verificationResendInterval = time.Minute
+)
+
+// EmailVerifier sends users links that prove they receive mail at their
+// email address, and marks the address verified when a link is opened. Links
+// carry a signed token naming a stored verification, so that each can only be
+// used once.
+type EmailVerifier struct {
+	jwt           *jwt.Service
+	verifications repository.EmailVerificationRepository
+	users         repository.UserRepository
+	mailer        mailer.Mailer
+	linkURL       string
+}
+
+// NewEmailVerifier creates an email verifier. The token is appended to the
+// link URL as the token query parameter.
+func NewEmailVerifier(jwtService *jwt.Service, verifications repository.EmailVerificationRepository, users repository.UserRepository, m mailer.Mailer, linkURL string) *EmailVerifier {
+	return &EmailVerifier{
+		jwt:           jwtService,
+		verifications: verifications,
+		users:         users,
+		mailer:        m,
+		linkURL:       linkURL,
+	}
+}
+
+// Send emails the user a new verification link, unless their address is
+// already verified
+func (v *EmailVerifier) Send(ctx context.Context, user *models.User) error {
+	if user.EmailVerified {
+		return nil
+	}
+
+	latest, err := v.verifications.GetLatestByUser(ctx, user.ID)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return err
+	}
+	now := time.Now()
+	if latest != nil && now.Sub(latest.CreatedAt) < verificationResendInterval {
+		return ErrVerificationThrottled
+	}
+
+	verification := &models.EmailVerification{
+		ID:        uuid.New(),
+		UserID:    user.ID,
+		Email:     user.Email,
+		CreatedAt: now,
+		ExpiresAt: now.Add(EmailVerificationTTL),
+	}
+	token, err := v.jwt.GenerateEmailVerificationToken(user.ID, user.Email, verification.ID, verification.ExpiresAt)
+	if err != nil {
+		return err
+	}
+	if err := v.verifications.Create(ctx, verification); err != nil {
+		return err
+	}
+
+	return v.mailer.Send(ctx, &mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"Please confirm that this is your email address by opening the link below:\n\n"+
+			"%s\n\n"+
+			"The link expires in %d hours. If you did not create an account, you can ignore this email.\n",
+			user.FirstName, v.link(token), int(EmailVerificationTTL.Hours())),
+	})
+}
+
+// Resend emails a new verification link to the user with the given address.
+// Unknown and already verified addresses are silently ignored, so that the
+// outcome does not reveal whether an account exists.
+func (v *EmailVerifier) Resend(ctx context.Context, email string) error {
+	user, err := v.users.GetByEmail(ctx, strings.TrimSpace(email))
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !user.IsActive {
+		return nil
+	}
+
+	err = v.Send(ctx, user)
+	if errors.Is(err, ErrVerificationThrottled) {
+		return nil
+	}
+	return err
+}
+
+// Verify marks the email address a verification link was sent to as verified
+// and returns its user
+func (v *EmailVerifier) Verify(ctx context.Context, token string) (*models.User, error) {
+	claims, err := v.jwt.ValidateEmailVerificationToken(token)
+	if err != nil {
+		return nil, ErrInvalidVerificationToken
+	}
+	id, err := uuid.Parse(claims.ID)
+	if err != nil {
+		return nil, ErrInvalidVerificationToken
+	}
+
+	verification, err := v.verifications.GetByID(ctx, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrInvalidVerificationToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if verification.UserID != claims.UserID || verification.UsedAt != nil || !verification.ExpiresAt.After(time.Now()) {
+		return nil, ErrInvalidVerificationToken
+	}
+
+	now := time.Now()
+	used, err := v.verifications.MarkUsed(ctx, verification.ID, now)
+	if err != nil {
+		return nil, err
+	}
+	if !used {
+		return nil, ErrInvalidVerificationToken
+	}
+	verified, err := v.users.MarkEmailVerified(ctx, verification.UserID, verification.Email, now)
+	if err != nil {
+		return nil, err
+	}
+	if !verified {
+		return nil, ErrInvalidVerificationToken
+	}
+
+	return v.users.GetByID(ctx, verification.UserID)
+}
+
+// PurgeExpired removes verifications that have expired
+func (v *EmailVerifier) PurgeExpired(ctx context.Context) (int64, error) {
+	return v.verifications.DeleteExpired(ctx, time.Now())
+}
+
+// link returns the verification link for a token
+func (v *EmailVerifier) link(token string) string {
+	separator := "?"
+	if strings.Contains(v.linkURL, "?") {
+		separator = "&"
+	}
+	return v.linkURL + separator + "token=" + url.QueryEscape(token)
+}
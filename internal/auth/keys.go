@@ -0,0 +1,244 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/goldcast/gc_auth_service/internal/models"
+	"github.com/goldcast/gc_auth_service/internal/repository"
+	"github.com/goldcast/gc_auth_service/pkg/jwt"
+	"github.com/goldcast/gc_auth_service/pkg/logger"
+	"github.com/goldcast/gc_auth_service/pkg/secretbox"
+)
+
+// minReloadInterval bounds how often an unknown kid may trigger a reload
+const minReloadInterval = 5 * time.Second
+
+// KeyPolicy controls how managed signing keys are generated and rotated
+type KeyPolicy struct {
+	Algorithm string
+	// RotationInterval is the age at which the active key is replaced; zero disables scheduled rotation
+	RotationInterval time.Duration
+	// GracePeriod is how long a retired key keeps verifying tokens and stays in the JWKS
+	GracePeriod time.Duration
+}
+
+// KeyManager keeps the JWT keyring in sync with the signing keys stored in
+// the database. The store is shared by all instances: rotations are
+// conditional on the active key, so concurrent rotations cannot produce two
+// active keys, and instances pick up keys rotated elsewhere by reloading
+// periodically and whenever a token carries an unknown kid.
+type KeyManager struct {
+	repo    repository.SigningKeyRepository
+	box     *secretbox.Box
+	policy  KeyPolicy
+	logger  *logger.Logger
+	keyring *jwt.Keyring
+
+	mu         sync.Mutex
+	active     *models.SigningKey
+	lastReload time.Time
+
+	missMu     sync.Mutex // serializes reloads for unknown kids
+	lastMissAt time.Time  // last reload attempt for an unknown kid, guarded by missMu
+}
+
+// NewKeyManager creates a key manager
+func NewKeyManager(repo repository.SigningKeyRepository, box *secretbox.Box, policy KeyPolicy, log *logger.Logger) *KeyManager {
+	return &KeyManager{
+		repo:   repo,
+		box:    box,
+		policy: policy,
+		logger: log,
+	}
+}
+
+// Init loads the stored keys, creating the first key if there is none, and
+// returns the keyring to sign and verify with
+func (m *KeyManager) Init(ctx context.Context) (*jwt.Keyring, error) {
+	err := m.Reload(ctx)
+	if errors.Is(err, errNoActiveKey) {
+		_, err = m.Rotate(ctx)
+		if errors.Is(err, repository.ErrConflict) {
+			err = nil // another instance created the first key
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	m.keyring.OnMiss(m.reloadOnMiss)
+	return m.keyring, nil
+}
+
+var errNoActiveKey = errors.New("no active signing key")
+
+// reloadOnMiss reloads the keys when a token carries an unknown kid, unless
+// they were reloaded or a reload was attempted recently. Misses wait for a
+// reload in progress instead of starting their own, so a burst of tokens
+// signed with a new key causes a single reload, after which the lookups
+// that waited find the key.
+func (m *KeyManager) reloadOnMiss(kid string) {
+	m.missMu.Lock()
+	defer m.missMu.Unlock()
+
+	m.mu.Lock()
+	lastReload := m.lastReload
+	m.mu.Unlock()
+	if time.Since(lastReload) < minReloadInterval || time.Since(m.lastMissAt) < minReloadInterval {
+		return
+	}
+	m.lastMissAt = time.Now()
+
+	if err := m.Reload(context.Background()); err != nil {
+		m.logger.WithField("error", err.Error()).Error("Failed to reload signing keys")
+	}
+}
+
+// Reload rebuilds the keyring from the stored keys
+func (m *KeyManager) Reload(ctx context.Context) error {
+	records, err := m.repo.List(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+
+	var active *jwt.Key
+	var activeRecord *models.SigningKey
+	verification := make([]*jwt.Key, 0, len(records))
+	for i := range records {
+		record := &records[i]
+		pemData, err := m.box.Open(record.PrivateKey)
+		if err != nil {
+			return fmt.Errorf("decrypt signing key %s: %w", record.ID, err)
+		}
+		key, err := jwt.ParsePrivateKeyPEM(record.ID, pemData)
+		if err != nil {
+			return fmt.Errorf("parse signing key %s: %w", record.ID, err)
+		}
+		if record.Status == models.SigningKeyActive {
+			active, activeRecord = key, record
+		} else {
+			verification = append(verification, key)
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lastReload = time.Now()
+
+	if active == nil {
+		return errNoActiveKey
+	}
+
+	if m.keyring == nil {
+		m.keyring = jwt.NewKeyring(active, verification...)
+	} else {
+		m.keyring.Replace(active, verification...)
+	}
+	m.active = activeRecord
+	return nil
+}
+
+// Rotate generates a new active key and retires the current one. It returns
+// repository.ErrConflict if another instance rotated concurrently, in which
+// case the keyring has already been reloaded with that instance's key.
+func (m *KeyManager) Rotate(ctx context.Context) (*models.SigningKey, error) {
+	// Start from the latest stored state so that a key rotated elsewhere
+	// since the last reload is not mistaken for a concurrent rotation
+	if err := m.Reload(ctx); err != nil && !errors.Is(err, errNoActiveKey) {
+		return nil, err
+	}
+
+	key, err := jwt.GenerateKey(m.policy.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	pemData, err := key.MarshalPrivateKeyPEM()
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := m.box.Seal(pemData)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	record := &models.SigningKey{
+		ID:         key.ID,
+		Algorithm:  key.Algorithm,
+		PrivateKey: sealed,
+		Status:     models.SigningKeyActive,
+		CreatedAt:  now,
+	}
+
+	var previousKID string
+	m.mu.Lock()
+	if m.active != nil {
+		previousKID = m.active.ID
+	}
+	m.mu.Unlock()
+
+	if err := m.repo.Rotate(ctx, record, previousKID, now, now.Add(m.policy.GracePeriod)); err != nil {
+		if errors.Is(err, repository.ErrConflict) {
+			if reloadErr := m.Reload(ctx); reloadErr != nil {
+				return nil, reloadErr
+			}
+		}
+		return nil, err
+	}
+
+	m.logger.WithFields(map[string]interface{}{
+		"kid":          record.ID,
+		"alg":          record.Algorithm,
+		"previous_kid": previousKID,
+	}).Info("Rotated JWT signing key")
+
+	return record, m.Reload(ctx)
+}
+
+// List returns the stored keys that have not expired
+func (m *KeyManager) List(ctx context.Context) ([]models.SigningKey, error) {
+	return m.repo.List(ctx, time.Now())
+}
+
+// Run reloads keys, rotates on schedule and removes expired keys every
+// interval until stop is closed
+func (m *KeyManager) Run(stop <-chan struct{}, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := m.maintain(context.Background()); err != nil {
+				m.logger.WithField("error", err.Error()).Error("Signing key maintenance failed")
+			}
+		}
+	}
+}
+
+// maintain performs one round of scheduled key maintenance
+func (m *KeyManager) maintain(ctx context.Context) error {
+	if err := m.Reload(ctx); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	due := m.policy.RotationInterval > 0 && m.active != nil &&
+		time.Since(m.active.CreatedAt) >= m.policy.RotationInterval
+	m.mu.Unlock()
+
+	if due {
+		if _, err := m.Rotate(ctx); err != nil && !errors.Is(err, repository.ErrConflict) {
+			return err
+		}
+	}
+
+	_, err := m.repo.DeleteExpired(ctx, time.Now())
+	return err
+}
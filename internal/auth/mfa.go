@@ -0,0 +1,482 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/goldcast/gc_auth_service/internal/models"
+	"github.com/goldcast/gc_auth_service/internal/repository"
+	"github.com/goldcast/gc_auth_service/pkg/ratelimit"
+	"github.com/goldcast/gc_auth_service/pkg/secretbox"
+	"github.com/goldcast/gc_auth_service/pkg/totp"
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrMFAAlreadyEnabled is returned when enrolling a user who already has
+	// a confirmed authenticator
+	ErrMFAAlreadyEnabled = errors.New("multi-factor authentication already enabled")
+	// ErrMFANotEnabled is returned when the user has no authenticator, or no
+	// pending enrollment to confirm
+	ErrMFANotEnabled = errors.New("multi-factor authentication not enabled")
+	// ErrInvalidMFACode is returned for wrong, expired or already used TOTP
+	// and recovery codes
+	ErrInvalidMFACode = errors.New("invalid MFA code")
+	// ErrInvalidMFAChallenge is returned for unknown, expired, completed or
+	// exhausted MFA tokens
+	ErrInvalidMFAChallenge = errors.New("invalid MFA challenge")
+)
+
+const (
+	// MFAChallengeTTL is how long the user has to complete a login with
+	// their second factor
+	MFAChallengeTTL = 5 * time.Minute
+
+	// maxMFAAttempts is the number of wrong codes after which a challenge is
+	// abandoned and the user has to sign in with their password again
+	maxMFAAttempts = 5
+
+	// mfaCodeLimitWindow is the window in which a user may enter
+	// maxMFAAttempts wrong codes to sign in, or any codes to manage their
+	// second factor, across all their challenges and sessions
+	mfaCodeLimitWindow = 15 * time.Minute
+
+	// totpSkew is the number of time steps before and after the current one
+	// whose codes are still accepted, to allow for clock drift
+	totpSkew = 1
+
+	// recoveryCodeCount is the number of recovery codes issued at a time
+	recoveryCodeCount = 10
+)
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// MFAService manages users' second factors and the challenges that complete
+// a password login with them: an authenticator app, a recovery code or a
+// passkey. TOTP secrets are encrypted at rest, and recovery codes and MFA
+// tokens are only stored hashed.
+type MFAService struct {
+	mfa        repository.MFARepository
+	challenges repository.MFAChallengeRepository
+	users      repository.UserRepository
+	passkeys   *PasskeyService
+	box        *secretbox.Box
+	issuer     string
+	codeLimit  *ratelimit.Limiter
+}
+
+// NewMFAService creates an MFA service. The issuer is the name authenticator
+// apps show next to the user's account.
+func NewMFAService(mfa repository.MFARepository, challenges repository.MFAChallengeRepository, users repository.UserRepository, passkeys *PasskeyService, box *secretbox.Box, issuer string) *MFAService {
+	return &MFAService{
+		mfa:        mfa,
+		challenges: challenges,
+		users:      users,
+		passkeys:   passkeys,
+		box:        box,
+		issuer:     issuer,
+		codeLimit:  ratelimit.New(maxMFAAttempts, mfaCodeLimitWindow),
+	}
+}
+
+// Enabled reports whether the user has a confirmed authenticator or a
+// passkey, and so must complete every password login with a second factor
+func (s *MFAService) Enabled(ctx context.Context, userID uuid.UUID) (bool, error) {
+	enabled, err := s.totpEnabled(ctx, userID)
+	if err != nil || enabled {
+		return enabled, err
+	}
+	passkeys, err := s.passkeys.Count(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return passkeys > 0, nil
+}
+
+// totpEnabled reports whether the user has a confirmed authenticator app
+func (s *MFAService) totpEnabled(ctx context.Context, userID uuid.UUID) (bool, error) {
+	cred, err := s.mfa.GetTOTP(ctx, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return cred.ConfirmedAt != nil, nil
+}
+
+// Status describes the user's second factors
+func (s *MFAService) Status(ctx context.Context, userID uuid.UUID) (*models.MFAStatus, error) {
+	enabled, err := s.totpEnabled(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	passkeys, err := s.passkeys.Count(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	remaining, err := s.mfa.CountRecoveryCodes(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &models.MFAStatus{TOTPEnabled: enabled, Passkeys: passkeys, RecoveryCodesRemaining: remaining}, nil
+}
+
+// BeginTOTPEnrollment generates a new TOTP secret for the user. It replaces
+// any earlier pending enrollment and only takes effect once confirmed.
+func (s *MFAService) BeginTOTPEnrollment(ctx context.Context, user *models.User) (*models.TOTPEnrollmentResponse, error) {
+	enabled, err := s.totpEnabled(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if enabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := s.box.Seal([]byte(secret))
+	if err != nil {
+		return nil, err
+	}
+	if err := s.mfa.SaveTOTP(ctx, &models.TOTPCredential{
+		UserID:          user.ID,
+		SecretEncrypted: sealed,
+		CreatedAt:       time.Now(),
+	}); err != nil {
+		return nil, err
+	}
+
+	return &models.TOTPEnrollmentResponse{
+		Secret:     secret,
+		OTPAuthURI: totp.URI(s.issuer, user.Email, secret),
+	}, nil
+}
+
+// ConfirmTOTP enables the pending enrollment once the user proves their app
+// generates valid codes, and returns a first set of recovery codes
+func (s *MFAService) ConfirmTOTP(ctx context.Context, userID uuid.UUID, code string) ([]string, error) {
+	cred, err := s.mfa.GetTOTP(ctx, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrMFANotEnabled
+	}
+	if err != nil {
+		return nil, err
+	}
+	if cred.ConfirmedAt != nil {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	now := time.Now()
+	step, ok, err := s.validateTOTP(cred, code, now)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+	confirmed, err := s.mfa.ConfirmTOTP(ctx, userID, now, step)
+	if err != nil {
+		return nil, err
+	}
+	if !confirmed {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	return s.issueRecoveryCodes(ctx, userID, now)
+}
+
+// Disable removes the user's authenticator and recovery codes after
+// verifying a current code. It returns a RateLimitError once the user
+// entered too many codes recently.
+func (s *MFAService) Disable(ctx context.Context, userID uuid.UUID, code string) error {
+	if err := s.verifyLimited(ctx, userID, code); err != nil {
+		return err
+	}
+	return s.mfa.DeleteTOTP(ctx, userID)
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes after verifying
+// a current code. It returns a RateLimitError once the user entered too many
+// codes recently.
+func (s *MFAService) RegenerateRecoveryCodes(ctx context.Context, userID uuid.UUID, code string) ([]string, error) {
+	if err := s.verifyLimited(ctx, userID, code); err != nil {
+		return nil, err
+	}
+	return s.issueRecoveryCodes(ctx, userID, time.Now())
+}
+
+// StartChallenge records that the user signed in with the given methods and
+// must still verify a second factor. It returns the MFA token to complete
+// the login with and its lifetime in seconds.
+func (s *MFAService) StartChallenge(ctx context.Context, user *models.User, amr []string) (string, int, error) {
+	rawToken, err := GenerateOpaqueToken()
+	if err != nil {
+		return "", 0, err
+	}
+
+	now := time.Now()
+	if err := s.challenges.Create(ctx, &models.MFAChallenge{
+		ID:        uuid.New(),
+		TokenHash: HashToken(rawToken),
+		UserID:    user.ID,
+		AMR:       amr,
+		CreatedAt: now,
+		ExpiresAt: now.Add(MFAChallengeTTL),
+	}); err != nil {
+		return "", 0, err
+	}
+	return rawToken, int(MFAChallengeTTL.Seconds()), nil
+}
+
+// VerifyChallenge completes a login with a TOTP or recovery code. It returns
+// the user together with all the methods they authenticated with. Each
+// challenge can be completed once, and is abandoned after too many wrong
+// codes. Wrong codes also count towards the user's limit, so that signing in
+// again for a fresh challenge does not allow more guesses; once it is
+// reached, a RateLimitError is returned.
+func (s *MFAService) VerifyChallenge(ctx context.Context, rawToken, code string) (*models.User, []string, error) {
+	return s.completeChallenge(ctx, rawToken, func(userID uuid.UUID) (string, error) {
+		method, err := s.verify(ctx, userID, code)
+		if errors.Is(err, ErrMFANotEnabled) {
+			// Users with only passkeys have no codes to verify
+			passkeys, countErr := s.passkeys.Count(ctx, userID)
+			if countErr != nil {
+				return "", countErr
+			}
+			if passkeys > 0 {
+				return "", ErrInvalidMFACode
+			}
+		}
+		return method, err
+	})
+}
+
+// BeginPasskeyChallenge returns the options for completing a login with one
+// of the user's passkeys, and the WebAuthn session token to send back along
+// with the authenticator's response
+func (s *MFAService) BeginPasskeyChallenge(ctx context.Context, rawToken string) (*protocol.CredentialAssertion, string, error) {
+	challenge, err := s.pendingChallenge(ctx, rawToken)
+	if err != nil {
+		return nil, "", err
+	}
+	user, err := s.users.GetByID(ctx, challenge.UserID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, "", ErrInvalidMFAChallenge
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	assertion, sessionToken, err := s.passkeys.BeginLogin(ctx, user)
+	if errors.Is(err, ErrInvalidPasskey) {
+		return nil, "", ErrMFANotEnabled
+	}
+	return assertion, sessionToken, err
+}
+
+// VerifyChallengeWithPasskey completes a login with the response of one of
+// the user's passkeys to the options of BeginPasskeyChallenge. A response
+// that does not verify counts as a wrong code, towards both the challenge's
+// and the user's limit.
+func (s *MFAService) VerifyChallengeWithPasskey(ctx context.Context, rawToken, sessionToken string, credential []byte) (*models.User, []string, error) {
+	return s.completeChallenge(ctx, rawToken, func(userID uuid.UUID) (string, error) {
+		user, err := s.users.GetByID(ctx, userID)
+		if err != nil {
+			return "", err
+		}
+		_, _, err = s.passkeys.FinishLogin(ctx, sessionToken, credential, user)
+		switch {
+		case errors.Is(err, ErrInvalidPasskey), errors.Is(err, ErrPasskeyCloned), errors.Is(err, ErrInvalidWebAuthnSession):
+			return "", ErrInvalidMFACode
+		case err != nil:
+			return "", err
+		}
+		return AMRHardwareKey, nil
+	})
+}
+
+// pendingChallenge returns the challenge of an MFA token that can still be
+// completed
+func (s *MFAService) pendingChallenge(ctx context.Context, rawToken string) (*models.MFAChallenge, error) {
+	challenge, err := s.challenges.GetByHash(ctx, HashToken(rawToken))
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrInvalidMFAChallenge
+	}
+	if err != nil {
+		return nil, err
+	}
+	if challenge.UsedAt != nil || !challenge.ExpiresAt.After(time.Now()) || challenge.Attempts >= maxMFAAttempts {
+		return nil, ErrInvalidMFAChallenge
+	}
+	return challenge, nil
+}
+
+// completeChallenge completes a login once verify accepts the user's second
+// factor, returning the method it verified if any
+func (s *MFAService) completeChallenge(ctx context.Context, rawToken string, verify func(userID uuid.UUID) (string, error)) (*models.User, []string, error) {
+	challenge, err := s.pendingChallenge(ctx, rawToken)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The attempt counts until the code is known to be correct, so that
+	// concurrent guesses cannot get past the limit
+	key := challenge.UserID.String()
+	if ok, retryAfter := s.codeLimit.Allow(key); !ok {
+		return nil, nil, &RateLimitError{RetryAfter: retryAfter}
+	}
+	method, err := verify(challenge.UserID)
+	if !errors.Is(err, ErrInvalidMFACode) {
+		s.codeLimit.Refund(key)
+	}
+	switch {
+	case errors.Is(err, ErrInvalidMFACode):
+		if _, err := s.challenges.RecordFailure(ctx, challenge.ID); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, ErrInvalidMFACode
+	case errors.Is(err, ErrMFANotEnabled):
+		// The authenticator was removed while the login was pending
+		return nil, nil, ErrInvalidMFAChallenge
+	case err != nil:
+		return nil, nil, err
+	}
+
+	used, err := s.challenges.MarkUsed(ctx, challenge.ID, time.Now())
+	if err != nil {
+		return nil, nil, err
+	}
+	if !used {
+		return nil, nil, ErrInvalidMFAChallenge
+	}
+
+	user, err := s.users.GetByID(ctx, challenge.UserID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, nil, ErrInvalidMFAChallenge
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if !user.IsActive {
+		return nil, nil, ErrInvalidMFAChallenge
+	}
+
+	amr := append([]string{}, challenge.AMR...)
+	if method != "" {
+		amr = append(amr, method)
+	}
+	return user, append(amr, AMRMultiFactor), nil
+}
+
+// PurgeExpired removes MFA challenges that have expired, and forgets users
+// who are no longer rate limited
+func (s *MFAService) PurgeExpired(ctx context.Context) (int64, error) {
+	s.codeLimit.Prune()
+	return s.challenges.DeleteExpired(ctx, time.Now())
+}
+
+// verify checks a code against the user's confirmed authenticator, or else
+// uses up one of their recovery codes. Six digit codes are TOTP codes, each
+// of which is only accepted once. It returns the authentication method
+// reference of TOTP codes.
+func (s *MFAService) verify(ctx context.Context, userID uuid.UUID, code string) (string, error) {
+	cred, err := s.mfa.GetTOTP(ctx, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return "", ErrMFANotEnabled
+	}
+	if err != nil {
+		return "", err
+	}
+	if cred.ConfirmedAt == nil {
+		return "", ErrMFANotEnabled
+	}
+
+	code = normalizeCode(code)
+	if len(code) == totp.Digits {
+		step, ok, err := s.validateTOTP(cred, code, time.Now())
+		if err != nil {
+			return "", err
+		}
+		if !ok {
+			return "", ErrInvalidMFACode
+		}
+		fresh, err := s.mfa.RecordTOTPStep(ctx, userID, step)
+		if err != nil {
+			return "", err
+		}
+		if !fresh {
+			return "", ErrInvalidMFACode
+		}
+		return AMROTP, nil
+	}
+
+	used, err := s.mfa.UseRecoveryCode(ctx, userID, HashToken(code), time.Now())
+	if err != nil {
+		return "", err
+	}
+	if !used {
+		return "", ErrInvalidMFACode
+	}
+	return "", nil
+}
+
+// verifyLimited verifies a code outside of a login challenge, which limits
+// attempts by itself. Every code counts towards the user's limit, so that a
+// stolen session cannot be used to guess codes.
+func (s *MFAService) verifyLimited(ctx context.Context, userID uuid.UUID, code string) error {
+	if ok, retryAfter := s.codeLimit.Allow(userID.String()); !ok {
+		return &RateLimitError{RetryAfter: retryAfter}
+	}
+	_, err := s.verify(ctx, userID, code)
+	return err
+}
+
+// validateTOTP checks a code against the enrollment's encrypted secret and
+// returns the time step it matched
+func (s *MFAService) validateTOTP(cred *models.TOTPCredential, code string, now time.Time) (int64, bool, error) {
+	secret, err := s.box.Open(cred.SecretEncrypted)
+	if err != nil {
+		return 0, false, err
+	}
+	return totp.Validate(string(secret), normalizeCode(code), now, totpSkew)
+}
+
+// issueRecoveryCodes replaces the user's recovery codes with new ones and
+// returns them formatted for display
+func (s *MFAService) issueRecoveryCodes(ctx context.Context, userID uuid.UUID, now time.Time) ([]string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	records := make([]*models.RecoveryCode, 0, recoveryCodeCount)
+	for len(codes) < recoveryCodeCount {
+		buf := make([]byte, 8)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, err
+		}
+		code := strings.ToLower(recoveryCodeEncoding.EncodeToString(buf))[:10]
+		codes = append(codes, code[:5]+"-"+code[5:])
+		records = append(records, &models.RecoveryCode{
+			ID:        uuid.New(),
+			UserID:    userID,
+			CodeHash:  HashToken(code),
+			CreatedAt: now,
+		})
+	}
+
+	if err := s.mfa.ReplaceRecoveryCodes(ctx, userID, records); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// normalizeCode strips the separators users may type or paste along with a
+// code, and lowercases recovery codes
+func normalizeCode(code string) string {
+	return strings.ToLower(strings.NewReplacer(" ", "", "-", "").Replace(code))
+}
@@ -0,0 +1,162 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/goldcast/gc_auth_service/internal/models"
+	"github.com/goldcast/gc_auth_service/internal/repository"
+	"github.com/goldcast/gc_auth_service/pkg/secretbox"
+	"github.com/goldcast/gc_auth_service/pkg/totp"
+)
+
+// newMFATestService returns an MFA service on in-memory repositories and a
+// user who confirmed an authenticator app with the returned secret, along
+// with their recovery codes
+func newMFATestService(t *testing.T) (*MFAService, *models.User, string, []string) {
+	t.Helper()
+	ctx := context.Background()
+	users := repository.NewMemoryUserRepository()
+	user := createTestUser(t, users)
+	box, err := secretbox.New(make([]byte, secretbox.KeySize))
+	if err != nil {
+		t.Fatalf("new secretbox: %v", err)
+	}
+	service := NewMFAService(repository.NewMemoryMFARepository(), repository.NewMemoryMFAChallengeRepository(), users, newPasskeyService(t, users), box, "Test")
+
+	enrollment, err := service.BeginTOTPEnrollment(ctx, user)
+	if err != nil {
+		t.Fatalf("begin enrollment: %v", err)
+	}
+	recoveryCodes, err := service.ConfirmTOTP(ctx, user.ID, totpCode(t, enrollment.Secret, 0))
+	if err != nil {
+		t.Fatalf("confirm enrollment: %v", err)
+	}
+	return service, user, enrollment.Secret, recoveryCodes
+}
+
+// totpCode returns the code of the time step offset steps from the current one
+func totpCode(t *testing.T, secret string, offset int64) string {
+	t.Helper()
+	code, err := totp.Code(secret, totp.Step(time.Now())+offset)
+	if err != nil {
+		t.Fatalf("generate code: %v", err)
+	}
+	return code
+}
+
+func startChallenge(t *testing.T, service *MFAService, user *models.User) string {
+	t.Helper()
+	token, _, err := service.StartChallenge(context.Background(), user, []string{AMRPassword})
+	if err != nil {
+		t.Fatalf("start challenge: %v", err)
+	}
+	return token
+}
+
+func TestMFAChallengeLimitPerUser(t *testing.T) {
+	ctx := context.Background()
+	service, user, secret, recoveryCodes := newMFATestService(t)
+	// A code of a step well outside the accepted skew is well formed but wrong
+	wrong := totpCode(t, secret, 10)
+
+	// Correct codes do not count towards the limit
+	for i := 0; i < maxMFAAttempts; i++ {
+		if _, _, err := service.VerifyChallenge(ctx, startChallenge(t, service, user), recoveryCodes[i]); err != nil {
+			t.Fatalf("login %d with a recovery code: %v", i+1, err)
+		}
+	}
+
+	// Signing in again for a fresh challenge does not allow more guesses
+	for i := 0; i < maxMFAAttempts; i++ {
+		token := startChallenge(t, service, user)
+		if _, _, err := service.VerifyChallenge(ctx, token, wrong); !errors.Is(err, ErrInvalidMFACode) {
+			t.Fatalf("wrong code %d: %v, want %v", i+1, err, ErrInvalidMFACode)
+		}
+	}
+
+	token := startChallenge(t, service, user)
+	var limited *RateLimitError
+	if _, _, err := service.VerifyChallenge(ctx, token, recoveryCodes[maxMFAAttempts]); !errors.As(err, &limited) {
+		t.Fatalf("code after the limit: %v, want a RateLimitError", err)
+	}
+	if limited.RetryAfter <= 0 || limited.RetryAfter > mfaCodeLimitWindow {
+		t.Errorf("retry after %s, want within %s", limited.RetryAfter, mfaCodeLimitWindow)
+	}
+	// Managing the second factor is locked as well
+	if err := service.Disable(ctx, user.ID, recoveryCodes[maxMFAAttempts]); !errors.As(err, &limited) {
+		t.Errorf("disable after the limit: %v, want a RateLimitError", err)
+	}
+	// The refused codes were not used up
+	if remaining, err := service.mfa.CountRecoveryCodes(ctx, user.ID); err != nil || remaining != recoveryCodeCount-maxMFAAttempts {
+		t.Errorf("%d recovery codes remaining, %v; want %d", remaining, err, recoveryCodeCount-maxMFAAttempts)
+	}
+}
+
+func TestTOTPCodeUsedOnce(t *testing.T) {
+	ctx := context.Background()
+	service, user, secret, _ := newMFATestService(t)
+	// The code of the current step was used to confirm the enrollment
+	next := totpCode(t, secret, 1)
+
+	_, amr, err := service.VerifyChallenge(ctx, startChallenge(t, service, user), next)
+	if err != nil {
+		t.Fatalf("login with a code: %v", err)
+	}
+	if want := []string{AMRPassword, AMROTP, AMRMultiFactor}; !slices.Equal(amr, want) {
+		t.Errorf("amr %v, want %v", amr, want)
+	}
+	for name, code := range map[string]string{"same code": next, "earlier code": totpCode(t, secret, 0)} {
+		if _, _, err := service.VerifyChallenge(ctx, startChallenge(t, service, user), code); !errors.Is(err, ErrInvalidMFACode) {
+			t.Errorf("%s: %v, want %v", name, err, ErrInvalidMFACode)
+		}
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	ctx := context.Background()
+	service, user, _, recoveryCodes := newMFATestService(t)
+	if len(recoveryCodes) != recoveryCodeCount {
+		t.Fatalf("%d recovery codes issued, want %d", len(recoveryCodes), recoveryCodeCount)
+	}
+
+	_, amr, err := service.VerifyChallenge(ctx, startChallenge(t, service, user), recoveryCodes[0])
+	if err != nil {
+		t.Fatalf("login with a recovery code: %v", err)
+	}
+	if want := []string{AMRPassword, AMRMultiFactor}; !slices.Equal(amr, want) {
+		t.Errorf("amr %v, want %v", amr, want)
+	}
+	if _, _, err := service.VerifyChallenge(ctx, startChallenge(t, service, user), recoveryCodes[0]); !errors.Is(err, ErrInvalidMFACode) {
+		t.Errorf("used recovery code: %v, want %v", err, ErrInvalidMFACode)
+	}
+	// Codes are accepted as typed, in capitals and without the dash
+	typed := strings.ToUpper(strings.ReplaceAll(recoveryCodes[1], "-", " "))
+	if _, _, err := service.VerifyChallenge(ctx, startChallenge(t, service, user), typed); err != nil {
+		t.Errorf("login with recovery code %q: %v", typed, err)
+	}
+
+	status, err := service.Status(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if status.RecoveryCodesRemaining != recoveryCodeCount-2 {
+		t.Errorf("%d recovery codes remaining, want %d", status.RecoveryCodesRemaining, recoveryCodeCount-2)
+	}
+
+	// New codes replace the remaining ones
+	fresh, err := service.RegenerateRecoveryCodes(ctx, user.ID, recoveryCodes[2])
+	if err != nil {
+		t.Fatalf("regenerate recovery codes: %v", err)
+	}
+	if _, _, err := service.VerifyChallenge(ctx, startChallenge(t, service, user), recoveryCodes[3]); !errors.Is(err, ErrInvalidMFACode) {
+		t.Errorf("replaced recovery code: %v, want %v", err, ErrInvalidMFACode)
+	}
+	if _, _, err := service.VerifyChallenge(ctx, startChallenge(t, service, user), fresh[0]); err != nil {
+		t.Errorf("login with a new recovery code: %v", err)
+	}
+}
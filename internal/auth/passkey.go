@@ -0,0 +1,424 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/goldcast/gc_auth_service/internal/models"
+	"github.com/goldcast/gc_auth_service/internal/repository"
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrInvalidPasskey is returned when an authenticator response does not
+	// verify, or names a passkey that is not registered
+	ErrInvalidPasskey = errors.New("invalid passkey response")
+	// ErrPasskeyCloned is returned when a passkey's signature counter did not
+	// increase, which means the credential was probably copied
+	ErrPasskeyCloned = errors.New("passkey signature counter did not increase")
+	// ErrPasskeyExists is returned when registering an authenticator that
+	// already holds a passkey for the service
+	ErrPasskeyExists = errors.New("passkey already registered")
+	// ErrInvalidWebAuthnSession is returned for unknown, expired or already
+	// used ceremony session tokens
+	ErrInvalidWebAuthnSession = errors.New("invalid WebAuthn session")
+)
+
+// AMRHardwareKey is the authentication method reference (RFC 8176) of logins
+// with a passkey, which proves possession of a key held by an authenticator
+const AMRHardwareKey = "hwk"
+
+// webAuthnCeremonyTTL is how long the user has to complete a ceremony with
+// their authenticator
+const webAuthnCeremonyTTL = 5 * time.Minute
+
+// defaultPasskeyName is the name of passkeys registered without one
+const defaultPasskeyName = "Passkey"
+
+// PasskeyConfig identifies the relying party passkeys are registered with
+type PasskeyConfig struct {
+	RPID    string
+	RPName  string
+	Origins []string
+}
+
+// PasskeyService runs WebAuthn registration and login ceremonies and manages
+// the passkeys they produce. Only "none" and "packed" attestation is
+// accepted, as the service does not rely on authenticator certification.
+type PasskeyService struct {
+	webauthn *webauthn.WebAuthn
+	passkeys repository.PasskeyRepository
+	sessions repository.WebAuthnSessionRepository
+	users    repository.UserRepository
+}
+
+// NewPasskeyService creates a passkey service for the given relying party
+func NewPasskeyService(cfg PasskeyConfig, passkeys repository.PasskeyRepository, sessions repository.WebAuthnSessionRepository, users repository.UserRepository) (*PasskeyService, error) {
+	timeout := webauthn.TimeoutConfig{Enforce: true, Timeout: webAuthnCeremonyTTL, TimeoutUVD: webAuthnCeremonyTTL}
+	w, err := webauthn.New(&webauthn.Config{
+		RPID:                  cfg.RPID,
+		RPDisplayName:         cfg.RPName,
+		RPOrigins:             cfg.Origins,
+		AttestationPreference: protocol.PreferNoAttestation,
+		AuthenticatorSelection: protocol.AuthenticatorSelection{
+			ResidentKey:      protocol.ResidentKeyRequirementPreferred,
+			UserVerification: protocol.VerificationPreferred,
+		},
+		Timeouts: webauthn.TimeoutsConfig{Login: timeout, Registration: timeout},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &PasskeyService{
+		webauthn: w,
+		passkeys: passkeys,
+		sessions: sessions,
+		users:    users,
+	}, nil
+}
+
+// List returns the user's passkeys
+func (s *PasskeyService) List(ctx context.Context, userID uuid.UUID) ([]*models.Passkey, error) {
+	return s.passkeys.ListByUser(ctx, userID)
+}
+
+// Count returns the number of the user's passkeys
+func (s *PasskeyService) Count(ctx context.Context, userID uuid.UUID) (int, error) {
+	return s.passkeys.CountByUser(ctx, userID)
+}
+
+// Rename renames one of the user's passkeys
+func (s *PasskeyService) Rename(ctx context.Context, userID, id uuid.UUID, name string) error {
+	return s.passkeys.Rename(ctx, userID, id, name)
+}
+
+// Delete removes one of the user's passkeys
+func (s *PasskeyService) Delete(ctx context.Context, userID, id uuid.UUID) error {
+	return s.passkeys.Delete(ctx, userID, id)
+}
+
+// BeginRegistration returns the options for creating a passkey for the user
+// and the session token to complete the registration with
+func (s *PasskeyService) BeginRegistration(ctx context.Context, user *models.User) (*protocol.CredentialCreation, string, error) {
+	owner, err := s.owner(ctx, user)
+	if err != nil {
+		return nil, "", err
+	}
+
+	creation, session, err := s.webauthn.BeginRegistration(owner,
+		webauthn.WithExclusions(webauthn.Credentials(owner.credentials).CredentialDescriptors()),
+		webauthn.WithAttestationFormats([]protocol.AttestationFormat{protocol.AttestationFormatNone, protocol.AttestationFormatPacked}),
+	)
+	if err != nil {
+		return nil, "", err
+	}
+
+	token, err := s.startSession(ctx, &user.ID, models.WebAuthnCeremonyRegistration, session)
+	if err != nil {
+		return nil, "", err
+	}
+	return creation, token, nil
+}
+
+// FinishRegistration verifies the authenticator's response to the options of
+// BeginRegistration and stores the new passkey
+func (s *PasskeyService) FinishRegistration(ctx context.Context, user *models.User, sessionToken, name string, response []byte) (*models.Passkey, error) {
+	session, err := s.consumeSession(ctx, sessionToken, models.WebAuthnCeremonyRegistration, &user.ID)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(response)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPasskey, err)
+	}
+	owner, err := s.owner(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	credential, err := s.webauthn.CreateCredential(owner, *session, parsed)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPasskey, describe(err))
+	}
+	switch protocol.AttestationFormat(credential.AttestationType) {
+	case protocol.AttestationFormatNone, protocol.AttestationFormatPacked:
+	default:
+		return nil, fmt.Errorf("%w: unsupported attestation format %q", ErrInvalidPasskey, credential.AttestationType)
+	}
+
+	aaguid, err := uuid.FromBytes(credential.Authenticator.AAGUID)
+	if err != nil {
+		aaguid = uuid.Nil
+	}
+	transports := make([]string, 0, len(credential.Transport))
+	for _, transport := range credential.Transport {
+		transports = append(transports, string(transport))
+	}
+	if name == "" {
+		name = defaultPasskeyName
+	}
+
+	passkey := &models.Passkey{
+		ID:              uuid.New(),
+		UserID:          user.ID,
+		CredentialID:    credential.ID,
+		PublicKey:       credential.PublicKey,
+		AttestationType: credential.AttestationType,
+		AAGUID:          aaguid,
+		SignCount:       credential.Authenticator.SignCount,
+		Transports:      transports,
+		UserVerified:    credential.Flags.UserVerified,
+		BackupEligible:  credential.Flags.BackupEligible,
+		BackupState:     credential.Flags.BackupState,
+		Name:            name,
+		CreatedAt:       time.Now(),
+	}
+	if err := s.passkeys.Create(ctx, passkey); err != nil {
+		if errors.Is(err, repository.ErrConflict) {
+			return nil, ErrPasskeyExists
+		}
+		return nil, err
+	}
+	return passkey, nil
+}
+
+// BeginLogin returns the options for signing in with a passkey and the
+// session token to complete the login with. Without a user, any discoverable
+// passkey can be used for a passwordless login; with one, only that user's
+// passkeys are allowed, as when verifying a second factor.
+func (s *PasskeyService) BeginLogin(ctx context.Context, user *models.User) (*protocol.CredentialAssertion, string, error) {
+	if user == nil {
+		assertion, session, err := s.webauthn.BeginDiscoverableLogin(webauthn.WithUserVerification(protocol.VerificationRequired))
+		if err != nil {
+			return nil, "", err
+		}
+		token, err := s.startSession(ctx, nil, models.WebAuthnCeremonyLogin, session)
+		if err != nil {
+			return nil, "", err
+		}
+		return assertion, token, nil
+	}
+
+	owner, err := s.owner(ctx, user)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(owner.credentials) == 0 {
+		return nil, "", ErrInvalidPasskey
+	}
+	assertion, session, err := s.webauthn.BeginLogin(owner)
+	if err != nil {
+		return nil, "", err
+	}
+	token, err := s.startSession(ctx, &user.ID, models.WebAuthnCeremonyLogin, session)
+	if err != nil {
+		return nil, "", err
+	}
+	return assertion, token, nil
+}
+
+// FinishLogin verifies the authenticator's response to the options of
+// BeginLogin and returns the user and the passkey they signed in with. The
+// user must match the one the login was started for, if any.
+func (s *PasskeyService) FinishLogin(ctx context.Context, sessionToken string, response []byte, user *models.User) (*models.User, *models.Passkey, error) {
+	var userID *uuid.UUID
+	if user != nil {
+		userID = &user.ID
+	}
+	session, err := s.consumeSession(ctx, sessionToken, models.WebAuthnCeremonyLogin, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(response)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidPasskey, err)
+	}
+
+	var owner *passkeyUser
+	var credential *webauthn.Credential
+	if user == nil {
+		handler := func(rawID, userHandle []byte) (webauthn.User, error) {
+			id, err := uuid.FromBytes(userHandle)
+			if err != nil {
+				return nil, err
+			}
+			found, err := s.users.GetByID(ctx, id)
+			if err != nil {
+				return nil, err
+			}
+			owner, err = s.owner(ctx, found)
+			return owner, err
+		}
+		_, credential, err = s.webauthn.ValidatePasskeyLogin(handler, *session, parsed)
+	} else {
+		if owner, err = s.owner(ctx, user); err != nil {
+			return nil, nil, err
+		}
+		credential, err = s.webauthn.ValidateLogin(owner, *session, parsed)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidPasskey, describe(err))
+	}
+	if !owner.user.IsActive {
+		return nil, nil, ErrInvalidPasskey
+	}
+	if credential.Authenticator.CloneWarning {
+		return nil, nil, ErrPasskeyCloned
+	}
+
+	passkey, err := s.passkeys.GetByCredentialID(ctx, credential.ID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, nil, ErrInvalidPasskey
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	recorded, err := s.passkeys.RecordUse(ctx, passkey.ID, credential.Authenticator.SignCount, credential.Flags.BackupState, time.Now())
+	if err != nil {
+		return nil, nil, err
+	}
+	if !recorded {
+		return nil, nil, ErrPasskeyCloned
+	}
+	// The flags of this login, rather than of the registration, tell how the
+	// user was verified
+	passkey.UserVerified = credential.Flags.UserVerified
+	return owner.user, passkey, nil
+}
+
+// PurgeExpired removes WebAuthn sessions that have expired
+func (s *PasskeyService) PurgeExpired(ctx context.Context) (int64, error) {
+	return s.sessions.DeleteExpired(ctx, time.Now())
+}
+
+// startSession stores the state of a ceremony and returns its session token
+func (s *PasskeyService) startSession(ctx context.Context, userID *uuid.UUID, ceremony string, data *webauthn.SessionData) (string, error) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+	rawToken, err := GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	if err := s.sessions.Create(ctx, &models.WebAuthnSession{
+		ID:        uuid.New(),
+		TokenHash: HashToken(rawToken),
+		UserID:    userID,
+		Ceremony:  ceremony,
+		Data:      encoded,
+		CreatedAt: now,
+		ExpiresAt: now.Add(webAuthnCeremonyTTL),
+	}); err != nil {
+		return "", err
+	}
+	return rawToken, nil
+}
+
+// consumeSession returns the state of a pending ceremony, which can only be
+// completed once, whether or not the authenticator's response verifies
+func (s *PasskeyService) consumeSession(ctx context.Context, rawToken, ceremony string, userID *uuid.UUID) (*webauthn.SessionData, error) {
+	session, err := s.sessions.GetByHash(ctx, HashToken(rawToken))
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrInvalidWebAuthnSession
+	}
+	if err != nil {
+		return nil, err
+	}
+	if session.Ceremony != ceremony || session.UsedAt != nil || !session.ExpiresAt.After(time.Now()) {
+		return nil, ErrInvalidWebAuthnSession
+	}
+	if (session.UserID == nil) != (userID == nil) || (userID != nil && *session.UserID != *userID) {
+		return nil, ErrInvalidWebAuthnSession
+	}
+
+	used, err := s.sessions.MarkUsed(ctx, session.ID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if !used {
+		return nil, ErrInvalidWebAuthnSession
+	}
+
+	var data webauthn.SessionData
+	if err := json.Unmarshal(session.Data, &data); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// owner loads the user's passkeys in the form the WebAuthn library expects
+func (s *PasskeyService) owner(ctx context.Context, user *models.User) (*passkeyUser, error) {
+	passkeys, err := s.passkeys.ListByUser(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	owner := &passkeyUser{user: user, credentials: make([]webauthn.Credential, 0, len(passkeys))}
+	for _, passkey := range passkeys {
+		transports := make([]protocol.AuthenticatorTransport, 0, len(passkey.Transports))
+		for _, transport := range passkey.Transports {
+			transports = append(transports, protocol.AuthenticatorTransport(transport))
+		}
+		owner.credentials = append(owner.credentials, webauthn.Credential{
+			ID:              passkey.CredentialID,
+			PublicKey:       passkey.PublicKey,
+			AttestationType: passkey.AttestationType,
+			Transport:       transports,
+			Flags: webauthn.CredentialFlags{
+				UserPresent:    true,
+				UserVerified:   passkey.UserVerified,
+				BackupEligible: passkey.BackupEligible,
+				BackupState:    passkey.BackupState,
+			},
+			Authenticator: webauthn.Authenticator{
+				AAGUID:    passkey.AAGUID[:],
+				SignCount: passkey.SignCount,
+			},
+		})
+	}
+	return owner, nil
+}
+
+// passkeyUser adapts a user and their passkeys to webauthn.User. The user
+// handle is the user ID, which carries no personal information.
+type passkeyUser struct {
+	user        *models.User
+	credentials []webauthn.Credential
+}
+
+func (u *passkeyUser) WebAuthnID() []byte {
+	return u.user.ID[:]
+}
+
+func (u *passkeyUser) WebAuthnName() string {
+	return u.user.Email
+}
+
+func (u *passkeyUser) WebAuthnDisplayName() string {
+	return u.user.FirstName + " " + u.user.LastName
+}
+
+func (u *passkeyUser) WebAuthnCredentials() []webauthn.Credential {
+	return u.credentials
+}
+
+// describe returns the most specific description of a WebAuthn library error
+func describe(err error) string {
+	var protocolErr *protocol.Error
+	if errors.As(err, &protocolErr) && protocolErr.DevInfo != "" {
+		return protocolErr.Details + ": " + protocolErr.DevInfo
+	}
+	if errors.As(err, &protocolErr) {
+		return protocolErr.Details
+	}
+	return err.Error()
+}
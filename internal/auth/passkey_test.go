@@ -0,0 +1,348 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/goldcast/gc_auth_service/internal/models"
+	"github.com/goldcast/gc_auth_service/internal/repository"
+	"github.com/google/uuid"
+)
+
+const (
+	testRPID   = "auth.test"
+	testOrigin = "https://auth.test"
+)
+
+// Authenticator data flags, see https://www.w3.org/TR/webauthn-3/#sctn-authenticator-data
+const (
+	flagUserPresent      = 0x01
+	flagUserVerified     = 0x04
+	flagAttestedCredData = 0x40
+)
+
+// softAuthenticator is a software passkey holding an ECDSA P-256 key, which
+// answers ceremonies the way a platform authenticator would
+type softAuthenticator struct {
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	userHandle   []byte
+	signCount    uint32
+	flags        byte
+}
+
+func newSoftAuthenticator(t *testing.T) *softAuthenticator {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	credentialID := make([]byte, 16)
+	if _, err := rand.Read(credentialID); err != nil {
+		t.Fatalf("generate credential id: %v", err)
+	}
+	return &softAuthenticator{key: key, credentialID: credentialID, flags: flagUserPresent | flagUserVerified}
+}
+
+func clientData(t *testing.T, ceremony string, challenge []byte) []byte {
+	t.Helper()
+	data, err := json.Marshal(map[string]string{
+		"type":      ceremony,
+		"challenge": base64.RawURLEncoding.EncodeToString(challenge),
+		"origin":    testOrigin,
+	})
+	if err != nil {
+		t.Fatalf("encode client data: %v", err)
+	}
+	return data
+}
+
+// authData returns the authenticator data for the relying party, without
+// attested credential data
+func (a *softAuthenticator) authData(flags byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(testRPID))
+	data := append(rpIDHash[:], flags)
+	return binary.BigEndian.AppendUint32(data, a.signCount)
+}
+
+// create answers the options of a registration with a "none" attestation
+func (a *softAuthenticator) create(t *testing.T, creation *protocol.CredentialCreation) []byte {
+	t.Helper()
+	a.userHandle = creation.Response.User.ID.(protocol.URLEncodedBase64)
+
+	publicKey, err := webauthncbor.Marshal(map[int]interface{}{
+		1:  2,  // kty: EC2
+		3:  -7, // alg: ES256
+		-1: 1,  // crv: P-256
+		-2: a.key.X.FillBytes(make([]byte, 32)),
+		-3: a.key.Y.FillBytes(make([]byte, 32)),
+	})
+	if err != nil {
+		t.Fatalf("encode public key: %v", err)
+	}
+	authData := a.authData(a.flags | flagAttestedCredData)
+	authData = append(authData, make([]byte, 16)...) // AAGUID
+	authData = binary.BigEndian.AppendUint16(authData, uint16(len(a.credentialID)))
+	authData = append(authData, a.credentialID...)
+	authData = append(authData, publicKey...)
+
+	attestation, err := webauthncbor.Marshal(map[string]interface{}{
+		"fmt":      "none",
+		"attStmt":  map[string]interface{}{},
+		"authData": authData,
+	})
+	if err != nil {
+		t.Fatalf("encode attestation: %v", err)
+	}
+	return a.credential(t, map[string]string{
+		"clientDataJSON":    base64.RawURLEncoding.EncodeToString(clientData(t, "webauthn.create", creation.Response.Challenge)),
+		"attestationObject": base64.RawURLEncoding.EncodeToString(attestation),
+	})
+}
+
+// get answers the options of a login with an assertion signed by the key
+func (a *softAuthenticator) get(t *testing.T, assertion *protocol.CredentialAssertion) []byte {
+	t.Helper()
+	authData := a.authData(a.flags)
+	data := clientData(t, "webauthn.get", assertion.Response.Challenge)
+	hash := sha256.Sum256(data)
+	digest := sha256.Sum256(append(authData, hash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		t.Fatalf("sign assertion: %v", err)
+	}
+	return a.credential(t, map[string]string{
+		"clientDataJSON":    base64.RawURLEncoding.EncodeToString(data),
+		"authenticatorData": base64.RawURLEncoding.EncodeToString(authData),
+		"signature":         base64.RawURLEncoding.EncodeToString(signature),
+		"userHandle":        base64.RawURLEncoding.EncodeToString(a.userHandle),
+	})
+}
+
+func (a *softAuthenticator) credential(t *testing.T, response map[string]string) []byte {
+	t.Helper()
+	id := base64.RawURLEncoding.EncodeToString(a.credentialID)
+	body, err := json.Marshal(map[string]interface{}{
+		"id":       id,
+		"rawId":    id,
+		"type":     "public-key",
+		"response": response,
+	})
+	if err != nil {
+		t.Fatalf("encode credential: %v", err)
+	}
+	return body
+}
+
+func newPasskeyTestService(t *testing.T) (*PasskeyService, *models.User) {
+	t.Helper()
+	users := repository.NewMemoryUserRepository()
+	return newPasskeyService(t, users), createTestUser(t, users)
+}
+
+func newPasskeyService(t *testing.T, users repository.UserRepository) *PasskeyService {
+	t.Helper()
+	service, err := NewPasskeyService(PasskeyConfig{
+		RPID:    testRPID,
+		RPName:  "Test",
+		Origins: []string{testOrigin},
+	}, repository.NewMemoryPasskeyRepository(), repository.NewMemoryWebAuthnSessionRepository(), users)
+	if err != nil {
+		t.Fatalf("new passkey service: %v", err)
+	}
+	return service
+}
+
+// createTestUser adds an active user with a verified email address
+func createTestUser(t *testing.T, users repository.UserRepository) *models.User {
+	t.Helper()
+	now := time.Now()
+	user := &models.User{
+		ID:            uuid.New(),
+		Email:         "alice@example.com",
+		Username:      "alice",
+		FirstName:     "Alice",
+		LastName:      "Example",
+		IsActive:      true,
+		EmailVerified: true,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if err := users.Create(context.Background(), user); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return user
+}
+
+// register creates a passkey for the user on the authenticator
+func register(t *testing.T, service *PasskeyService, user *models.User, authenticator *softAuthenticator) *models.Passkey {
+	t.Helper()
+	ctx := context.Background()
+	creation, token, err := service.BeginRegistration(ctx, user)
+	if err != nil {
+		t.Fatalf("begin registration: %v", err)
+	}
+	passkey, err := service.FinishRegistration(ctx, user, token, "", authenticator.create(t, creation))
+	if err != nil {
+		t.Fatalf("finish registration: %v", err)
+	}
+	return passkey
+}
+
+// login signs in with the authenticator, as the given user or, without one,
+// discoverably
+func login(t *testing.T, service *PasskeyService, user *models.User, authenticator *softAuthenticator) (*models.User, *models.Passkey, error) {
+	t.Helper()
+	ctx := context.Background()
+	assertion, token, err := service.BeginLogin(ctx, user)
+	if err != nil {
+		t.Fatalf("begin login: %v", err)
+	}
+	return service.FinishLogin(ctx, token, authenticator.get(t, assertion), user)
+}
+
+func TestPasskeyRegistrationAndLogin(t *testing.T) {
+	ctx := context.Background()
+	service, user := newPasskeyTestService(t)
+	authenticator := newSoftAuthenticator(t)
+
+	passkey := register(t, service, user, authenticator)
+	if passkey.UserID != user.ID {
+		t.Errorf("passkey belongs to %s, want %s", passkey.UserID, user.ID)
+	}
+	if string(passkey.CredentialID) != string(authenticator.credentialID) {
+		t.Errorf("credential id %x, want %x", passkey.CredentialID, authenticator.credentialID)
+	}
+	if passkey.AttestationType != string(protocol.AttestationFormatNone) {
+		t.Errorf("attestation type %q, want none", passkey.AttestationType)
+	}
+	if passkey.Name != defaultPasskeyName {
+		t.Errorf("name %q, want %q", passkey.Name, defaultPasskeyName)
+	}
+	if count, err := service.Count(ctx, user.ID); err != nil || count != 1 {
+		t.Errorf("count %d, %v; want 1", count, err)
+	}
+
+	// The same credential cannot be registered twice
+	creation, token, err := service.BeginRegistration(ctx, user)
+	if err != nil {
+		t.Fatalf("begin second registration: %v", err)
+	}
+	if len(creation.Response.CredentialExcludeList) != 1 {
+		t.Errorf("exclude list has %d credentials, want 1", len(creation.Response.CredentialExcludeList))
+	}
+	if _, err := service.FinishRegistration(ctx, user, token, "", authenticator.create(t, creation)); !errors.Is(err, ErrPasskeyExists) {
+		t.Errorf("second registration: %v, want %v", err, ErrPasskeyExists)
+	}
+
+	t.Run("as the user", func(t *testing.T) {
+		authenticator.signCount++
+		got, used, err := login(t, service, user, authenticator)
+		if err != nil {
+			t.Fatalf("login: %v", err)
+		}
+		if got.ID != user.ID || used.ID != passkey.ID {
+			t.Errorf("signed in as %s with %s, want %s with %s", got.ID, used.ID, user.ID, passkey.ID)
+		}
+		if !used.UserVerified {
+			t.Errorf("login not reported as user verified")
+		}
+	})
+
+	t.Run("discoverable", func(t *testing.T) {
+		authenticator.signCount++
+		got, _, err := login(t, service, nil, authenticator)
+		if err != nil {
+			t.Fatalf("login: %v", err)
+		}
+		if got.ID != user.ID {
+			t.Errorf("signed in as %s, want %s", got.ID, user.ID)
+		}
+	})
+
+	t.Run("discoverable without user verification", func(t *testing.T) {
+		authenticator.signCount++
+		authenticator.flags = flagUserPresent
+		defer func() { authenticator.flags = flagUserPresent | flagUserVerified }()
+		if _, _, err := login(t, service, nil, authenticator); !errors.Is(err, ErrInvalidPasskey) {
+			t.Errorf("login: %v, want %v", err, ErrInvalidPasskey)
+		}
+	})
+
+	t.Run("session reused", func(t *testing.T) {
+		assertion, token, err := service.BeginLogin(ctx, user)
+		if err != nil {
+			t.Fatalf("begin login: %v", err)
+		}
+		authenticator.signCount++
+		if _, _, err := service.FinishLogin(ctx, token, authenticator.get(t, assertion), user); err != nil {
+			t.Fatalf("login: %v", err)
+		}
+		authenticator.signCount++
+		if _, _, err := service.FinishLogin(ctx, token, authenticator.get(t, assertion), user); !errors.Is(err, ErrInvalidWebAuthnSession) {
+			t.Errorf("reused session: %v, want %v", err, ErrInvalidWebAuthnSession)
+		}
+	})
+
+	t.Run("bad signature", func(t *testing.T) {
+		assertion, token, err := service.BeginLogin(ctx, user)
+		if err != nil {
+			t.Fatalf("begin login: %v", err)
+		}
+		impostor := newSoftAuthenticator(t)
+		impostor.credentialID = authenticator.credentialID
+		impostor.userHandle = authenticator.userHandle
+		impostor.signCount = authenticator.signCount + 1
+		if _, _, err := service.FinishLogin(ctx, token, impostor.get(t, assertion), user); !errors.Is(err, ErrInvalidPasskey) {
+			t.Errorf("login: %v, want %v", err, ErrInvalidPasskey)
+		}
+	})
+}
+
+func TestPasskeySignCount(t *testing.T) {
+	t.Run("cloned", func(t *testing.T) {
+		service, user := newPasskeyTestService(t)
+		authenticator := newSoftAuthenticator(t)
+		authenticator.signCount = 1
+		register(t, service, user, authenticator)
+
+		authenticator.signCount = 5
+		if _, _, err := login(t, service, user, authenticator); err != nil {
+			t.Fatalf("login: %v", err)
+		}
+		// A copy of the key answers with a counter that has not moved on
+		for _, signCount := range []uint32{5, 3} {
+			authenticator.signCount = signCount
+			if _, _, err := login(t, service, user, authenticator); !errors.Is(err, ErrPasskeyCloned) {
+				t.Errorf("login with counter %d: %v, want %v", signCount, err, ErrPasskeyCloned)
+			}
+		}
+		authenticator.signCount = 6
+		if _, _, err := login(t, service, nil, authenticator); err != nil {
+			t.Errorf("login with counter 6: %v", err)
+		}
+	})
+
+	t.Run("no counter", func(t *testing.T) {
+		// Authenticators that do not implement a counter always report zero
+		service, user := newPasskeyTestService(t)
+		authenticator := newSoftAuthenticator(t)
+		register(t, service, user, authenticator)
+		for i := 0; i < 2; i++ {
+			if _, _, err := login(t, service, user, authenticator); err != nil {
+				t.Fatalf("login %d: %v", i+1, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,194 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/goldcast/gc_auth_service/internal/models"
+	"github.com/goldcast/gc_auth_service/internal/repository"
+	"github.com/goldcast/gc_auth_service/pkg/mailer"
+	"github.com/goldcast/gc_auth_service/pkg/password"
+	"github.com/goldcast/gc_auth_service/pkg/ratelimit"
+	"github.com/google/uuid"
+)
+
+// ErrPasswordReused is returned when a new password is the current password
+// or one of the user's recent passwords
+var ErrPasswordReused = errors.New("password was used recently")
+
+const (
+	// maxPasswordChangeAttempts is the number of wrong current passwords a
+	// user may enter within passwordChangeLimitWindow when changing it
+	maxPasswordChangeAttempts = 5
+	passwordChangeLimitWindow = 15 * time.Minute
+)
+
+// PasswordService changes user passwords. New passwords must meet the
+// password policy. It remembers the hashes of the last passwords of each
+// user, which cannot be chosen again.
+type PasswordService struct {
+	users       repository.UserRepository
+	history     repository.PasswordHistoryRepository
+	tokens      *TokenService
+	mailer      mailer.Mailer
+	historySize int
+	hasher      *password.Hasher
+	policy      password.Policy
+	// changeLimit counts wrong current passwords per user
+	changeLimit *ratelimit.Limiter
+}
+
+// NewPasswordService creates a password service remembering historySize
+// previous passwords per user, in addition to the current one
+func NewPasswordService(users repository.UserRepository, history repository.PasswordHistoryRepository, tokens *TokenService, m mailer.Mailer, historySize int, hasher *password.Hasher, policy password.Policy) *PasswordService {
+	return &PasswordService{
+		users:       users,
+		history:     history,
+		tokens:      tokens,
+		mailer:      m,
+		historySize: historySize,
+		hasher:      hasher,
+		policy:      policy,
+		changeLimit: ratelimit.New(maxPasswordChangeAttempts, passwordChangeLimitWindow),
+	}
+}
+
+// Policy returns the requirements new passwords must meet
+func (s *PasswordService) Policy() password.Policy {
+	return s.policy
+}
+
+// Hash hashes a new password with the configured algorithm
+func (s *PasswordService) Hash(newPassword string) (string, error) {
+	return s.hasher.Hash(newPassword)
+}
+
+// Validate checks a new password of the user against the password policy.
+// It returns a *password.PolicyError listing the requirements the password
+// does not meet.
+func (s *PasswordService) Validate(user *models.User, newPassword string) error {
+	return s.policy.Validate(newPassword, password.UserInfo{
+		Username:  user.Username,
+		Email:     user.Email,
+		FirstName: user.FirstName,
+		LastName:  user.LastName,
+	})
+}
+
+// Change replaces the user's password after checking their current one, and
+// returns the user. With signOutOthers, all sessions of the user but the
+// current one are ended. It returns ErrInvalidCredentials if the current
+// password is wrong, a RateLimitError once the user entered too many wrong
+// ones recently, a *password.PolicyError if the new password does not meet
+// the policy, and ErrPasswordReused if it was used recently.
+func (s *PasswordService) Change(ctx context.Context, userID uuid.UUID, currentPassword, newPassword string, signOutOthers bool, currentSession uuid.UUID) (*models.User, error) {
+	user, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.verifyLimited(user, currentPassword); err != nil {
+		return nil, err
+	}
+
+	if err := s.Validate(user, newPassword); err != nil {
+		return nil, err
+	}
+	if err := s.CheckReuse(ctx, user, newPassword); err != nil {
+		return nil, err
+	}
+	if err := s.Set(ctx, user, newPassword); err != nil {
+		return nil, err
+	}
+	if signOutOthers {
+		if err := s.tokens.LogoutOthers(ctx, user.ID, currentSession); err != nil {
+			return nil, err
+		}
+	}
+	return user, nil
+}
+
+// verifyLimited checks the user's current password. Wrong passwords count
+// towards the user's limit, so that a stolen access token cannot be used to
+// guess it; the attempt counts until the password is known to be right, so
+// that concurrent guesses cannot get past the limit.
+func (s *PasswordService) verifyLimited(user *models.User, currentPassword string) error {
+	key := user.ID.String()
+	if ok, retryAfter := s.changeLimit.Allow(key); !ok {
+		return &RateLimitError{RetryAfter: retryAfter}
+	}
+	if ok, _ := s.hasher.Verify(currentPassword, user.Password); !ok {
+		return ErrInvalidCredentials
+	}
+	s.changeLimit.Refund(key)
+	return nil
+}
+
+// PurgeExpired forgets users who are no longer limited in changing their
+// password
+func (s *PasswordService) PurgeExpired(ctx context.Context) (int64, error) {
+	return int64(s.changeLimit.Prune()), nil
+}
+
+// NotifyChanged emails the user that their password was changed, so that
+// they notice if someone else did it
+func (s *PasswordService) NotifyChanged(ctx context.Context, user *models.User) error {
+	return s.mailer.Send(ctx, &mailer.Message{
+		To:      user.Email,
+		Subject: "Your password was changed",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"The password of your account was changed on %s.\n\n"+
+			"If you did not change it, reset your password right away with the \"Forgot password\" link on the sign-in page, "+
+			"and review the devices signed in to your account.\n",
+			user.FirstName, time.Now().UTC().Format("January 2, 2006 at 15:04 UTC")),
+	})
+}
+
+// CheckReuse returns ErrPasswordReused if the password is the user's current
+// password or one of their recent passwords
+func (s *PasswordService) CheckReuse(ctx context.Context, user *models.User, newPassword string) error {
+	if ok, _ := s.hasher.Verify(newPassword, user.Password); ok {
+		return ErrPasswordReused
+	}
+	if s.historySize <= 0 {
+		return nil
+	}
+
+	recent, err := s.history.ListRecent(ctx, user.ID, s.historySize)
+	if err != nil {
+		return err
+	}
+	for _, entry := range recent {
+		if ok, _ := s.hasher.Verify(newPassword, entry.PasswordHash); ok {
+			return ErrPasswordReused
+		}
+	}
+	return nil
+}
+
+// Set replaces the user's password and remembers the old hash. Callers check
+// the current password, the policy and reuse first, as needed.
+func (s *PasswordService) Set(ctx context.Context, user *models.User, newPassword string) error {
+	hash, err := s.hasher.Hash(newPassword)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if err := s.users.UpdatePassword(ctx, user.ID, hash, now); err != nil {
+		return err
+	}
+	if s.historySize <= 0 {
+		return nil
+	}
+	if err := s.history.Add(ctx, &models.PasswordHistoryEntry{
+		ID:           uuid.New(),
+		UserID:       user.ID,
+		PasswordHash: user.Password,
+		CreatedAt:    now,
+	}); err != nil {
+		return err
+	}
+	return s.history.Prune(ctx, user.ID, s.historySize)
+}
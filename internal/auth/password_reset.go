@@ -0,0 +1,239 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/goldcast/gc_auth_service/internal/models"
+	"github.com/goldcast/gc_auth_service/internal/repository"
+	"github.com/goldcast/gc_auth_service/pkg/logger"
+	"github.com/goldcast/gc_auth_service/pkg/mailer"
+	"github.com/goldcast/gc_auth_service/pkg/ratelimit"
+	"github.com/google/uuid"
+)
+
+// ErrInvalidResetToken is returned for password reset tokens that are
+// unknown, expired or already used
+var ErrInvalidResetToken = errors.New("invalid password reset token")
+
+// RateLimitError is returned when a client made too many requests
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("too many requests, retry in %s", e.RetryAfter.Round(time.Second))
+}
+
+// passwordResetLimitWindow is the window the password reset rate limits
+// apply to
+const passwordResetLimitWindow = time.Hour
+
+// resetMailTimeout bounds the delivery of a reset email, which goes on after
+// the request for it has been answered
+const resetMailTimeout = time.Minute
+
+// PasswordResetPolicy controls password reset links and how often they can
+// be requested and used
+type PasswordResetPolicy struct {
+	TTL        time.Duration // how long reset links remain valid
+	EmailLimit int           // reset links sent to one address per hour
+	IPLimit    int           // reset requests and attempts from one IP address per hour
+}
+
+// PasswordResetService lets users who forgot their password choose a new one
+// through a link sent to their email address. Links carry a random token of
+// which only a hash is stored, and can be used once.
+type PasswordResetService struct {
+	resets    repository.PasswordResetRepository
+	users     repository.UserRepository
+	tokens    *TokenService
+	passwords *PasswordService
+	mailer    mailer.Mailer
+	linkURL   string
+	ttl       time.Duration
+	perEmail  *ratelimit.Limiter
+	perIP     *ratelimit.Limiter
+	logger    *logger.Logger
+}
+
+// NewPasswordResetService creates a password reset service. The token is
+// appended to the link URL as the token query parameter.
+func NewPasswordResetService(resets repository.PasswordResetRepository, users repository.UserRepository, tokens *TokenService, passwords *PasswordService, m mailer.Mailer, linkURL string, policy PasswordResetPolicy, log *logger.Logger) *PasswordResetService {
+	return &PasswordResetService{
+		resets:    resets,
+		users:     users,
+		tokens:    tokens,
+		passwords: passwords,
+		mailer:    m,
+		linkURL:   linkURL,
+		ttl:       policy.TTL,
+		perEmail:  ratelimit.New(policy.EmailLimit, passwordResetLimitWindow),
+		perIP:     ratelimit.New(policy.IPLimit, passwordResetLimitWindow),
+		logger:    log,
+	}
+}
+
+// RequestReset emails a reset link to the user with the given address.
+// Unknown and inactive addresses, and addresses that were sent too many links
+// recently, are silently ignored so that the outcome does not reveal whether
+// an account exists. The email is sent in the background and failures are
+// logged, as the time taken to send it would reveal the same. It returns a
+// RateLimitError if the client IP address made too many requests.
+func (s *PasswordResetService) RequestReset(ctx context.Context, email, ip string) error {
+	if err := s.allowIP(ip); err != nil {
+		return err
+	}
+	email = strings.ToLower(strings.TrimSpace(email))
+	if ok, _ := s.perEmail.Allow(email); !ok {
+		return nil
+	}
+
+	user, err := s.users.GetByEmail(ctx, email)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !user.IsActive {
+		return nil
+	}
+
+	rawToken, err := GenerateOpaqueToken()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if err := s.resets.Create(ctx, &models.PasswordReset{
+		ID:        uuid.New(),
+		TokenHash: HashToken(rawToken),
+		UserID:    user.ID,
+		Email:     user.Email,
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.ttl),
+	}); err != nil {
+		return err
+	}
+
+	go s.send(ctx, user, &mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"We received a request to reset the password of your account. Choose a new password by opening the link below:\n\n"+
+			"%s\n\n"+
+			"The link expires in %d minutes and can be used once. If you did not ask to reset your password, you can ignore this email.\n",
+			user.FirstName, s.link(rawToken), int(s.ttl.Minutes())),
+	})
+	return nil
+}
+
+// send delivers a reset email. It keeps the values of the request context
+// but not its cancellation, since the request is answered without waiting.
+func (s *PasswordResetService) send(ctx context.Context, user *models.User, msg *mailer.Message) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), resetMailTimeout)
+	defer cancel()
+	if err := s.mailer.Send(ctx, msg); err != nil {
+		s.logger.WithFields(map[string]interface{}{
+			"user_id": user.ID,
+			"error":   err.Error(),
+		}).Error("Failed to send password reset email")
+	}
+}
+
+// Reset sets a new password for the user a reset link was sent to, and ends
+// all of their sessions. Opening the link also proves the user receives mail
+// at their address, which is marked verified. It returns a
+// *password.PolicyError if the password does not meet the policy,
+// ErrPasswordReused if it was used recently, and a RateLimitError if the
+// client IP address made too many attempts.
+func (s *PasswordResetService) Reset(ctx context.Context, rawToken, newPassword, ip string) (*models.User, error) {
+	if err := s.allowIP(ip); err != nil {
+		return nil, err
+	}
+
+	reset, err := s.resets.GetByHash(ctx, HashToken(rawToken))
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrInvalidResetToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if reset.UsedAt != nil || !reset.ExpiresAt.After(time.Now()) {
+		return nil, ErrInvalidResetToken
+	}
+
+	user, err := s.users.GetByID(ctx, reset.UserID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrInvalidResetToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	// A link sent to an address the user no longer has must not take over
+	// the account
+	if !user.IsActive || !strings.EqualFold(user.Email, reset.Email) {
+		return nil, ErrInvalidResetToken
+	}
+
+	// Checked before the link is used up, so that the user can try another
+	// password
+	if err := s.passwords.Validate(user, newPassword); err != nil {
+		return nil, err
+	}
+	if err := s.passwords.CheckReuse(ctx, user, newPassword); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	used, err := s.resets.MarkUsed(ctx, reset.ID, now)
+	if err != nil {
+		return nil, err
+	}
+	if !used {
+		return nil, ErrInvalidResetToken
+	}
+	if err := s.passwords.Set(ctx, user, newPassword); err != nil {
+		return nil, err
+	}
+	if err := s.resets.InvalidateUser(ctx, user.ID, now); err != nil {
+		return nil, err
+	}
+	if err := s.tokens.LogoutAll(ctx, user.ID); err != nil {
+		return nil, err
+	}
+	if _, err := s.users.MarkEmailVerified(ctx, user.ID, reset.Email, now); err != nil {
+		return nil, err
+	}
+
+	return s.users.GetByID(ctx, user.ID)
+}
+
+// PurgeExpired removes resets that have expired, and forgets clients that
+// are no longer rate limited
+func (s *PasswordResetService) PurgeExpired(ctx context.Context) (int64, error) {
+	s.perEmail.Prune()
+	s.perIP.Prune()
+	return s.resets.DeleteExpired(ctx, time.Now())
+}
+
+// allowIP counts a request from the client IP address against its limit
+func (s *PasswordResetService) allowIP(ip string) error {
+	if ok, retryAfter := s.perIP.Allow(ip); !ok {
+		return &RateLimitError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// link returns the reset link for a token
+func (s *PasswordResetService) link(rawToken string) string {
+	separator := "?"
+	if strings.Contains(s.linkURL, "?") {
+		separator = "&"
+	}
+	return s.linkURL + separator + "token=" + url.QueryEscape(rawToken)
+}
@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/goldcast/gc_auth_service/internal/models"
+	"github.com/goldcast/gc_auth_service/internal/repository"
+	"github.com/goldcast/gc_auth_service/pkg/password"
+	"github.com/google/uuid"
+)
+
+const testPassword = "Tulip-orbit-42x"
+
+// newPasswordTestService returns a password service on in-memory
+// repositories and a user whose password is testPassword
+func newPasswordTestService(t *testing.T) (*PasswordService, *models.User) {
+	t.Helper()
+	hasher, err := password.NewHasher(password.HasherConfig{Algorithm: password.Bcrypt, BcryptCost: 4})
+	if err != nil {
+		t.Fatalf("new hasher: %v", err)
+	}
+	users := repository.NewMemoryUserRepository()
+	user := createTestUser(t, users)
+	hash, err := hasher.Hash(testPassword)
+	if err != nil {
+		t.Fatalf("hash password: %v", err)
+	}
+	if err := users.UpdatePassword(context.Background(), user.ID, hash, time.Now()); err != nil {
+		t.Fatalf("set password: %v", err)
+	}
+	policy := password.Policy{MinLength: 8, MaxLength: 128}
+	return NewPasswordService(users, repository.NewMemoryPasswordHistoryRepository(), nil, nil, 2, hasher, policy), user
+}
+
+func TestChangePasswordLimit(t *testing.T) {
+	ctx := context.Background()
+	service, user := newPasswordTestService(t)
+
+	// A right current password does not count, even if the new one is refused
+	if _, err := service.Change(ctx, user.ID, testPassword, "short", false, uuid.Nil); !errors.As(err, new(*password.PolicyError)) {
+		t.Fatalf("weak new password: %v, want a PolicyError", err)
+	}
+	for i := 0; i < maxPasswordChangeAttempts; i++ {
+		if _, err := service.Change(ctx, user.ID, "wrong-password", "Saffron-delta-77q", false, uuid.Nil); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("wrong password %d: %v, want %v", i+1, err, ErrInvalidCredentials)
+		}
+	}
+
+	var limited *RateLimitError
+	if _, err := service.Change(ctx, user.ID, testPassword, "Saffron-delta-77q", false, uuid.Nil); !errors.As(err, &limited) {
+		t.Fatalf("change after the limit: %v, want a RateLimitError", err)
+	}
+	if limited.RetryAfter <= 0 || limited.RetryAfter > passwordChangeLimitWindow {
+		t.Errorf("retry after %s, want within %s", limited.RetryAfter, passwordChangeLimitWindow)
+	}
+}
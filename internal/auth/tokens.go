@@ -0,0 +1,394 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/goldcast/gc_auth_service/internal/models"
+	"github.com/goldcast/gc_auth_service/internal/repository"
+	"github.com/goldcast/gc_auth_service/pkg/jwt"
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrInvalidRefreshToken is returned for unknown, expired or revoked refresh tokens
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	// ErrRefreshTokenReused is returned when an already rotated refresh token is
+	// presented again; the whole session is revoked when this happens
+	ErrRefreshTokenReused = errors.New("refresh token reused")
+)
+
+// ClientInfo describes the client a session was created from
+type ClientInfo struct {
+	UserAgent string
+	IPAddress string
+}
+
+// Authentication method references (RFC 8176) recorded on sessions
+const (
+	AMRPassword    = "pwd"
+	AMROTP         = "otp"
+	AMRMultiFactor = "mfa"
+)
+
+// Grant describes what a session was authorized for and how the user
+// authenticated. A zero ClientID is a first-party login through the JSON API.
+type Grant struct {
+	ClientID string
+	Scope    string
+	AuthTime time.Time // defaults to the session start
+	AMR      []string
+}
+
+// TokenPair is an access token together with its refresh token, and the
+// session and user they were issued for
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    int
+	Scope        string
+	Session      *models.Session
+	User         *models.User
+}
+
+// TokenService issues access tokens and rotates the opaque refresh tokens
+// that are tracked server-side per session
+type TokenService struct {
+	jwt         *jwt.Service
+	users       repository.UserRepository
+	sessions    repository.SessionRepository
+	revocations repository.RevocationRepository
+	clients     repository.ClientRepository
+	refreshTTL  time.Duration
+}
+
+// NewTokenService creates a token service. Sessions of OAuth clients use the
+// token lifetimes and audience registered for the client.
+func NewTokenService(jwtService *jwt.Service, users repository.UserRepository, sessions repository.SessionRepository, revocations repository.RevocationRepository, clients repository.ClientRepository, refreshTTL time.Duration) *TokenService {
+	return &TokenService{
+		jwt:         jwtService,
+		users:       users,
+		sessions:    sessions,
+		revocations: revocations,
+		clients:     clients,
+		refreshTTL:  refreshTTL,
+	}
+}
+
+// tokenPolicy is how long the tokens of a session live and who they are for
+type tokenPolicy struct {
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+	audience   []string
+}
+
+// StartSession creates a new session for the user and issues its first token pair
+func (s *TokenService) StartSession(ctx context.Context, user *models.User, client ClientInfo, grant Grant) (*TokenPair, error) {
+	policy, err := s.policy(ctx, grant.ClientID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	authTime := grant.AuthTime
+	if authTime.IsZero() {
+		authTime = now
+	}
+	session := &models.Session{
+		ID:         uuid.New(),
+		UserID:     user.ID,
+		ClientID:   grant.ClientID,
+		Scope:      grant.Scope,
+		AuthTime:   authTime.UTC(),
+		AMR:        grant.AMR,
+		UserAgent:  client.UserAgent,
+		IPAddress:  client.IPAddress,
+		CreatedAt:  now,
+		LastUsedAt: now,
+		ExpiresAt:  now.Add(policy.refreshTTL),
+	}
+	if err := s.sessions.CreateSession(ctx, session); err != nil {
+		return nil, err
+	}
+
+	return s.issue(ctx, user, session, policy, now)
+}
+
+// Refresh exchanges a refresh token for a new token pair. The presented token
+// is consumed; presenting it again revokes the whole session. Tokens are only
+// accepted from the OAuth client they were issued to, or with an empty
+// clientID for first-party sessions.
+func (s *TokenService) Refresh(ctx context.Context, rawToken, clientID string) (*TokenPair, error) {
+	token, err := s.sessions.GetRefreshTokenByHash(ctx, HashToken(rawToken))
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	if token.RevokedAt != nil || !now.Before(token.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+	if token.UsedAt != nil {
+		return nil, s.revokeReused(ctx, token.SessionID, now)
+	}
+
+	session, err := s.sessions.GetSession(ctx, token.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session.RevokedAt != nil || session.ClientID != clientID {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	// Claim the token atomically; losing the race means it was replayed
+	claimed, err := s.sessions.MarkRefreshTokenUsed(ctx, token.ID, now)
+	if err != nil {
+		return nil, err
+	}
+	if !claimed {
+		return nil, s.revokeReused(ctx, token.SessionID, now)
+	}
+
+	user, err := s.users.GetByID(ctx, token.UserID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !user.IsActive {
+		if err := s.sessions.RevokeSession(ctx, session.ID, now); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidRefreshToken
+	}
+
+	// The client may have been deleted since the session started
+	policy, err := s.policy(ctx, session.ClientID)
+	if errors.Is(err, repository.ErrNotFound) {
+		if err := s.sessions.RevokeSession(ctx, session.ID, now); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return s.issue(ctx, user, session, policy, now)
+}
+
+// InspectRefreshToken returns a refresh token that can still be used, along
+// with its session. Unknown, used, expired and revoked tokens yield
+// ErrInvalidRefreshToken; unlike Refresh, nothing is consumed or revoked.
+func (s *TokenService) InspectRefreshToken(ctx context.Context, rawToken string) (*models.RefreshToken, *models.Session, error) {
+	token, err := s.sessions.GetRefreshTokenByHash(ctx, HashToken(rawToken))
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if token.RevokedAt != nil || token.UsedAt != nil || !time.Now().Before(token.ExpiresAt) {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+
+	session, err := s.sessions.GetSession(ctx, token.SessionID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if session.RevokedAt != nil {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+	return token, session, nil
+}
+
+// IsSessionRevoked reports whether a session has been revoked or no longer exists
+func (s *TokenService) IsSessionRevoked(ctx context.Context, sessionID uuid.UUID) (bool, error) {
+	session, err := s.sessions.GetSession(ctx, sessionID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return session.RevokedAt != nil, nil
+}
+
+// RevokeSession ends a session and its refresh tokens. Access tokens issued
+// within it are refused from then on by IsAccessTokenRevoked.
+func (s *TokenService) RevokeSession(ctx context.Context, sessionID uuid.UUID) error {
+	err := s.sessions.RevokeSession(ctx, sessionID, time.Now().UTC())
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// Logout ends the session the access token belongs to and denylists the
+// access token itself until it expires
+func (s *TokenService) Logout(ctx context.Context, claims *jwt.Claims) error {
+	now := time.Now().UTC()
+	if claims.SessionID != uuid.Nil {
+		if err := s.sessions.RevokeSession(ctx, claims.SessionID, now); err != nil && !errors.Is(err, repository.ErrNotFound) {
+			return err
+		}
+	}
+	return s.RevokeAccessToken(ctx, claims)
+}
+
+// LogoutAll ends every session of the user and invalidates all access tokens
+// issued to them so far
+func (s *TokenService) LogoutAll(ctx context.Context, userID uuid.UUID) error {
+	now := time.Now().UTC()
+	if err := s.sessions.RevokeUserSessions(ctx, userID, uuid.Nil, now); err != nil {
+		return err
+	}
+	return s.revocations.RevokeUserTokens(ctx, userID, now)
+}
+
+// LogoutOthers ends every session of the user except the given one, which
+// may be uuid.Nil to end them all. Like with RevokeSession, access tokens
+// issued within the ended sessions are refused from then on.
+func (s *TokenService) LogoutOthers(ctx context.Context, userID, keep uuid.UUID) error {
+	return s.sessions.RevokeUserSessions(ctx, userID, keep, time.Now().UTC())
+}
+
+// RevokeAccessToken denylists a single access token until its expiry
+func (s *TokenService) RevokeAccessToken(ctx context.Context, claims *jwt.Claims) error {
+	if claims.ID == "" || claims.ExpiresAt == nil {
+		return nil
+	}
+	return s.revocations.RevokeToken(ctx, claims.ID, claims.ExpiresAt.Time)
+}
+
+// IsAccessTokenRevoked reports whether a validated access token has been
+// denylisted, belongs to a session that has ended, or was issued before the
+// user logged out everywhere
+func (s *TokenService) IsAccessTokenRevoked(ctx context.Context, claims *jwt.Claims) (bool, error) {
+	if claims.ID != "" {
+		revoked, err := s.revocations.IsTokenRevoked(ctx, claims.ID, time.Now())
+		if err != nil || revoked {
+			return revoked, err
+		}
+	}
+	if claims.SessionID != uuid.Nil {
+		revoked, err := s.IsSessionRevoked(ctx, claims.SessionID)
+		if err != nil || revoked {
+			return revoked, err
+		}
+	}
+
+	if claims.Principal() != jwt.PrincipalUser {
+		return false, nil
+	}
+	cutoff, err := s.revocations.UserTokensRevokedBefore(ctx, claims.UserID)
+	if err != nil || cutoff.IsZero() || claims.IssuedAt == nil {
+		return false, err
+	}
+	// iat has second precision, so a token issued in the same second as the
+	// cutoff is treated as revoked
+	return !claims.IssuedAt.Time.After(cutoff), nil
+}
+
+// PurgeExpired removes denylist entries for access tokens that have expired
+func (s *TokenService) PurgeExpired(ctx context.Context) (int64, error) {
+	return s.revocations.PurgeExpired(ctx, time.Now())
+}
+
+// policy returns the token policy for sessions of the given client, or the
+// service defaults and first-party audience for first-party sessions
+func (s *TokenService) policy(ctx context.Context, clientID string) (*tokenPolicy, error) {
+	policy := &tokenPolicy{accessTTL: s.jwt.Expiry(), refreshTTL: s.refreshTTL}
+	if clientID == "" {
+		policy.audience = []string{jwt.AudienceFirstParty}
+		return policy, nil
+	}
+
+	client, err := s.clients.GetByID(ctx, clientID)
+	if err != nil {
+		return nil, err
+	}
+	if client.AccessTokenTTL > 0 {
+		policy.accessTTL = time.Duration(client.AccessTokenTTL) * time.Second
+	}
+	if client.RefreshTokenTTL > 0 {
+		policy.refreshTTL = time.Duration(client.RefreshTokenTTL) * time.Second
+	}
+	policy.audience = client.Audience
+	return policy, nil
+}
+
+// issue creates a new refresh token in the session and a matching access token
+func (s *TokenService) issue(ctx context.Context, user *models.User, session *models.Session, policy *tokenPolicy, now time.Time) (*TokenPair, error) {
+	rawToken, err := GenerateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt := now.Add(policy.refreshTTL)
+	refreshToken := &models.RefreshToken{
+		ID:        uuid.New(),
+		SessionID: session.ID,
+		UserID:    user.ID,
+		TokenHash: HashToken(rawToken),
+		CreatedAt: now,
+		ExpiresAt: expiresAt,
+	}
+	if err := s.sessions.CreateRefreshToken(ctx, refreshToken); err != nil {
+		return nil, err
+	}
+	if err := s.sessions.TouchSession(ctx, session.ID, now, expiresAt); err != nil {
+		return nil, err
+	}
+
+	accessToken, err := s.jwt.GenerateToken(user.ID, user.Email, user.Username,
+		jwt.WithSessionID(session.ID), jwt.WithClientID(session.ClientID), jwt.WithScope(session.Scope),
+		jwt.WithExpiry(policy.accessTTL), jwt.WithAudience(policy.audience...))
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: rawToken,
+		ExpiresIn:    int(policy.accessTTL.Seconds()),
+		Scope:        session.Scope,
+		Session:      session,
+		User:         user,
+	}, nil
+}
+
+// revokeReused revokes the session of a replayed refresh token
+func (s *TokenService) revokeReused(ctx context.Context, sessionID uuid.UUID, now time.Time) error {
+	if err := s.sessions.RevokeSession(ctx, sessionID, now); err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return err
+	}
+	return ErrRefreshTokenReused
+}
+
+// GenerateOpaqueToken returns a random URL-safe token with 256 bits of entropy
+func GenerateOpaqueToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// HashToken returns the hex-encoded SHA-256 hash under which an opaque token is stored
+func HashToken(rawToken string) string {
+	sum := sha256.Sum256([]byte(rawToken))
+	return hex.EncodeToString(sum[:])
+}
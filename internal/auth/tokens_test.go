@@ -0,0 +1,212 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+	"github.com/goldcast/gc_auth_service/internal/models"
+	"github.com/goldcast/gc_auth_service/internal/repository"
+	"github.com/goldcast/gc_auth_service/pkg/jwt"
+	"github.com/google/uuid"
+)
+
+// newTokenTestService returns a token service on in-memory repositories and
+// a user to issue tokens to
+func newTokenTestService(t *testing.T) (*TokenService, *models.User) {
+	t.Helper()
+	users := repository.NewMemoryUserRepository()
+	user := createTestUser(t, users)
+	jwtService := jwt.New("test-secret-of-at-least-32-bytes!", 1)
+	service := NewTokenService(jwtService, users, repository.NewMemorySessionRepository(), repository.NewMemoryRevocationRepository(), repository.NewMemoryClientRepository(), time.Hour)
+	return service, user
+}
+
+func startSession(t *testing.T, service *TokenService, user *models.User) *TokenPair {
+	t.Helper()
+	pair, err := service.StartSession(context.Background(), user, ClientInfo{}, Grant{AMR: []string{AMRPassword}})
+	if err != nil {
+		t.Fatalf("start session: %v", err)
+	}
+	return pair
+}
+
+func TestRefreshRotation(t *testing.T) {
+	ctx := context.Background()
+	service, user := newTokenTestService(t)
+	first := startSession(t, service, user)
+
+	second, err := service.Refresh(ctx, first.RefreshToken, "")
+	if err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if second.RefreshToken == first.RefreshToken || second.AccessToken == "" {
+		t.Fatal("refresh did not issue a new token pair")
+	}
+	if second.Session.ID != first.Session.ID {
+		t.Errorf("refresh moved to session %s, want %s", second.Session.ID, first.Session.ID)
+	}
+	third, err := service.Refresh(ctx, second.RefreshToken, "")
+	if err != nil {
+		t.Fatalf("refresh with the rotated token: %v", err)
+	}
+
+	for _, tt := range []struct {
+		name, token, clientID string
+	}{
+		{"unknown token", "not-a-refresh-token", ""},
+		{"other client", third.RefreshToken, "other-client"},
+	} {
+		if _, err := service.Refresh(ctx, tt.token, tt.clientID); !errors.Is(err, ErrInvalidRefreshToken) {
+			t.Errorf("%s: %v, want %v", tt.name, err, ErrInvalidRefreshToken)
+		}
+	}
+}
+
+func TestRefreshReuseRevokesSession(t *testing.T) {
+	ctx := context.Background()
+	service, user := newTokenTestService(t)
+	first := startSession(t, service, user)
+	second, err := service.Refresh(ctx, first.RefreshToken, "")
+	if err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+
+	if _, err := service.Refresh(ctx, first.RefreshToken, ""); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("reused token: %v, want %v", err, ErrRefreshTokenReused)
+	}
+	// The token the legitimate client holds now was revoked along with the session
+	if _, err := service.Refresh(ctx, second.RefreshToken, ""); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("token of the revoked session: %v, want %v", err, ErrInvalidRefreshToken)
+	}
+	if revoked, err := service.IsSessionRevoked(ctx, first.Session.ID); err != nil || !revoked {
+		t.Errorf("session revoked: %v, %v; want true", revoked, err)
+	}
+}
+
+func TestRefreshConcurrentReplay(t *testing.T) {
+	const requests = 10
+	service, user := newTokenTestService(t)
+	pair := startSession(t, service, user)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, requests)
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := service.Refresh(context.Background(), pair.RefreshToken, "")
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	// Replays that lose the race revoke the session, after which the others
+	// find it revoked
+	refreshed := 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			refreshed++
+		case !errors.Is(err, ErrRefreshTokenReused) && !errors.Is(err, ErrInvalidRefreshToken):
+			t.Errorf("refresh: %v, want success or a refused token", err)
+		}
+	}
+	if refreshed != 1 {
+		t.Errorf("%d of %d concurrent refreshes succeeded, want 1", refreshed, requests)
+	}
+	if revoked, err := service.IsSessionRevoked(context.Background(), pair.Session.ID); err != nil || !revoked {
+		t.Errorf("session revoked: %v, %v; want true", revoked, err)
+	}
+}
+
+// accessClaims validates an access token issued by the service
+func accessClaims(t *testing.T, service *TokenService, token string) *jwt.Claims {
+	t.Helper()
+	claims, err := service.jwt.ValidateToken(token)
+	if err != nil {
+		t.Fatalf("validate access token: %v", err)
+	}
+	return claims
+}
+
+func TestLogoutRevokesTokens(t *testing.T) {
+	ctx := context.Background()
+	service, user := newTokenTestService(t)
+	pair := startSession(t, service, user)
+	other := startSession(t, service, user)
+	claims := accessClaims(t, service, pair.AccessToken)
+
+	if err := service.Logout(ctx, claims); err != nil {
+		t.Fatalf("logout: %v", err)
+	}
+	if revoked, err := service.IsAccessTokenRevoked(ctx, claims); err != nil || !revoked {
+		t.Errorf("access token revoked after logout: %v, %v; want true", revoked, err)
+	}
+	if _, err := service.Refresh(ctx, pair.RefreshToken, ""); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("refresh after logout: %v, want %v", err, ErrInvalidRefreshToken)
+	}
+
+	// The access token is refused on the denylist alone, also once its
+	// session is no longer known
+	denylisted := *claims
+	denylisted.SessionID = uuid.Nil
+	if revoked, err := service.IsAccessTokenRevoked(ctx, &denylisted); err != nil || !revoked {
+		t.Errorf("denylisted access token revoked: %v, %v; want true", revoked, err)
+	}
+
+	// Other sessions of the user keep working
+	if revoked, err := service.IsAccessTokenRevoked(ctx, accessClaims(t, service, other.AccessToken)); err != nil || revoked {
+		t.Errorf("access token of another session revoked: %v, %v; want false", revoked, err)
+	}
+	if _, err := service.Refresh(ctx, other.RefreshToken, ""); err != nil {
+		t.Errorf("refresh of another session: %v", err)
+	}
+}
+
+func TestLogoutAllCutoff(t *testing.T) {
+	ctx := context.Background()
+	service, user := newTokenTestService(t)
+	pair := startSession(t, service, user)
+	claims := accessClaims(t, service, pair.AccessToken)
+
+	if err := service.LogoutAll(ctx, user.ID); err != nil {
+		t.Fatalf("logout everywhere: %v", err)
+	}
+	if _, err := service.Refresh(ctx, pair.RefreshToken, ""); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("refresh after logging out everywhere: %v, want %v", err, ErrInvalidRefreshToken)
+	}
+
+	// Tokens are refused by the cutoff alone, whatever their session
+	cutoff, err := service.revocations.UserTokensRevokedBefore(ctx, user.ID)
+	if err != nil || cutoff.IsZero() {
+		t.Fatalf("cutoff after logging out everywhere: %v, %v", cutoff, err)
+	}
+	for _, tt := range []struct {
+		name     string
+		issuedAt time.Time
+		want     bool
+	}{
+		{"issued before", cutoff.Add(-time.Minute), true},
+		{"issued in the same second", cutoff, true},
+		{"issued after", cutoff.Add(time.Second), false},
+	} {
+		token := *claims
+		token.ID = uuid.NewString()
+		token.SessionID = uuid.Nil
+		token.IssuedAt = gojwt.NewNumericDate(tt.issuedAt)
+		if revoked, err := service.IsAccessTokenRevoked(ctx, &token); err != nil || revoked != tt.want {
+			t.Errorf("%s: revoked %v, %v; want %v", tt.name, revoked, err, tt.want)
+		}
+	}
+
+	// Tokens issued to clients on their own behalf are not the user's
+	client := &jwt.Claims{ClientID: "service", RegisteredClaims: gojwt.RegisteredClaims{IssuedAt: gojwt.NewNumericDate(time.Now().Add(-time.Minute))}}
+	if revoked, err := service.IsAccessTokenRevoked(ctx, client); err != nil || revoked {
+		t.Errorf("client token revoked: %v, %v; want false", revoked, err)
+	}
+}
@@ -0,0 +1,336 @@
+package auth
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/goldcast/gc_auth_service/internal/models"
+	"github.com/goldcast/gc_auth_service/internal/repository"
+	"github.com/goldcast/gc_auth_service/pkg/password"
+	"github.com/google/uuid"
+)
+
+// Formats of user import files
+const (
+	ImportCSV   = "csv"
+	ImportJSONL = "jsonl"
+)
+
+// maxImportLine is the length of the longest line of a JSONL import file
+const maxImportLine = 1 << 20
+
+// UserImporter creates users from files exported by other systems, keeping
+// their password hashes. Imported hashes are verified like native ones and
+// replaced with a hash in the current format when their user signs in.
+type UserImporter struct {
+	users     repository.UserRepository
+	hasher    *password.Hasher
+	validator *validator.Validate
+}
+
+// NewUserImporter creates a user importer
+func NewUserImporter(users repository.UserRepository, hasher *password.Hasher) *UserImporter {
+	v := validator.New()
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		return name
+	})
+	return &UserImporter{users: users, hasher: hasher, validator: v}
+}
+
+// Import creates the users of a CSV or JSONL file, one user per line. Rows
+// are imported independently: invalid rows are reported and do not stop the
+// import. Users whose email address is already registered are skipped, so
+// importing a file again only creates the users that failed before. With
+// dryRun, rows are checked but no user is created. An error is returned if
+// the file cannot be read at all.
+func (i *UserImporter) Import(ctx context.Context, r io.Reader, format string, dryRun bool) (*models.ImportReport, error) {
+	report := &models.ImportReport{DryRun: dryRun, Rows: []models.ImportRowResult{}}
+	emails := make(map[string]int)    // line each email address was first seen on
+	usernames := make(map[string]int) // line each username was first seen on
+
+	err := readImportFile(r, format, func(line int, record *models.ImportUserRecord, err error) error {
+		result := models.ImportRowResult{Line: line}
+		if record != nil {
+			result.Email = strings.ToLower(strings.TrimSpace(record.Email))
+		}
+		if err == nil {
+			err = i.checkDuplicates(record, line, emails, usernames)
+		}
+		if err == nil {
+			err = i.importRow(ctx, record, dryRun, &result)
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			result.Status = models.ImportFailed
+			result.Error = err.Error()
+		}
+
+		report.Total++
+		switch result.Status {
+		case models.ImportCreated:
+			report.Created++
+		case models.ImportWouldCreate:
+			report.WouldCreate++
+		case models.ImportSkipped:
+			report.Skipped++
+		case models.ImportFailed:
+			report.Failed++
+		}
+		report.Rows = append(report.Rows, result)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// checkDuplicates refuses a row whose email address or username appears on
+// an earlier row of the file
+func (i *UserImporter) checkDuplicates(record *models.ImportUserRecord, line int, emails, usernames map[string]int) error {
+	email := strings.ToLower(strings.TrimSpace(record.Email))
+	if first, ok := emails[email]; ok && email != "" {
+		return fmt.Errorf("email address already appears on line %d", first)
+	}
+	if first, ok := usernames[record.Username]; ok && record.Username != "" {
+		return fmt.Errorf("username already appears on line %d", first)
+	}
+	emails[email] = line
+	usernames[record.Username] = line
+	return nil
+}
+
+// importRow validates a row and creates its user, filling in the result
+func (i *UserImporter) importRow(ctx context.Context, record *models.ImportUserRecord, dryRun bool, result *models.ImportRowResult) error {
+	record.Email = strings.ToLower(strings.TrimSpace(record.Email))
+	record.Username = strings.TrimSpace(record.Username)
+	if err := i.validator.Struct(record); err != nil {
+		return validationError(err)
+	}
+	hash, err := i.hasher.Import(password.ImportedHash{
+		Format:       record.HashFormat,
+		Hash:         record.PasswordHash,
+		Salt:         record.Salt,
+		SaltPosition: record.SaltPosition,
+	})
+	if err != nil {
+		return fmt.Errorf("password_hash: %w", err)
+	}
+
+	existing, err := i.users.GetByEmail(ctx, record.Email)
+	if err == nil {
+		result.Status = models.ImportSkipped
+		result.UserID = &existing.ID
+		return nil
+	}
+	if !errors.Is(err, repository.ErrNotFound) {
+		return err
+	}
+	if _, err := i.users.GetByUsername(ctx, record.Username); err == nil {
+		return repository.ErrDuplicateUsername
+	} else if !errors.Is(err, repository.ErrNotFound) {
+		return err
+	}
+
+	if dryRun {
+		result.Status = models.ImportWouldCreate
+		return nil
+	}
+
+	now := time.Now()
+	user := &models.User{
+		ID:            uuid.New(),
+		Email:         record.Email,
+		Username:      record.Username,
+		Password:      hash,
+		FirstName:     strings.TrimSpace(record.FirstName),
+		LastName:      strings.TrimSpace(record.LastName),
+		IsActive:      record.IsActive == nil || *record.IsActive,
+		EmailVerified: record.EmailVerified,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if record.CreatedAt != nil {
+		user.CreatedAt = *record.CreatedAt
+	}
+	if err := i.users.Create(ctx, user); err != nil {
+		return err
+	}
+	result.Status = models.ImportCreated
+	result.UserID = &user.ID
+	return nil
+}
+
+// validationError describes what is wrong with an import row
+func validationError(err error) error {
+	var fieldErrors validator.ValidationErrors
+	if !errors.As(err, &fieldErrors) {
+		return err
+	}
+	problems := make([]string, len(fieldErrors))
+	for n, fe := range fieldErrors {
+		switch fe.Tag() {
+		case "required":
+			problems[n] = fe.Field() + " is required"
+		case "email":
+			problems[n] = fe.Field() + " must be an email address"
+		case "min":
+			problems[n] = fmt.Sprintf("%s must be at least %s characters", fe.Field(), fe.Param())
+		case "max":
+			problems[n] = fmt.Sprintf("%s must be at most %s characters", fe.Field(), fe.Param())
+		default:
+			problems[n] = fe.Field() + " is invalid"
+		}
+	}
+	return errors.New(strings.Join(problems, "; "))
+}
+
+// readImportFile calls fn with each row of an import file and the line it
+// starts on. Rows that cannot be parsed are passed with an error instead.
+func readImportFile(r io.Reader, format string, fn func(line int, record *models.ImportUserRecord, err error) error) error {
+	switch format {
+	case ImportCSV:
+		return readImportCSV(r, fn)
+	case ImportJSONL:
+		return readImportJSONL(r, fn)
+	default:
+		return fmt.Errorf("unknown import format %q, expected %s or %s", format, ImportCSV, ImportJSONL)
+	}
+}
+
+// readImportJSONL reads a file with a JSON object per line
+func readImportJSONL(r io.Reader, fn func(line int, record *models.ImportUserRecord, err error) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxImportLine)
+	for line := 1; scanner.Scan(); line++ {
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		var record models.ImportUserRecord
+		if err := decoder.Decode(&record); err != nil {
+			if err := fn(line, nil, fmt.Errorf("invalid JSON: %w", err)); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := fn(line, &record, nil); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// readImportCSV reads a CSV file whose header names the columns, using the
+// JSON field names of models.ImportUserRecord
+func readImportCSV(r io.Reader, fn func(line int, record *models.ImportUserRecord, err error) error) error {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("read CSV header: %w", err)
+	}
+	if len(header) > 0 {
+		// Spreadsheet programs may start the file with a byte order mark
+		header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	}
+	for _, column := range header {
+		if !slices.Contains(importColumns, column) {
+			return fmt.Errorf("unknown CSV column %q, expected %s", column, strings.Join(importColumns, ", "))
+		}
+	}
+
+	for {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		line, _ := reader.FieldPos(0)
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			if err := fn(parseErr.StartLine, nil, fmt.Errorf("invalid CSV: %w", parseErr.Err)); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		record, err := csvRecord(header, fields)
+		if err := fn(line, record, err); err != nil {
+			return err
+		}
+	}
+}
+
+// importColumns are the columns of CSV import files
+var importColumns = []string{
+	"email", "username", "first_name", "last_name", "email_verified", "is_active", "created_at",
+	"hash_format", "password_hash", "salt", "salt_position",
+}
+
+// csvRecord converts the fields of a CSV row into a record
+func csvRecord(header, fields []string) (*models.ImportUserRecord, error) {
+	record := &models.ImportUserRecord{}
+	for n, column := range header {
+		value := fields[n]
+		switch column {
+		case "email":
+			record.Email = value
+		case "username":
+			record.Username = value
+		case "first_name":
+			record.FirstName = value
+		case "last_name":
+			record.LastName = value
+		case "hash_format":
+			record.HashFormat = value
+		case "password_hash":
+			record.PasswordHash = value
+		case "salt":
+			record.Salt = value
+		case "salt_position":
+			record.SaltPosition = value
+		case "email_verified", "is_active":
+			if value == "" {
+				continue
+			}
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return record, fmt.Errorf("%s must be true or false, got %q", column, value)
+			}
+			if column == "email_verified" {
+				record.EmailVerified = b
+			} else {
+				record.IsActive = &b
+			}
+		case "created_at":
+			if value == "" {
+				continue
+			}
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return record, fmt.Errorf("created_at must be an RFC 3339 time, got %q", value)
+			}
+			record.CreatedAt = &t
+		}
+	}
+	return record, nil
+}
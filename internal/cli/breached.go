@@ -0,0 +1,65 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/goldcast/gc_auth_service/internal/config"
+	"github.com/goldcast/gc_auth_service/pkg/logger"
+	"github.com/goldcast/gc_auth_service/pkg/password"
+)
+
+// runBreached builds the Bloom filter of breached passwords that
+// BREACHED_PASSWORDS_PATH can point to
+func runBreached(cfg *config.Config, log *logger.Logger, args []string, out io.Writer) error {
+	if len(args) == 0 || args[0] != "build" {
+		return errors.New("usage: breached build -in CORPUS -out FILE [-fp RATE] [-min-count N]")
+	}
+
+	flags := flag.NewFlagSet("breached build", flag.ContinueOnError)
+	flags.SetOutput(out)
+	in := flags.String("in", "", "Pwned Passwords SHA-1 corpus: a directory of range files or a file of full hashes")
+	output := flags.String("out", "", "Bloom filter file to write")
+	rate := flags.Float64("fp", 0.001, "rate of passwords wrongly reported as breached")
+	minCount := flags.Int("min-count", 1, "leave out passwords seen fewer times in breaches")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	if *in == "" || *output == "" {
+		return errors.New("-in and -out are required")
+	}
+
+	start := time.Now()
+	filter, err := password.BuildBloomFilter(*in, *minCount, *rate)
+	if err != nil {
+		return err
+	}
+
+	// Written next to the destination first, so that a running service never
+	// loads a partial file
+	tmp := *output + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := filter.WriteTo(f); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, *output); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "wrote %s: %d passwords, %d bytes, false positive rate %g, in %s\n",
+		*output, filter.Len(), filter.Size(), *rate, time.Since(start).Round(time.Second))
+	return nil
+}
@@ -0,0 +1,49 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/goldcast/gc_auth_service/internal/config"
+	"github.com/goldcast/gc_auth_service/pkg/logger"
+)
+
+// command is a subcommand of the service binary
+type command struct {
+	usage string
+	run   func(cfg *config.Config, log *logger.Logger, args []string, out io.Writer) error
+}
+
+var commands = map[string]command{
+	"migrate":      {usage: "migrate [up|down|status] [-steps N]", run: runMigrate},
+	"keys":         {usage: "keys [list|rotate]", run: runKeys},
+	"breached":     {usage: "breached build -in CORPUS -out FILE [-fp RATE] [-min-count N]", run: runBreached},
+	"import-users": {usage: "import-users -file FILE [-format csv|jsonl] [-dry-run]", run: runImportUsers},
+}
+
+// Run executes the subcommand named by args[0] and returns the process exit
+// code. The configuration must have been validated. Subcommands that need
+// the container build it with app.New and do not start it, so that they run
+// no background work of the server.
+func Run(cfg *config.Config, log *logger.Logger, args []string, out io.Writer) int {
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(out, "unknown command %q\n\nUsage:\n", args[0])
+		names := make([]string, 0, len(commands))
+		for name := range commands {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(out, "  gc_auth_service %s\n", commands[name].usage)
+		}
+		return 2
+	}
+
+	if err := cmd.run(cfg, log, args[1:], out); err != nil {
+		log.WithField("error", err.Error()).Errorf("%s failed", args[0])
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,97 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/goldcast/gc_auth_service/internal/app"
+	"github.com/goldcast/gc_auth_service/internal/auth"
+	"github.com/goldcast/gc_auth_service/internal/config"
+	"github.com/goldcast/gc_auth_service/internal/models"
+	"github.com/goldcast/gc_auth_service/pkg/logger"
+)
+
+// runImportUsers creates users from a CSV or JSONL file exported by another
+// system, keeping their password hashes
+func runImportUsers(cfg *config.Config, log *logger.Logger, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("import-users", flag.ContinueOnError)
+	flags.SetOutput(out)
+	file := flags.String("file", "", "CSV or JSONL file of users to import")
+	format := flags.String("format", "", "csv or jsonl, by default from the file extension")
+	dryRun := flags.Bool("dry-run", false, "check the file without creating any user")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *file == "" {
+		return errors.New("-file is required")
+	}
+	if *format == "" {
+		switch strings.ToLower(filepath.Ext(*file)) {
+		case ".csv":
+			*format = auth.ImportCSV
+		case ".jsonl", ".ndjson":
+			*format = auth.ImportJSONL
+		default:
+			return fmt.Errorf("cannot tell the format of %s, use -format csv or -format jsonl", *file)
+		}
+	}
+	if cfg.DatabaseURL == "" {
+		return errors.New("DATABASE_URL is not set")
+	}
+
+	f, err := os.Open(*file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	container, err := app.New(cfg, log)
+	if err != nil {
+		return err
+	}
+	defer container.Close()
+
+	report, err := container.Importer.Import(context.Background(), f, *format, *dryRun)
+	if err != nil {
+		return err
+	}
+
+	for _, row := range report.Rows {
+		fmt.Fprintf(out, "line %-6d %-12s %s", row.Line, row.Status, row.Email)
+		if row.Error != "" {
+			fmt.Fprintf(out, ": %s", row.Error)
+		}
+		fmt.Fprintln(out)
+	}
+	if report.DryRun {
+		fmt.Fprintf(out, "\ndry run of %d rows: %d would be created, %d skipped, %d failed\n",
+			report.Total, report.WouldCreate, report.Skipped, report.Failed)
+	} else {
+		fmt.Fprintf(out, "\nimported %d rows: %d created, %d skipped, %d failed\n",
+			report.Total, report.Created, report.Skipped, report.Failed)
+	}
+	container.Audit.Record(context.Background(), &models.AuditEvent{
+		Type: "users_imported",
+		Metadata: map[string]interface{}{
+			"format":       *format,
+			"dry_run":      report.DryRun,
+			"total":        report.Total,
+			"created":      report.Created,
+			"would_create": report.WouldCreate,
+			"skipped":      report.Skipped,
+			"failed":       report.Failed,
+			"file":         *file,
+		},
+	})
+
+	if report.Failed > 0 {
+		return fmt.Errorf("%d of %d rows failed", report.Failed, report.Total)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/goldcast/gc_auth_service/internal/app"
+	"github.com/goldcast/gc_auth_service/internal/config"
+	"github.com/goldcast/gc_auth_service/pkg/logger"
+)
+
+// runKeys lists or rotates the managed JWT signing keys
+func runKeys(cfg *config.Config, log *logger.Logger, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New("usage: keys [list|rotate]")
+	}
+	if !cfg.JWTManagedKeys {
+		return errors.New("JWT_MANAGED_KEYS is not enabled")
+	}
+	if cfg.DatabaseURL == "" {
+		return errors.New("DATABASE_URL is not set")
+	}
+
+	container, err := app.New(cfg, log)
+	if err != nil {
+		return err
+	}
+	defer container.Close()
+
+	ctx := context.Background()
+	switch args[0] {
+	case "list":
+	case "rotate":
+		key, err := container.Keys.Rotate(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "activated %s (%s)\n\n", key.ID, key.Algorithm)
+	default:
+		return fmt.Errorf("unknown keys action %q", args[0])
+	}
+
+	keys, err := container.Keys.List(ctx)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		expires := "-"
+		if key.ExpiresAt != nil {
+			expires = key.ExpiresAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(out, "%-45s %-6s %-8s created %s  expires %s\n",
+			key.ID, key.Algorithm, key.Status, key.CreatedAt.Format("2006-01-02 15:04:05"), expires)
+	}
+	return nil
+}
@@ -1,20 +1,86 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/url"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
 
+// DefaultJWTSecret is the development fallback secret, which is refused in production
+const DefaultJWTSecret = "your-secret-key-change-in-production"
+
 // Config holds all configuration for our application
 type Config struct {
-	Environment string
-	Port        string
-	LogLevel    string
-	JWTSecret   string
-	JWTExpiry   int // in hours
-	DatabaseURL string
+	Environment        string
+	Port               string
+	LogLevel           string
+	TrustedProxies     []string // addresses or CIDR ranges of reverse proxies whose X-Forwarded-For is trusted
+	JWTSecret          string
+	JWTExpiry          int    // in hours
+	RefreshExpiry      int    // in hours
+	JWTKeyFile         string // PEM private key; when set, tokens are signed asymmetrically
+	JWTKeyID           string
+	JWTManagedKeys     bool // keys are generated, stored and rotated by the service
+	JWTKeyAlgorithm    string
+	JWTKeyRotation     int // in hours, 0 disables scheduled rotation
+	JWTKeyGrace        int // in hours
+	EncryptionKey      string
+	AdminToken         string
+	OAuthClientsFile   string   // JSON file of OAuth clients to register at startup
+	RegistrationToken  string   // initial access token for dynamic client registration
+	RegistrationScopes []string // scopes dynamically registered clients may request
+	RegistrationGrants []string // grant types dynamically registered clients may use
+	IssuerURL          string   // public base URL, used as the OpenID Connect issuer
+	TOTPIssuer         string   // name authenticator apps show for TOTP enrollments
+	WebAuthnRPID       string   // WebAuthn relying party ID, the domain passkeys are bound to
+	WebAuthnRPName     string   // name browsers show when creating a passkey
+	WebAuthnOrigins    []string // origins passkey ceremonies may run on
+	Mailer             string   // smtp, log or memory
+	SMTPHost           string
+	SMTPPort           int
+	SMTPUsername       string
+	SMTPPassword       string
+	SMTPInsecure       bool   // mail may be sent without TLS to servers that do not offer it
+	MailFrom           string // sender of outgoing email
+	VerifiedEmailOnly  bool   // logins are refused until the user has verified their email address
+	VerificationURL    string // link in verification emails, with the token appended
+	PasswordResetURL   string // link in password reset emails, with the token appended
+	PasswordResetTTL   int    // in minutes
+	ResetEmailLimit    int    // reset links sent to one address per hour
+	ResetIPLimit       int    // reset requests and attempts from one IP address per hour
+	PasswordHistory    int    // previous passwords that cannot be chosen again
+	PasswordMinLength  int
+	PasswordMaxLength  int
+	PasswordClasses    []string // character classes passwords must include: lower, upper, digit, symbol
+	PasswordRepeat     int      // times in a row a character may appear in a password, 0 for any
+	PasswordNoPersonal bool     // passwords may not contain the user's username, email or name
+	PasswordNoCommon   bool     // commonly used passwords are refused
+	CommonPasswords    string   // file of commonly used passwords, in addition to the built-in list
+	PasswordEntropy    int      // estimated strength passwords need, in bits
+	BreachedPasswords  string   // Pwned Passwords range directory or Bloom filter file
+	PasswordHash       string   // argon2id or bcrypt, for new password hashes
+	Argon2Memory       int      // in KiB
+	Argon2Iterations   int
+	Argon2Parallelism  int
+	BcryptCost         int
+	PasswordPepper     string // secret argon2id password hashes are keyed with
+	FirebaseSignerKey  string // base64, for password hashes imported from Firebase
+	FirebaseSeparator  string // base64 salt separator of Firebase password hashes
+	FirebaseRounds     int
+	FirebaseMemCost    int
+	DatabaseURL        string
+	DatabaseRequired   bool
+	AutoMigrate        bool
+
+	// parseErrors records values that were present but could not be parsed,
+	// so that Validate can report them instead of silently using the default
+	parseErrors []FieldError
 }
 
 // Load reads configuration from environment variables
@@ -22,14 +88,120 @@ func Load() *Config {
 	// Load .env file if it exists
 	_ = godotenv.Load()
 
-	return &Config{
-		Environment: getEnv("ENVIRONMENT", "development"),
-		Port:        getEnv("PORT", "8080"),
-		LogLevel:    getEnv("LOG_LEVEL", "info"),
-		JWTSecret:   getEnv("JWT_SECRET", "your-secret-key-change-in-production"),
-		JWTExpiry:   getEnvAsInt("JWT_EXPIRY_HOURS", 24),
-		DatabaseURL: getEnv("DATABASE_URL", ""),
+	cfg := &Config{
+		Environment:       getEnv("ENVIRONMENT", "development"),
+		Port:              getEnv("PORT", "8080"),
+		LogLevel:          getEnv("LOG_LEVEL", "info"),
+		JWTSecret:         getEnv("JWT_SECRET", DefaultJWTSecret),
+		JWTKeyFile:        getEnv("JWT_SIGNING_KEY_FILE", ""),
+		JWTKeyID:          getEnv("JWT_KEY_ID", ""),
+		JWTKeyAlgorithm:   getEnv("JWT_KEY_ALGORITHM", "ES256"),
+		EncryptionKey:     getEnv("ENCRYPTION_KEY", ""),
+		AdminToken:        getEnv("ADMIN_API_TOKEN", ""),
+		OAuthClientsFile:  getEnv("OAUTH_CLIENTS_FILE", ""),
+		RegistrationToken: getEnv("OAUTH_REGISTRATION_TOKEN", ""),
+		TOTPIssuer:        getEnv("TOTP_ISSUER", "GC Auth"),
+		SMTPHost:          getEnv("SMTP_HOST", ""),
+		SMTPUsername:      getEnv("SMTP_USERNAME", ""),
+		SMTPPassword:      getEnv("SMTP_PASSWORD", ""),
+		MailFrom:          getEnv("MAIL_FROM", ""),
+		DatabaseURL:       getEnv("DATABASE_URL", ""),
+	}
+	cfg.TrustedProxies = splitList(getEnv("TRUSTED_PROXIES", ""))
+	cfg.RegistrationScopes = strings.Fields(getEnv("OAUTH_REGISTRATION_SCOPES", "openid profile email"))
+	cfg.RegistrationGrants = strings.Fields(getEnv("OAUTH_REGISTRATION_GRANT_TYPES", "authorization_code refresh_token"))
+	cfg.IssuerURL = strings.TrimRight(getEnv("ISSUER_URL", "http://localhost:"+cfg.Port), "/")
+	cfg.WebAuthnRPName = getEnv("WEBAUTHN_RP_NAME", cfg.TOTPIssuer)
+	cfg.WebAuthnOrigins = splitList(getEnv("WEBAUTHN_RP_ORIGINS", cfg.IssuerURL))
+	// Passkeys are bound to the issuer's domain unless configured otherwise
+	cfg.WebAuthnRPID = os.Getenv("WEBAUTHN_RP_ID")
+	if issuer, err := url.Parse(cfg.IssuerURL); cfg.WebAuthnRPID == "" && err == nil {
+		cfg.WebAuthnRPID = issuer.Hostname()
+	}
+	// Mail is delivered once an SMTP server is configured, and logged otherwise
+	defaultMailer := "log"
+	if cfg.SMTPHost != "" {
+		defaultMailer = "smtp"
+	}
+	cfg.Mailer = getEnv("MAILER", defaultMailer)
+	cfg.SMTPPort = cfg.getEnvAsInt("SMTP_PORT", 587)
+	cfg.SMTPInsecure = cfg.getEnvAsBool("SMTP_INSECURE", false)
+	cfg.VerifiedEmailOnly = cfg.getEnvAsBool("EMAIL_VERIFICATION_REQUIRED", false)
+	cfg.VerificationURL = getEnv("EMAIL_VERIFICATION_URL", cfg.IssuerURL+"/api/v1/auth/verify-email")
+	cfg.PasswordResetURL = getEnv("PASSWORD_RESET_URL", cfg.IssuerURL+"/api/v1/auth/password/reset")
+	cfg.PasswordResetTTL = cfg.getEnvAsInt("PASSWORD_RESET_TTL_MINUTES", 30)
+	cfg.ResetEmailLimit = cfg.getEnvAsInt("PASSWORD_RESET_EMAIL_LIMIT", 3)
+	cfg.ResetIPLimit = cfg.getEnvAsInt("PASSWORD_RESET_IP_LIMIT", 20)
+	cfg.PasswordHistory = cfg.getEnvAsInt("PASSWORD_HISTORY", 5)
+	cfg.PasswordHash = getEnv("PASSWORD_HASH_ALGORITHM", "argon2id")
+	cfg.Argon2Memory = cfg.getEnvAsInt("ARGON2_MEMORY_KIB", 19456)
+	cfg.Argon2Iterations = cfg.getEnvAsInt("ARGON2_ITERATIONS", 2)
+	cfg.Argon2Parallelism = cfg.getEnvAsInt("ARGON2_PARALLELISM", 1)
+	cfg.BcryptCost = cfg.getEnvAsInt("BCRYPT_COST", 12)
+	cfg.PasswordPepper = getEnv("PASSWORD_PEPPER", "")
+	cfg.FirebaseSignerKey = getEnv("FIREBASE_SCRYPT_SIGNER_KEY", "")
+	cfg.FirebaseSeparator = getEnv("FIREBASE_SCRYPT_SALT_SEPARATOR", "Bw==")
+	cfg.FirebaseRounds = cfg.getEnvAsInt("FIREBASE_SCRYPT_ROUNDS", 8)
+	cfg.FirebaseMemCost = cfg.getEnvAsInt("FIREBASE_SCRYPT_MEM_COST", 14)
+	cfg.PasswordMinLength = cfg.getEnvAsInt("PASSWORD_MIN_LENGTH", 8)
+	// bcrypt hashes passwords of up to 72 bytes
+	defaultMaxLength := 128
+	if cfg.PasswordHash == "bcrypt" {
+		defaultMaxLength = 72
+	}
+	cfg.PasswordMaxLength = cfg.getEnvAsInt("PASSWORD_MAX_LENGTH", defaultMaxLength)
+	cfg.PasswordClasses = splitList(getEnv("PASSWORD_REQUIRED_CLASSES", ""))
+	cfg.PasswordRepeat = cfg.getEnvAsInt("PASSWORD_MAX_REPEATED", 3)
+	cfg.PasswordNoPersonal = cfg.getEnvAsBool("PASSWORD_REJECT_PERSONAL_INFO", true)
+	cfg.PasswordNoCommon = cfg.getEnvAsBool("PASSWORD_REJECT_COMMON", true)
+	cfg.CommonPasswords = getEnv("COMMON_PASSWORDS_PATH", "")
+	cfg.PasswordEntropy = cfg.getEnvAsInt("PASSWORD_MIN_ENTROPY", 35)
+	cfg.BreachedPasswords = getEnv("BREACHED_PASSWORDS_PATH", "")
+	cfg.JWTExpiry = cfg.getEnvAsInt("JWT_EXPIRY_HOURS", 24)
+	cfg.RefreshExpiry = cfg.getEnvAsInt("REFRESH_TOKEN_EXPIRY_HOURS", 7*24)
+	cfg.JWTManagedKeys = cfg.getEnvAsBool("JWT_MANAGED_KEYS", false)
+	cfg.JWTKeyRotation = cfg.getEnvAsInt("JWT_KEY_ROTATION_HOURS", 30*24)
+	cfg.JWTKeyGrace = cfg.getEnvAsInt("JWT_KEY_GRACE_HOURS", 48)
+	cfg.DatabaseRequired = cfg.getEnvAsBool("DATABASE_REQUIRED", cfg.IsProduction())
+	cfg.AutoMigrate = cfg.getEnvAsBool("DB_AUTO_MIGRATE", false)
+
+	return cfg
+}
+
+// IsProduction reports whether the service runs in the production environment
+func (c *Config) IsProduction() bool {
+	return c.Environment == "production"
+}
+
+// EncryptionKeyBytes returns the key used to encrypt secrets at rest. Outside
+// production a key derived from JWT_SECRET is used when ENCRYPTION_KEY is unset.
+func (c *Config) EncryptionKeyBytes() ([]byte, error) {
+	if c.EncryptionKey == "" {
+		if c.IsProduction() {
+			return nil, errors.New("ENCRYPTION_KEY is not set")
+		}
+		sum := sha256.Sum256([]byte("encryption:" + c.JWTSecret))
+		return sum[:], nil
+	}
+	key, err := base64.StdEncoding.DecodeString(c.EncryptionKey)
+	if err != nil {
+		return nil, errors.New("ENCRYPTION_KEY must be base64 encoded")
+	}
+	if len(key) != 32 {
+		return nil, errors.New("ENCRYPTION_KEY must decode to 32 bytes")
+	}
+	return key, nil
+}
+
+// splitList splits a comma-separated list, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
 	}
+	return items
 }
 
 // getEnv gets an environment variable or returns a default value
@@ -41,11 +213,29 @@ func getEnv(key, defaultValue string) string {
 }
 
 // getEnvAsInt gets an environment variable as integer or returns a default value
-func getEnvAsInt(key string, defaultValue int) int {
-	if value := os.Getenv(key); value != "" {
-		if intValue, err := strconv.Atoi(value); err == nil {
-			return intValue
-		}
+func (c *Config) getEnvAsInt(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
 	}
-	return defaultValue
+	intValue, err := strconv.Atoi(value)
+	if err != nil {
+		c.parseErrors = append(c.parseErrors, FieldError{Key: key, Message: "must be an integer, got " + strconv.Quote(value)})
+		return defaultValue
+	}
+	return intValue
+}
+
+// getEnvAsBool gets an environment variable as boolean or returns a default value
+func (c *Config) getEnvAsBool(key string, defaultValue bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	boolValue, err := strconv.ParseBool(value)
+	if err != nil {
+		c.parseErrors = append(c.parseErrors, FieldError{Key: key, Message: "must be a boolean, got " + strconv.Quote(value)})
+		return defaultValue
+	}
+	return boolValue
 }
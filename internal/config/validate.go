@@ -0,0 +1,311 @@
+package config
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/goldcast/gc_auth_service/pkg/password"
+)
+
+// MinJWTSecretLength is the minimum accepted length of JWT_SECRET in bytes
+const MinJWTSecretLength = 32
+
+// weakSecrets are well-known placeholder secrets that must never reach production
+var weakSecrets = []string{
+	DefaultJWTSecret,
+	"your-super-secret-jwt-key-change-this-in-production",
+	"secret",
+	"changeme",
+}
+
+var (
+	validEnvironments  = []string{"development", "test", "staging", "production"}
+	validLogLevels     = []string{"debug", "info", "warn", "error"}
+	validKeyAlgorithms = []string{"RS256", "ES256", "ES384", "ES512", "EdDSA"}
+	validMailers       = []string{"smtp", "log", "memory"}
+	validCharClasses   = []string{"lower", "upper", "digit", "symbol"}
+	validHashes        = []string{"argon2id", "bcrypt"}
+	validRegistration  = []string{"authorization_code", "refresh_token", "client_credentials", "urn:ietf:params:oauth:grant-type:device_code"}
+)
+
+// FieldError describes a single invalid configuration value
+type FieldError struct {
+	Key     string `json:"key"`
+	Message string `json:"message"`
+}
+
+func (e FieldError) Error() string {
+	return e.Key + ": " + e.Message
+}
+
+// ValidationError collects every problem found in the configuration
+type ValidationError struct {
+	Errors []FieldError `json:"errors"`
+}
+
+func (e *ValidationError) Error() string {
+	var b strings.Builder
+	b.WriteString("invalid configuration:")
+	for _, fieldErr := range e.Errors {
+		b.WriteString("\n  - ")
+		b.WriteString(fieldErr.Error())
+	}
+	return b.String()
+}
+
+// Validate checks the configuration and returns a *ValidationError listing
+// every problem found, or nil if the configuration is usable
+func (c *Config) Validate() error {
+	errs := append([]FieldError(nil), c.parseErrors...)
+	add := func(key, format string, args ...interface{}) {
+		errs = append(errs, FieldError{Key: key, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if !contains(validEnvironments, c.Environment) {
+		add("ENVIRONMENT", "unknown environment %q, expected one of %s", c.Environment, strings.Join(validEnvironments, ", "))
+	}
+	if !contains(validLogLevels, c.LogLevel) {
+		add("LOG_LEVEL", "unknown log level %q, expected one of %s", c.LogLevel, strings.Join(validLogLevels, ", "))
+	}
+	if port, err := strconv.Atoi(c.Port); err != nil || port < 1 || port > 65535 {
+		add("PORT", "must be a port number between 1 and 65535, got %q", c.Port)
+	}
+	for _, proxy := range c.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			add("TRUSTED_PROXIES", "must be IP addresses or CIDR ranges, got %q", proxy)
+		}
+	}
+
+	if c.JWTExpiry <= 0 {
+		add("JWT_EXPIRY_HOURS", "must be positive, got %d", c.JWTExpiry)
+	}
+	if c.RefreshExpiry <= 0 {
+		add("REFRESH_TOKEN_EXPIRY_HOURS", "must be positive, got %d", c.RefreshExpiry)
+	}
+	switch {
+	case c.JWTManagedKeys:
+		if c.JWTKeyFile != "" {
+			add("JWT_SIGNING_KEY_FILE", "cannot be combined with JWT_MANAGED_KEYS")
+		}
+		if !contains(validKeyAlgorithms, c.JWTKeyAlgorithm) {
+			add("JWT_KEY_ALGORITHM", "unsupported algorithm %q, expected one of %s", c.JWTKeyAlgorithm, strings.Join(validKeyAlgorithms, ", "))
+		}
+		if c.JWTKeyRotation < 0 {
+			add("JWT_KEY_ROTATION_HOURS", "must not be negative, got %d", c.JWTKeyRotation)
+		}
+		if c.JWTKeyGrace < c.JWTExpiry {
+			add("JWT_KEY_GRACE_HOURS", "must be at least JWT_EXPIRY_HOURS (%d) so retired keys outlive the tokens they signed, got %d", c.JWTExpiry, c.JWTKeyGrace)
+		}
+	case c.JWTKeyFile != "":
+		if _, err := os.Stat(c.JWTKeyFile); err != nil {
+			add("JWT_SIGNING_KEY_FILE", "cannot read key file: %v", err)
+		}
+	default:
+		if len(c.JWTSecret) < MinJWTSecretLength {
+			add("JWT_SECRET", "must be at least %d bytes long, got %d", MinJWTSecretLength, len(c.JWTSecret))
+		}
+		if c.IsProduction() && isWeakSecret(c.JWTSecret) {
+			add("JWT_SECRET", "a default or weak secret cannot be used in production")
+		}
+	}
+
+	// TOTP secrets are always encrypted, so production needs a real key
+	if c.EncryptionKey != "" || c.IsProduction() {
+		if _, err := c.EncryptionKeyBytes(); err != nil {
+			add("ENCRYPTION_KEY", "%v", err)
+		}
+	}
+	if c.IsProduction() && c.AdminToken != "" && len(c.AdminToken) < MinJWTSecretLength {
+		add("ADMIN_API_TOKEN", "must be at least %d bytes long in production", MinJWTSecretLength)
+	}
+	if c.IsProduction() && c.RegistrationToken != "" && len(c.RegistrationToken) < MinJWTSecretLength {
+		add("OAUTH_REGISTRATION_TOKEN", "must be at least %d bytes long in production", MinJWTSecretLength)
+	}
+	// Token exchange is left out: it needs audiences only an operator can grant
+	for _, grant := range c.RegistrationGrants {
+		if !contains(validRegistration, grant) {
+			add("OAUTH_REGISTRATION_GRANT_TYPES", "must only contain %s, got %q", strings.Join(validRegistration, ", "), grant)
+		}
+	}
+
+	if issuer, err := url.Parse(c.IssuerURL); err != nil || (issuer.Scheme != "https" && issuer.Scheme != "http") || issuer.Host == "" || issuer.RawQuery != "" || issuer.Fragment != "" {
+		add("ISSUER_URL", "must be an absolute http(s) URL without query or fragment, got %q", c.IssuerURL)
+	} else if c.IsProduction() && issuer.Scheme != "https" {
+		add("ISSUER_URL", "must use https in production")
+	}
+
+	// Authenticator apps split the account label on the colon
+	if strings.Contains(c.TOTPIssuer, ":") {
+		add("TOTP_ISSUER", "must not contain a colon, got %q", c.TOTPIssuer)
+	}
+
+	if c.WebAuthnRPID == "" {
+		add("WEBAUTHN_RP_ID", "is required")
+	}
+	if len(c.WebAuthnOrigins) == 0 {
+		add("WEBAUTHN_RP_ORIGINS", "must list at least one origin")
+	}
+	for _, origin := range c.WebAuthnOrigins {
+		u, err := url.Parse(origin)
+		switch {
+		case err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" || u.Path != "" || u.RawQuery != "" || u.Fragment != "":
+			add("WEBAUTHN_RP_ORIGINS", "must be http(s) origins without a path, got %q", origin)
+		case c.IsProduction() && u.Scheme != "https":
+			add("WEBAUTHN_RP_ORIGINS", "must use https in production, got %q", origin)
+		case u.Hostname() != c.WebAuthnRPID && !strings.HasSuffix(u.Hostname(), "."+c.WebAuthnRPID):
+			// Browsers refuse relying party IDs that are not the origin's domain or a parent of it
+			add("WEBAUTHN_RP_ORIGINS", "origin %q is not within the relying party ID %q", origin, c.WebAuthnRPID)
+		}
+	}
+
+	switch {
+	case !contains(validMailers, c.Mailer):
+		add("MAILER", "unknown mailer %q, expected one of %s", c.Mailer, strings.Join(validMailers, ", "))
+	case c.Mailer == "smtp":
+		if c.SMTPHost == "" {
+			add("SMTP_HOST", "is required for the smtp mailer")
+		}
+		if c.SMTPPort < 1 || c.SMTPPort > 65535 {
+			add("SMTP_PORT", "must be a port number between 1 and 65535, got %d", c.SMTPPort)
+		}
+		if _, err := mail.ParseAddress(c.MailFrom); err != nil {
+			add("MAIL_FROM", "must be an email address such as \"GC Auth <no-reply@example.com>\", got %q", c.MailFrom)
+		}
+		if c.SMTPInsecure && c.IsProduction() {
+			add("SMTP_INSECURE", "mail cannot be sent without TLS in production")
+		}
+	case c.Mailer == "memory" && c.IsProduction():
+		// The in-memory mailbox is readable on the admin API
+		add("MAILER", "the memory mailer cannot be used in production")
+	}
+	links := []struct{ name, value string }{
+		{"EMAIL_VERIFICATION_URL", c.VerificationURL},
+		{"PASSWORD_RESET_URL", c.PasswordResetURL},
+	}
+	for _, l := range links {
+		if link, err := url.Parse(l.value); err != nil || (link.Scheme != "https" && link.Scheme != "http") || link.Host == "" || link.Fragment != "" {
+			add(l.name, "must be an absolute http(s) URL without fragment, got %q", l.value)
+		} else if c.IsProduction() && link.Scheme != "https" {
+			add(l.name, "must use https in production")
+		}
+	}
+	if c.PasswordResetTTL < 5 || c.PasswordResetTTL > 24*60 {
+		add("PASSWORD_RESET_TTL_MINUTES", "must be between 5 and 1440, got %d", c.PasswordResetTTL)
+	}
+	if c.PasswordHistory < 0 || c.PasswordHistory > 24 {
+		add("PASSWORD_HISTORY", "must be between 0 and 24, got %d", c.PasswordHistory)
+	}
+	switch {
+	case !contains(validHashes, c.PasswordHash):
+		add("PASSWORD_HASH_ALGORITHM", "unknown algorithm %q, expected one of %s", c.PasswordHash, strings.Join(validHashes, ", "))
+	case c.PasswordHash == "bcrypt" && c.PasswordPepper != "":
+		add("PASSWORD_PEPPER", "is only supported with PASSWORD_HASH_ALGORITHM=argon2id")
+	}
+	if c.Argon2Parallelism < 1 || c.Argon2Parallelism > password.MaxArgon2Parallelism {
+		add("ARGON2_PARALLELISM", "must be between 1 and %d, got %d", password.MaxArgon2Parallelism, c.Argon2Parallelism)
+	}
+	if c.Argon2Memory < 8*c.Argon2Parallelism || c.Argon2Memory > password.MaxArgon2Memory {
+		add("ARGON2_MEMORY_KIB", "must be between 8 times ARGON2_PARALLELISM and %d (1 GiB), got %d", password.MaxArgon2Memory, c.Argon2Memory)
+	}
+	if c.Argon2Iterations < 1 || c.Argon2Iterations > password.MaxArgon2Iterations {
+		add("ARGON2_ITERATIONS", "must be between 1 and %d, got %d", password.MaxArgon2Iterations, c.Argon2Iterations)
+	}
+	if c.BcryptCost < 4 || c.BcryptCost > password.MaxBcryptCost {
+		add("BCRYPT_COST", "must be between 4 and %d, got %d", password.MaxBcryptCost, c.BcryptCost)
+	}
+	if c.PasswordPepper != "" && len(c.PasswordPepper) < MinJWTSecretLength {
+		add("PASSWORD_PEPPER", "must be at least %d bytes long, got %d", MinJWTSecretLength, len(c.PasswordPepper))
+	}
+	if c.FirebaseSignerKey != "" {
+		if _, err := base64.StdEncoding.DecodeString(c.FirebaseSignerKey); err != nil {
+			add("FIREBASE_SCRYPT_SIGNER_KEY", "must be base64: %v", err)
+		}
+		if _, err := base64.StdEncoding.DecodeString(c.FirebaseSeparator); err != nil {
+			add("FIREBASE_SCRYPT_SALT_SEPARATOR", "must be base64: %v", err)
+		}
+		if c.FirebaseRounds < 1 || c.FirebaseRounds > 8 {
+			add("FIREBASE_SCRYPT_ROUNDS", "must be between 1 and 8, got %d", c.FirebaseRounds)
+		}
+		if c.FirebaseMemCost < 1 || c.FirebaseMemCost > 14 {
+			add("FIREBASE_SCRYPT_MEM_COST", "must be between 1 and 14, got %d", c.FirebaseMemCost)
+		}
+	}
+	// bcrypt hashes passwords of up to 72 bytes
+	maxLength := 1024
+	if c.PasswordHash == "bcrypt" {
+		maxLength = 72
+	}
+	if c.PasswordMinLength < 1 || c.PasswordMinLength > c.PasswordMaxLength {
+		add("PASSWORD_MIN_LENGTH", "must be between 1 and PASSWORD_MAX_LENGTH, got %d", c.PasswordMinLength)
+	}
+	if c.PasswordMaxLength < 1 || c.PasswordMaxLength > maxLength {
+		add("PASSWORD_MAX_LENGTH", "must be between 1 and %d with %s, got %d", maxLength, c.PasswordHash, c.PasswordMaxLength)
+	}
+	for _, class := range c.PasswordClasses {
+		if !contains(validCharClasses, class) {
+			add("PASSWORD_REQUIRED_CLASSES", "unknown character class %q, expected %s", class, strings.Join(validCharClasses, ", "))
+		}
+	}
+	if c.PasswordRepeat < 0 {
+		add("PASSWORD_MAX_REPEATED", "must not be negative, got %d", c.PasswordRepeat)
+	}
+	if c.PasswordEntropy < 0 || c.PasswordEntropy > 128 {
+		add("PASSWORD_MIN_ENTROPY", "must be between 0 and 128, got %d", c.PasswordEntropy)
+	}
+	if c.CommonPasswords != "" {
+		if _, err := os.Stat(c.CommonPasswords); err != nil {
+			add("COMMON_PASSWORDS_PATH", "cannot be read: %v", err)
+		}
+	}
+	if c.BreachedPasswords != "" {
+		if _, err := os.Stat(c.BreachedPasswords); err != nil {
+			add("BREACHED_PASSWORDS_PATH", "cannot be read: %v", err)
+		}
+	}
+	if c.ResetEmailLimit < 1 {
+		add("PASSWORD_RESET_EMAIL_LIMIT", "must be positive, got %d", c.ResetEmailLimit)
+	}
+	if c.ResetIPLimit < 1 {
+		add("PASSWORD_RESET_IP_LIMIT", "must be positive, got %d", c.ResetIPLimit)
+	}
+
+	if c.DatabaseRequired && c.DatabaseURL == "" {
+		add("DATABASE_URL", "is required when DATABASE_REQUIRED is enabled (the default in production)")
+	}
+
+	if len(errs) > 0 {
+		return &ValidationError{Errors: errs}
+	}
+	return nil
+}
+
+// isWeakSecret reports whether a secret is a known placeholder or has too
+// little variety to resist guessing
+func isWeakSecret(secret string) bool {
+	for _, weak := range weakSecrets {
+		if strings.EqualFold(secret, weak) {
+			return true
+		}
+	}
+
+	distinct := make(map[rune]struct{})
+	for _, r := range secret {
+		distinct[r] = struct{}{}
+	}
+	return len(distinct) < 10
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
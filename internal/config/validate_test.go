@@ -0,0 +1,136 @@
+package config
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// load returns the configuration read from the given environment variables
+func load(t *testing.T, env map[string]string) *Config {
+	t.Helper()
+	for key, value := range env {
+		t.Setenv(key, value)
+	}
+	return Load()
+}
+
+// invalidKeys returns the keys Validate reports, in order
+func invalidKeys(t *testing.T, cfg *Config) []string {
+	t.Helper()
+	err := cfg.Validate()
+	if err == nil {
+		return nil
+	}
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("validate: %v, want a ValidationError", err)
+	}
+	keys := make([]string, len(validationErr.Errors))
+	for i, fieldErr := range validationErr.Errors {
+		keys[i] = fieldErr.Key
+	}
+	return keys
+}
+
+// production are settings that pass validation in production
+var production = map[string]string{
+	"ENVIRONMENT":    "production",
+	"JWT_SECRET":     "q8Zr2LwX9vTn4Kp7Hs1Mc6Yb3Jf5Gd0A",
+	"ENCRYPTION_KEY": "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=",
+	"ISSUER_URL":     "https://auth.example.com",
+	"DATABASE_URL":   "postgres://auth@db.example.com/auth",
+}
+
+func TestValidateDefaults(t *testing.T) {
+	if err := load(t, nil).Validate(); err != nil {
+		t.Errorf("development defaults: %v", err)
+	}
+	if err := load(t, production).Validate(); err != nil {
+		t.Errorf("production settings: %v", err)
+	}
+}
+
+func TestValidateProductionGuards(t *testing.T) {
+	// The development defaults are refused in production
+	cfg := load(t, map[string]string{"ENVIRONMENT": "production"})
+	want := []string{"JWT_SECRET", "ENCRYPTION_KEY", "ISSUER_URL", "WEBAUTHN_RP_ORIGINS", "EMAIL_VERIFICATION_URL", "PASSWORD_RESET_URL", "DATABASE_URL"}
+	if got := invalidKeys(t, cfg); !slices.Equal(got, want) {
+		t.Errorf("invalid keys %v, want %v", got, want)
+	}
+
+	for name, env := range map[string]map[string]string{
+		"JWT_SECRET":          {"JWT_SECRET": "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"},
+		"ADMIN_API_TOKEN":     {"ADMIN_API_TOKEN": "short"},
+		"SMTP_INSECURE":       {"SMTP_HOST": "smtp.example.com", "MAIL_FROM": "no-reply@example.com", "SMTP_INSECURE": "true"},
+		"MAILER":              {"MAILER": "memory"},
+		"WEBAUTHN_RP_ORIGINS": {"WEBAUTHN_RP_ORIGINS": "http://auth.example.com"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			for key, value := range production {
+				t.Setenv(key, value)
+			}
+			if got := invalidKeys(t, load(t, env)); !slices.Equal(got, []string{name}) {
+				t.Errorf("invalid keys %v, want %s", got, name)
+			}
+		})
+	}
+}
+
+func TestValidateValues(t *testing.T) {
+	tests := []struct {
+		key string
+		env map[string]string
+	}{
+		{"ENVIRONMENT", map[string]string{"ENVIRONMENT": "prod"}},
+		{"LOG_LEVEL", map[string]string{"LOG_LEVEL": "verbose"}},
+		{"PORT", map[string]string{"PORT": "80000"}},
+		{"TRUSTED_PROXIES", map[string]string{"TRUSTED_PROXIES": "10.0.0.0/8, proxy"}},
+		{"JWT_EXPIRY_HOURS", map[string]string{"JWT_EXPIRY_HOURS": "one"}},
+		{"JWT_MANAGED_KEYS", map[string]string{"JWT_MANAGED_KEYS": "maybe"}},
+		{"JWT_SECRET", map[string]string{"JWT_SECRET": "too-short"}},
+		{"JWT_KEY_GRACE_HOURS", map[string]string{"JWT_MANAGED_KEYS": "true", "JWT_KEY_GRACE_HOURS": "12"}},
+		{"JWT_SIGNING_KEY_FILE", map[string]string{"JWT_SIGNING_KEY_FILE": "/nonexistent/key.pem"}},
+		{"ENCRYPTION_KEY", map[string]string{"ENCRYPTION_KEY": "c2hvcnQ="}},
+		{"OAUTH_REGISTRATION_GRANT_TYPES", map[string]string{"OAUTH_REGISTRATION_GRANT_TYPES": "authorization_code password"}},
+		{"ISSUER_URL", map[string]string{"ISSUER_URL": "https://auth.example.com/?tenant=1", "WEBAUTHN_RP_ORIGINS": "https://auth.example.com"}},
+		{"TOTP_ISSUER", map[string]string{"TOTP_ISSUER": "GC:Auth"}},
+		{"WEBAUTHN_RP_ORIGINS", map[string]string{"WEBAUTHN_RP_ORIGINS": "http://localhost:8080,https://example.org"}},
+		{"SMTP_HOST", map[string]string{"MAILER": "smtp", "MAIL_FROM": "no-reply@example.com"}},
+		{"PASSWORD_RESET_TTL_MINUTES", map[string]string{"PASSWORD_RESET_TTL_MINUTES": "2"}},
+		{"PASSWORD_HISTORY", map[string]string{"PASSWORD_HISTORY": "25"}},
+		{"PASSWORD_HASH_ALGORITHM", map[string]string{"PASSWORD_HASH_ALGORITHM": "scrypt"}},
+		{"PASSWORD_PEPPER", map[string]string{"PASSWORD_PEPPER": "short"}},
+		{"ARGON2_MEMORY_KIB", map[string]string{"ARGON2_MEMORY_KIB": "4194304"}},
+		{"ARGON2_ITERATIONS", map[string]string{"ARGON2_ITERATIONS": "11"}},
+		{"BCRYPT_COST", map[string]string{"BCRYPT_COST": "17"}},
+		{"FIREBASE_SCRYPT_MEM_COST", map[string]string{"FIREBASE_SCRYPT_SIGNER_KEY": "c2lnbmVy", "FIREBASE_SCRYPT_MEM_COST": "15"}},
+		{"PASSWORD_MAX_LENGTH", map[string]string{"PASSWORD_HASH_ALGORITHM": "bcrypt", "PASSWORD_MAX_LENGTH": "100"}},
+		{"PASSWORD_REQUIRED_CLASSES", map[string]string{"PASSWORD_REQUIRED_CLASSES": "lower,emoji"}},
+		{"PASSWORD_MIN_ENTROPY", map[string]string{"PASSWORD_MIN_ENTROPY": "200"}},
+		{"COMMON_PASSWORDS_PATH", map[string]string{"COMMON_PASSWORDS_PATH": filepath.Join(t.TempDir(), "missing.txt")}},
+		{"BREACHED_PASSWORDS_PATH", map[string]string{"BREACHED_PASSWORDS_PATH": filepath.Join(t.TempDir(), "missing.bloom")}},
+		{"DATABASE_URL", map[string]string{"DATABASE_REQUIRED": "true"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if got := invalidKeys(t, load(t, tt.env)); !slices.Equal(got, []string{tt.key}) {
+				t.Errorf("invalid keys %v, want %s", got, tt.key)
+			}
+		})
+	}
+}
+
+func TestValidationErrorListsEveryProblem(t *testing.T) {
+	err := load(t, map[string]string{"PORT": "0", "LOG_LEVEL": "loud", "BCRYPT_COST": "x"}).Validate()
+	if err == nil {
+		t.Fatal("invalid configuration validated")
+	}
+	for _, want := range []string{"invalid configuration:", "PORT:", "LOG_LEVEL:", `BCRYPT_COST: must be an integer, got "x"`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not contain %q", err, want)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	// Register the PostgreSQL and SQLite drivers
+	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
+)
+
+// Dialect identifies the SQL backend behind a connection
+type Dialect string
+
+const (
+	// Postgres is the production backend
+	Postgres Dialect = "postgres"
+	// SQLite is an embedded backend used for tests and local development
+	SQLite Dialect = "sqlite"
+)
+
+// DB wraps a database connection pool together with its dialect
+type DB struct {
+	*sql.DB
+	Dialect Dialect
+}
+
+// Open connects to the database at the given URL and verifies the connection.
+// URLs starting with sqlite: select the embedded SQLite backend, e.g.
+// sqlite://data/auth.db or sqlite::memory:; anything else is handed to PostgreSQL.
+func Open(databaseURL string) (*DB, error) {
+	dialect, driver, dsn := parseURL(databaseURL)
+
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open database: %w", err)
+	}
+
+	if dialect == SQLite {
+		// SQLite allows a single writer; serialising access through one
+		// connection avoids SQLITE_BUSY and keeps in-memory databases shared
+		db.SetMaxOpenConns(1)
+	} else {
+		db.SetMaxOpenConns(25)
+		db.SetMaxIdleConns(5)
+		db.SetConnMaxLifetime(30 * time.Minute)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("ping database: %w", err)
+	}
+
+	return &DB{DB: db, Dialect: dialect}, nil
+}
+
+// parseURL maps a database URL to its dialect, driver name and driver DSN
+func parseURL(databaseURL string) (Dialect, string, string) {
+	if !strings.HasPrefix(databaseURL, "sqlite:") {
+		return Postgres, "postgres", databaseURL
+	}
+
+	path := strings.TrimPrefix(databaseURL, "sqlite:")
+	path = strings.TrimPrefix(path, "//")
+	if path == "" || path == ":memory:" {
+		path = ":memory:"
+	}
+
+	dsn := "file:" + path + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_time_format=sqlite"
+	return SQLite, "sqlite", dsn
+}
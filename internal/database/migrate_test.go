@@ -0,0 +1,253 @@
+package database
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func openMemory(t *testing.T) *DB {
+	t.Helper()
+	db, err := Open("sqlite::memory:")
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// tables lists the tables of a SQLite database other than its internal ones
+func tables(t *testing.T, db *DB) []string {
+	t.Helper()
+	rows, err := db.Query(`SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name`)
+	if err != nil {
+		t.Fatalf("list tables: %v", err)
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatalf("scan table name: %v", err)
+		}
+		names = append(names, name)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatalf("list tables: %v", err)
+	}
+	return names
+}
+
+// schema returns the definitions of a SQLite database's tables and indexes
+func schema(t *testing.T, db *DB) []string {
+	t.Helper()
+	rows, err := db.Query(`SELECT type || ' ' || name || ': ' || COALESCE(sql, '') FROM sqlite_master WHERE name NOT LIKE 'sqlite_%' ORDER BY type, name`)
+	if err != nil {
+		t.Fatalf("read schema: %v", err)
+	}
+	defer rows.Close()
+
+	var definitions []string
+	for rows.Next() {
+		var definition string
+		if err := rows.Scan(&definition); err != nil {
+			t.Fatalf("scan schema: %v", err)
+		}
+		definitions = append(definitions, definition)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatalf("read schema: %v", err)
+	}
+	return definitions
+}
+
+func TestLoadMigrations(t *testing.T) {
+	byDialect := make(map[Dialect][]Migration)
+	for _, dialect := range []Dialect{SQLite, Postgres} {
+		migrations, err := loadMigrations(string(dialect))
+		if err != nil {
+			t.Fatalf("load %s migrations: %v", dialect, err)
+		}
+		if len(migrations) == 0 {
+			t.Fatalf("no %s migrations", dialect)
+		}
+		for i, migration := range migrations {
+			if migration.Version != i+1 {
+				t.Errorf("%s migration %d has version %d, want %d", dialect, i, migration.Version, i+1)
+			}
+			if migration.Up == "" || migration.Down == "" {
+				t.Errorf("%s migration %04d_%s is missing its up or down script", dialect, migration.Version, migration.Name)
+			}
+		}
+		byDialect[dialect] = migrations
+	}
+
+	sqlite, postgres := byDialect[SQLite], byDialect[Postgres]
+	if len(sqlite) != len(postgres) {
+		t.Fatalf("sqlite has %d migrations, postgres %d", len(sqlite), len(postgres))
+	}
+	for i := range sqlite {
+		if sqlite[i].Name != postgres[i].Name {
+			t.Errorf("migration %04d is %q on sqlite but %q on postgres", sqlite[i].Version, sqlite[i].Name, postgres[i].Name)
+		}
+	}
+}
+
+func TestMigrateUpDown(t *testing.T) {
+	ctx := context.Background()
+	db := openMemory(t)
+	migrator, err := NewMigrator(db)
+	if err != nil {
+		t.Fatalf("new migrator: %v", err)
+	}
+	all := migrator.Migrations()
+
+	applied, err := migrator.Up(ctx)
+	if err != nil {
+		t.Fatalf("up: %v", err)
+	}
+	if len(applied) != len(all) {
+		t.Fatalf("up applied %d migrations, want %d", len(applied), len(all))
+	}
+	for _, table := range []string{"users", "sessions", "refresh_tokens", "oauth_clients", "schema_migrations"} {
+		if !slices.Contains(tables(t, db), table) {
+			t.Errorf("table %s missing after up", table)
+		}
+	}
+
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	for _, status := range statuses {
+		if status.AppliedAt == nil {
+			t.Errorf("migration %04d_%s not reported as applied", status.Version, status.Name)
+		}
+	}
+
+	applied, err = migrator.Up(ctx)
+	if err != nil {
+		t.Fatalf("second up: %v", err)
+	}
+	if len(applied) != 0 {
+		t.Errorf("second up applied %d migrations, want none", len(applied))
+	}
+
+	reverted, err := migrator.Down(ctx, len(all))
+	if err != nil {
+		t.Fatalf("down: %v", err)
+	}
+	if len(reverted) != len(all) {
+		t.Fatalf("down reverted %d migrations, want %d", len(reverted), len(all))
+	}
+	if reverted[0].Version != all[len(all)-1].Version {
+		t.Errorf("down started with migration %04d, want the latest %04d", reverted[0].Version, all[len(all)-1].Version)
+	}
+	if got := tables(t, db); !slices.Equal(got, []string{"schema_migrations"}) {
+		t.Errorf("tables left after down: %v", got)
+	}
+
+	statuses, err = migrator.Status(ctx)
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	for _, status := range statuses {
+		if status.AppliedAt != nil {
+			t.Errorf("migration %04d_%s still reported as applied", status.Version, status.Name)
+		}
+	}
+
+	// A clean revert leaves nothing in the way of migrating again
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("up after down: %v", err)
+	}
+}
+
+// TestMigrateDownSteps reverts an increasing number of migrations and
+// reapplies them, so that every down script is run against the schema it
+// follows and must leave nothing behind that gets in the way of its up script
+func TestMigrateDownSteps(t *testing.T) {
+	ctx := context.Background()
+	db := openMemory(t)
+	migrator, err := NewMigrator(db)
+	if err != nil {
+		t.Fatalf("new migrator: %v", err)
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("up: %v", err)
+	}
+	want := schema(t, db)
+
+	all := migrator.Migrations()
+	for steps := 1; steps <= len(all); steps++ {
+		reverted, err := migrator.Down(ctx, steps)
+		if err != nil {
+			t.Fatalf("down %d: %v", steps, err)
+		}
+		if len(reverted) != steps {
+			t.Fatalf("down %d reverted %d migrations", steps, len(reverted))
+		}
+		for i, migration := range reverted {
+			if wantVersion := all[len(all)-1-i].Version; migration.Version != wantVersion {
+				t.Fatalf("down %d reverted %04d as its step %d, want %04d", steps, migration.Version, i+1, wantVersion)
+			}
+		}
+
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			t.Fatalf("up after down %d: %v", steps, err)
+		}
+		if len(applied) != steps {
+			t.Fatalf("up after down %d applied %d migrations", steps, len(applied))
+		}
+		if got := schema(t, db); !slices.Equal(got, want) {
+			t.Fatalf("schema after down %d and up differs:\ngot  %v\nwant %v", steps, got, want)
+		}
+	}
+}
+
+// TestEmailVerifiedBackfill checks that users created before email
+// verification existed count as verified, unlike those created after
+func TestEmailVerifiedBackfill(t *testing.T) {
+	ctx := context.Background()
+	db := openMemory(t)
+	migrator, err := NewMigrator(db)
+	if err != nil {
+		t.Fatalf("new migrator: %v", err)
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("up: %v", err)
+	}
+	var before int
+	for _, migration := range migrator.Migrations() {
+		if migration.Name == "add_email_verification" {
+			before = len(migrator.Migrations()) - migration.Version + 1
+		}
+	}
+	if _, err := migrator.Down(ctx, before); err != nil {
+		t.Fatalf("down to before email verification: %v", err)
+	}
+
+	insert := `INSERT INTO users (id, email, username, password, first_name, last_name, created_at, updated_at)
+		VALUES ($1, $2, $3, 'hash', 'First', 'Last', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)`
+	if _, err := db.ExecContext(ctx, insert, "existing", "existing@example.com", "existing"); err != nil {
+		t.Fatalf("insert existing user: %v", err)
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("up: %v", err)
+	}
+	if _, err := db.ExecContext(ctx, insert, "new", "new@example.com", "new"); err != nil {
+		t.Fatalf("insert new user: %v", err)
+	}
+
+	for id, want := range map[string]bool{"existing": true, "new": false} {
+		var verified bool
+		if err := db.QueryRowContext(ctx, `SELECT email_verified FROM users WHERE id = $1`, id).Scan(&verified); err != nil {
+			t.Fatalf("read %s user: %v", id, err)
+		}
+		if verified != want {
+			t.Errorf("%s user email_verified = %v, want %v", id, verified, want)
+		}
+	}
+}
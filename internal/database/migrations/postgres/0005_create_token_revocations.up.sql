@@ -0,0 +1,11 @@
+CREATE TABLE revoked_tokens (
+    jti TEXT PRIMARY KEY,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX revoked_tokens_expires_at_idx ON revoked_tokens (expires_at);
+
+CREATE TABLE user_token_revocations (
+    user_id UUID PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    revoked_before TIMESTAMPTZ NOT NULL
+);
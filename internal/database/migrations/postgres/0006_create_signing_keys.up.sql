@@ -0,0 +1,13 @@
+CREATE TABLE signing_keys (
+    kid TEXT PRIMARY KEY,
+    algorithm TEXT NOT NULL,
+    private_key TEXT NOT NULL,
+    status TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    retired_at TIMESTAMPTZ,
+    expires_at TIMESTAMPTZ
+);
+
+-- At most one key may be active, which makes concurrent rotations from
+-- several instances fail instead of producing two active keys
+CREATE UNIQUE INDEX signing_keys_single_active_idx ON signing_keys (status) WHERE status = 'active';
@@ -0,0 +1,11 @@
+CREATE TABLE oauth_clients (
+    id TEXT PRIMARY KEY,
+    secret_hash TEXT NOT NULL DEFAULT '',
+    name TEXT NOT NULL,
+    redirect_uris JSONB NOT NULL DEFAULT '[]',
+    grant_types JSONB NOT NULL DEFAULT '[]',
+    scopes JSONB NOT NULL DEFAULT '[]',
+    token_endpoint_auth_method TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
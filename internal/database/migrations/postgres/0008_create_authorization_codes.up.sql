@@ -0,0 +1,17 @@
+CREATE TABLE authorization_codes (
+    id UUID PRIMARY KEY,
+    code_hash TEXT NOT NULL,
+    client_id TEXT NOT NULL REFERENCES oauth_clients (id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    redirect_uri TEXT NOT NULL,
+    scope TEXT NOT NULL DEFAULT '',
+    code_challenge TEXT NOT NULL,
+    code_challenge_method TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ,
+    session_id UUID REFERENCES sessions (id) ON DELETE SET NULL,
+    CONSTRAINT authorization_codes_code_hash_key UNIQUE (code_hash)
+);
+
+CREATE INDEX authorization_codes_expires_at_idx ON authorization_codes (expires_at);
@@ -0,0 +1,21 @@
+CREATE TABLE device_codes (
+    id UUID PRIMARY KEY,
+    device_code_hash TEXT NOT NULL,
+    user_code TEXT NOT NULL,
+    client_id TEXT NOT NULL REFERENCES oauth_clients (id) ON DELETE CASCADE,
+    scope TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL,
+    user_id UUID REFERENCES users (id) ON DELETE CASCADE,
+    auth_time TIMESTAMPTZ,
+    amr JSONB NOT NULL DEFAULT '[]',
+    poll_interval INTEGER NOT NULL,
+    last_polled_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ,
+    session_id UUID REFERENCES sessions (id) ON DELETE SET NULL,
+    CONSTRAINT device_codes_device_code_hash_key UNIQUE (device_code_hash),
+    CONSTRAINT device_codes_user_code_key UNIQUE (user_code)
+);
+
+CREATE INDEX device_codes_expires_at_idx ON device_codes (expires_at);
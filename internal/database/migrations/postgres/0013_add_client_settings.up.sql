@@ -0,0 +1,5 @@
+ALTER TABLE oauth_clients ADD COLUMN access_token_ttl INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE oauth_clients ADD COLUMN refresh_token_ttl INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE oauth_clients ADD COLUMN audience JSONB NOT NULL DEFAULT '[]';
+ALTER TABLE oauth_clients ADD COLUMN previous_secret_hash TEXT NOT NULL DEFAULT '';
+ALTER TABLE oauth_clients ADD COLUMN previous_secret_expires_at TIMESTAMPTZ;
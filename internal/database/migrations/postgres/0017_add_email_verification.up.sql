@@ -0,0 +1,18 @@
+ALTER TABLE users ADD COLUMN email_verified BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Users who signed up before addresses were verified could not verify theirs,
+-- so they are treated as verified rather than locked out when verification
+-- is required
+UPDATE users SET email_verified = TRUE;
+
+CREATE TABLE email_verifications (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    email TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ
+);
+
+CREATE INDEX email_verifications_user_id_idx ON email_verifications (user_id);
+CREATE INDEX email_verifications_expires_at_idx ON email_verifications (expires_at);
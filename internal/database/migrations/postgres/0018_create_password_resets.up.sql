@@ -0,0 +1,13 @@
+CREATE TABLE password_resets (
+    id UUID PRIMARY KEY,
+    token_hash TEXT NOT NULL,
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    email TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ,
+    CONSTRAINT password_resets_token_hash_key UNIQUE (token_hash)
+);
+
+CREATE INDEX password_resets_user_id_idx ON password_resets (user_id);
+CREATE INDEX password_resets_expires_at_idx ON password_resets (expires_at);
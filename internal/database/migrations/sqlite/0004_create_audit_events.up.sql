@@ -0,0 +1,12 @@
+CREATE TABLE audit_events (
+    id TEXT PRIMARY KEY,
+    user_id TEXT REFERENCES users (id) ON DELETE SET NULL,
+    event_type TEXT NOT NULL,
+    ip_address TEXT NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    metadata TEXT NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX audit_events_user_id_created_at_idx ON audit_events (user_id, created_at);
+CREATE INDEX audit_events_event_type_idx ON audit_events (event_type);
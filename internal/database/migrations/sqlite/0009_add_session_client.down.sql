@@ -0,0 +1,2 @@
+ALTER TABLE sessions DROP COLUMN scope;
+ALTER TABLE sessions DROP COLUMN client_id;
@@ -0,0 +1,2 @@
+ALTER TABLE sessions ADD COLUMN client_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE sessions ADD COLUMN scope TEXT NOT NULL DEFAULT '';
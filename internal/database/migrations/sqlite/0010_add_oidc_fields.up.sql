@@ -0,0 +1,8 @@
+ALTER TABLE sessions ADD COLUMN auth_time TIMESTAMP;
+UPDATE sessions SET auth_time = created_at;
+ALTER TABLE sessions ADD COLUMN amr TEXT NOT NULL DEFAULT '[]';
+
+ALTER TABLE authorization_codes ADD COLUMN nonce TEXT NOT NULL DEFAULT '';
+ALTER TABLE authorization_codes ADD COLUMN auth_time TIMESTAMP;
+UPDATE authorization_codes SET auth_time = created_at;
+ALTER TABLE authorization_codes ADD COLUMN amr TEXT NOT NULL DEFAULT '[]';
@@ -0,0 +1 @@
+ALTER TABLE oauth_clients ADD COLUMN jwks TEXT;
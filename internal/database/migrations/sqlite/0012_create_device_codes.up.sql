@@ -0,0 +1,21 @@
+CREATE TABLE device_codes (
+    id TEXT PRIMARY KEY,
+    device_code_hash TEXT NOT NULL,
+    user_code TEXT NOT NULL,
+    client_id TEXT NOT NULL REFERENCES oauth_clients (id) ON DELETE CASCADE,
+    scope TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL,
+    user_id TEXT REFERENCES users (id) ON DELETE CASCADE,
+    auth_time TIMESTAMP,
+    amr TEXT NOT NULL DEFAULT '[]',
+    poll_interval INTEGER NOT NULL,
+    last_polled_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    session_id TEXT REFERENCES sessions (id) ON DELETE SET NULL,
+    CONSTRAINT device_codes_device_code_hash_key UNIQUE (device_code_hash),
+    CONSTRAINT device_codes_user_code_key UNIQUE (user_code)
+);
+
+CREATE INDEX device_codes_expires_at_idx ON device_codes (expires_at);
@@ -0,0 +1,5 @@
+ALTER TABLE oauth_clients DROP COLUMN previous_secret_expires_at;
+ALTER TABLE oauth_clients DROP COLUMN previous_secret_hash;
+ALTER TABLE oauth_clients DROP COLUMN audience;
+ALTER TABLE oauth_clients DROP COLUMN refresh_token_ttl;
+ALTER TABLE oauth_clients DROP COLUMN access_token_ttl;
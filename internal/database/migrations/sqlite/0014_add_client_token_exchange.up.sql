@@ -0,0 +1 @@
+ALTER TABLE oauth_clients ADD COLUMN token_exchange_audiences TEXT NOT NULL DEFAULT '[]';
@@ -0,0 +1,2 @@
+DROP TABLE webauthn_sessions;
+DROP TABLE passkeys;
@@ -0,0 +1,33 @@
+CREATE TABLE passkeys (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    credential_id TEXT NOT NULL,
+    public_key TEXT NOT NULL,
+    attestation_type TEXT NOT NULL,
+    aaguid TEXT NOT NULL,
+    sign_count BIGINT NOT NULL DEFAULT 0,
+    transports TEXT NOT NULL DEFAULT '[]',
+    user_verified BOOLEAN NOT NULL DEFAULT FALSE,
+    backup_eligible BOOLEAN NOT NULL DEFAULT FALSE,
+    backup_state BOOLEAN NOT NULL DEFAULT FALSE,
+    name TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    last_used_at TIMESTAMP,
+    CONSTRAINT passkeys_credential_id_key UNIQUE (credential_id)
+);
+
+CREATE INDEX passkeys_user_id_idx ON passkeys (user_id);
+
+CREATE TABLE webauthn_sessions (
+    id TEXT PRIMARY KEY,
+    token_hash TEXT NOT NULL,
+    user_id TEXT REFERENCES users (id) ON DELETE CASCADE,
+    ceremony TEXT NOT NULL,
+    data TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    CONSTRAINT webauthn_sessions_token_hash_key UNIQUE (token_hash)
+);
+
+CREATE INDEX webauthn_sessions_expires_at_idx ON webauthn_sessions (expires_at);
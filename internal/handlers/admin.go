@@ -0,0 +1,382 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/goldcast/gc_auth_service/internal/app"
+	"github.com/goldcast/gc_auth_service/internal/auth"
+	"github.com/goldcast/gc_auth_service/internal/models"
+	"github.com/goldcast/gc_auth_service/internal/oauth"
+	"github.com/goldcast/gc_auth_service/internal/repository"
+	"github.com/goldcast/gc_auth_service/pkg/logger"
+	"github.com/goldcast/gc_auth_service/pkg/mailer"
+)
+
+// AdminHandler handles operator requests on the admin API
+type AdminHandler struct {
+	logger    *logger.Logger
+	validator *validator.Validate
+	keys      *auth.KeyManager
+	clients   *oauth.ClientRegistry
+	mailbox   *mailer.MemoryMailer
+	importer  *auth.UserImporter
+	audit     *auth.AuditLog
+}
+
+// maxImportSize is the size in bytes of the largest user import file the
+// admin API accepts; larger files can be imported with the import-users
+// command
+const maxImportSize = 32 << 20
+
+// NewAdminHandler creates a new admin handler
+func NewAdminHandler(c *app.Container) *AdminHandler {
+	return &AdminHandler{
+		logger:    c.Logger,
+		validator: validator.New(),
+		keys:      c.Keys,
+		clients:   c.ClientRegistry,
+		mailbox:   c.Mailbox,
+		importer:  c.Importer,
+		audit:     c.Audit,
+	}
+}
+
+// ListKeys returns the managed signing keys
+func (h *AdminHandler) ListKeys(c *gin.Context) {
+	if !h.requireManagedKeys(c) {
+		return
+	}
+
+	keys, err := h.keys.List(c.Request.Context())
+	if err != nil {
+		h.logger.WithField("error", err.Error()).Error("Failed to list signing keys")
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "Internal server error",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Signing keys retrieved successfully",
+		Data:    keys,
+	})
+}
+
+// RotateKey activates a new signing key and retires the current one
+func (h *AdminHandler) RotateKey(c *gin.Context) {
+	if !h.requireManagedKeys(c) {
+		return
+	}
+
+	key, err := h.keys.Rotate(c.Request.Context())
+	if err != nil {
+		if errors.Is(err, repository.ErrConflict) {
+			c.JSON(http.StatusConflict, models.APIResponse{
+				Success: false,
+				Message: "Signing key was rotated concurrently",
+			})
+			return
+		}
+		h.logger.WithField("error", err.Error()).Error("Failed to rotate signing key")
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "Internal server error",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Signing key rotated successfully",
+		Data:    key,
+	})
+}
+
+// requireManagedKeys rejects key management requests when keys are not managed by the service
+func (h *AdminHandler) requireManagedKeys(c *gin.Context) bool {
+	if h.keys != nil {
+		return true
+	}
+	c.JSON(http.StatusNotFound, models.APIResponse{
+		Success: false,
+		Message: "Managed signing keys are not enabled",
+	})
+	return false
+}
+
+// ListMail returns the messages the in-memory mailer sent to the address in
+// the to query parameter, newest first
+func (h *AdminHandler) ListMail(c *gin.Context) {
+	if h.mailbox == nil {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Message: "The in-memory mailer is not enabled",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Mail retrieved successfully",
+		Data:    h.mailbox.Messages(c.Query("to")),
+	})
+}
+
+// ImportUsers creates users from a CSV or JSONL file in the request body,
+// keeping their password hashes. The format is given by the format query
+// parameter or the content type, and dry_run=true checks the file without
+// creating any user. The response reports the outcome of every row.
+func (h *AdminHandler) ImportUsers(c *gin.Context) {
+	format := c.Query("format")
+	if format == "" {
+		switch c.ContentType() {
+		case "text/csv":
+			format = auth.ImportCSV
+		case "application/x-ndjson", "application/jsonl":
+			format = auth.ImportJSONL
+		}
+	}
+	if format != auth.ImportCSV && format != auth.ImportJSONL {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "Invalid import format",
+			Error:   "format must be csv or jsonl, or the content type text/csv or application/x-ndjson",
+		})
+		return
+	}
+	dryRun := false
+	if value := c.Query("dry_run"); value != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(value); err != nil {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false,
+				Message: "Invalid request",
+				Error:   "dry_run must be true or false",
+			})
+			return
+		}
+	}
+
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize)
+	report, err := h.importer.Import(c.Request.Context(), body, format, dryRun)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, models.APIResponse{
+				Success: false,
+				Message: "Import file is too large",
+			})
+			return
+		}
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "Invalid import file",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	h.audit.Record(c.Request.Context(), &models.AuditEvent{
+		Type:      "users_imported",
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		Metadata: map[string]interface{}{
+			"format":       format,
+			"dry_run":      report.DryRun,
+			"total":        report.Total,
+			"created":      report.Created,
+			"would_create": report.WouldCreate,
+			"skipped":      report.Skipped,
+			"failed":       report.Failed,
+		},
+	})
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Users imported",
+		Data:    report,
+	})
+}
+
+// ListClients returns all registered OAuth clients
+func (h *AdminHandler) ListClients(c *gin.Context) {
+	clients, err := h.clients.List(c.Request.Context())
+	if err != nil {
+		h.clientError(c, err, "Failed to list clients")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Clients retrieved successfully",
+		Data:    clients,
+	})
+}
+
+// CreateClient registers an OAuth client. A generated secret is returned
+// once in the response.
+func (h *AdminHandler) CreateClient(c *gin.Context) {
+	var req oauth.ClientConfig
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "Invalid request payload",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	client, secret, err := h.clients.Create(c.Request.Context(), req)
+	if err != nil {
+		h.clientError(c, err, "Failed to create client")
+		return
+	}
+
+	h.logger.WithField("client_id", client.ID).Info("OAuth client created")
+
+	c.JSON(http.StatusCreated, models.APIResponse{
+		Success: true,
+		Message: "Client created successfully",
+		Data:    models.ClientResponse{Client: client, ClientSecret: secret},
+	})
+}
+
+// GetClient returns a single OAuth client
+func (h *AdminHandler) GetClient(c *gin.Context) {
+	client, err := h.clients.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		h.clientError(c, err, "Failed to fetch client")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Client retrieved successfully",
+		Data:    client,
+	})
+}
+
+// UpdateClient replaces the settings of an OAuth client
+func (h *AdminHandler) UpdateClient(c *gin.Context) {
+	var req oauth.ClientConfig
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "Invalid request payload",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	client, secret, err := h.clients.Update(c.Request.Context(), c.Param("id"), req)
+	if err != nil {
+		h.clientError(c, err, "Failed to update client")
+		return
+	}
+
+	h.logger.WithField("client_id", client.ID).Info("OAuth client updated")
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Client updated successfully",
+		Data:    models.ClientResponse{Client: client, ClientSecret: secret},
+	})
+}
+
+// DeleteClient removes an OAuth client
+func (h *AdminHandler) DeleteClient(c *gin.Context) {
+	id := c.Param("id")
+	if err := h.clients.Delete(c.Request.Context(), id); err != nil {
+		h.clientError(c, err, "Failed to delete client")
+		return
+	}
+
+	h.logger.WithField("client_id", id).Info("OAuth client deleted")
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Client deleted successfully",
+	})
+}
+
+// RotateClientSecret issues a new client secret, keeping the previous one
+// valid for the requested overlap
+func (h *AdminHandler) RotateClientSecret(c *gin.Context) {
+	var req models.RotateClientSecretRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "Invalid request payload",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if err := h.validator.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "Validation failed",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	overlap := oauth.DefaultSecretOverlap
+	if req.OverlapSeconds != nil {
+		overlap = time.Duration(*req.OverlapSeconds) * time.Second
+	}
+	client, secret, err := h.clients.RotateSecret(c.Request.Context(), c.Param("id"), overlap)
+	if err != nil {
+		h.clientError(c, err, "Failed to rotate client secret")
+		return
+	}
+
+	h.logger.WithField("client_id", client.ID).Info("OAuth client secret rotated")
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Client secret rotated successfully",
+		Data:    models.ClientResponse{Client: client, ClientSecret: secret},
+	})
+}
+
+// clientError maps errors from the client registry to responses
+func (h *AdminHandler) clientError(c *gin.Context, err error, logMessage string) {
+	var oauthErr *oauth.Error
+	switch {
+	case errors.As(err, &oauthErr):
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "Validation failed",
+			Error:   oauthErr.Description,
+		})
+	case errors.Is(err, oauth.ErrNoClientSecret):
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "Client does not authenticate with a client secret",
+		})
+	case errors.Is(err, repository.ErrNotFound):
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Message: "Client not found",
+		})
+	case errors.Is(err, repository.ErrDuplicateClient):
+		c.JSON(http.StatusConflict, models.APIResponse{
+			Success: false,
+			Message: "Client already exists",
+		})
+	default:
+		h.logger.WithField("error", err.Error()).Error(logMessage)
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "Internal server error",
+		})
+	}
+}
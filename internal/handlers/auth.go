@@ -1,12 +1,18 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/goldcast/gc_auth_service/internal/app"
+	"github.com/goldcast/gc_auth_service/internal/auth"
+	"github.com/goldcast/gc_auth_service/internal/middleware"
 	"github.com/goldcast/gc_auth_service/internal/models"
+	"github.com/goldcast/gc_auth_service/internal/repository"
 	"github.com/goldcast/gc_auth_service/pkg/jwt"
 	"github.com/goldcast/gc_auth_service/pkg/logger"
 	"github.com/goldcast/gc_auth_service/pkg/password"
@@ -15,17 +21,35 @@ import (
 
 // AuthHandler handles authentication-related requests
 type AuthHandler struct {
-	logger     *logger.Logger
-	jwtService *jwt.Service
-	validator  *validator.Validate
+	logger        *logger.Logger
+	jwtService    *jwt.Service
+	validator     *validator.Validate
+	users         repository.UserRepository
+	authenticator *auth.Authenticator
+	tokens        *auth.TokenService
+	mfa           *auth.MFAService
+	passkeys      *auth.PasskeyService
+	verifier      *auth.EmailVerifier
+	passwords     *auth.PasswordService
+	resets        *auth.PasswordResetService
+	audit         *auth.AuditLog
 }
 
 // NewAuthHandler creates a new auth handler
-func NewAuthHandler(logger *logger.Logger) *AuthHandler {
+func NewAuthHandler(c *app.Container) *AuthHandler {
 	return &AuthHandler{
-		logger:     logger,
-		jwtService: jwt.New("your-secret-key-change-in-production", 24), // TODO: Get from config
-		validator:  validator.New(),
+		logger:        c.Logger,
+		jwtService:    c.JWT,
+		validator:     validator.New(),
+		users:         c.Users,
+		authenticator: c.Authenticator,
+		tokens:        c.Tokens,
+		mfa:           c.MFAService,
+		passkeys:      c.PasskeyService,
+		verifier:      c.EmailVerifier,
+		passwords:     c.Passwords,
+		resets:        c.ResetService,
+		audit:         c.Audit,
 	}
 }
 
@@ -52,8 +76,33 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
+	user := &models.User{
+		ID:        uuid.New(),
+		Email:     strings.ToLower(strings.TrimSpace(req.Email)),
+		Username:  req.Username,
+		FirstName: req.FirstName,
+		LastName:  req.LastName,
+		IsActive:  true,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+
+	if err := h.passwords.Validate(user, req.Password); err != nil {
+		var weak *password.PolicyError
+		if errors.As(err, &weak) {
+			passwordRejected(c, weak)
+			return
+		}
+		h.logger.WithField("error", err.Error()).Error("Failed to check password")
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "Internal server error",
+		})
+		return
+	}
+
 	// Hash password
-	hashedPassword, err := password.HashPassword(req.Password)
+	hashedPassword, err := h.passwords.Hash(req.Password)
 	if err != nil {
 		h.logger.WithField("error", err.Error()).Error("Failed to hash password")
 		c.JSON(http.StatusInternalServerError, models.APIResponse{
@@ -62,18 +111,24 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		})
 		return
 	}
-
-	// Create user (in a real app, this would be saved to database)
-	user := &models.User{
-		ID:        uuid.New(),
-		Email:     req.Email,
-		Username:  req.Username,
-		Password:  hashedPassword,
-		FirstName: req.FirstName,
-		LastName:  req.LastName,
-		IsActive:  true,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+	user.Password = hashedPassword
+
+	if err := h.users.Create(c.Request.Context(), user); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrDuplicateEmail), errors.Is(err, repository.ErrDuplicateUsername):
+			c.JSON(http.StatusConflict, models.APIResponse{
+				Success: false,
+				Message: "User already exists",
+				Error:   err.Error(),
+			})
+		default:
+			h.logger.WithField("error", err.Error()).Error("Failed to create user")
+			c.JSON(http.StatusInternalServerError, models.APIResponse{
+				Success: false,
+				Message: "Internal server error",
+			})
+		}
+		return
 	}
 
 	h.logger.WithFields(map[string]interface{}{
@@ -82,9 +137,14 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		"username": user.Username,
 	}).Info("User registered successfully")
 
+	// The user can ask for another link if this one does not arrive
+	if err := h.verifier.Send(c.Request.Context(), user); err != nil {
+		h.logger.WithField("error", err.Error()).Error("Failed to send verification email")
+	}
+
 	c.JSON(http.StatusCreated, models.APIResponse{
 		Success: true,
-		Message: "User registered successfully",
+		Message: "User registered successfully. Check your email for a link to verify your address.",
 		Data:    user,
 	})
 }
@@ -112,44 +172,55 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	// TODO: In a real app, fetch user from database
-	// For now, we'll simulate a user
-	user := &models.User{
-		ID:        uuid.New(),
-		Email:     req.Email,
-		Username:  "testuser",
-		Password:  "$2a$10$example_hash", // This would be the actual hashed password from DB
-		FirstName: "Test",
-		LastName:  "User",
-		IsActive:  true,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+	user, err := h.authenticator.Authenticate(c.Request.Context(), req.Email, req.Password)
+	if errors.Is(err, auth.ErrEmailNotVerified) {
+		c.JSON(http.StatusForbidden, models.APIResponse{
+			Success: false,
+			Message: "Email address not verified",
+		})
+		return
+	}
+	if err != nil {
+		if !errors.Is(err, auth.ErrInvalidCredentials) {
+			h.logger.WithField("error", err.Error()).Error("Failed to authenticate user")
+			c.JSON(http.StatusInternalServerError, models.APIResponse{
+				Success: false,
+				Message: "Internal server error",
+			})
+			return
+		}
+		h.logger.WithField("email", req.Email).Warn("Failed login attempt")
+		c.JSON(http.StatusUnauthorized, models.APIResponse{
+			Success: false,
+			Message: "Invalid credentials",
+		})
+		return
 	}
 
-	// Check password (in real app, compare with stored hash)
-	// For demo purposes, we'll accept any password
-	// if !password.CheckPasswordHash(req.Password, user.Password) {
-	//     c.JSON(http.StatusUnauthorized, models.APIResponse{
-	//         Success: false,
-	//         Message: "Invalid credentials",
-	//     })
-	//     return
-	// }
-
-	// Generate tokens
-	accessToken, err := h.jwtService.GenerateToken(user.ID, user.Email, user.Username)
+	amr := []string{auth.AMRPassword}
+	mfaRequired, err := h.mfa.Enabled(c.Request.Context(), user.ID)
 	if err != nil {
-		h.logger.WithField("error", err.Error()).Error("Failed to generate access token")
+		h.logger.WithField("error", err.Error()).Error("Failed to check MFA enrollment")
 		c.JSON(http.StatusInternalServerError, models.APIResponse{
 			Success: false,
 			Message: "Internal server error",
 		})
 		return
 	}
+	if mfaRequired {
+		h.startMFAChallenge(c, user, amr)
+		return
+	}
 
-	refreshToken, err := h.jwtService.GenerateRefreshToken(user.ID)
+	h.completeLogin(c, user, amr)
+}
+
+// completeLogin starts a session for a user who has fully authenticated and
+// responds with its tokens
+func (h *AuthHandler) completeLogin(c *gin.Context, user *models.User, amr []string) {
+	tokens, err := h.tokens.StartSession(c.Request.Context(), user, clientInfo(c), auth.Grant{AMR: amr})
 	if err != nil {
-		h.logger.WithField("error", err.Error()).Error("Failed to generate refresh token")
+		h.logger.WithField("error", err.Error()).Error("Failed to issue tokens")
 		c.JSON(http.StatusInternalServerError, models.APIResponse{
 			Success: false,
 			Message: "Internal server error",
@@ -162,9 +233,9 @@ func (h *AuthHandler) Login(c *gin.Context) {
 
 	response := models.LoginResponse{
 		User:         user,
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-		ExpiresIn:    24 * 60 * 60, // 24 hours in seconds
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    tokens.ExpiresIn,
 	}
 
 	h.logger.WithFields(map[string]interface{}{
@@ -192,37 +263,45 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 		return
 	}
 
-	// Validate refresh token
-	claims, err := h.jwtService.ValidateToken(req.RefreshToken)
-	if err != nil {
-		h.logger.WithField("error", err.Error()).Warn("Invalid refresh token")
-		c.JSON(http.StatusUnauthorized, models.APIResponse{
+	// Validate request
+	if err := h.validator.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
 			Success: false,
-			Message: "Invalid or expired refresh token",
+			Message: "Validation failed",
+			Error:   err.Error(),
 		})
 		return
 	}
 
-	// TODO: In a real app, verify the refresh token exists in database
-	// and fetch user details
-
-	// Generate new access token
-	accessToken, err := h.jwtService.GenerateToken(claims.UserID, claims.Email, claims.Username)
+	tokens, err := h.tokens.Refresh(c.Request.Context(), req.RefreshToken, "")
 	if err != nil {
-		h.logger.WithField("error", err.Error()).Error("Failed to generate new access token")
-		c.JSON(http.StatusInternalServerError, models.APIResponse{
+		switch {
+		case errors.Is(err, auth.ErrRefreshTokenReused):
+			h.logger.Warn("Refresh token reuse detected, session revoked")
+		case errors.Is(err, auth.ErrInvalidRefreshToken):
+			h.logger.Warn("Invalid refresh token")
+		default:
+			h.logger.WithField("error", err.Error()).Error("Failed to refresh token")
+			c.JSON(http.StatusInternalServerError, models.APIResponse{
+				Success: false,
+				Message: "Internal server error",
+			})
+			return
+		}
+		c.JSON(http.StatusUnauthorized, models.APIResponse{
 			Success: false,
-			Message: "Internal server error",
+			Message: "Invalid or expired refresh token",
 		})
 		return
 	}
 
-	response := map[string]interface{}{
-		"access_token": accessToken,
-		"expires_in":   24 * 60 * 60, // 24 hours in seconds
+	response := models.RefreshTokenResponse{
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    tokens.ExpiresIn,
 	}
 
-	h.logger.WithField("user_id", claims.UserID).Info("Token refreshed successfully")
+	h.logger.WithField("session_id", tokens.Session.ID).Info("Token refreshed successfully")
 
 	c.JSON(http.StatusOK, models.APIResponse{
 		Success: true,
@@ -242,16 +321,21 @@ func (h *AuthHandler) GetProfile(c *gin.Context) {
 		return
 	}
 
-	// TODO: In a real app, fetch user from database
-	user := &models.User{
-		ID:        userID.(uuid.UUID),
-		Email:     c.GetString("user_email"),
-		Username:  c.GetString("user_username"),
-		FirstName: "Test",
-		LastName:  "User",
-		IsActive:  true,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+	user, err := h.users.GetByID(c.Request.Context(), userID.(uuid.UUID))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, models.APIResponse{
+				Success: false,
+				Message: "User not found",
+			})
+			return
+		}
+		h.logger.WithField("error", err.Error()).Error("Failed to fetch user")
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "Internal server error",
+		})
+		return
 	}
 
 	c.JSON(http.StatusOK, models.APIResponse{
@@ -263,13 +347,53 @@ func (h *AuthHandler) GetProfile(c *gin.Context) {
 
 // Logout handles user logout
 func (h *AuthHandler) Logout(c *gin.Context) {
-	// TODO: In a real app, invalidate the refresh token in database
-	// For now, we'll just return success
+	claims := c.MustGet(middleware.ClaimsKey).(*jwt.Claims)
 
-	h.logger.WithField("user_id", c.GetString("user_id")).Info("User logged out")
+	if err := h.tokens.Logout(c.Request.Context(), claims); err != nil {
+		h.logger.WithField("error", err.Error()).Error("Failed to revoke tokens")
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "Internal server error",
+		})
+		return
+	}
+
+	h.logger.WithFields(map[string]interface{}{
+		"user_id":    claims.UserID,
+		"session_id": claims.SessionID,
+	}).Info("User logged out")
 
 	c.JSON(http.StatusOK, models.APIResponse{
 		Success: true,
 		Message: "Logged out successfully",
 	})
 }
+
+// LogoutAll ends every session of the current user
+func (h *AuthHandler) LogoutAll(c *gin.Context) {
+	claims := c.MustGet(middleware.ClaimsKey).(*jwt.Claims)
+
+	if err := h.tokens.LogoutAll(c.Request.Context(), claims.UserID); err != nil {
+		h.logger.WithField("error", err.Error()).Error("Failed to revoke sessions")
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "Internal server error",
+		})
+		return
+	}
+
+	h.logger.WithField("user_id", claims.UserID).Info("User logged out of all sessions")
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Logged out of all sessions successfully",
+	})
+}
+
+// clientInfo describes the client making the request
+func clientInfo(c *gin.Context) auth.ClientInfo {
+	return auth.ClientInfo{
+		UserAgent: c.Request.UserAgent(),
+		IPAddress: c.ClientIP(),
+	}
+}
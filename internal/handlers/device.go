@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/goldcast/gc_auth_service/internal/middleware"
+	"github.com/goldcast/gc_auth_service/internal/models"
+	"github.com/goldcast/gc_auth_service/internal/oauth"
+	"github.com/goldcast/gc_auth_service/pkg/jwt"
+	"github.com/google/uuid"
+)
+
+// DeviceAuthorization starts a device authorization for a client that cannot
+// receive redirects (RFC 8628 section 3.1)
+func (h *OAuthHandler) DeviceAuthorization(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+
+	client, ok := h.authenticateClient(c)
+	if !ok {
+		return
+	}
+
+	resp, err := h.server.AuthorizeDevice(c.Request.Context(), client, c.PostForm("scope"))
+	if err != nil {
+		h.tokenError(c, err)
+		return
+	}
+
+	h.logger.WithField("client_id", client.ID).Info("Device authorization started")
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// Device shows the verification page where the user enters the code shown
+// on their device, and then signs in to approve it
+func (h *OAuthHandler) Device(c *gin.Context) {
+	userCode := c.Query("user_code")
+	if userCode == "" {
+		h.renderDevice(c, http.StatusOK, "")
+		return
+	}
+
+	code, client, ok := h.lookupUserCode(c, userCode)
+	if !ok {
+		return
+	}
+	h.renderDeviceLogin(c, http.StatusOK, client, code, "", "")
+}
+
+// DeviceSubmit authenticates the user from the verification page and
+// approves or denies the device authorization
+func (h *OAuthHandler) DeviceSubmit(c *gin.Context) {
+	code, client, ok := h.lookupUserCode(c, c.PostForm("user_code"))
+	if !ok {
+		return
+	}
+
+	if c.PostForm("action") == "deny" {
+		if err := h.server.DenyDevice(c.Request.Context(), code); err != nil {
+			h.deviceError(c, err)
+			return
+		}
+		h.logger.WithField("client_id", client.ID).Info("Device authorization denied")
+		h.render(c, http.StatusOK, "message", loginPage{
+			Title:   "Device not connected",
+			Message: "The request was denied. You can close this window.",
+		})
+		return
+	}
+
+	user, amr, ok, err := h.signIn(c, client, h.devicePage(c, client, code))
+	if err != nil {
+		h.logger.WithField("error", err.Error()).Error("Failed to authenticate user")
+		h.renderError(c, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	if !ok {
+		return
+	}
+
+	if err := h.server.ApproveDevice(c.Request.Context(), code, user.ID, time.Now(), amr); err != nil {
+		h.deviceError(c, err)
+		return
+	}
+
+	h.logger.WithFields(map[string]interface{}{
+		"user_id":   user.ID,
+		"client_id": client.ID,
+	}).Info("Device authorization approved")
+
+	h.render(c, http.StatusOK, "message", loginPage{
+		Title:   "Device connected",
+		Message: "You are signed in to " + clientName(client) + ". Return to your device to continue.",
+	})
+}
+
+// ApproveDevice approves or denies a device authorization as the user the
+// access token belongs to, for apps where the user is already signed in
+func (h *OAuthHandler) ApproveDevice(c *gin.Context) {
+	claims := c.MustGet(middleware.ClaimsKey).(*jwt.Claims)
+
+	var req models.DeviceApprovalRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "Invalid request payload",
+			Error:   err.Error(),
+		})
+		return
+	}
+	if err := h.validator.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "Validation failed",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	code, client, err := h.server.LookupUserCode(c.Request.Context(), req.UserCode)
+	if err == nil {
+		if req.Approve {
+			err = h.approveAsSession(c, claims, code)
+		} else {
+			err = h.server.DenyDevice(c.Request.Context(), code)
+		}
+	}
+	if err != nil {
+		if errors.Is(err, oauth.ErrInvalidUserCode) {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false,
+				Message: "Invalid or expired code",
+			})
+			return
+		}
+		h.logger.WithField("error", err.Error()).Error("Failed to decide device authorization")
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "Internal server error",
+		})
+		return
+	}
+
+	status, message := models.DeviceCodeDenied, "Device denied"
+	if req.Approve {
+		status, message = models.DeviceCodeApproved, "Device approved"
+	}
+	h.logger.WithFields(map[string]interface{}{
+		"user_id":   claims.UserID,
+		"client_id": client.ID,
+		"status":    status,
+	}).Info("Device authorization decided")
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: message,
+		Data: models.DeviceApprovalResponse{
+			ClientID:   client.ID,
+			ClientName: clientName(client),
+			Scope:      code.Scope,
+			Status:     status,
+		},
+	})
+}
+
+// approveAsSession approves a device with the authentication time and
+// methods of the session the access token was issued in
+func (h *OAuthHandler) approveAsSession(c *gin.Context, claims *jwt.Claims, code *models.DeviceCode) error {
+	authTime := time.Now()
+	var amr []string
+	if claims.SessionID != uuid.Nil {
+		session, err := h.sessions.GetSession(c.Request.Context(), claims.SessionID)
+		if err != nil {
+			return err
+		}
+		authTime, amr = session.AuthTime, session.AMR
+	}
+	return h.server.ApproveDevice(c.Request.Context(), code, claims.UserID, authTime, amr)
+}
+
+// lookupUserCode resolves the user code entered on the verification page,
+// showing the code form again if it is not valid
+func (h *OAuthHandler) lookupUserCode(c *gin.Context, userCode string) (*models.DeviceCode, *models.Client, bool) {
+	code, client, err := h.server.LookupUserCode(c.Request.Context(), userCode)
+	if err != nil {
+		h.deviceError(c, err)
+		return nil, nil, false
+	}
+	return code, client, true
+}
+
+// deviceError shows the code form for invalid user codes and an error page otherwise
+func (h *OAuthHandler) deviceError(c *gin.Context, err error) {
+	if errors.Is(err, oauth.ErrInvalidUserCode) {
+		h.renderDevice(c, http.StatusBadRequest, "The code is invalid or has expired. Check your device and try again.")
+		return
+	}
+	h.logger.WithField("error", err.Error()).Error("Failed to process device verification")
+	h.renderError(c, http.StatusInternalServerError, "Internal server error")
+}
+
+// renderDevice shows the form for entering a user code
+func (h *OAuthHandler) renderDevice(c *gin.Context, status int, message string) {
+	h.render(c, status, "device", loginPage{
+		Title:  "Connect a device",
+		Action: c.Request.URL.Path,
+		Error:  message,
+	})
+}
+
+// renderDeviceLogin shows the login form for approving a device authorization
+func (h *OAuthHandler) renderDeviceLogin(c *gin.Context, status int, client *models.Client, code *models.DeviceCode, email, message string) {
+	page := h.devicePage(c, client, code)
+	page.Email = email
+	page.Error = message
+	h.render(c, status, "login", page)
+}
+
+// devicePage is the sign-in page for approving a device authorization
+func (h *OAuthHandler) devicePage(c *gin.Context, client *models.Client, code *models.DeviceCode) loginPage {
+	userCode := oauth.FormatUserCode(code.UserCode)
+	return loginPage{
+		Title:      "Connect a device",
+		Action:     c.Request.URL.Path,
+		ClientName: clientName(client),
+		Scopes:     oauth.ParseScope(code.Scope),
+		Params:     map[string]string{"user_code": userCode},
+		UserCode:   userCode,
+	}
+}
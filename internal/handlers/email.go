@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/goldcast/gc_auth_service/internal/auth"
+	"github.com/goldcast/gc_auth_service/internal/models"
+)
+
+// VerifyEmail marks the user's email address verified with the token from a
+// verification link. It also accepts the form on the page a verification
+// link opens.
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+	if c.ContentType() == binding.MIMEPOSTForm {
+		h.verifyEmailForm(c)
+		return
+	}
+
+	var req models.VerifyEmailRequest
+	if !h.bindJSON(c, &req) {
+		return
+	}
+
+	user, err := h.verifier.Verify(c.Request.Context(), req.Token)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidVerificationToken) {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false,
+				Message: "Invalid or expired verification link",
+			})
+			return
+		}
+		h.logger.WithField("error", err.Error()).Error("Failed to verify email address")
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "Internal server error",
+		})
+		return
+	}
+
+	h.logger.WithField("user_id", user.ID).Info("Email address verified")
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Email address verified",
+		Data:    user,
+	})
+}
+
+// VerifyEmailPage shows the page verification links open, whose button
+// posts the token back. The address is only verified on that post, so that
+// mail scanners and link previews fetching the link do not use it up.
+func (h *AuthHandler) VerifyEmailPage(c *gin.Context) {
+	renderPage(c, h.logger, http.StatusOK, "verify", loginPage{
+		Title:  "Verify email address",
+		Action: c.Request.URL.Path,
+		Params: map[string]string{"token": c.Query("token")},
+	})
+}
+
+// verifyEmailForm handles the form on the verification page, showing the
+// outcome as a page
+func (h *AuthHandler) verifyEmailForm(c *gin.Context) {
+	var req models.VerifyEmailRequest
+	if err := c.ShouldBindWith(&req, binding.Form); err != nil {
+		req.Token = "" // refused below like any invalid link
+	}
+	user, err := h.verifier.Verify(c.Request.Context(), req.Token)
+	if err != nil {
+		status := http.StatusBadRequest
+		page := loginPage{
+			Title:   "Link expired",
+			Message: "This verification link is invalid, expired or was already used. Sign in to request a new one.",
+		}
+		if !errors.Is(err, auth.ErrInvalidVerificationToken) {
+			h.logger.WithField("error", err.Error()).Error("Failed to verify email address")
+			status = http.StatusInternalServerError
+			page = loginPage{
+				Title:   "Something went wrong",
+				Message: "We could not verify your email address. Please try again later.",
+			}
+		}
+		renderPage(c, h.logger, status, "message", page)
+		return
+	}
+
+	h.logger.WithField("user_id", user.ID).Info("Email address verified")
+
+	renderPage(c, h.logger, http.StatusOK, "message", loginPage{
+		Title:   "Email verified",
+		Message: "Thanks, " + user.Email + " is verified. You can close this page.",
+	})
+}
+
+// ResendVerification emails a new verification link. It responds the same
+// whether or not an account uses the address.
+func (h *AuthHandler) ResendVerification(c *gin.Context) {
+	var req models.ResendVerificationRequest
+	if !h.bindJSON(c, &req) {
+		return
+	}
+
+	if err := h.verifier.Resend(c.Request.Context(), req.Email); err != nil {
+		h.logger.WithField("error", err.Error()).Error("Failed to resend verification email")
+	}
+
+	c.JSON(http.StatusAccepted, models.APIResponse{
+		Success: true,
+		Message: "If an unverified account uses this address, a new verification link is on its way",
+	})
+}
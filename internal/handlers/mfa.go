@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/goldcast/gc_auth_service/internal/auth"
+	"github.com/goldcast/gc_auth_service/internal/middleware"
+	"github.com/goldcast/gc_auth_service/internal/models"
+	"github.com/goldcast/gc_auth_service/pkg/jwt"
+)
+
+// VerifyMFA completes a login that requires a second factor, exchanging the
+// MFA token and a TOTP or recovery code, or a passkey assertion, for access
+// tokens
+func (h *AuthHandler) VerifyMFA(c *gin.Context) {
+	var req models.MFAVerifyRequest
+	if !h.bindJSON(c, &req) {
+		return
+	}
+
+	var user *models.User
+	var amr []string
+	var err error
+	if len(req.Credential) > 0 {
+		user, amr, err = h.mfa.VerifyChallengeWithPasskey(c.Request.Context(), req.MFAToken, req.SessionToken, req.Credential)
+	} else {
+		user, amr, err = h.mfa.VerifyChallenge(c.Request.Context(), req.MFAToken, req.Code)
+	}
+	if err != nil {
+		var limited *auth.RateLimitError
+		switch {
+		case errors.As(err, &limited):
+			h.logger.Warn("MFA verification rate limited")
+			tooManyRequests(c, limited)
+		case errors.Is(err, auth.ErrInvalidMFACode):
+			h.logger.Warn("Failed MFA verification attempt")
+			c.JSON(http.StatusUnauthorized, models.APIResponse{
+				Success: false,
+				Message: "Invalid MFA code",
+			})
+		case errors.Is(err, auth.ErrInvalidMFAChallenge):
+			c.JSON(http.StatusUnauthorized, models.APIResponse{
+				Success: false,
+				Message: "Invalid or expired MFA token",
+			})
+		default:
+			h.logger.WithField("error", err.Error()).Error("Failed to verify MFA code")
+			c.JSON(http.StatusInternalServerError, models.APIResponse{
+				Success: false,
+				Message: "Internal server error",
+			})
+		}
+		return
+	}
+
+	h.completeLogin(c, user, amr)
+}
+
+// MFAStatus returns the current user's second factors
+func (h *AuthHandler) MFAStatus(c *gin.Context) {
+	claims := c.MustGet(middleware.ClaimsKey).(*jwt.Claims)
+
+	status, err := h.mfa.Status(c.Request.Context(), claims.UserID)
+	if err != nil {
+		h.mfaError(c, err, "Failed to fetch MFA status")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "MFA status retrieved successfully",
+		Data:    status,
+	})
+}
+
+// EnrollTOTP starts enrolling an authenticator app for the current user. The
+// enrollment must be confirmed with a code before it is enforced.
+func (h *AuthHandler) EnrollTOTP(c *gin.Context) {
+	claims := c.MustGet(middleware.ClaimsKey).(*jwt.Claims)
+
+	user, err := h.users.GetByID(c.Request.Context(), claims.UserID)
+	if err != nil {
+		h.mfaError(c, err, "Failed to fetch user")
+		return
+	}
+	enrollment, err := h.mfa.BeginTOTPEnrollment(c.Request.Context(), user)
+	if err != nil {
+		h.mfaError(c, err, "Failed to start TOTP enrollment")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Scan the QR code with your authenticator app and confirm with a code",
+		Data:    enrollment,
+	})
+}
+
+// ConfirmTOTP enables the pending authenticator app of the current user and
+// returns their recovery codes
+func (h *AuthHandler) ConfirmTOTP(c *gin.Context) {
+	claims := c.MustGet(middleware.ClaimsKey).(*jwt.Claims)
+
+	var req models.MFACodeRequest
+	if !h.bindJSON(c, &req) {
+		return
+	}
+
+	codes, err := h.mfa.ConfirmTOTP(c.Request.Context(), claims.UserID, req.Code)
+	if err != nil {
+		h.mfaError(c, err, "Failed to confirm TOTP enrollment")
+		return
+	}
+
+	h.logger.WithField("user_id", claims.UserID).Info("TOTP enabled")
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Two-factor authentication enabled",
+		Data:    models.RecoveryCodesResponse{RecoveryCodes: codes},
+	})
+}
+
+// DisableTOTP removes the current user's authenticator app and recovery
+// codes, confirmed with a current code
+func (h *AuthHandler) DisableTOTP(c *gin.Context) {
+	claims := c.MustGet(middleware.ClaimsKey).(*jwt.Claims)
+
+	var req models.MFACodeRequest
+	if !h.bindJSON(c, &req) {
+		return
+	}
+
+	if err := h.mfa.Disable(c.Request.Context(), claims.UserID, req.Code); err != nil {
+		h.mfaError(c, err, "Failed to disable TOTP")
+		return
+	}
+
+	h.logger.WithField("user_id", claims.UserID).Info("TOTP disabled")
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Two-factor authentication disabled",
+	})
+}
+
+// RegenerateRecoveryCodes replaces the current user's recovery codes,
+// confirmed with a current code
+func (h *AuthHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	claims := c.MustGet(middleware.ClaimsKey).(*jwt.Claims)
+
+	var req models.MFACodeRequest
+	if !h.bindJSON(c, &req) {
+		return
+	}
+
+	codes, err := h.mfa.RegenerateRecoveryCodes(c.Request.Context(), claims.UserID, req.Code)
+	if err != nil {
+		h.mfaError(c, err, "Failed to regenerate recovery codes")
+		return
+	}
+
+	h.logger.WithField("user_id", claims.UserID).Info("Recovery codes regenerated")
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Recovery codes regenerated",
+		Data:    models.RecoveryCodesResponse{RecoveryCodes: codes},
+	})
+}
+
+// startMFAChallenge responds to a password login with an MFA token instead
+// of access tokens
+func (h *AuthHandler) startMFAChallenge(c *gin.Context, user *models.User, amr []string) {
+	token, expiresIn, err := h.mfa.StartChallenge(c.Request.Context(), user, amr)
+	if err != nil {
+		h.logger.WithField("error", err.Error()).Error("Failed to start MFA challenge")
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "Internal server error",
+		})
+		return
+	}
+
+	h.logger.WithField("user_id", user.ID).Info("MFA challenge started")
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Multi-factor authentication required",
+		Data: models.MFAChallengeResponse{
+			MFARequired: true,
+			MFAToken:    token,
+			ExpiresIn:   expiresIn,
+		},
+	})
+}
+
+// mfaError maps errors from the MFA service to responses
+func (h *AuthHandler) mfaError(c *gin.Context, err error, logMessage string) {
+	var limited *auth.RateLimitError
+	switch {
+	case errors.As(err, &limited):
+		tooManyRequests(c, limited)
+	case errors.Is(err, auth.ErrInvalidMFACode):
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "Invalid MFA code",
+		})
+	case errors.Is(err, auth.ErrMFAAlreadyEnabled):
+		c.JSON(http.StatusConflict, models.APIResponse{
+			Success: false,
+			Message: "Two-factor authentication is already enabled",
+		})
+	case errors.Is(err, auth.ErrMFANotEnabled):
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "Two-factor authentication is not enabled",
+		})
+	default:
+		h.logger.WithField("error", err.Error()).Error(logMessage)
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "Internal server error",
+		})
+	}
+}
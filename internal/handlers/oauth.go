@@ -0,0 +1,526 @@
+package handlers
+
+import (
+	"embed"
+	"errors"
+	"html/template"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/goldcast/gc_auth_service/internal/app"
+	"github.com/goldcast/gc_auth_service/internal/auth"
+	"github.com/goldcast/gc_auth_service/internal/middleware"
+	"github.com/goldcast/gc_auth_service/internal/models"
+	"github.com/goldcast/gc_auth_service/internal/oauth"
+	"github.com/goldcast/gc_auth_service/internal/repository"
+	"github.com/goldcast/gc_auth_service/pkg/jwt"
+	"github.com/goldcast/gc_auth_service/pkg/logger"
+)
+
+//go:embed templates/*.html
+var templateFS embed.FS
+
+var oauthTemplates = template.Must(template.ParseFS(templateFS, "templates/oauth.html"))
+
+// OAuthHandler serves the OAuth 2.0 and OpenID Connect endpoints
+type OAuthHandler struct {
+	logger        *logger.Logger
+	validator     *validator.Validate
+	server        *oauth.Server
+	authenticator *auth.Authenticator
+	mfa           *auth.MFAService
+	users         repository.UserRepository
+	sessions      repository.SessionRepository
+	registry      *oauth.ClientRegistry
+	// registrationToken is the initial access token required for dynamic
+	// client registration, which is disabled when it is empty
+	registrationToken string
+}
+
+// NewOAuthHandler creates a new OAuth handler
+func NewOAuthHandler(c *app.Container) *OAuthHandler {
+	return &OAuthHandler{
+		logger:        c.Logger,
+		validator:     validator.New(),
+		server:        c.OAuth,
+		authenticator: c.Authenticator,
+		mfa:           c.MFAService,
+		users:         c.Users,
+		sessions:      c.Sessions,
+		registry:      c.ClientRegistry,
+
+		registrationToken: c.Config.RegistrationToken,
+	}
+}
+
+// loginPage is the data rendered by the login template
+type loginPage struct {
+	Title      string
+	Action     string
+	ClientName string
+	Scopes     []string
+	Params     map[string]string
+	Email      string
+	UserCode   string
+	Message    string
+	Error      string
+	Hints      []string // shown below Error, such as password requirements
+	// Passkey offers signing in with a passkey on the second factor form,
+	// whose script is allowed by the Nonce
+	Passkey bool
+	Nonce   string
+}
+
+// Authorize validates an authorization request and shows the login form
+func (h *OAuthHandler) Authorize(c *gin.Context) {
+	var req oauth.AuthorizeRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		h.renderError(c, http.StatusBadRequest, "Invalid authorization request")
+		return
+	}
+
+	client, ok := h.validateAuthorize(c, &req)
+	if !ok {
+		return
+	}
+
+	// There is no browser session to fall back on, so the user always has to
+	// sign in interactively
+	if req.Prompt == "none" {
+		h.redirectError(c, &req, oauth.NewError(oauth.ErrorLoginRequired, "user authentication is required"))
+		return
+	}
+
+	h.renderLogin(c, http.StatusOK, client, &req, "", "")
+}
+
+// AuthorizeSubmit authenticates the user from the login form and redirects
+// back to the client with an authorization code
+func (h *OAuthHandler) AuthorizeSubmit(c *gin.Context) {
+	var req oauth.AuthorizeRequest
+	if err := c.ShouldBind(&req); err != nil {
+		h.renderError(c, http.StatusBadRequest, "Invalid authorization request")
+		return
+	}
+
+	client, ok := h.validateAuthorize(c, &req)
+	if !ok {
+		return
+	}
+
+	if c.PostForm("action") == "deny" {
+		h.redirectError(c, &req, oauth.NewError(oauth.ErrorAccessDenied, "the user denied the request"))
+		return
+	}
+
+	user, amr, ok, err := h.signIn(c, client, h.authorizePage(c, client, &req))
+	if err != nil {
+		h.logger.WithField("error", err.Error()).Error("Failed to authenticate user")
+		h.redirectError(c, &req, oauth.NewError(oauth.ErrorServerError, ""))
+		return
+	}
+	if !ok {
+		return
+	}
+
+	code, err := h.server.IssueCode(c.Request.Context(), client, user.ID, &req, amr)
+	if err != nil {
+		h.logger.WithField("error", err.Error()).Error("Failed to issue authorization code")
+		h.redirectError(c, &req, oauth.NewError(oauth.ErrorServerError, ""))
+		return
+	}
+
+	h.logger.WithFields(map[string]interface{}{
+		"user_id":   user.ID,
+		"client_id": client.ID,
+	}).Info("Authorization code issued")
+
+	c.Redirect(http.StatusFound, oauth.RedirectURL(req.RedirectURI, url.Values{
+		"code":  {code},
+		"state": {req.State},
+	}))
+}
+
+// Token issues tokens for the authorization code, refresh token, client
+// credentials, device code and token exchange grants
+func (h *OAuthHandler) Token(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+
+	client, ok := h.authenticateClient(c)
+	if !ok {
+		return
+	}
+
+	var tokens *oauth.Tokens
+	var err error
+	switch grantType := c.PostForm("grant_type"); grantType {
+	case oauth.GrantAuthorizationCode:
+		tokens, err = h.server.ExchangeCode(c.Request.Context(), client,
+			c.PostForm("code"), c.PostForm("redirect_uri"), c.PostForm("code_verifier"), clientInfo(c))
+	case oauth.GrantRefreshToken:
+		tokens, err = h.server.RefreshToken(c.Request.Context(), client, c.PostForm("refresh_token"))
+	case oauth.GrantClientCredentials:
+		tokens, err = h.server.ClientCredentials(c.Request.Context(), client, c.PostForm("scope"))
+	case oauth.GrantDeviceCode:
+		tokens, err = h.server.PollDevice(c.Request.Context(), client, c.PostForm("device_code"), clientInfo(c))
+	case oauth.GrantTokenExchange:
+		tokens, err = h.server.ExchangeToken(c.Request.Context(), client, &oauth.TokenExchangeRequest{
+			SubjectToken:       c.PostForm("subject_token"),
+			SubjectTokenType:   c.PostForm("subject_token_type"),
+			ActorToken:         c.PostForm("actor_token"),
+			ActorTokenType:     c.PostForm("actor_token_type"),
+			RequestedTokenType: c.PostForm("requested_token_type"),
+			Audience:           append(c.PostFormArray("audience"), c.PostFormArray("resource")...),
+			Scope:              c.PostForm("scope"),
+		})
+	case "":
+		err = oauth.NewError(oauth.ErrorInvalidRequest, "grant_type is required")
+	default:
+		err = oauth.NewError(oauth.ErrorUnsupportedGrantType, "unsupported grant_type %q", grantType)
+	}
+	if err != nil {
+		h.tokenError(c, err)
+		return
+	}
+
+	fields := map[string]interface{}{"client_id": client.ID}
+	if tokens.Session != nil {
+		fields["session_id"] = tokens.Session.ID
+	}
+	h.logger.WithFields(fields).Info("OAuth tokens issued")
+
+	c.JSON(http.StatusOK, oauth.NewTokenResponse(tokens))
+}
+
+// Introspect reports whether a token is active (RFC 7662)
+func (h *OAuthHandler) Introspect(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+
+	client, ok := h.authenticateClient(c)
+	if !ok {
+		return
+	}
+
+	resp, err := h.server.Introspect(c.Request.Context(), client, c.PostForm("token"))
+	if err != nil {
+		h.tokenError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// Revoke revokes an access or refresh token (RFC 7009). Unknown tokens are
+// reported as revoked successfully.
+func (h *OAuthHandler) Revoke(c *gin.Context) {
+	client, ok := h.authenticateClient(c)
+	if !ok {
+		return
+	}
+
+	if err := h.server.Revoke(c.Request.Context(), client, c.PostForm("token")); err != nil {
+		h.tokenError(c, err)
+		return
+	}
+
+	h.logger.WithField("client_id", client.ID).Info("OAuth token revoked")
+
+	c.Status(http.StatusOK)
+}
+
+// UserInfo returns claims about the user the access token was issued for,
+// limited to the scopes the token carries
+func (h *OAuthHandler) UserInfo(c *gin.Context) {
+	claims := c.MustGet(middleware.ClaimsKey).(*jwt.Claims)
+	if !oauth.HasScope(claims.Scope, oauth.ScopeOpenID) {
+		c.Header("WWW-Authenticate", `Bearer error="insufficient_scope", scope="openid"`)
+		c.JSON(http.StatusForbidden, oauth.NewError(oauth.ErrorInsufficientScope, "the access token was not granted the openid scope"))
+		return
+	}
+
+	user, err := h.users.GetByID(c.Request.Context(), claims.UserID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+			c.JSON(http.StatusUnauthorized, oauth.NewError(oauth.ErrorInvalidToken, "the user no longer exists"))
+			return
+		}
+		h.logger.WithField("error", err.Error()).Error("Failed to fetch user")
+		c.JSON(http.StatusInternalServerError, oauth.NewError(oauth.ErrorServerError, ""))
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, oauth.UserInfo(user, claims.Scope))
+}
+
+// validateAuthorize checks an authorization request, rendering or redirecting
+// the error itself. It reports false if the request must not proceed.
+func (h *OAuthHandler) validateAuthorize(c *gin.Context, req *oauth.AuthorizeRequest) (*models.Client, bool) {
+	client, err := h.server.LookupClient(c.Request.Context(), req.ClientID, req.RedirectURI)
+	if err != nil {
+		var oauthErr *oauth.Error
+		if errors.As(err, &oauthErr) {
+			h.renderError(c, oauthErr.Status, oauthErr.Description)
+		} else {
+			h.logger.WithField("error", err.Error()).Error("Failed to look up OAuth client")
+			h.renderError(c, http.StatusInternalServerError, "Internal server error")
+		}
+		return nil, false
+	}
+
+	if err := h.server.ValidateAuthorizeRequest(client, req); err != nil {
+		h.redirectError(c, req, err)
+		return nil, false
+	}
+	return client, true
+}
+
+// redirectError sends an authorization error back to the client's redirect URI
+func (h *OAuthHandler) redirectError(c *gin.Context, req *oauth.AuthorizeRequest, err *oauth.Error) {
+	c.Redirect(http.StatusFound, oauth.RedirectURL(req.RedirectURI, url.Values{
+		"error":             {err.Code},
+		"error_description": {err.Description},
+		"state":             {req.State},
+	}))
+}
+
+// renderLogin shows the login form, carrying the authorization request along
+func (h *OAuthHandler) renderLogin(c *gin.Context, status int, client *models.Client, req *oauth.AuthorizeRequest, email, message string) {
+	page := h.authorizePage(c, client, req)
+	page.Email = email
+	page.Error = message
+	h.render(c, status, "login", page)
+}
+
+// authorizePage is the sign-in page for an authorization request
+func (h *OAuthHandler) authorizePage(c *gin.Context, client *models.Client, req *oauth.AuthorizeRequest) loginPage {
+	return loginPage{
+		Title:      "Sign in",
+		Action:     c.Request.URL.Path,
+		ClientName: clientName(client),
+		Scopes:     oauth.ParseScope(req.Scope),
+		Params: map[string]string{
+			"response_type":         req.ResponseType,
+			"client_id":             req.ClientID,
+			"redirect_uri":          req.RedirectURI,
+			"scope":                 req.Scope,
+			"state":                 req.State,
+			"code_challenge":        req.CodeChallenge,
+			"code_challenge_method": req.CodeChallengeMethod,
+			"nonce":                 req.Nonce,
+		},
+	}
+}
+
+// signIn authenticates the user from a submitted sign-in page: the login
+// form, and then the second factor form for users who enabled MFA. It
+// reports false after showing the next form or an error to the user, and
+// returns the authentication methods used otherwise.
+func (h *OAuthHandler) signIn(c *gin.Context, client *models.Client, page loginPage) (*models.User, []string, bool, error) {
+	ctx := c.Request.Context()
+
+	if token := c.PostForm("mfa_token"); token != "" {
+		passkey := c.PostForm("passkey") != ""
+		var user *models.User
+		var amr []string
+		var err error
+		if credential := c.PostForm("passkey_credential"); credential != "" {
+			user, amr, err = h.mfa.VerifyChallengeWithPasskey(ctx, token, c.PostForm("passkey_session"), []byte(credential))
+		} else {
+			user, amr, err = h.mfa.VerifyChallenge(ctx, token, c.PostForm("code"))
+		}
+		var limited *auth.RateLimitError
+		switch {
+		case errors.As(err, &limited):
+			h.logger.WithField("client_id", client.ID).Warn("MFA verification rate limited")
+			c.Header("Retry-After", retryAfter(limited))
+			page.Error = "Too many wrong codes. Try again later."
+			h.renderMFA(c, http.StatusTooManyRequests, page, token, passkey)
+			return nil, nil, false, nil
+		case errors.Is(err, auth.ErrInvalidMFACode):
+			h.logger.WithField("client_id", client.ID).Warn("Failed MFA verification attempt")
+			page.Error = "Invalid code"
+			h.renderMFA(c, http.StatusUnauthorized, page, token, passkey)
+			return nil, nil, false, nil
+		case errors.Is(err, auth.ErrInvalidMFAChallenge):
+			page.Error = "Your sign-in has expired. Sign in again."
+			h.render(c, http.StatusUnauthorized, "login", page)
+			return nil, nil, false, nil
+		case err != nil:
+			return nil, nil, false, err
+		}
+		return user, amr, true, nil
+	}
+
+	email := c.PostForm("email")
+	user, err := h.authenticator.Authenticate(ctx, email, c.PostForm("password"))
+	if errors.Is(err, auth.ErrEmailNotVerified) {
+		page.Email = email
+		page.Error = "Verify your email address with the link we sent you before signing in."
+		h.render(c, http.StatusForbidden, "login", page)
+		return nil, nil, false, nil
+	}
+	if err != nil {
+		if !errors.Is(err, auth.ErrInvalidCredentials) {
+			return nil, nil, false, err
+		}
+		h.logger.WithFields(map[string]interface{}{
+			"email":     email,
+			"client_id": client.ID,
+		}).Warn("Failed OAuth login attempt")
+		page.Email = email
+		page.Error = "Invalid email or password"
+		h.render(c, http.StatusUnauthorized, "login", page)
+		return nil, nil, false, nil
+	}
+
+	amr := []string{auth.AMRPassword}
+	mfaRequired, err := h.mfa.Enabled(ctx, user.ID)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	if !mfaRequired {
+		return user, amr, true, nil
+	}
+
+	status, err := h.mfa.Status(ctx, user.ID)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	token, _, err := h.mfa.StartChallenge(ctx, user, amr)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	h.renderMFA(c, http.StatusOK, page, token, status.Passkeys > 0)
+	return nil, nil, false, nil
+}
+
+// renderMFA shows the second factor form, carrying the request and the MFA
+// token along. Users with passkeys are also offered to verify with one.
+func (h *OAuthHandler) renderMFA(c *gin.Context, status int, page loginPage, token string, passkey bool) {
+	params := make(map[string]string, len(page.Params)+2)
+	for name, value := range page.Params {
+		params[name] = value
+	}
+	params["mfa_token"] = token
+	if passkey {
+		nonce, err := auth.GenerateOpaqueToken()
+		if err != nil {
+			h.logger.WithField("error", err.Error()).Error("Failed to generate script nonce")
+		} else {
+			params["passkey"] = "1"
+			page.Passkey = true
+			page.Nonce = nonce
+		}
+	}
+	page.Params = params
+	page.Title = "Two-factor authentication"
+	h.render(c, status, "mfa", page)
+}
+
+// clientName is the name shown to users for a client
+func clientName(client *models.Client) string {
+	if client.Name == "" {
+		return client.ID
+	}
+	return client.Name
+}
+
+// renderError shows an error page for requests that cannot be redirected
+func (h *OAuthHandler) renderError(c *gin.Context, status int, message string) {
+	h.render(c, status, "error", loginPage{Title: "Authorization failed", Error: message})
+}
+
+// render writes an HTML page that must not be cached or framed
+func (h *OAuthHandler) render(c *gin.Context, status int, name string, data loginPage) {
+	renderPage(c, h.logger, status, name, data)
+}
+
+// renderPage writes one of the service's HTML pages, which must not be
+// cached or framed
+func renderPage(c *gin.Context, log *logger.Logger, status int, name string, data loginPage) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("X-Frame-Options", "DENY")
+	// Pages can be opened from links carrying tokens, which must not leak
+	c.Header("Referrer-Policy", "no-referrer")
+	csp := "default-src 'none'; style-src 'unsafe-inline'; frame-ancestors 'none'"
+	if data.Nonce != "" {
+		csp += "; script-src 'nonce-" + data.Nonce + "'; connect-src 'self'"
+	}
+	c.Header("Content-Security-Policy", csp)
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(status)
+	if err := oauthTemplates.ExecuteTemplate(c.Writer, name, data); err != nil {
+		log.WithField("error", err.Error()).Error("Failed to render page")
+	}
+}
+
+// tokenError writes a token endpoint error response (RFC 6749 section 5.2)
+func (h *OAuthHandler) tokenError(c *gin.Context, err error) {
+	var oauthErr *oauth.Error
+	if !errors.As(err, &oauthErr) {
+		h.logger.WithField("error", err.Error()).Error("Failed to process token request")
+		oauthErr = oauth.NewError(oauth.ErrorServerError, "")
+	}
+	if oauthErr.Code == oauth.ErrorInvalidClient {
+		c.Header("WWW-Authenticate", `Basic realm="oauth"`)
+	}
+	c.JSON(oauthErr.Status, oauthErr)
+}
+
+// authenticateClient authenticates the client calling a back-channel
+// endpoint, writing the error response itself if that fails
+func (h *OAuthHandler) authenticateClient(c *gin.Context) (*models.Client, bool) {
+	creds, err := clientCredentials(c)
+	if err != nil {
+		h.tokenError(c, err)
+		return nil, false
+	}
+	client, err := h.server.AuthenticateClient(c.Request.Context(), creds)
+	if err != nil {
+		h.tokenError(c, err)
+		return nil, false
+	}
+	return client, true
+}
+
+// clientCredentials extracts client authentication from the Authorization
+// header (client_secret_basic) or the form body (client_secret_post,
+// private_key_jwt, none)
+func clientCredentials(c *gin.Context) (oauth.ClientCredentials, error) {
+	if assertionType := c.PostForm("client_assertion_type"); assertionType != "" {
+		if assertionType != oauth.ClientAssertionTypeJWTBearer {
+			return oauth.ClientCredentials{}, oauth.NewError(oauth.ErrorInvalidClient, "unsupported client_assertion_type")
+		}
+		if _, _, ok := c.Request.BasicAuth(); ok || c.PostForm("client_secret") != "" {
+			return oauth.ClientCredentials{}, oauth.NewError(oauth.ErrorInvalidRequest, "multiple client authentication methods used")
+		}
+		return oauth.ClientCredentials{
+			ID:        c.PostForm("client_id"),
+			Assertion: c.PostForm("client_assertion"),
+			Method:    models.ClientAuthPrivateKeyJWT,
+		}, nil
+	}
+
+	if id, secret, ok := c.Request.BasicAuth(); ok {
+		if c.PostForm("client_secret") != "" {
+			return oauth.ClientCredentials{}, oauth.NewError(oauth.ErrorInvalidRequest, "multiple client authentication methods used")
+		}
+		// RFC 6749 section 2.3.1 form-encodes both values before base64
+		id, idErr := url.QueryUnescape(id)
+		secret, secretErr := url.QueryUnescape(secret)
+		if idErr != nil || secretErr != nil {
+			return oauth.ClientCredentials{}, oauth.NewError(oauth.ErrorInvalidClient, "malformed client credentials")
+		}
+		return oauth.ClientCredentials{ID: id, Secret: secret, Method: models.ClientAuthSecretBasic}, nil
+	}
+
+	if secret := c.PostForm("client_secret"); secret != "" {
+		return oauth.ClientCredentials{ID: c.PostForm("client_id"), Secret: secret, Method: models.ClientAuthSecretPost}, nil
+	}
+	return oauth.ClientCredentials{ID: c.PostForm("client_id"), Method: models.ClientAuthNone}, nil
+}
@@ -0,0 +1,304 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/goldcast/gc_auth_service/internal/app"
+	"github.com/goldcast/gc_auth_service/internal/auth"
+	"github.com/goldcast/gc_auth_service/internal/config"
+	"github.com/goldcast/gc_auth_service/internal/middleware"
+	"github.com/goldcast/gc_auth_service/internal/models"
+	"github.com/goldcast/gc_auth_service/internal/oauth"
+	"github.com/goldcast/gc_auth_service/pkg/jwt"
+	"github.com/goldcast/gc_auth_service/pkg/logger"
+	"github.com/google/uuid"
+)
+
+const (
+	testClientID    = "spa"
+	testRedirectURI = "http://localhost:3000/callback"
+	testEmail       = "alice@example.com"
+	testPassword    = "Tulip-orbit-42x"
+)
+
+// newOAuthTestServer builds the service on in-memory repositories with a
+// public client and a user, and routes the endpoints the code flow uses
+func newOAuthTestServer(t *testing.T) (*app.Container, *gin.Engine) {
+	t.Helper()
+	for key, value := range map[string]string{
+		"ENVIRONMENT":             "test",
+		"LOG_LEVEL":               "error",
+		"DATABASE_URL":            "",
+		"MAILER":                  "memory",
+		"ISSUER_URL":              "http://auth.test",
+		"PASSWORD_HASH_ALGORITHM": "bcrypt",
+		"BCRYPT_COST":             "4",
+	} {
+		t.Setenv(key, value)
+	}
+	cfg := config.Load()
+	c, err := app.New(cfg, logger.New(cfg.LogLevel, cfg.Environment))
+	if err != nil {
+		t.Fatalf("create container: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+
+	ctx := context.Background()
+	if _, _, err := c.ClientRegistry.Create(ctx, oauth.ClientConfig{
+		ID:                      testClientID,
+		Name:                    "Test App",
+		RedirectURIs:            []string{testRedirectURI},
+		GrantTypes:              []string{oauth.GrantAuthorizationCode, oauth.GrantRefreshToken},
+		Scopes:                  []string{"openid", "email", "profile"},
+		TokenEndpointAuthMethod: models.ClientAuthNone,
+	}); err != nil {
+		t.Fatalf("create client: %v", err)
+	}
+	hash, err := c.Hasher.Hash(testPassword)
+	if err != nil {
+		t.Fatalf("hash password: %v", err)
+	}
+	now := time.Now()
+	if err := c.Users.Create(ctx, &models.User{
+		ID:            uuid.New(),
+		Email:         testEmail,
+		Username:      "alice",
+		Password:      hash,
+		FirstName:     "Alice",
+		LastName:      "Example",
+		IsActive:      true,
+		EmailVerified: true,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	oauthHandler := NewOAuthHandler(c)
+	authHandler := NewAuthHandler(c)
+	router.GET("/oauth/authorize", oauthHandler.Authorize)
+	router.POST("/oauth/authorize", oauthHandler.AuthorizeSubmit)
+	router.POST("/oauth/token", oauthHandler.Token)
+	router.GET("/oauth/userinfo", middleware.AuthMiddleware(c.Logger, c.JWT, c.Tokens, ""), middleware.RequireUser(), oauthHandler.UserInfo)
+	router.GET("/api/v1/profile", middleware.AuthMiddleware(c.Logger, c.JWT, c.Tokens, jwt.AudienceFirstParty), middleware.RequireUser(), authHandler.GetProfile)
+	return c, router
+}
+
+// pkcePair returns a code verifier and its S256 code challenge
+func pkcePair(t *testing.T) (string, string) {
+	t.Helper()
+	verifier, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		t.Fatalf("generate verifier: %v", err)
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	return verifier, base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func authorizeParams(challenge string) url.Values {
+	return url.Values{
+		"response_type":         {"code"},
+		"client_id":             {testClientID},
+		"redirect_uri":          {testRedirectURI},
+		"scope":                 {"openid email"},
+		"state":                 {"state-123"},
+		"nonce":                 {"nonce-456"},
+		"code_challenge":        {challenge},
+		"code_challenge_method": {"S256"},
+	}
+}
+
+func serve(router *gin.Engine, req *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func postForm(router *gin.Engine, path string, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return serve(router, req)
+}
+
+func getWithToken(router *gin.Engine, path, accessToken string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	return serve(router, req)
+}
+
+// authorize signs in on the authorization form and returns the code the
+// client is redirected back with
+func authorize(t *testing.T, router *gin.Engine, challenge string) string {
+	t.Helper()
+	form := authorizeParams(challenge)
+	form.Set("email", testEmail)
+	form.Set("password", testPassword)
+	form.Set("action", "allow")
+	rec := postForm(router, "/oauth/authorize", form)
+	if rec.Code != http.StatusFound {
+		t.Fatalf("authorize: status %d, body %s", rec.Code, rec.Body.String())
+	}
+	location, err := url.Parse(rec.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("parse redirect: %v", err)
+	}
+	if got := location.Scheme + "://" + location.Host + location.Path; got != testRedirectURI {
+		t.Fatalf("redirected to %s, want %s", got, testRedirectURI)
+	}
+	if state := location.Query().Get("state"); state != "state-123" {
+		t.Errorf("state %q, want state-123", state)
+	}
+	code := location.Query().Get("code")
+	if code == "" {
+		t.Fatalf("no code in redirect %s", location)
+	}
+	return code
+}
+
+// exchangeCode redeems a code at the token endpoint and decodes the response
+func exchangeCode(t *testing.T, router *gin.Engine, code, verifier string) (int, map[string]interface{}) {
+	t.Helper()
+	form := url.Values{
+		"grant_type":   {oauth.GrantAuthorizationCode},
+		"client_id":    {testClientID},
+		"code":         {code},
+		"redirect_uri": {testRedirectURI},
+	}
+	if verifier != "" {
+		form.Set("code_verifier", verifier)
+	}
+	rec := postForm(router, "/oauth/token", form)
+	var body map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode token response %q: %v", rec.Body.String(), err)
+	}
+	return rec.Code, body
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	_, router := newOAuthTestServer(t)
+	verifier, challenge := pkcePair(t)
+
+	rec := serve(router, httptest.NewRequest(http.MethodGet, "/oauth/authorize?"+authorizeParams(challenge).Encode(), nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("login page: status %d", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "Test App") {
+		t.Errorf("login page does not name the client")
+	}
+
+	code := authorize(t, router, challenge)
+	status, tokens := exchangeCode(t, router, code, verifier)
+	if status != http.StatusOK {
+		t.Fatalf("token: status %d, body %v", status, tokens)
+	}
+	for _, field := range []string{"access_token", "refresh_token", "id_token"} {
+		if s, _ := tokens[field].(string); s == "" {
+			t.Errorf("token response has no %s", field)
+		}
+	}
+	if tokens["token_type"] != "Bearer" {
+		t.Errorf("token_type %v, want Bearer", tokens["token_type"])
+	}
+	accessToken := tokens["access_token"].(string)
+
+	rec = getWithToken(router, "/oauth/userinfo", accessToken)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("userinfo: status %d, body %s", rec.Code, rec.Body.String())
+	}
+	var info map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &info); err != nil {
+		t.Fatalf("decode userinfo: %v", err)
+	}
+	if info["email"] != testEmail {
+		t.Errorf("userinfo email %v, want %s", info["email"], testEmail)
+	}
+
+	// Tokens issued to OAuth clients are not accepted by the first-party API
+	if rec := getWithToken(router, "/api/v1/profile", accessToken); rec.Code != http.StatusUnauthorized {
+		t.Errorf("profile with a client token: status %d, want 401", rec.Code)
+	}
+}
+
+func TestAuthorizationCodePKCE(t *testing.T) {
+	_, router := newOAuthTestServer(t)
+
+	t.Run("challenge required", func(t *testing.T) {
+		params := authorizeParams("")
+		params.Del("code_challenge")
+		params.Del("code_challenge_method")
+		rec := serve(router, httptest.NewRequest(http.MethodGet, "/oauth/authorize?"+params.Encode(), nil))
+		if rec.Code != http.StatusFound {
+			t.Fatalf("status %d, want a redirect with an error", rec.Code)
+		}
+		location, _ := url.Parse(rec.Header().Get("Location"))
+		if got := location.Query().Get("error"); got != oauth.ErrorInvalidRequest {
+			t.Errorf("error %q, want %s", got, oauth.ErrorInvalidRequest)
+		}
+	})
+
+	t.Run("wrong verifier", func(t *testing.T) {
+		_, challenge := pkcePair(t)
+		otherVerifier, _ := pkcePair(t)
+		code := authorize(t, router, challenge)
+		status, body := exchangeCode(t, router, code, otherVerifier)
+		if status != http.StatusBadRequest || body["error"] != oauth.ErrorInvalidGrant {
+			t.Errorf("status %d, body %v; want 400 invalid_grant", status, body)
+		}
+	})
+
+	t.Run("missing verifier", func(t *testing.T) {
+		_, challenge := pkcePair(t)
+		code := authorize(t, router, challenge)
+		status, body := exchangeCode(t, router, code, "")
+		if status != http.StatusBadRequest || body["error"] != oauth.ErrorInvalidRequest {
+			t.Errorf("status %d, body %v; want 400 invalid_request", status, body)
+		}
+	})
+}
+
+func TestAuthorizationCodeReplay(t *testing.T) {
+	_, router := newOAuthTestServer(t)
+	verifier, challenge := pkcePair(t)
+	code := authorize(t, router, challenge)
+
+	status, tokens := exchangeCode(t, router, code, verifier)
+	if status != http.StatusOK {
+		t.Fatalf("first redemption: status %d, body %v", status, tokens)
+	}
+	accessToken := tokens["access_token"].(string)
+	refreshToken := tokens["refresh_token"].(string)
+	if rec := getWithToken(router, "/oauth/userinfo", accessToken); rec.Code != http.StatusOK {
+		t.Fatalf("userinfo before replay: status %d", rec.Code)
+	}
+
+	status, body := exchangeCode(t, router, code, verifier)
+	if status != http.StatusBadRequest || body["error"] != oauth.ErrorInvalidGrant {
+		t.Fatalf("replay: status %d, body %v; want 400 invalid_grant", status, body)
+	}
+
+	// The replay revokes the session the code was first redeemed for
+	if rec := getWithToken(router, "/oauth/userinfo", accessToken); rec.Code != http.StatusUnauthorized {
+		t.Errorf("userinfo after replay: status %d, want 401", rec.Code)
+	}
+	rec := postForm(router, "/oauth/token", url.Values{
+		"grant_type":    {oauth.GrantRefreshToken},
+		"client_id":     {testClientID},
+		"refresh_token": {refreshToken},
+	})
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), oauth.ErrorInvalidGrant) {
+		t.Errorf("refresh after replay: status %d, body %s; want 400 invalid_grant", rec.Code, rec.Body.String())
+	}
+}
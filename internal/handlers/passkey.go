@@ -0,0 +1,262 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/goldcast/gc_auth_service/internal/auth"
+	"github.com/goldcast/gc_auth_service/internal/middleware"
+	"github.com/goldcast/gc_auth_service/internal/models"
+	"github.com/goldcast/gc_auth_service/internal/repository"
+	"github.com/goldcast/gc_auth_service/pkg/jwt"
+	"github.com/google/uuid"
+)
+
+// BeginPasskeyLogin returns the options for a passwordless login with any
+// passkey registered with the service
+func (h *AuthHandler) BeginPasskeyLogin(c *gin.Context) {
+	assertion, token, err := h.passkeys.BeginLogin(c.Request.Context(), nil)
+	if err != nil {
+		h.passkeyError(c, err, "Failed to start passkey login")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Sign in with your passkey",
+		Data:    models.PasskeyOptionsResponse{SessionToken: token, Options: assertion},
+	})
+}
+
+// FinishPasskeyLogin completes a passwordless login with the authenticator's
+// response and issues access tokens. A passkey that verified the user, with
+// a PIN or biometric, counts as a second factor by itself.
+func (h *AuthHandler) FinishPasskeyLogin(c *gin.Context) {
+	var req models.PasskeyLoginRequest
+	if !h.bindJSON(c, &req) {
+		return
+	}
+
+	user, passkey, err := h.passkeys.FinishLogin(c.Request.Context(), req.SessionToken, req.Credential, nil)
+	if err != nil {
+		h.passkeyError(c, err, "Failed to verify passkey login")
+		return
+	}
+	if err := h.authenticator.CheckPolicy(user); err != nil {
+		c.JSON(http.StatusForbidden, models.APIResponse{
+			Success: false,
+			Message: "Email address not verified",
+		})
+		return
+	}
+
+	amr := []string{auth.AMRHardwareKey}
+	if passkey.UserVerified {
+		amr = append(amr, auth.AMRMultiFactor)
+	}
+	h.completeLogin(c, user, amr)
+}
+
+// BeginMFAPasskey returns the options for completing a login that requires a
+// second factor with one of the user's passkeys
+func (h *AuthHandler) BeginMFAPasskey(c *gin.Context) {
+	var req models.MFATokenRequest
+	if !h.bindJSON(c, &req) {
+		return
+	}
+
+	assertion, token, err := h.mfa.BeginPasskeyChallenge(c.Request.Context(), req.MFAToken)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidMFAChallenge) {
+			c.JSON(http.StatusUnauthorized, models.APIResponse{
+				Success: false,
+				Message: "Invalid or expired MFA token",
+			})
+			return
+		}
+		h.passkeyError(c, err, "Failed to start passkey verification")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Verify with your passkey",
+		Data:    models.PasskeyOptionsResponse{SessionToken: token, Options: assertion},
+	})
+}
+
+// ListPasskeys returns the current user's passkeys
+func (h *AuthHandler) ListPasskeys(c *gin.Context) {
+	claims := c.MustGet(middleware.ClaimsKey).(*jwt.Claims)
+
+	passkeys, err := h.passkeys.List(c.Request.Context(), claims.UserID)
+	if err != nil {
+		h.passkeyError(c, err, "Failed to list passkeys")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Passkeys retrieved successfully",
+		Data:    passkeys,
+	})
+}
+
+// BeginPasskeyRegistration returns the options for creating a passkey for
+// the current user
+func (h *AuthHandler) BeginPasskeyRegistration(c *gin.Context) {
+	claims := c.MustGet(middleware.ClaimsKey).(*jwt.Claims)
+
+	user, err := h.users.GetByID(c.Request.Context(), claims.UserID)
+	if err != nil {
+		h.passkeyError(c, err, "Failed to fetch user")
+		return
+	}
+	creation, token, err := h.passkeys.BeginRegistration(c.Request.Context(), user)
+	if err != nil {
+		h.passkeyError(c, err, "Failed to start passkey registration")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Create a passkey with your authenticator",
+		Data:    models.PasskeyOptionsResponse{SessionToken: token, Options: creation},
+	})
+}
+
+// FinishPasskeyRegistration stores the passkey the authenticator created for
+// the current user
+func (h *AuthHandler) FinishPasskeyRegistration(c *gin.Context) {
+	claims := c.MustGet(middleware.ClaimsKey).(*jwt.Claims)
+
+	var req models.PasskeyRegistrationRequest
+	if !h.bindJSON(c, &req) {
+		return
+	}
+
+	user, err := h.users.GetByID(c.Request.Context(), claims.UserID)
+	if err != nil {
+		h.passkeyError(c, err, "Failed to fetch user")
+		return
+	}
+	passkey, err := h.passkeys.FinishRegistration(c.Request.Context(), user, req.SessionToken, req.Name, req.Credential)
+	if err != nil {
+		h.passkeyError(c, err, "Failed to register passkey")
+		return
+	}
+
+	h.logger.WithFields(map[string]interface{}{
+		"user_id":    user.ID,
+		"passkey_id": passkey.ID,
+	}).Info("Passkey registered")
+
+	c.JSON(http.StatusCreated, models.APIResponse{
+		Success: true,
+		Message: "Passkey registered successfully",
+		Data:    passkey,
+	})
+}
+
+// RenamePasskey renames one of the current user's passkeys
+func (h *AuthHandler) RenamePasskey(c *gin.Context) {
+	claims := c.MustGet(middleware.ClaimsKey).(*jwt.Claims)
+
+	id, ok := passkeyID(c)
+	if !ok {
+		return
+	}
+	var req models.RenamePasskeyRequest
+	if !h.bindJSON(c, &req) {
+		return
+	}
+
+	if err := h.passkeys.Rename(c.Request.Context(), claims.UserID, id, req.Name); err != nil {
+		h.passkeyError(c, err, "Failed to rename passkey")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Passkey renamed successfully",
+	})
+}
+
+// DeletePasskey removes one of the current user's passkeys
+func (h *AuthHandler) DeletePasskey(c *gin.Context) {
+	claims := c.MustGet(middleware.ClaimsKey).(*jwt.Claims)
+
+	id, ok := passkeyID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.passkeys.Delete(c.Request.Context(), claims.UserID, id); err != nil {
+		h.passkeyError(c, err, "Failed to delete passkey")
+		return
+	}
+
+	h.logger.WithFields(map[string]interface{}{
+		"user_id":    claims.UserID,
+		"passkey_id": id,
+	}).Info("Passkey deleted")
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Passkey deleted successfully",
+	})
+}
+
+// passkeyID parses the passkey ID in the path, responding with not found if
+// it is malformed
+func passkeyID(c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Message: "Passkey not found",
+		})
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// passkeyError maps errors from the passkey service to responses
+func (h *AuthHandler) passkeyError(c *gin.Context, err error, logMessage string) {
+	switch {
+	case errors.Is(err, auth.ErrInvalidPasskey), errors.Is(err, auth.ErrInvalidWebAuthnSession):
+		h.logger.WithField("error", err.Error()).Warn("Rejected passkey response")
+		c.JSON(http.StatusUnauthorized, models.APIResponse{
+			Success: false,
+			Message: "Invalid passkey",
+		})
+	case errors.Is(err, auth.ErrPasskeyCloned):
+		h.logger.WithField("error", err.Error()).Warn("Possibly cloned passkey used")
+		c.JSON(http.StatusUnauthorized, models.APIResponse{
+			Success: false,
+			Message: "Invalid passkey",
+		})
+	case errors.Is(err, auth.ErrPasskeyExists):
+		c.JSON(http.StatusConflict, models.APIResponse{
+			Success: false,
+			Message: "This authenticator already has a passkey",
+		})
+	case errors.Is(err, auth.ErrMFANotEnabled):
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "No passkeys registered",
+		})
+	case errors.Is(err, repository.ErrNotFound):
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Message: "Passkey not found",
+		})
+	default:
+		h.logger.WithField("error", err.Error()).Error(logMessage)
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "Internal server error",
+		})
+	}
+}
@@ -0,0 +1,274 @@
+package handlers
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/goldcast/gc_auth_service/internal/auth"
+	"github.com/goldcast/gc_auth_service/internal/middleware"
+	"github.com/goldcast/gc_auth_service/internal/models"
+	"github.com/goldcast/gc_auth_service/pkg/jwt"
+	"github.com/goldcast/gc_auth_service/pkg/password"
+)
+
+// ChangePassword replaces the current user's password after checking their
+// current one, optionally signing them out of their other sessions
+func (h *AuthHandler) ChangePassword(c *gin.Context) {
+	claims := c.MustGet(middleware.ClaimsKey).(*jwt.Claims)
+
+	var req models.ChangePasswordRequest
+	if !h.bindJSON(c, &req) {
+		return
+	}
+
+	user, err := h.passwords.Change(c.Request.Context(), claims.UserID, req.CurrentPassword, req.NewPassword, req.SignOutOtherSessions, claims.SessionID)
+	if err != nil {
+		var weak *password.PolicyError
+		var limited *auth.RateLimitError
+		switch {
+		case errors.As(err, &limited):
+			h.logger.WithField("user_id", claims.UserID).Warn("Password change rate limited")
+			tooManyRequests(c, limited)
+		case errors.Is(err, auth.ErrInvalidCredentials):
+			h.logger.WithField("user_id", claims.UserID).Warn("Password change with wrong current password")
+			c.JSON(http.StatusUnauthorized, models.APIResponse{
+				Success: false,
+				Message: "Current password is incorrect",
+			})
+		case errors.As(err, &weak):
+			passwordRejected(c, weak)
+		case errors.Is(err, auth.ErrPasswordReused):
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false,
+				Message: "Choose a password you have not used recently",
+			})
+		default:
+			h.logger.WithField("error", err.Error()).Error("Failed to change password")
+			c.JSON(http.StatusInternalServerError, models.APIResponse{
+				Success: false,
+				Message: "Internal server error",
+			})
+		}
+		return
+	}
+
+	h.audit.Record(c.Request.Context(), &models.AuditEvent{
+		Type:      "password_changed",
+		UserID:    &user.ID,
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		Metadata: map[string]interface{}{
+			"session_id":                claims.SessionID,
+			"signed_out_other_sessions": req.SignOutOtherSessions,
+		},
+	})
+	// The password is changed even if the notification cannot be sent
+	if err := h.passwords.NotifyChanged(c.Request.Context(), user); err != nil {
+		h.logger.WithField("error", err.Error()).Error("Failed to send password change notification")
+	}
+
+	message := "Password changed successfully"
+	if req.SignOutOtherSessions {
+		message = "Password changed successfully. Your other sessions were signed out."
+	}
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: message,
+	})
+}
+
+// PasswordPolicy returns the requirements new passwords must meet, so that
+// clients can show them while the user chooses a password
+func (h *AuthHandler) PasswordPolicy(c *gin.Context) {
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Password policy retrieved successfully",
+		Data:    h.passwords.Policy(),
+	})
+}
+
+// ForgotPassword emails a password reset link. It responds the same whether
+// or not an account uses the address.
+func (h *AuthHandler) ForgotPassword(c *gin.Context) {
+	var req models.ForgotPasswordRequest
+	if !h.bindJSON(c, &req) {
+		return
+	}
+
+	err := h.resets.RequestReset(c.Request.Context(), req.Email, c.ClientIP())
+	var limited *auth.RateLimitError
+	if errors.As(err, &limited) {
+		tooManyRequests(c, limited)
+		return
+	}
+	if err != nil {
+		h.logger.WithField("error", err.Error()).Error("Failed to request password reset")
+	}
+
+	c.JSON(http.StatusAccepted, models.APIResponse{
+		Success: true,
+		Message: "If an account uses this address, a password reset link is on its way",
+	})
+}
+
+// ResetPassword sets a new password with the token from a reset link. It
+// also accepts the form on the page a reset link opens.
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	if c.ContentType() == binding.MIMEPOSTForm {
+		h.resetPasswordForm(c)
+		return
+	}
+
+	var req models.ResetPasswordRequest
+	if !h.bindJSON(c, &req) {
+		return
+	}
+
+	user, err := h.resets.Reset(c.Request.Context(), req.Token, req.Password, c.ClientIP())
+	if err != nil {
+		var limited *auth.RateLimitError
+		var weak *password.PolicyError
+		switch {
+		case errors.As(err, &limited):
+			tooManyRequests(c, limited)
+		case errors.Is(err, auth.ErrInvalidResetToken):
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false,
+				Message: "Invalid or expired reset link",
+			})
+		case errors.As(err, &weak):
+			passwordRejected(c, weak)
+		case errors.Is(err, auth.ErrPasswordReused):
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false,
+				Message: "Choose a password you have not used recently",
+			})
+		default:
+			h.logger.WithField("error", err.Error()).Error("Failed to reset password")
+			c.JSON(http.StatusInternalServerError, models.APIResponse{
+				Success: false,
+				Message: "Internal server error",
+			})
+		}
+		return
+	}
+
+	h.audit.Record(c.Request.Context(), &models.AuditEvent{
+		Type:      "password_reset",
+		UserID:    &user.ID,
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Password reset successfully. Sign in with your new password.",
+	})
+}
+
+// ResetPasswordPage shows the form for choosing a new password that reset
+// links open
+func (h *AuthHandler) ResetPasswordPage(c *gin.Context) {
+	h.renderReset(c, http.StatusOK, c.Query("token"), "")
+}
+
+// resetPasswordForm handles the form on the reset page
+func (h *AuthHandler) resetPasswordForm(c *gin.Context) {
+	var req models.ResetPasswordRequest
+	if err := c.ShouldBindWith(&req, binding.Form); err != nil {
+		h.renderReset(c, http.StatusBadRequest, c.PostForm("token"), "Invalid request.")
+		return
+	}
+	if err := h.validator.Struct(req); err != nil {
+		h.renderReset(c, http.StatusBadRequest, req.Token, "Choose a new password.")
+		return
+	}
+
+	user, err := h.resets.Reset(c.Request.Context(), req.Token, req.Password, c.ClientIP())
+	if err != nil {
+		var limited *auth.RateLimitError
+		var weak *password.PolicyError
+		switch {
+		case errors.As(err, &limited):
+			c.Header("Retry-After", retryAfter(limited))
+			renderPage(c, h.logger, http.StatusTooManyRequests, "message", loginPage{
+				Title:   "Too many attempts",
+				Message: "Too many password reset attempts. Please try again later.",
+			})
+		case errors.As(err, &weak):
+			h.renderReset(c, http.StatusBadRequest, req.Token, "Choose a stronger password.", weak.Violations...)
+		case errors.Is(err, auth.ErrPasswordReused):
+			h.renderReset(c, http.StatusBadRequest, req.Token, "Choose a password you have not used recently.")
+		case errors.Is(err, auth.ErrInvalidResetToken):
+			renderPage(c, h.logger, http.StatusBadRequest, "message", loginPage{
+				Title:   "Link expired",
+				Message: "This password reset link is invalid, expired or was already used. Request a new one to reset your password.",
+			})
+		default:
+			h.logger.WithField("error", err.Error()).Error("Failed to reset password")
+			renderPage(c, h.logger, http.StatusInternalServerError, "message", loginPage{
+				Title:   "Something went wrong",
+				Message: "We could not reset your password. Please try again later.",
+			})
+		}
+		return
+	}
+
+	h.audit.Record(c.Request.Context(), &models.AuditEvent{
+		Type:      "password_reset",
+		UserID:    &user.ID,
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+
+	renderPage(c, h.logger, http.StatusOK, "message", loginPage{
+		Title:   "Password reset",
+		Message: "Your password was changed and you were signed out everywhere. Sign in with your new password.",
+	})
+}
+
+// renderReset renders the form for choosing a new password, listing the
+// requirements of the password policy a rejected password did not meet
+func (h *AuthHandler) renderReset(c *gin.Context, status int, token, message string, violations ...password.Violation) {
+	hints := make([]string, len(violations))
+	for i, v := range violations {
+		hints[i] = v.Message
+	}
+	renderPage(c, h.logger, status, "reset", loginPage{
+		Title:  "Reset password",
+		Action: c.Request.URL.Path,
+		Params: map[string]string{"token": token},
+		Error:  message,
+		Hints:  hints,
+	})
+}
+
+// passwordRejected responds to a client that chose a password which does not
+// meet the password policy, listing the requirements it failed so that they
+// can be shown to the user
+func passwordRejected(c *gin.Context, err *password.PolicyError) {
+	c.JSON(http.StatusBadRequest, models.APIResponse{
+		Success: false,
+		Message: "Password does not meet the requirements",
+		Data:    err,
+	})
+}
+
+// tooManyRequests responds to a client that exceeded a rate limit
+func tooManyRequests(c *gin.Context, err *auth.RateLimitError) {
+	c.Header("Retry-After", retryAfter(err))
+	c.JSON(http.StatusTooManyRequests, models.APIResponse{
+		Success: false,
+		Message: "Too many requests",
+	})
+}
+
+// retryAfter formats the Retry-After header for a rate limited client, in
+// whole seconds
+func retryAfter(err *auth.RateLimitError) string {
+	return strconv.Itoa(int(math.Ceil(err.RetryAfter.Seconds())))
+}
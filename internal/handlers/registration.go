@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/goldcast/gc_auth_service/internal/oauth"
+)
+
+// Register registers a client from the metadata it sends (RFC 7591). The
+// caller must present the initial access token as a bearer token.
+func (h *OAuthHandler) Register(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+
+	if h.registrationToken == "" {
+		c.JSON(http.StatusNotFound, oauth.NewError(oauth.ErrorInvalidRequest, "dynamic client registration is disabled"))
+		return
+	}
+
+	provided := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(provided), []byte(h.registrationToken)) != 1 {
+		h.logger.WithField("client_ip", c.ClientIP()).Warn("Rejected client registration request")
+		c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+		c.JSON(http.StatusUnauthorized, oauth.NewError(oauth.ErrorInvalidToken, "invalid initial access token"))
+		return
+	}
+
+	var req oauth.RegistrationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, oauth.NewError(oauth.ErrorInvalidClientMetadata, "invalid request payload"))
+		return
+	}
+
+	resp, err := h.registry.Register(c.Request.Context(), &req)
+	if err != nil {
+		var oauthErr *oauth.Error
+		if errors.As(err, &oauthErr) {
+			c.JSON(oauthErr.Status, oauthErr)
+			return
+		}
+		h.logger.WithField("error", err.Error()).Error("Failed to register client")
+		c.JSON(http.StatusInternalServerError, oauth.NewError(oauth.ErrorServerError, ""))
+		return
+	}
+
+	h.logger.WithField("client_id", resp.ClientID).Info("OAuth client registered")
+
+	c.JSON(http.StatusCreated, resp)
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/goldcast/gc_auth_service/internal/app"
+	"github.com/goldcast/gc_auth_service/internal/oauth"
+	"github.com/goldcast/gc_auth_service/pkg/jwt"
+)
+
+// WellKnownHandler serves the public discovery documents under /.well-known
+type WellKnownHandler struct {
+	jwtService   *jwt.Service
+	oauth        *oauth.Server
+	registration bool
+}
+
+// NewWellKnownHandler creates a new well-known handler
+func NewWellKnownHandler(c *app.Container) *WellKnownHandler {
+	return &WellKnownHandler{
+		jwtService:   c.JWT,
+		oauth:        c.OAuth,
+		registration: c.Config.RegistrationToken != "",
+	}
+}
+
+// JWKS publishes the public keys used to verify tokens issued by the service
+func (h *WellKnownHandler) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.jwtService.JWKS())
+}
+
+// OpenIDConfiguration publishes the OpenID Provider metadata. The
+// registration endpoint is only advertised while registration is enabled.
+func (h *WellKnownHandler) OpenIDConfiguration(c *gin.Context) {
+	doc := h.oauth.Discovery()
+	if h.registration {
+		doc.RegistrationEndpoint = doc.Issuer + "/oauth/register"
+	}
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, doc)
+}
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/goldcast/gc_auth_service/internal/models"
+	"github.com/goldcast/gc_auth_service/pkg/logger"
+)
+
+// AdminTokenHeader carries the admin API token
+const AdminTokenHeader = "X-Admin-Token"
+
+// AdminAuth restricts a route group to callers presenting the admin API
+// token. The admin API is disabled entirely when no token is configured.
+func AdminAuth(log *logger.Logger, adminToken string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if adminToken == "" {
+			c.JSON(http.StatusNotFound, models.APIResponse{
+				Success: false,
+				Message: "Admin API is disabled",
+			})
+			c.Abort()
+			return
+		}
+
+		provided := c.GetHeader(AdminTokenHeader)
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(adminToken)) != 1 {
+			log.WithField("client_ip", c.ClientIP()).Warn("Rejected admin API request")
+			c.JSON(http.StatusUnauthorized, models.APIResponse{
+				Success: false,
+				Message: "Invalid admin token",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"context"
 	"net/http"
 	"strings"
 
@@ -10,8 +11,22 @@ import (
 	"github.com/goldcast/gc_auth_service/pkg/logger"
 )
 
-// AuthMiddleware validates JWT tokens
-func AuthMiddleware(log *logger.Logger, jwtService *jwt.Service) gin.HandlerFunc {
+// Context keys under which AuthMiddleware stores the authenticated principal
+const (
+	ClaimsKey    = "token_claims"
+	PrincipalKey = "principal" // jwt.PrincipalUser or jwt.PrincipalClient
+	ClientIDKey  = "client_id"
+)
+
+// RevocationChecker reports whether a validated access token has been revoked
+type RevocationChecker interface {
+	IsAccessTokenRevoked(ctx context.Context, claims *jwt.Claims) (bool, error)
+}
+
+// AuthMiddleware validates JWT tokens, which must be intended for the
+// audience. An empty audience accepts tokens issued for any audience, for
+// endpoints such as userinfo that check the token's scopes instead.
+func AuthMiddleware(log *logger.Logger, jwtService *jwt.Service, revocations RevocationChecker, audience string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -37,7 +52,7 @@ func AuthMiddleware(log *logger.Logger, jwtService *jwt.Service) gin.HandlerFunc
 		token := strings.TrimPrefix(authHeader, "Bearer ")
 
 		// Validate the token
-		claims, err := jwtService.ValidateToken(token)
+		claims, err := jwtService.ValidateAccessToken(token, audience)
 		if err != nil {
 			log.WithField("error", err.Error()).Warn("Invalid token")
 			c.JSON(http.StatusUnauthorized, models.APIResponse{
@@ -48,11 +63,54 @@ func AuthMiddleware(log *logger.Logger, jwtService *jwt.Service) gin.HandlerFunc
 			return
 		}
 
-		// Set user information in context
-		c.Set("user_id", claims.UserID)
-		c.Set("user_email", claims.Email)
-		c.Set("user_username", claims.Username)
+		// Reject tokens revoked by logout
+		revoked, err := revocations.IsAccessTokenRevoked(c.Request.Context(), claims)
+		if err != nil {
+			log.WithField("error", err.Error()).Error("Failed to check token revocation")
+			c.JSON(http.StatusInternalServerError, models.APIResponse{
+				Success: false,
+				Message: "Internal server error",
+			})
+			c.Abort()
+			return
+		}
+		if revoked {
+			c.JSON(http.StatusUnauthorized, models.APIResponse{
+				Success: false,
+				Message: "Invalid or expired token",
+			})
+			c.Abort()
+			return
+		}
+
+		// Set principal information in context. User details are only set
+		// for user tokens so handlers cannot mistake a client for a user.
+		principal := claims.Principal()
+		c.Set(ClaimsKey, claims)
+		c.Set(PrincipalKey, principal)
+		c.Set(ClientIDKey, claims.ClientID)
+		if principal == jwt.PrincipalUser {
+			c.Set("user_id", claims.UserID)
+			c.Set("user_email", claims.Email)
+			c.Set("user_username", claims.Username)
+		}
+
+		c.Next()
+	}
+}
 
+// RequireUser rejects requests authenticated with a client token. It must run
+// after AuthMiddleware.
+func RequireUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString(PrincipalKey) != jwt.PrincipalUser {
+			c.JSON(http.StatusForbidden, models.APIResponse{
+				Success: false,
+				Message: "This endpoint requires a user token",
+			})
+			c.Abort()
+			return
+		}
 		c.Next()
 	}
 }
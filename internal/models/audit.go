@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AuditEvent is a security-relevant action kept in the audit trail, such as
+// a password change
+type AuditEvent struct {
+	ID        uuid.UUID              `json:"id" db:"id"`
+	UserID    *uuid.UUID             `json:"user_id,omitempty" db:"user_id"` // the user the event concerns, if any
+	Type      string                 `json:"event_type" db:"event_type"`
+	IPAddress string                 `json:"ip_address" db:"ip_address"`
+	UserAgent string                 `json:"user_agent" db:"user_agent"`
+	Metadata  map[string]interface{} `json:"metadata" db:"metadata"`
+	CreatedAt time.Time              `json:"created_at" db:"created_at"`
+}
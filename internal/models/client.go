@@ -0,0 +1,143 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Token endpoint authentication methods
+const (
+	ClientAuthSecretBasic   = "client_secret_basic"
+	ClientAuthSecretPost    = "client_secret_post"
+	ClientAuthPrivateKeyJWT = "private_key_jwt"
+	ClientAuthNone          = "none"
+)
+
+// Client is a registered OAuth client
+type Client struct {
+	ID                      string          `json:"client_id" db:"id"`
+	SecretHash              string          `json:"-" db:"secret_hash"`
+	Name                    string          `json:"client_name" db:"name"`
+	RedirectURIs            []string        `json:"redirect_uris" db:"redirect_uris"`
+	GrantTypes              []string        `json:"grant_types" db:"grant_types"`
+	Scopes                  []string        `json:"scopes" db:"scopes"`
+	TokenEndpointAuthMethod string          `json:"token_endpoint_auth_method" db:"token_endpoint_auth_method"`
+	JWKS                    json.RawMessage `json:"jwks,omitempty" db:"jwks"`                               // public keys for private_key_jwt
+	AccessTokenTTL          int             `json:"access_token_ttl" db:"access_token_ttl"`                 // in seconds, 0 uses the service default
+	RefreshTokenTTL         int             `json:"refresh_token_ttl" db:"refresh_token_ttl"`               // in seconds, 0 uses the service default
+	Audience                []string        `json:"audience" db:"audience"`                                 // aud of access tokens issued to the client
+	TokenExchangeAudiences  []string        `json:"token_exchange_audiences" db:"token_exchange_audiences"` // audiences the client may exchange tokens for
+	PreviousSecretHash      string          `json:"-" db:"previous_secret_hash"`
+	PreviousSecretExpiresAt *time.Time      `json:"previous_secret_expires_at,omitempty" db:"previous_secret_expires_at"`
+	CreatedAt               time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt               time.Time       `json:"updated_at" db:"updated_at"`
+}
+
+// ClientResponse is a client together with a newly issued secret. The
+// secret is only ever returned once, when it is created.
+type ClientResponse struct {
+	*Client
+	ClientSecret string `json:"client_secret,omitempty"`
+}
+
+// RotateClientSecretRequest represents the request payload for rotating a
+// client secret. The previous secret keeps working for the overlap, which
+// defaults to a day.
+type RotateClientSecretRequest struct {
+	OverlapSeconds *int `json:"overlap_seconds" validate:"omitempty,min=0,max=2592000"`
+}
+
+// IsPublic reports whether the client cannot keep a secret, e.g. a SPA or native app
+func (c *Client) IsPublic() bool {
+	return c.TokenEndpointAuthMethod == ClientAuthNone
+}
+
+// UsesSecret reports whether the client authenticates with a client secret
+func (c *Client) UsesSecret() bool {
+	return c.TokenEndpointAuthMethod == ClientAuthSecretBasic || c.TokenEndpointAuthMethod == ClientAuthSecretPost
+}
+
+// AllowsGrant reports whether the client may use the given grant type
+func (c *Client) AllowsGrant(grantType string) bool {
+	for _, g := range c.GrantTypes {
+		if g == grantType {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsRedirectURI reports whether the redirect URI is registered for the client
+func (c *Client) AllowsRedirectURI(redirectURI string) bool {
+	for _, uri := range c.RedirectURIs {
+		if uri == redirectURI {
+			return true
+		}
+	}
+	return false
+}
+
+// AuthorizationCode is a server-side record of an issued authorization code.
+// Only a hash of the code is stored.
+type AuthorizationCode struct {
+	ID                  uuid.UUID  `json:"id" db:"id"`
+	CodeHash            string     `json:"-" db:"code_hash"`
+	ClientID            string     `json:"client_id" db:"client_id"`
+	UserID              uuid.UUID  `json:"user_id" db:"user_id"`
+	RedirectURI         string     `json:"redirect_uri" db:"redirect_uri"`
+	Scope               string     `json:"scope" db:"scope"`
+	CodeChallenge       string     `json:"-" db:"code_challenge"`
+	CodeChallengeMethod string     `json:"-" db:"code_challenge_method"`
+	Nonce               string     `json:"-" db:"nonce"`
+	AuthTime            time.Time  `json:"auth_time" db:"auth_time"`
+	AMR                 []string   `json:"amr" db:"amr"`
+	CreatedAt           time.Time  `json:"created_at" db:"created_at"`
+	ExpiresAt           time.Time  `json:"expires_at" db:"expires_at"`
+	UsedAt              *time.Time `json:"used_at,omitempty" db:"used_at"`
+	SessionID           *uuid.UUID `json:"session_id,omitempty" db:"session_id"`
+}
+
+// Device code states
+const (
+	DeviceCodePending  = "pending"
+	DeviceCodeApproved = "approved"
+	DeviceCodeDenied   = "denied"
+)
+
+// DeviceCode is a pending device authorization (RFC 8628). The device polls
+// with the device code, of which only a hash is stored, while the user
+// approves the request by entering the user code.
+type DeviceCode struct {
+	ID             uuid.UUID  `json:"id" db:"id"`
+	DeviceCodeHash string     `json:"-" db:"device_code_hash"`
+	UserCode       string     `json:"user_code" db:"user_code"`
+	ClientID       string     `json:"client_id" db:"client_id"`
+	Scope          string     `json:"scope" db:"scope"`
+	Status         string     `json:"status" db:"status"`
+	UserID         *uuid.UUID `json:"user_id,omitempty" db:"user_id"`
+	AuthTime       *time.Time `json:"auth_time,omitempty" db:"auth_time"`
+	AMR            []string   `json:"amr" db:"amr"`
+	Interval       int        `json:"interval" db:"poll_interval"` // in seconds
+	LastPolledAt   *time.Time `json:"last_polled_at,omitempty" db:"last_polled_at"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	ExpiresAt      time.Time  `json:"expires_at" db:"expires_at"`
+	UsedAt         *time.Time `json:"used_at,omitempty" db:"used_at"`
+	SessionID      *uuid.UUID `json:"session_id,omitempty" db:"session_id"`
+}
+
+// DeviceApprovalRequest represents the request payload for approving or
+// denying a device authorization as the signed-in user
+type DeviceApprovalRequest struct {
+	UserCode string `json:"user_code" validate:"required"`
+	Approve  bool   `json:"approve"`
+}
+
+// DeviceApprovalResponse describes the device authorization that was decided
+type DeviceApprovalResponse struct {
+	ClientID   string `json:"client_id"`
+	ClientName string `json:"client_name"`
+	Scope      string `json:"scope"`
+	Status     string `json:"status"`
+}
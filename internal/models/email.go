@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EmailVerification records a verification link sent to a user. The token in
+// the link is signed and carries the record's ID, so that each link can only
+// be used once.
+type EmailVerification struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	UserID    uuid.UUID  `json:"user_id" db:"user_id"`
+	Email     string     `json:"email" db:"email"` // the address the link was sent to
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at" db:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty" db:"used_at"`
+}
+
+// VerifyEmailRequest represents the request payload for verifying an email address
+type VerifyEmailRequest struct {
+	Token string `json:"token" form:"token" validate:"required"`
+}
+
+// ResendVerificationRequest represents the request payload for sending a new
+// verification link
+type ResendVerificationRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
@@ -0,0 +1,85 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TOTPCredential is a user's authenticator app enrollment. The secret is
+// stored encrypted, and the enrollment only takes effect once the user has
+// confirmed it with a code.
+type TOTPCredential struct {
+	UserID          uuid.UUID  `json:"user_id" db:"user_id"`
+	SecretEncrypted string     `json:"-" db:"secret_encrypted"`
+	ConfirmedAt     *time.Time `json:"confirmed_at,omitempty" db:"confirmed_at"`
+	LastUsedStep    int64      `json:"-" db:"last_used_step"` // time step of the last accepted code, to prevent replay
+	CreatedAt       time.Time  `json:"created_at" db:"created_at"`
+}
+
+// RecoveryCode is a one-time code for signing in without the authenticator
+// app. Only a hash of the code is stored.
+type RecoveryCode struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	UserID    uuid.UUID  `json:"user_id" db:"user_id"`
+	CodeHash  string     `json:"-" db:"code_hash"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty" db:"used_at"`
+}
+
+// MFAChallenge is a pending second-factor verification, started after the
+// user signed in with their password. Only a hash of the MFA token is stored.
+type MFAChallenge struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	TokenHash string     `json:"-" db:"token_hash"`
+	UserID    uuid.UUID  `json:"user_id" db:"user_id"`
+	AMR       []string   `json:"amr" db:"amr"` // methods the user already authenticated with
+	Attempts  int        `json:"attempts" db:"attempts"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at" db:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty" db:"used_at"`
+}
+
+// MFACodeRequest represents a request confirmed with a TOTP or recovery code
+type MFACodeRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+// MFAVerifyRequest represents the request payload for completing a login
+// with a second factor: either a TOTP or recovery code, or a passkey
+// assertion together with its session token
+type MFAVerifyRequest struct {
+	MFAToken     string          `json:"mfa_token" validate:"required"`
+	Code         string          `json:"code" validate:"required_without=Credential"`
+	SessionToken string          `json:"session_token" validate:"required_with=Credential"`
+	Credential   json.RawMessage `json:"credential"`
+}
+
+// MFAChallengeResponse is returned by login instead of tokens when the user
+// must still verify a second factor
+type MFAChallengeResponse struct {
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// TOTPEnrollmentResponse carries a new TOTP secret, both plain for manual
+// entry and as an otpauth:// URI for rendering as a QR code
+type TOTPEnrollmentResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+// RecoveryCodesResponse carries newly generated recovery codes, which are
+// only shown once
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// MFAStatus describes a user's second factors
+type MFAStatus struct {
+	TOTPEnabled            bool `json:"totp_enabled"`
+	Passkeys               int  `json:"passkeys"`
+	RecoveryCodesRemaining int  `json:"recovery_codes_remaining"`
+}
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WebAuthn ceremonies a WebAuthnSession can be started for
+const (
+	WebAuthnCeremonyRegistration = "registration"
+	WebAuthnCeremonyLogin        = "login"
+)
+
+// Passkey is a WebAuthn credential registered by a user, usable for
+// passwordless login and as a second factor
+type Passkey struct {
+	ID              uuid.UUID  `json:"id" db:"id"`
+	UserID          uuid.UUID  `json:"user_id" db:"user_id"`
+	CredentialID    []byte     `json:"-" db:"credential_id"`
+	PublicKey       []byte     `json:"-" db:"public_key"` // COSE encoded
+	AttestationType string     `json:"attestation_type" db:"attestation_type"`
+	AAGUID          uuid.UUID  `json:"aaguid" db:"aaguid"` // authenticator model
+	SignCount       uint32     `json:"-" db:"sign_count"`
+	Transports      []string   `json:"transports" db:"transports"`
+	UserVerified    bool       `json:"user_verified" db:"user_verified"`
+	BackupEligible  bool       `json:"backup_eligible" db:"backup_eligible"`
+	BackupState     bool       `json:"backup_state" db:"backup_state"` // synced to other devices
+	Name            string     `json:"name" db:"name"`
+	CreatedAt       time.Time  `json:"created_at" db:"created_at"`
+	LastUsedAt      *time.Time `json:"last_used_at,omitempty" db:"last_used_at"`
+}
+
+// WebAuthnSession holds the state of a registration or login ceremony
+// between its options being issued and the authenticator's response. Only a
+// hash of the session token is stored.
+type WebAuthnSession struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	TokenHash string     `json:"-" db:"token_hash"`
+	UserID    *uuid.UUID `json:"user_id,omitempty" db:"user_id"` // nil for passwordless logins
+	Ceremony  string     `json:"ceremony" db:"ceremony"`
+	Data      []byte     `json:"-" db:"data"` // the library's session data, as JSON
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at" db:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty" db:"used_at"`
+}
+
+// PasskeyOptionsResponse carries the options to pass to
+// navigator.credentials.create() or get(), and the session token to send
+// back with the authenticator's response
+type PasskeyOptionsResponse struct {
+	SessionToken string      `json:"session_token"`
+	Options      interface{} `json:"options"`
+}
+
+// PasskeyRegistrationRequest represents the request payload for completing
+// a passkey registration
+type PasskeyRegistrationRequest struct {
+	SessionToken string          `json:"session_token" validate:"required"`
+	Name         string          `json:"name" validate:"max=64"`
+	Credential   json.RawMessage `json:"credential" validate:"required"`
+}
+
+// PasskeyLoginRequest represents the request payload for completing a
+// passwordless login
+type PasskeyLoginRequest struct {
+	SessionToken string          `json:"session_token" validate:"required"`
+	Credential   json.RawMessage `json:"credential" validate:"required"`
+}
+
+// RenamePasskeyRequest represents the request payload for renaming a passkey
+type RenamePasskeyRequest struct {
+	Name string `json:"name" validate:"required,max=64"`
+}
+
+// MFATokenRequest represents a request made within a pending MFA challenge
+type MFATokenRequest struct {
+	MFAToken string `json:"mfa_token" validate:"required"`
+}
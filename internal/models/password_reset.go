@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PasswordReset is a password reset link sent to a user. Only a hash of the
+// token in the link is stored.
+type PasswordReset struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	TokenHash string     `json:"-" db:"token_hash"`
+	UserID    uuid.UUID  `json:"user_id" db:"user_id"`
+	Email     string     `json:"email" db:"email"` // the address the link was sent to
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at" db:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty" db:"used_at"`
+}
+
+// ForgotPasswordRequest represents the request payload for requesting a
+// password reset link
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// ResetPasswordRequest represents the request payload for choosing a new
+// password with the token from a reset link
+type ResetPasswordRequest struct {
+	Token    string `json:"token" form:"token" validate:"required"`
+	Password string `json:"password" form:"password" validate:"required"`
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Session is a login session. All refresh tokens rotated from the same login
+// belong to one session, which acts as the refresh token family.
+type Session struct {
+	ID         uuid.UUID  `json:"id" db:"id"`
+	UserID     uuid.UUID  `json:"user_id" db:"user_id"`
+	ClientID   string     `json:"client_id,omitempty" db:"client_id"` // empty for first-party logins
+	Scope      string     `json:"scope,omitempty" db:"scope"`
+	AuthTime   time.Time  `json:"auth_time" db:"auth_time"` // when the user last actively authenticated
+	AMR        []string   `json:"amr" db:"amr"`             // authentication methods used, e.g. "pwd"
+	UserAgent  string     `json:"user_agent" db:"user_agent"`
+	IPAddress  string     `json:"ip_address" db:"ip_address"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	LastUsedAt time.Time  `json:"last_used_at" db:"last_used_at"`
+	ExpiresAt  time.Time  `json:"expires_at" db:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+}
+
+// RefreshToken is a server-side record of an opaque refresh token. Only a
+// hash of the token is stored.
+type RefreshToken struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	SessionID uuid.UUID  `json:"session_id" db:"session_id"`
+	UserID    uuid.UUID  `json:"user_id" db:"user_id"`
+	TokenHash string     `json:"-" db:"token_hash"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at" db:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty" db:"used_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+}
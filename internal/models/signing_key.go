@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// Signing key states
+const (
+	SigningKeyActive  = "active"
+	SigningKeyRetired = "retired"
+)
+
+// SigningKey is a managed JWT signing key. Exactly one key is active at a
+// time; retired keys keep verifying tokens until they expire.
+type SigningKey struct {
+	ID         string     `json:"kid" db:"kid"`
+	Algorithm  string     `json:"alg" db:"algorithm"`
+	PrivateKey string     `json:"-" db:"private_key"` // encrypted PKCS#8 PEM
+	Status     string     `json:"status" db:"status"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	RetiredAt  *time.Time `json:"retired_at,omitempty" db:"retired_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty" db:"expires_at"`
+}
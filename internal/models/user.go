@@ -15,15 +15,17 @@ type User struct {
 	FirstName string    `json:"first_name" db:"first_name" validate:"required,min=2,max=50"`
 	LastName  string    `json:"last_name" db:"last_name" validate:"required,min=2,max=50"`
 	IsActive  bool      `json:"is_active" db:"is_active"`
-	CreatedAt time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	// EmailVerified is set once the user proves they receive mail at Email
+	EmailVerified bool      `json:"email_verified" db:"email_verified"`
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at" db:"updated_at"`
 }
 
 // RegisterRequest represents the request payload for user registration
 type RegisterRequest struct {
 	Email     string `json:"email" validate:"required,email"`
 	Username  string `json:"username" validate:"required,min=3,max=20"`
-	Password  string `json:"password" validate:"required,min=6"`
+	Password  string `json:"password" validate:"required"`
 	FirstName string `json:"first_name" validate:"required,min=2,max=50"`
 	LastName  string `json:"last_name" validate:"required,min=2,max=50"`
 }
@@ -47,10 +49,19 @@ type RefreshTokenRequest struct {
 	RefreshToken string `json:"refresh_token" validate:"required"`
 }
 
+// RefreshTokenResponse represents the response payload for a successful token refresh
+type RefreshTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
 // ChangePasswordRequest represents the request payload for password change
 type ChangePasswordRequest struct {
 	CurrentPassword string `json:"current_password" validate:"required"`
-	NewPassword     string `json:"new_password" validate:"required,min=6"`
+	NewPassword     string `json:"new_password" validate:"required"`
+	// SignOutOtherSessions ends all of the user's sessions but the current one
+	SignOutOtherSessions bool `json:"sign_out_other_sessions"`
 }
 
 // APIResponse represents a standard API response
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Statuses of rows in a user import
+const (
+	ImportCreated     = "created"
+	ImportWouldCreate = "would_create" // in a dry run
+	ImportSkipped     = "skipped"      // a user with the email address exists
+	ImportFailed      = "failed"
+)
+
+// ImportUserRecord is a user to import with the password hash of the system
+// they come from. In CSV files the column names are the JSON field names.
+type ImportUserRecord struct {
+	Email         string     `json:"email" validate:"required,email"`
+	Username      string     `json:"username" validate:"required,min=3,max=20"`
+	FirstName     string     `json:"first_name" validate:"max=50"`
+	LastName      string     `json:"last_name" validate:"max=50"`
+	EmailVerified bool       `json:"email_verified"`
+	IsActive      *bool      `json:"is_active"` // active unless false
+	CreatedAt     *time.Time `json:"created_at"`
+	// HashFormat is one of argon2id, bcrypt, firebase_scrypt, django_pbkdf2
+	// and sha256_salted
+	HashFormat   string `json:"hash_format" validate:"required"`
+	PasswordHash string `json:"password_hash" validate:"required"`
+	Salt         string `json:"salt"`
+	SaltPosition string `json:"salt_position"` // prefix or suffix, for sha256_salted
+}
+
+// ImportRowResult is the outcome of importing one row
+type ImportRowResult struct {
+	Line   int        `json:"line"` // in the file, counting the CSV header
+	Email  string     `json:"email,omitempty"`
+	Status string     `json:"status"`
+	UserID *uuid.UUID `json:"user_id,omitempty"` // of the created or existing user
+	Error  string     `json:"error,omitempty"`
+}
+
+// ImportReport summarizes a user import
+type ImportReport struct {
+	DryRun      bool              `json:"dry_run"`
+	Total       int               `json:"total"`
+	Created     int               `json:"created"`
+	WouldCreate int               `json:"would_create"`
+	Skipped     int               `json:"skipped"`
+	Failed      int               `json:"failed"`
+	Rows        []ImportRowResult `json:"rows"`
+}
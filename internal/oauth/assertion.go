@@ -0,0 +1,85 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+	"github.com/goldcast/gc_auth_service/internal/models"
+	"github.com/goldcast/gc_auth_service/pkg/jwt"
+)
+
+// ClientAssertionTypeJWTBearer is the only client_assertion_type accepted (RFC 7523)
+const ClientAssertionTypeJWTBearer = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
+// maxAssertionLifetime bounds how far in the future a client assertion may
+// expire, which also bounds how long its jti has to be remembered
+const maxAssertionLifetime = 10 * time.Minute
+
+// verifyAssertion authenticates a private_key_jwt client (RFC 7523 section 3):
+// the assertion must be signed by one of the client's registered keys, name
+// the client as issuer and subject, be addressed to this server and not have
+// been used before
+func (s *Server) verifyAssertion(ctx context.Context, client *models.Client, assertion string) error {
+	if assertion == "" {
+		return NewError(ErrorInvalidClient, "client_assertion is required")
+	}
+
+	var jwks jwt.JWKS
+	if err := json.Unmarshal(client.JWKS, &jwks); err != nil || len(jwks.Keys) == 0 {
+		return NewError(ErrorInvalidClient, "client has no registered keys")
+	}
+
+	var claims gojwt.RegisteredClaims
+	if err := jwt.ParseWithJWKS(assertion, jwks, &claims); err != nil {
+		return NewError(ErrorInvalidClient, "client assertion is invalid: %v", err)
+	}
+	if claims.Issuer != client.ID || claims.Subject != client.ID {
+		return NewError(ErrorInvalidClient, "client assertion must have the client as iss and sub")
+	}
+	if !s.acceptsAudience(claims.Audience) {
+		return NewError(ErrorInvalidClient, "client assertion has the wrong audience")
+	}
+	now := time.Now()
+	if claims.ExpiresAt.Time.After(now.Add(maxAssertionLifetime)) {
+		return NewError(ErrorInvalidClient, "client assertion expires too far in the future")
+	}
+	if claims.ID == "" {
+		return NewError(ErrorInvalidClient, "client assertion must have a jti")
+	}
+
+	// Remember the jti until the assertion expires so it cannot be replayed.
+	// Claiming it is a single step, so that of concurrent requests with the
+	// same assertion only one is accepted.
+	jti := "client_assertion:" + client.ID + ":" + claims.ID
+	claimed, err := s.revocations.ClaimToken(ctx, jti, claims.ExpiresAt.Time, now)
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		return NewError(ErrorInvalidClient, "client assertion has already been used")
+	}
+	return nil
+}
+
+// acceptsAudience reports whether an assertion audience names this server,
+// either by its token endpoint or its issuer identifier
+func (s *Server) acceptsAudience(audience gojwt.ClaimStrings) bool {
+	for _, aud := range audience {
+		if aud == s.issuer || aud == s.issuer+"/oauth/token" {
+			return true
+		}
+	}
+	return false
+}
+
+// assertionSubject returns the unverified subject of a client assertion,
+// which identifies the client before its signature can be checked
+func assertionSubject(assertion string) string {
+	var claims gojwt.RegisteredClaims
+	if _, _, err := gojwt.NewParser().ParseUnverified(assertion, &claims); err != nil {
+		return ""
+	}
+	return claims.Subject
+}
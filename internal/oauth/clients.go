@@ -0,0 +1,238 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/goldcast/gc_auth_service/internal/models"
+	"github.com/goldcast/gc_auth_service/internal/repository"
+	"github.com/goldcast/gc_auth_service/pkg/jwt"
+	"github.com/goldcast/gc_auth_service/pkg/password"
+)
+
+// ClientConfig describes a client in the OAUTH_CLIENTS_FILE or the admin
+// API. Secrets are given in plain text and hashed before they are stored.
+type ClientConfig struct {
+	ID                      string    `json:"client_id"`
+	Secret                  string    `json:"client_secret"`
+	Name                    string    `json:"client_name"`
+	RedirectURIs            []string  `json:"redirect_uris"`
+	GrantTypes              []string  `json:"grant_types"`
+	Scopes                  []string  `json:"scopes"`
+	TokenEndpointAuthMethod string    `json:"token_endpoint_auth_method"`
+	JWKS                    *jwt.JWKS `json:"jwks,omitempty"`
+	AccessTokenTTL          int       `json:"access_token_ttl"`  // in seconds, 0 uses the service default
+	RefreshTokenTTL         int       `json:"refresh_token_ttl"` // in seconds, 0 uses the service default
+	Audience                []string  `json:"audience"`
+	TokenExchangeAudiences  []string  `json:"token_exchange_audiences"`
+}
+
+// errInvalidRedirectURI marks validation errors caused by a redirect URI, which
+// dynamic registration reports with their own error code
+var errInvalidRedirectURI = errors.New("invalid redirect URI")
+
+// LoadClientsFile reads client definitions from a JSON array file
+func LoadClientsFile(path string) ([]ClientConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var clients []ClientConfig
+	if err := json.Unmarshal(data, &clients); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return clients, nil
+}
+
+// SeedClients creates or updates the given clients so the store matches the
+// configuration. It returns the number of clients written.
+func SeedClients(ctx context.Context, repo repository.ClientRepository, clients []ClientConfig) (int, error) {
+	for _, cfg := range clients {
+		if err := cfg.validate(); err != nil {
+			return 0, fmt.Errorf("client %q: %w", cfg.ID, err)
+		}
+	}
+
+	for i, cfg := range clients {
+		client, err := cfg.toModel()
+		if err != nil {
+			return i, fmt.Errorf("client %q: %w", cfg.ID, err)
+		}
+
+		existing, err := repo.GetByID(ctx, client.ID)
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			err = repo.Create(ctx, client)
+		case err == nil:
+			client.CreatedAt = existing.CreatedAt
+			err = repo.Update(ctx, client)
+		}
+		if err != nil {
+			return i, fmt.Errorf("client %q: %w", cfg.ID, err)
+		}
+	}
+	return len(clients), nil
+}
+
+// validate checks a client definition from the clients file before anything
+// is written. The file must give the secret of confidential clients.
+func (cfg *ClientConfig) validate() error {
+	if cfg.ID == "" {
+		return errors.New("client_id is required")
+	}
+	if err := cfg.validateMetadata(); err != nil {
+		return err
+	}
+	if cfg.usesSecret() && cfg.Secret == "" {
+		return errors.New("client_secret is required for confidential clients")
+	}
+	return nil
+}
+
+// validateMetadata checks everything about a client definition except its
+// ID and whether a secret is present, which callers may generate
+func (cfg *ClientConfig) validateMetadata() error {
+	switch cfg.TokenEndpointAuthMethod {
+	case models.ClientAuthNone:
+		if cfg.Secret != "" {
+			return errors.New("public clients must not have a client_secret")
+		}
+	case models.ClientAuthSecretBasic, models.ClientAuthSecretPost:
+	case models.ClientAuthPrivateKeyJWT:
+		if cfg.Secret != "" {
+			return errors.New("private_key_jwt clients must not have a client_secret")
+		}
+		if cfg.JWKS == nil || len(cfg.JWKS.Keys) == 0 {
+			return errors.New("jwks is required for private_key_jwt clients")
+		}
+		for _, key := range cfg.JWKS.Keys {
+			if _, err := key.PublicKey(); err != nil {
+				return fmt.Errorf("jwks: %w", err)
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported token_endpoint_auth_method %q", cfg.TokenEndpointAuthMethod)
+	}
+	for _, grant := range cfg.GrantTypes {
+		switch grant {
+		case GrantAuthorizationCode:
+			if len(cfg.RedirectURIs) == 0 {
+				return fmt.Errorf("%w: redirect_uris are required for the authorization_code grant", errInvalidRedirectURI)
+			}
+		case GrantRefreshToken, GrantDeviceCode:
+		case GrantClientCredentials, GrantTokenExchange:
+			if cfg.TokenEndpointAuthMethod == models.ClientAuthNone {
+				return fmt.Errorf("public clients cannot use the %s grant", grant)
+			}
+		default:
+			return fmt.Errorf("unsupported grant type %q", grant)
+		}
+	}
+	for _, uri := range cfg.RedirectURIs {
+		if err := validateRedirectURI(uri); err != nil {
+			return err
+		}
+	}
+	if cfg.AccessTokenTTL < 0 || cfg.RefreshTokenTTL < 0 {
+		return errors.New("token lifetimes must not be negative")
+	}
+	for _, aud := range cfg.Audience {
+		if aud == "" {
+			return errors.New("audience must not contain empty values")
+		}
+		if aud == jwt.AudienceFirstParty {
+			return fmt.Errorf("audience must not contain %q, which is reserved for first-party tokens", aud)
+		}
+	}
+	for _, aud := range cfg.TokenExchangeAudiences {
+		if aud == "" {
+			return errors.New("token_exchange_audiences must not contain empty values")
+		}
+		if aud == jwt.AudienceFirstParty {
+			return fmt.Errorf("token_exchange_audiences must not contain %q, which is reserved for first-party tokens", aud)
+		}
+	}
+	return nil
+}
+
+// usesSecret reports whether the client authenticates with a client secret
+func (cfg *ClientConfig) usesSecret() bool {
+	return cfg.TokenEndpointAuthMethod == models.ClientAuthSecretBasic || cfg.TokenEndpointAuthMethod == models.ClientAuthSecretPost
+}
+
+// toModel converts a client definition into a record, hashing its secret
+func (cfg *ClientConfig) toModel() (*models.Client, error) {
+	now := time.Now().UTC()
+	client := &models.Client{
+		ID:                      cfg.ID,
+		Name:                    cfg.Name,
+		RedirectURIs:            cfg.RedirectURIs,
+		GrantTypes:              cfg.GrantTypes,
+		Scopes:                  cfg.Scopes,
+		TokenEndpointAuthMethod: cfg.TokenEndpointAuthMethod,
+		AccessTokenTTL:          cfg.AccessTokenTTL,
+		RefreshTokenTTL:         cfg.RefreshTokenTTL,
+		Audience:                cfg.Audience,
+		TokenExchangeAudiences:  cfg.TokenExchangeAudiences,
+		CreatedAt:               now,
+		UpdatedAt:               now,
+	}
+	if cfg.JWKS != nil {
+		jwks, err := json.Marshal(cfg.JWKS)
+		if err != nil {
+			return nil, err
+		}
+		client.JWKS = jwks
+	}
+	if cfg.Secret != "" {
+		hash, err := password.HashPassword(cfg.Secret)
+		if err != nil {
+			return nil, err
+		}
+		client.SecretHash = hash
+	}
+	return client, nil
+}
+
+// validateRedirectURI requires an absolute URI without a fragment (RFC 6749
+// section 3.1.2). Its scheme must be https, http for the loopback interface,
+// or a private-use scheme in reverse domain name notation for native apps
+// (RFC 8252 sections 7.1 and 7.3).
+func validateRedirectURI(uri string) error {
+	u, err := url.Parse(uri)
+	if err != nil || !u.IsAbs() {
+		return fmt.Errorf("%w: %q must be absolute", errInvalidRedirectURI, uri)
+	}
+	if u.Fragment != "" {
+		return fmt.Errorf("%w: %q must not contain a fragment", errInvalidRedirectURI, uri)
+	}
+	switch {
+	case u.Scheme == "https":
+		if u.Host == "" {
+			return fmt.Errorf("%w: %q must have a host", errInvalidRedirectURI, uri)
+		}
+	case u.Scheme == "http":
+		if !isLoopback(u.Hostname()) {
+			return fmt.Errorf("%w: %q must use https unless it points to the loopback interface", errInvalidRedirectURI, uri)
+		}
+	case !strings.Contains(u.Scheme, "."):
+		return fmt.Errorf("%w: %q must use https, or a private-use scheme in reverse domain name notation such as com.example.app", errInvalidRedirectURI, uri)
+	}
+	return nil
+}
+
+// isLoopback reports whether a host names the loopback interface
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,209 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/goldcast/gc_auth_service/internal/models"
+	"github.com/goldcast/gc_auth_service/internal/repository"
+	"github.com/goldcast/gc_auth_service/pkg/jwt"
+	"github.com/goldcast/gc_auth_service/pkg/password"
+)
+
+func TestValidateRedirectURI(t *testing.T) {
+	for _, uri := range []string{
+		"https://app.example.com/callback",
+		"http://localhost:3000/callback",
+		"http://127.0.0.1/callback",
+		"http://[::1]:8080/callback",
+		"com.example.app:/oauth2redirect",
+	} {
+		if err := validateRedirectURI(uri); err != nil {
+			t.Errorf("%s: %v", uri, err)
+		}
+	}
+	for _, uri := range []string{
+		"/callback",
+		"https://app.example.com/callback#state",
+		"https:///callback",
+		"http://app.example.com/callback",
+		"javascript:alert(1)",
+		"data:text/html,hello",
+		"myapp:/callback",
+	} {
+		if err := validateRedirectURI(uri); !errors.Is(err, errInvalidRedirectURI) {
+			t.Errorf("%s: %v, want %v", uri, err, errInvalidRedirectURI)
+		}
+	}
+}
+
+func TestClientConfigValidate(t *testing.T) {
+	key, err := jwt.GenerateKey(jwt.AlgES256)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	jwks := &jwt.JWKS{Keys: []jwt.JWK{key.PublicJWK()}}
+
+	valid := map[string]ClientConfig{
+		"confidential": {ID: "web", Secret: "s3cret", TokenEndpointAuthMethod: models.ClientAuthSecretBasic,
+			GrantTypes: []string{GrantAuthorizationCode, GrantRefreshToken}, RedirectURIs: []string{"https://app.example.com/cb"}},
+		"public": {ID: "spa", TokenEndpointAuthMethod: models.ClientAuthNone,
+			GrantTypes: []string{GrantAuthorizationCode, GrantDeviceCode}, RedirectURIs: []string{"http://localhost/cb"}},
+		"private key": {ID: "svc", TokenEndpointAuthMethod: models.ClientAuthPrivateKeyJWT, JWKS: jwks,
+			GrantTypes: []string{GrantClientCredentials, GrantTokenExchange}, Audience: []string{"reports"}},
+	}
+	for name, cfg := range valid {
+		if err := cfg.validate(); err != nil {
+			t.Errorf("%s client: %v", name, err)
+		}
+	}
+
+	invalid := map[string]ClientConfig{
+		"no id":                       {Secret: "s3cret", TokenEndpointAuthMethod: models.ClientAuthSecretPost},
+		"no secret":                   {ID: "web", TokenEndpointAuthMethod: models.ClientAuthSecretPost},
+		"unknown auth method":         {ID: "web", TokenEndpointAuthMethod: "tls_client_auth"},
+		"public with secret":          {ID: "spa", Secret: "s3cret", TokenEndpointAuthMethod: models.ClientAuthNone},
+		"public client credentials":   {ID: "spa", TokenEndpointAuthMethod: models.ClientAuthNone, GrantTypes: []string{GrantClientCredentials}},
+		"private key without jwks":    {ID: "svc", TokenEndpointAuthMethod: models.ClientAuthPrivateKeyJWT},
+		"private key with secret":     {ID: "svc", Secret: "s3cret", TokenEndpointAuthMethod: models.ClientAuthPrivateKeyJWT, JWKS: jwks},
+		"invalid jwk":                 {ID: "svc", TokenEndpointAuthMethod: models.ClientAuthPrivateKeyJWT, JWKS: &jwt.JWKS{Keys: []jwt.JWK{{KeyType: "oct"}}}},
+		"code without redirect":       {ID: "spa", TokenEndpointAuthMethod: models.ClientAuthNone, GrantTypes: []string{GrantAuthorizationCode}},
+		"unknown grant":               {ID: "spa", TokenEndpointAuthMethod: models.ClientAuthNone, GrantTypes: []string{"password"}},
+		"insecure redirect":           {ID: "spa", TokenEndpointAuthMethod: models.ClientAuthNone, RedirectURIs: []string{"http://app.example.com/cb"}},
+		"negative lifetime":           {ID: "spa", TokenEndpointAuthMethod: models.ClientAuthNone, AccessTokenTTL: -1},
+		"first-party audience":        {ID: "spa", TokenEndpointAuthMethod: models.ClientAuthNone, Audience: []string{jwt.AudienceFirstParty}},
+		"first-party exchange target": {ID: "spa", TokenEndpointAuthMethod: models.ClientAuthNone, TokenExchangeAudiences: []string{jwt.AudienceFirstParty}},
+	}
+	for name, cfg := range invalid {
+		if err := cfg.validate(); err == nil {
+			t.Errorf("%s client validated", name)
+		}
+	}
+}
+
+func TestSeedClients(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "clients.json")
+	file := `[{"client_id": "web", "client_secret": "s3cret", "token_endpoint_auth_method": "client_secret_basic",
+		"grant_types": ["authorization_code"], "redirect_uris": ["https://app.example.com/cb"], "scopes": ["openid"]}]`
+	if err := os.WriteFile(path, []byte(file), 0o600); err != nil {
+		t.Fatalf("write clients file: %v", err)
+	}
+	clients, err := LoadClientsFile(path)
+	if err != nil {
+		t.Fatalf("load clients file: %v", err)
+	}
+
+	repo := repository.NewMemoryClientRepository()
+	if n, err := SeedClients(ctx, repo, clients); err != nil || n != 1 {
+		t.Fatalf("seed clients: %d, %v; want 1", n, err)
+	}
+	client, err := repo.GetByID(ctx, "web")
+	if err != nil {
+		t.Fatalf("get client: %v", err)
+	}
+	if client.SecretHash == "s3cret" || !password.CheckPasswordHash("s3cret", client.SecretHash) {
+		t.Error("client secret not stored as a hash")
+	}
+
+	// Seeding again updates the client and keeps its creation time
+	clients[0].Scopes = []string{"openid", "email"}
+	if _, err := SeedClients(ctx, repo, clients); err != nil {
+		t.Fatalf("seed clients again: %v", err)
+	}
+	updated, err := repo.GetByID(ctx, "web")
+	if err != nil {
+		t.Fatalf("get client: %v", err)
+	}
+	if len(updated.Scopes) != 2 || !updated.CreatedAt.Equal(client.CreatedAt) {
+		t.Errorf("updated client scopes %v created %v, want 2 scopes created %v", updated.Scopes, updated.CreatedAt, client.CreatedAt)
+	}
+
+	// Nothing is written when any client is invalid
+	bad := append(clients, ClientConfig{ID: "other", TokenEndpointAuthMethod: models.ClientAuthSecretBasic})
+	bad[0].Scopes = []string{"openid"}
+	if _, err := SeedClients(ctx, repo, bad); err == nil {
+		t.Fatal("seeded an invalid client")
+	}
+	if unchanged, _ := repo.GetByID(ctx, "web"); len(unchanged.Scopes) != 2 {
+		t.Errorf("client updated although another client was invalid")
+	}
+}
+
+func TestCheckClientSecret(t *testing.T) {
+	current, err := password.HashPassword("new-secret")
+	if err != nil {
+		t.Fatalf("hash secret: %v", err)
+	}
+	previous, err := password.HashPassword("old-secret")
+	if err != nil {
+		t.Fatalf("hash secret: %v", err)
+	}
+	now := time.Now()
+	until := now.Add(time.Hour)
+	client := &models.Client{SecretHash: current, PreviousSecretHash: previous, PreviousSecretExpiresAt: &until}
+
+	if !checkClientSecret(client, "new-secret", now) {
+		t.Error("current secret refused")
+	}
+	if !checkClientSecret(client, "old-secret", now) {
+		t.Error("previous secret refused during the overlap")
+	}
+	if checkClientSecret(client, "old-secret", until.Add(time.Second)) {
+		t.Error("previous secret accepted after the overlap")
+	}
+	if checkClientSecret(client, "", now) || checkClientSecret(client, "other-secret", now) {
+		t.Error("wrong secret accepted")
+	}
+}
+
+func TestUserCode(t *testing.T) {
+	code, err := generateUserCode()
+	if err != nil {
+		t.Fatalf("generate user code: %v", err)
+	}
+	if len(code) != userCodeLength {
+		t.Fatalf("user code %s, want %d characters", code, userCodeLength)
+	}
+	for _, c := range code {
+		if !containsRune(userCodeAlphabet, c) {
+			t.Errorf("user code %s has %q outside the alphabet", code, c)
+		}
+	}
+
+	// Users may type the displayed code in any case, with or without the dash
+	display := FormatUserCode(code)
+	if display != code[:4]+"-"+code[4:] {
+		t.Errorf("displayed code %s", display)
+	}
+	for _, typed := range []string{display, code, " " + display + " "} {
+		if got := NormalizeUserCode(typed); got != code {
+			t.Errorf("normalized %q to %q, want %q", typed, got, code)
+		}
+	}
+	if got := NormalizeUserCode("bcdf-ghjk"); got != "BCDFGHJK" {
+		t.Errorf("normalized lowercase code to %q", got)
+	}
+}
+
+// containsRune reports whether s contains r
+func containsRune(s string, r rune) bool {
+	for _, c := range s {
+		if c == r {
+			return true
+		}
+	}
+	return false
+}
+
+func TestRedirectURL(t *testing.T) {
+	got := RedirectURL("https://app.example.com/cb?tenant=1", url.Values{"code": {"abc"}, "state": {""}})
+	if got != "https://app.example.com/cb?code=abc&tenant=1" {
+		t.Errorf("redirect URL %s, want the code added and the empty state left out", got)
+	}
+}
@@ -0,0 +1,281 @@
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"net/url"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/goldcast/gc_auth_service/internal/auth"
+	"github.com/goldcast/gc_auth_service/internal/models"
+	"github.com/goldcast/gc_auth_service/internal/repository"
+	"github.com/google/uuid"
+)
+
+// GrantDeviceCode is the grant type a device polls the token endpoint with (RFC 8628)
+const GrantDeviceCode = "urn:ietf:params:oauth:grant-type:device_code"
+
+const (
+	// deviceCodeTTL bounds how long the user has to enter the user code
+	deviceCodeTTL = 10 * time.Minute
+	// devicePollInterval is the initial number of seconds a device must wait
+	// between polls; every slow_down adds devicePollBackoff to it
+	devicePollInterval = 5
+	devicePollBackoff  = 5
+)
+
+// User codes use consonants only, so they are easy to type on a TV remote and
+// cannot spell words (RFC 8628 section 6.1). Eight characters give about 34
+// bits of entropy, plenty for a code that expires in minutes.
+const (
+	userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+	userCodeLength   = 8
+)
+
+// ErrInvalidUserCode is returned for user codes that are unknown, expired or
+// already decided
+var ErrInvalidUserCode = errors.New("invalid or expired user code")
+
+// DeviceAuthorizationResponse is the response of the device authorization
+// endpoint (RFC 8628 section 3.2)
+type DeviceAuthorizationResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
+
+// AuthorizeDevice starts a device authorization for the client. The device
+// shows the user code and polls the token endpoint with the device code.
+func (s *Server) AuthorizeDevice(ctx context.Context, client *models.Client, scope string) (*DeviceAuthorizationResponse, error) {
+	if !client.AllowsGrant(GrantDeviceCode) {
+		return nil, NewError(ErrorUnauthorizedClient, "client may not use the device authorization grant")
+	}
+	requested := ParseScope(scope)
+	if !subsetOf(requested, client.Scopes) {
+		return nil, NewError(ErrorInvalidScope, "requested scope is not allowed for this client")
+	}
+
+	rawCode, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	code := &models.DeviceCode{
+		ID:             uuid.New(),
+		DeviceCodeHash: auth.HashToken(rawCode),
+		ClientID:       client.ID,
+		Scope:          strings.Join(requested, " "),
+		Status:         models.DeviceCodePending,
+		Interval:       devicePollInterval,
+		CreatedAt:      now,
+		ExpiresAt:      now.Add(deviceCodeTTL),
+	}
+
+	// A user code can collide with one that is still live; just draw again
+	for attempt := 0; ; attempt++ {
+		if code.UserCode, err = generateUserCode(); err != nil {
+			return nil, err
+		}
+		err = s.deviceCodes.Create(ctx, code)
+		if !errors.Is(err, repository.ErrConflict) || attempt == 2 {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	verificationURI := s.issuer + "/oauth/device"
+	userCode := FormatUserCode(code.UserCode)
+	return &DeviceAuthorizationResponse{
+		DeviceCode:              rawCode,
+		UserCode:                userCode,
+		VerificationURI:         verificationURI,
+		VerificationURIComplete: verificationURI + "?" + url.Values{"user_code": {userCode}}.Encode(),
+		ExpiresIn:               int(deviceCodeTTL.Seconds()),
+		Interval:                devicePollInterval,
+	}, nil
+}
+
+// LookupUserCode returns the pending device authorization for a user code
+// entered by the user, together with the client that requested it
+func (s *Server) LookupUserCode(ctx context.Context, userCode string) (*models.DeviceCode, *models.Client, error) {
+	userCode = NormalizeUserCode(userCode)
+	if len(userCode) != userCodeLength {
+		return nil, nil, ErrInvalidUserCode
+	}
+	code, err := s.deviceCodes.GetByUserCode(ctx, userCode)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, nil, ErrInvalidUserCode
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if code.Status != models.DeviceCodePending || !time.Now().Before(code.ExpiresAt) {
+		return nil, nil, ErrInvalidUserCode
+	}
+
+	client, err := s.clients.GetByID(ctx, code.ClientID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, nil, ErrInvalidUserCode
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return code, client, nil
+}
+
+// ApproveDevice grants a pending device authorization to a user who
+// authenticated at authTime using the given methods
+func (s *Server) ApproveDevice(ctx context.Context, code *models.DeviceCode, userID uuid.UUID, authTime time.Time, amr []string) error {
+	approved, err := s.deviceCodes.Approve(ctx, code.ID, userID, authTime.UTC(), amr)
+	if err != nil {
+		return err
+	}
+	if !approved {
+		return ErrInvalidUserCode
+	}
+	return nil
+}
+
+// DenyDevice rejects a pending device authorization
+func (s *Server) DenyDevice(ctx context.Context, code *models.DeviceCode) error {
+	denied, err := s.deviceCodes.Deny(ctx, code.ID)
+	if err != nil {
+		return err
+	}
+	if !denied {
+		return ErrInvalidUserCode
+	}
+	return nil
+}
+
+// PollDevice redeems a device code once the user has approved it (RFC 8628
+// section 3.5). Until then the device is told to keep polling, and to back
+// off when it polls faster than its interval.
+func (s *Server) PollDevice(ctx context.Context, client *models.Client, rawCode string, info auth.ClientInfo) (*Tokens, error) {
+	if !client.AllowsGrant(GrantDeviceCode) {
+		return nil, NewError(ErrorUnauthorizedClient, "client may not use the device authorization grant")
+	}
+	if rawCode == "" {
+		return nil, NewError(ErrorInvalidRequest, "device_code is required")
+	}
+
+	code, err := s.deviceCodes.GetByHash(ctx, auth.HashToken(rawCode))
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, NewError(ErrorInvalidGrant, "invalid device code")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if code.ClientID != client.ID {
+		return nil, NewError(ErrorInvalidGrant, "invalid device code")
+	}
+	if code.UsedAt != nil {
+		return nil, NewError(ErrorInvalidGrant, "device code has already been used")
+	}
+
+	now := time.Now().UTC()
+	if !now.Before(code.ExpiresAt) {
+		return nil, NewError(ErrorExpiredToken, "device code has expired")
+	}
+	interval := code.Interval
+	tooFast := code.LastPolledAt != nil && now.Sub(*code.LastPolledAt) < time.Duration(interval)*time.Second
+	if tooFast {
+		interval += devicePollBackoff
+	}
+	if err := s.deviceCodes.RecordPoll(ctx, code.ID, now, interval); err != nil {
+		return nil, err
+	}
+	if tooFast {
+		return nil, NewError(ErrorSlowDown, "poll at most every %d seconds", interval)
+	}
+
+	switch code.Status {
+	case models.DeviceCodePending:
+		return nil, NewError(ErrorAuthorizationPending, "the user has not yet approved the request")
+	case models.DeviceCodeDenied:
+		return nil, NewError(ErrorAccessDenied, "the user denied the request")
+	}
+
+	user, err := s.users.GetByID(ctx, *code.UserID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, NewError(ErrorInvalidGrant, "invalid device code")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !user.IsActive {
+		return nil, NewError(ErrorInvalidGrant, "invalid device code")
+	}
+
+	tokens, err := s.tokens.StartSession(ctx, user, info, auth.Grant{
+		ClientID: client.ID,
+		Scope:    code.Scope,
+		AuthTime: *code.AuthTime,
+		AMR:      code.AMR,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Claim the code atomically; a concurrent poll that lost the race must
+	// not keep its tokens
+	claimed, err := s.deviceCodes.MarkUsed(ctx, code.ID, now, tokens.Session.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !claimed {
+		if err := s.tokens.RevokeSession(ctx, tokens.Session.ID); err != nil {
+			return nil, err
+		}
+		return nil, NewError(ErrorInvalidGrant, "device code has already been used")
+	}
+
+	return s.withIDToken(client, tokens, "")
+}
+
+// PurgeExpiredDeviceCodes removes device codes that can no longer be redeemed
+func (s *Server) PurgeExpiredDeviceCodes(ctx context.Context) (int64, error) {
+	return s.deviceCodes.DeleteExpired(ctx, time.Now())
+}
+
+// NormalizeUserCode converts a user code as typed by the user to its stored
+// form, ignoring case, dashes and whitespace
+func NormalizeUserCode(userCode string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || unicode.IsSpace(r) {
+			return -1
+		}
+		return unicode.ToUpper(r)
+	}, userCode)
+}
+
+// FormatUserCode splits a stored user code in two halves for display
+func FormatUserCode(userCode string) string {
+	if len(userCode) != userCodeLength {
+		return userCode
+	}
+	return userCode[:userCodeLength/2] + "-" + userCode[userCodeLength/2:]
+}
+
+// generateUserCode returns a random user code in its stored form
+func generateUserCode() (string, error) {
+	max := big.NewInt(int64(len(userCodeAlphabet)))
+	code := make([]byte, userCodeLength)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		code[i] = userCodeAlphabet[n.Int64()]
+	}
+	return string(code), nil
+}
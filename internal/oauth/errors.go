@@ -0,0 +1,56 @@
+package oauth
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Error codes defined by RFC 6749, RFC 6750, RFC 8628, RFC 8693 and OpenID Connect
+const (
+	ErrorInvalidRequest          = "invalid_request"
+	ErrorInvalidClient           = "invalid_client"
+	ErrorInvalidGrant            = "invalid_grant"
+	ErrorUnauthorizedClient      = "unauthorized_client"
+	ErrorUnsupportedGrantType    = "unsupported_grant_type"
+	ErrorUnsupportedResponseType = "unsupported_response_type"
+	ErrorInvalidScope            = "invalid_scope"
+	ErrorAccessDenied            = "access_denied"
+	ErrorLoginRequired           = "login_required"
+	ErrorServerError             = "server_error"
+	ErrorInvalidToken            = "invalid_token"
+	ErrorInsufficientScope       = "insufficient_scope"
+	ErrorAuthorizationPending    = "authorization_pending"
+	ErrorSlowDown                = "slow_down"
+	ErrorExpiredToken            = "expired_token"
+	ErrorInvalidTarget           = "invalid_target"
+)
+
+// Error is an OAuth error response. Status is the HTTP status used when the
+// error is returned directly rather than through a redirect.
+type Error struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+	Status      int    `json:"-"`
+}
+
+// Error implements the error interface
+func (e *Error) Error() string {
+	if e.Description == "" {
+		return e.Code
+	}
+	return e.Code + ": " + e.Description
+}
+
+// NewError creates an error with the status RFC 6749 prescribes for the code
+func NewError(code, format string, args ...interface{}) *Error {
+	status := http.StatusBadRequest
+	switch code {
+	case ErrorInvalidClient, ErrorInvalidToken:
+		status = http.StatusUnauthorized
+	case ErrorInsufficientScope:
+		status = http.StatusForbidden
+	case ErrorServerError:
+		status = http.StatusInternalServerError
+	}
+	return &Error{Code: code, Description: fmt.Sprintf(format, args...), Status: status}
+}
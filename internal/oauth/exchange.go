@@ -0,0 +1,146 @@
+package oauth
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/goldcast/gc_auth_service/internal/auth"
+	"github.com/goldcast/gc_auth_service/internal/models"
+	"github.com/goldcast/gc_auth_service/pkg/jwt"
+)
+
+// GrantTokenExchange is the grant type for exchanging one token for another (RFC 8693)
+const GrantTokenExchange = "urn:ietf:params:oauth:grant-type:token-exchange"
+
+// Token type identifiers of RFC 8693 section 3. Access tokens are JWTs, so
+// both identifiers are accepted for the tokens presented.
+const (
+	TokenTypeURIAccessToken = "urn:ietf:params:oauth:token-type:access_token"
+	TokenTypeURIJWT         = "urn:ietf:params:oauth:token-type:jwt"
+)
+
+// TokenExchangeRequest holds the parameters of a token exchange request (RFC
+// 8693 section 2.1). Audience holds both the audience and resource values.
+type TokenExchangeRequest struct {
+	SubjectToken       string
+	SubjectTokenType   string
+	ActorToken         string
+	ActorTokenType     string
+	RequestedTokenType string
+	Audience           []string
+	Scope              string
+}
+
+// ExchangeToken issues an access token for another audience on behalf of the
+// subject of an access token, typically so a gateway can call an internal
+// service as the user. The new token records the acting client, or the
+// subject of the actor token, in its act claim; it never carries more scope
+// than the subject token and does not outlive it. Clients may only exchange
+// for the audiences registered in their token exchange policy.
+func (s *Server) ExchangeToken(ctx context.Context, client *models.Client, req *TokenExchangeRequest) (*Tokens, error) {
+	if client.IsPublic() || !client.AllowsGrant(GrantTokenExchange) {
+		return nil, NewError(ErrorUnauthorizedClient, "client may not use the token exchange grant")
+	}
+	if req.RequestedTokenType != "" && req.RequestedTokenType != TokenTypeURIAccessToken {
+		return nil, NewError(ErrorInvalidRequest, "only access tokens can be requested")
+	}
+	if len(req.Audience) == 0 {
+		return nil, NewError(ErrorInvalidRequest, "audience or resource is required")
+	}
+	if !subsetOf(req.Audience, client.TokenExchangeAudiences) {
+		return nil, NewError(ErrorInvalidTarget, "client may not exchange tokens for the requested audience")
+	}
+
+	subject, err := s.exchangedToken(ctx, req.SubjectToken, req.SubjectTokenType, "subject_token")
+	if err != nil {
+		return nil, err
+	}
+
+	actor := &jwt.Actor{Subject: client.ID, ClientID: client.ID}
+	if req.ActorToken != "" {
+		claims, err := s.exchangedToken(ctx, req.ActorToken, req.ActorTokenType, "actor_token")
+		if err != nil {
+			return nil, err
+		}
+		if claims.ClientID != client.ID {
+			return nil, NewError(ErrorInvalidGrant, "actor_token was not issued to this client")
+		}
+		actor = &jwt.Actor{Subject: claims.Subject, ClientID: claims.ClientID}
+	} else if req.ActorTokenType != "" {
+		return nil, NewError(ErrorInvalidRequest, "actor_token_type requires an actor_token")
+	}
+	// Keep the actors of earlier exchanges, so the whole chain is visible
+	actor.Actor = subject.Actor
+
+	available := ParseScope(subject.Scope)
+	requested := ParseScope(req.Scope)
+	if len(requested) == 0 {
+		requested = available
+	} else if !subsetOf(requested, available) {
+		return nil, NewError(ErrorInvalidScope, "requested scope exceeds the scope of the subject token")
+	}
+	scope := strings.Join(requested, " ")
+
+	lifetime := s.jwt.Expiry()
+	if client.AccessTokenTTL > 0 {
+		lifetime = time.Duration(client.AccessTokenTTL) * time.Second
+	}
+	if remaining := time.Until(subject.ExpiresAt.Time); remaining < lifetime {
+		lifetime = remaining
+	}
+	if lifetime <= 0 {
+		return nil, NewError(ErrorInvalidGrant, "subject_token is invalid or expired")
+	}
+
+	opts := []jwt.TokenOption{
+		jwt.WithScope(scope), jwt.WithAudience(req.Audience...), jwt.WithActor(actor), jwt.WithExpiry(lifetime),
+	}
+	var accessToken string
+	if subject.Principal() == jwt.PrincipalUser {
+		// Tokens issued on behalf of a user stay bound to the user's session,
+		// so they end with it
+		accessToken, err = s.jwt.GenerateToken(subject.UserID, subject.Email, subject.Username,
+			append(opts, jwt.WithSessionID(subject.SessionID), jwt.WithClientID(client.ID))...)
+	} else {
+		accessToken, err = s.jwt.GenerateClientToken(subject.ClientID, opts...)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &Tokens{
+		TokenPair: &auth.TokenPair{
+			AccessToken: accessToken,
+			ExpiresIn:   int(lifetime.Seconds()),
+			Scope:       scope,
+		},
+		IssuedTokenType: TokenTypeURIAccessToken,
+	}, nil
+}
+
+// exchangedToken validates an access token presented for exchange, which
+// must still be active
+func (s *Server) exchangedToken(ctx context.Context, token, tokenType, param string) (*jwt.Claims, error) {
+	if token == "" {
+		return nil, NewError(ErrorInvalidRequest, "%s is required", param)
+	}
+	if tokenType != TokenTypeURIAccessToken && tokenType != TokenTypeURIJWT {
+		return nil, NewError(ErrorInvalidRequest, "unsupported %s_type", param)
+	}
+
+	// Tokens for any audience can be exchanged; the audience of the new token
+	// is limited by the client's policy
+	claims, err := s.jwt.ValidateAccessToken(token, "")
+	if err != nil {
+		return nil, NewError(ErrorInvalidGrant, "%s is invalid or expired", param)
+	}
+	info, err := s.introspectAccessToken(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+	if !info.Active {
+		return nil, NewError(ErrorInvalidGrant, "%s has been revoked", param)
+	}
+	return claims, nil
+}
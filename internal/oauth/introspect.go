@@ -0,0 +1,177 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+
+	"github.com/goldcast/gc_auth_service/internal/auth"
+	"github.com/goldcast/gc_auth_service/internal/models"
+	"github.com/goldcast/gc_auth_service/internal/repository"
+	"github.com/goldcast/gc_auth_service/pkg/jwt"
+	"github.com/google/uuid"
+)
+
+// Token types reported by introspection. They match the token_type_hint
+// values of RFC 7009, which clients may send but which are not needed: access
+// tokens are JWTs and refresh tokens are opaque, so they are told apart anyway.
+const (
+	TokenTypeAccessToken  = "access_token"
+	TokenTypeRefreshToken = "refresh_token"
+)
+
+// Introspection is the response of the introspection endpoint (RFC 7662
+// section 2.2). Inactive tokens carry no other members.
+type Introspection struct {
+	Active    bool       `json:"active"`
+	Scope     string     `json:"scope,omitempty"`
+	ClientID  string     `json:"client_id,omitempty"`
+	Username  string     `json:"username,omitempty"`
+	TokenType string     `json:"token_type,omitempty"`
+	Exp       int64      `json:"exp,omitempty"`
+	Iat       int64      `json:"iat,omitempty"`
+	Nbf       int64      `json:"nbf,omitempty"`
+	Sub       string     `json:"sub,omitempty"`
+	Aud       []string   `json:"aud,omitempty"`
+	Iss       string     `json:"iss,omitempty"`
+	Jti       string     `json:"jti,omitempty"`
+	Act       *jwt.Actor `json:"act,omitempty"`
+}
+
+// inactive is the response for tokens that are unknown, expired or revoked
+var inactive = &Introspection{Active: false}
+
+// Introspect reports whether an access or refresh token is currently active
+// and what it was issued for. Only confidential clients, typically resource
+// servers, may introspect, but they may do so for tokens of any client.
+func (s *Server) Introspect(ctx context.Context, client *models.Client, token string) (*Introspection, error) {
+	if client.IsPublic() {
+		return nil, NewError(ErrorUnauthorizedClient, "public clients may not introspect tokens")
+	}
+	if token == "" {
+		return nil, NewError(ErrorInvalidRequest, "token is required")
+	}
+
+	if claims, err := s.jwt.ValidateAccessToken(token, ""); err == nil {
+		return s.introspectAccessToken(ctx, claims)
+	}
+	return s.introspectRefreshToken(ctx, token)
+}
+
+// Revoke revokes an access or refresh token issued to the client (RFC 7009).
+// Revoking a refresh token ends its session, after which introspection also
+// reports the access tokens issued within it as inactive. Tokens that are
+// already invalid are ignored, as the client has nothing left to revoke.
+func (s *Server) Revoke(ctx context.Context, client *models.Client, token string) error {
+	if token == "" {
+		return NewError(ErrorInvalidRequest, "token is required")
+	}
+
+	if claims, err := s.jwt.ValidateAccessToken(token, ""); err == nil {
+		if claims.ClientID != client.ID {
+			return NewError(ErrorUnauthorizedClient, "the token was not issued to this client")
+		}
+		return s.tokens.RevokeAccessToken(ctx, claims)
+	}
+
+	_, session, err := s.tokens.InspectRefreshToken(ctx, token)
+	if errors.Is(err, auth.ErrInvalidRefreshToken) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if session.ClientID != client.ID {
+		return NewError(ErrorUnauthorizedClient, "the token was not issued to this client")
+	}
+	return s.tokens.RevokeSession(ctx, session.ID)
+}
+
+// introspectAccessToken describes a validly signed, unexpired access token,
+// which is only active while it is not revoked and its user and client still
+// exist
+func (s *Server) introspectAccessToken(ctx context.Context, claims *jwt.Claims) (*Introspection, error) {
+	revoked, err := s.tokens.IsAccessTokenRevoked(ctx, claims)
+	if err != nil || revoked {
+		return inactive, err
+	}
+
+	if claims.ClientID != "" {
+		_, err := s.clients.GetByID(ctx, claims.ClientID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return inactive, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	var username string
+	if claims.Principal() == jwt.PrincipalUser {
+		user, active, err := s.activeUser(ctx, claims.UserID)
+		if err != nil || !active {
+			return inactive, err
+		}
+		username = user.Username
+	}
+
+	resp := &Introspection{
+		Active:    true,
+		Scope:     claims.Scope,
+		ClientID:  claims.ClientID,
+		Username:  username,
+		TokenType: TokenTypeAccessToken,
+		Sub:       claims.Subject,
+		Aud:       claims.Audience,
+		Iss:       claims.Issuer,
+		Jti:       claims.ID,
+		Act:       claims.Actor,
+	}
+	if claims.ExpiresAt != nil {
+		resp.Exp = claims.ExpiresAt.Unix()
+	}
+	if claims.IssuedAt != nil {
+		resp.Iat = claims.IssuedAt.Unix()
+	}
+	if claims.NotBefore != nil {
+		resp.Nbf = claims.NotBefore.Unix()
+	}
+	return resp, nil
+}
+
+// introspectRefreshToken describes an opaque refresh token
+func (s *Server) introspectRefreshToken(ctx context.Context, rawToken string) (*Introspection, error) {
+	token, session, err := s.tokens.InspectRefreshToken(ctx, rawToken)
+	if errors.Is(err, auth.ErrInvalidRefreshToken) {
+		return inactive, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	user, active, err := s.activeUser(ctx, token.UserID)
+	if err != nil || !active {
+		return inactive, err
+	}
+
+	return &Introspection{
+		Active:    true,
+		Scope:     session.Scope,
+		ClientID:  session.ClientID,
+		Username:  user.Username,
+		TokenType: TokenTypeRefreshToken,
+		Exp:       token.ExpiresAt.Unix(),
+		Iat:       token.CreatedAt.Unix(),
+		Sub:       user.ID.String(),
+	}, nil
+}
+
+// activeUser returns the user and whether they still exist and are active
+func (s *Server) activeUser(ctx context.Context, id uuid.UUID) (*models.User, bool, error) {
+	user, err := s.users.GetByID(ctx, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return user, user.IsActive, nil
+}
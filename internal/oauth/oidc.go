@@ -0,0 +1,145 @@
+package oauth
+
+import (
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+	"github.com/goldcast/gc_auth_service/internal/auth"
+	"github.com/goldcast/gc_auth_service/internal/models"
+	"github.com/goldcast/gc_auth_service/pkg/jwt"
+)
+
+// OpenID Connect scopes
+const (
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+	ScopeEmail   = "email"
+)
+
+// Authentication context class references reported in the acr claim
+const (
+	ACRSingleFactor = "urn:goldcast:acr:1fa"
+	ACRMultiFactor  = "urn:goldcast:acr:mfa"
+)
+
+// Discovery is the OpenID Provider metadata document (OpenID Connect Discovery 1.0)
+type Discovery struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	DeviceAuthorizationEndpoint       string   `json:"device_authorization_endpoint"`
+	IntrospectionEndpoint             string   `json:"introspection_endpoint"`
+	RevocationEndpoint                string   `json:"revocation_endpoint"`
+	UserInfoEndpoint                  string   `json:"userinfo_endpoint"`
+	RegistrationEndpoint              string   `json:"registration_endpoint,omitempty"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	ResponseModesSupported            []string `json:"response_modes_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	TokenEndpointAuthSigningAlgs      []string `json:"token_endpoint_auth_signing_alg_values_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	ACRValuesSupported                []string `json:"acr_values_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+}
+
+// Discovery describes the provider's endpoints and capabilities
+func (s *Server) Discovery() *Discovery {
+	return &Discovery{
+		Issuer:                            s.issuer,
+		AuthorizationEndpoint:             s.issuer + "/oauth/authorize",
+		TokenEndpoint:                     s.issuer + "/oauth/token",
+		DeviceAuthorizationEndpoint:       s.issuer + "/oauth/device_authorization",
+		IntrospectionEndpoint:             s.issuer + "/oauth/introspect",
+		RevocationEndpoint:                s.issuer + "/oauth/revoke",
+		UserInfoEndpoint:                  s.issuer + "/oauth/userinfo",
+		JWKSURI:                           s.issuer + "/.well-known/jwks.json",
+		ScopesSupported:                   []string{ScopeOpenID, ScopeProfile, ScopeEmail},
+		ResponseTypesSupported:            []string{ResponseTypeCode},
+		ResponseModesSupported:            []string{"query"},
+		GrantTypesSupported:               []string{GrantAuthorizationCode, GrantRefreshToken, GrantClientCredentials, GrantDeviceCode, GrantTokenExchange},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  []string{s.jwt.Algorithm()},
+		TokenEndpointAuthMethodsSupported: []string{models.ClientAuthSecretBasic, models.ClientAuthSecretPost, models.ClientAuthPrivateKeyJWT, models.ClientAuthNone},
+		TokenEndpointAuthSigningAlgs:      []string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512", "EdDSA"},
+		CodeChallengeMethodsSupported:     []string{CodeChallengeS256},
+		ACRValuesSupported:                []string{ACRSingleFactor, ACRMultiFactor},
+		ClaimsSupported: []string{
+			"iss", "sub", "aud", "exp", "iat", "auth_time", "nonce", "amr", "acr", "azp",
+			"name", "given_name", "family_name", "preferred_username", "updated_at", "email", "email_verified",
+		},
+	}
+}
+
+// UserInfo returns the claims about the user released for the granted scope
+func UserInfo(user *models.User, scope string) map[string]interface{} {
+	claims := map[string]interface{}{
+		"sub": user.ID.String(),
+	}
+	if HasScope(scope, ScopeProfile) {
+		claims["name"] = fullName(user)
+		claims["given_name"] = user.FirstName
+		claims["family_name"] = user.LastName
+		claims["preferred_username"] = user.Username
+		claims["updated_at"] = user.UpdatedAt.Unix()
+	}
+	if HasScope(scope, ScopeEmail) {
+		claims["email"] = user.Email
+		claims["email_verified"] = user.EmailVerified
+	}
+	return claims
+}
+
+// idToken issues an ID token for a session of an openid request
+func (s *Server) idToken(client *models.Client, user *models.User, session *models.Session, nonce string) (string, error) {
+	now := time.Now()
+	claims := &jwt.IDTokenClaims{
+		Nonce:           nonce,
+		AuthTime:        gojwt.NewNumericDate(session.AuthTime),
+		AMR:             session.AMR,
+		ACR:             acr(session.AMR),
+		AuthorizedParty: client.ID,
+		RegisteredClaims: gojwt.RegisteredClaims{
+			Issuer:    s.issuer,
+			Subject:   user.ID.String(),
+			Audience:  gojwt.ClaimStrings{client.ID},
+			ExpiresAt: gojwt.NewNumericDate(now.Add(s.jwt.Expiry())),
+			IssuedAt:  gojwt.NewNumericDate(now),
+		},
+	}
+	if HasScope(session.Scope, ScopeProfile) {
+		claims.Name = fullName(user)
+		claims.GivenName = user.FirstName
+		claims.FamilyName = user.LastName
+		claims.PreferredUsername = user.Username
+	}
+	if HasScope(session.Scope, ScopeEmail) {
+		claims.Email = user.Email
+		claims.EmailVerified = &user.EmailVerified
+	}
+	return s.jwt.SignIDToken(claims)
+}
+
+// acr derives the authentication context class from the methods used
+func acr(amr []string) string {
+	for _, method := range amr {
+		if method == auth.AMRMultiFactor {
+			return ACRMultiFactor
+		}
+	}
+	return ACRSingleFactor
+}
+
+// fullName joins the user's first and last name
+func fullName(user *models.User) string {
+	switch {
+	case user.FirstName == "":
+		return user.LastName
+	case user.LastName == "":
+		return user.FirstName
+	}
+	return user.FirstName + " " + user.LastName
+}
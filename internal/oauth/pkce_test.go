@@ -0,0 +1,37 @@
+package oauth
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestS256Challenge checks the challenge is the unpadded base64url encoding
+// of the SHA-256 test vector of FIPS 180-2
+func TestS256Challenge(t *testing.T) {
+	if got, want := S256Challenge("abc"), "ungWv48Bz-pBQUDeXa4iI7ADYaOWF3qctBD_YfIAFa0"; got != want {
+		t.Errorf("challenge %s, want %s", got, want)
+	}
+}
+
+func TestVerifyCodeChallenge(t *testing.T) {
+	verifier := strings.Repeat("a1-._~", 8)
+	challenge := S256Challenge(verifier)
+
+	for name, tt := range map[string]struct{ verifier, challenge, method string }{
+		"plain method":        {verifier, verifier, "plain"},
+		"another verifier":    {verifier + "b", challenge, CodeChallengeS256},
+		"verifier too short":  {verifier[:42], S256Challenge(verifier[:42]), CodeChallengeS256},
+		"verifier too long":   {strings.Repeat("a", 129), S256Challenge(strings.Repeat("a", 129)), CodeChallengeS256},
+		"reserved characters": {verifier + "+/", S256Challenge(verifier + "+/"), CodeChallengeS256},
+	} {
+		if verifyCodeChallenge(tt.verifier, tt.challenge, tt.method) {
+			t.Errorf("%s accepted", name)
+		}
+	}
+	for _, length := range []int{43, 128} {
+		v := strings.Repeat("Z", length)
+		if !verifyCodeChallenge(v, S256Challenge(v), CodeChallengeS256) {
+			t.Errorf("verifier of %d characters refused", length)
+		}
+	}
+}
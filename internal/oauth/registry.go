@@ -0,0 +1,276 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/goldcast/gc_auth_service/internal/auth"
+	"github.com/goldcast/gc_auth_service/internal/models"
+	"github.com/goldcast/gc_auth_service/internal/repository"
+	"github.com/goldcast/gc_auth_service/pkg/jwt"
+	"github.com/goldcast/gc_auth_service/pkg/password"
+	"github.com/google/uuid"
+)
+
+// Error codes defined by RFC 7591 for dynamic client registration
+const (
+	ErrorInvalidRedirectURI    = "invalid_redirect_uri"
+	ErrorInvalidClientMetadata = "invalid_client_metadata"
+)
+
+// DefaultSecretOverlap is how long a rotated client secret keeps working when
+// no overlap is given, so deployments can pick up the new secret
+const DefaultSecretOverlap = 24 * time.Hour
+
+// ErrNoClientSecret is returned when rotating the secret of a client that
+// does not authenticate with one
+var ErrNoClientSecret = errors.New("client does not authenticate with a client secret")
+
+// RegistrationRequest is the client metadata sent to the registration
+// endpoint (RFC 7591 section 2)
+type RegistrationRequest struct {
+	RedirectURIs            []string  `json:"redirect_uris"`
+	TokenEndpointAuthMethod string    `json:"token_endpoint_auth_method"`
+	GrantTypes              []string  `json:"grant_types"`
+	ResponseTypes           []string  `json:"response_types"`
+	ClientName              string    `json:"client_name"`
+	Scope                   string    `json:"scope"`
+	JWKS                    *jwt.JWKS `json:"jwks,omitempty"`
+}
+
+// RegistrationResponse is the registered client's information (RFC 7591
+// section 3.2.1). The secret expiry is only present when a secret is issued,
+// and is zero as secrets do not expire.
+type RegistrationResponse struct {
+	ClientID                string    `json:"client_id"`
+	ClientSecret            string    `json:"client_secret,omitempty"`
+	ClientIDIssuedAt        int64     `json:"client_id_issued_at"`
+	ClientSecretExpiresAt   *int64    `json:"client_secret_expires_at,omitempty"`
+	RedirectURIs            []string  `json:"redirect_uris,omitempty"`
+	TokenEndpointAuthMethod string    `json:"token_endpoint_auth_method"`
+	GrantTypes              []string  `json:"grant_types"`
+	ResponseTypes           []string  `json:"response_types"`
+	ClientName              string    `json:"client_name,omitempty"`
+	Scope                   string    `json:"scope"`
+	JWKS                    *jwt.JWKS `json:"jwks,omitempty"`
+}
+
+// ClientRegistry manages OAuth clients at runtime, both for operators on the
+// admin API and for clients registering themselves
+type ClientRegistry struct {
+	clients            repository.ClientRepository
+	registrationScopes []string
+	registrationGrants []string
+}
+
+// NewClientRegistry creates a client registry. Dynamically registered
+// clients may request at most the registration scopes and grant types.
+func NewClientRegistry(clients repository.ClientRepository, registrationScopes, registrationGrants []string) *ClientRegistry {
+	return &ClientRegistry{
+		clients:            clients,
+		registrationScopes: registrationScopes,
+		registrationGrants: registrationGrants,
+	}
+}
+
+// List returns all clients
+func (r *ClientRegistry) List(ctx context.Context) ([]*models.Client, error) {
+	return r.clients.List(ctx)
+}
+
+// Get returns a client by ID
+func (r *ClientRegistry) Get(ctx context.Context, id string) (*models.Client, error) {
+	return r.clients.GetByID(ctx, id)
+}
+
+// Create registers a new client. A client ID is generated when none is given,
+// as is a secret for confidential clients without one; the generated secret
+// is returned and cannot be retrieved later.
+func (r *ClientRegistry) Create(ctx context.Context, cfg ClientConfig) (*models.Client, string, error) {
+	if cfg.ID == "" {
+		cfg.ID = uuid.NewString()
+	}
+	if err := metadataError(cfg.validateMetadata()); err != nil {
+		return nil, "", err
+	}
+
+	var secret string
+	if cfg.usesSecret() && cfg.Secret == "" {
+		generated, err := auth.GenerateOpaqueToken()
+		if err != nil {
+			return nil, "", err
+		}
+		cfg.Secret, secret = generated, generated
+	}
+
+	client, err := cfg.toModel()
+	if err != nil {
+		return nil, "", err
+	}
+	if err := r.clients.Create(ctx, client); err != nil {
+		return nil, "", err
+	}
+	return client, secret, nil
+}
+
+// Update replaces the settings of a client. The current secret is kept unless
+// a new one is given, and a secret is generated and returned when the client
+// switches to secret authentication without one.
+func (r *ClientRegistry) Update(ctx context.Context, id string, cfg ClientConfig) (*models.Client, string, error) {
+	existing, err := r.clients.GetByID(ctx, id)
+	if err != nil {
+		return nil, "", err
+	}
+	cfg.ID = id
+	if err := metadataError(cfg.validateMetadata()); err != nil {
+		return nil, "", err
+	}
+
+	var secret string
+	if cfg.usesSecret() && cfg.Secret == "" && existing.SecretHash == "" {
+		generated, err := auth.GenerateOpaqueToken()
+		if err != nil {
+			return nil, "", err
+		}
+		cfg.Secret, secret = generated, generated
+	}
+
+	client, err := cfg.toModel()
+	if err != nil {
+		return nil, "", err
+	}
+	client.CreatedAt = existing.CreatedAt
+	if cfg.usesSecret() && cfg.Secret == "" {
+		client.SecretHash = existing.SecretHash
+		client.PreviousSecretHash = existing.PreviousSecretHash
+		client.PreviousSecretExpiresAt = existing.PreviousSecretExpiresAt
+	}
+	if err := r.clients.Update(ctx, client); err != nil {
+		return nil, "", err
+	}
+	return client, secret, nil
+}
+
+// Delete removes a client and its pending codes. Its sessions can no longer
+// be refreshed and its tokens are reported inactive by introspection.
+func (r *ClientRegistry) Delete(ctx context.Context, id string) error {
+	return r.clients.Delete(ctx, id)
+}
+
+// RotateSecret issues a new secret for a client. The previous secret keeps
+// working for the overlap, so the client can be redeployed without downtime.
+func (r *ClientRegistry) RotateSecret(ctx context.Context, id string, overlap time.Duration) (*models.Client, string, error) {
+	client, err := r.clients.GetByID(ctx, id)
+	if err != nil {
+		return nil, "", err
+	}
+	if !client.UsesSecret() {
+		return nil, "", ErrNoClientSecret
+	}
+
+	secret, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		return nil, "", err
+	}
+	hash, err := password.HashPassword(secret)
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now().UTC()
+	client.PreviousSecretHash, client.PreviousSecretExpiresAt = "", nil
+	if overlap > 0 {
+		expiresAt := now.Add(overlap)
+		client.PreviousSecretHash, client.PreviousSecretExpiresAt = client.SecretHash, &expiresAt
+	}
+	client.SecretHash = hash
+	client.UpdatedAt = now
+	if err := r.clients.Update(ctx, client); err != nil {
+		return nil, "", err
+	}
+	return client, secret, nil
+}
+
+// Register creates a client from the metadata a client sent to the
+// registration endpoint (RFC 7591 section 3). Unlike the admin API, the
+// client ID is always generated and scopes and grant types are limited to
+// the registration ones.
+func (r *ClientRegistry) Register(ctx context.Context, req *RegistrationRequest) (*RegistrationResponse, error) {
+	cfg := ClientConfig{
+		Name:                    req.ClientName,
+		RedirectURIs:            req.RedirectURIs,
+		GrantTypes:              req.GrantTypes,
+		TokenEndpointAuthMethod: req.TokenEndpointAuthMethod,
+		JWKS:                    req.JWKS,
+	}
+	if cfg.TokenEndpointAuthMethod == "" {
+		cfg.TokenEndpointAuthMethod = models.ClientAuthSecretBasic
+	}
+	if len(cfg.GrantTypes) == 0 {
+		cfg.GrantTypes = []string{GrantAuthorizationCode}
+	}
+	if !subsetOf(cfg.GrantTypes, r.registrationGrants) {
+		return nil, NewError(ErrorInvalidClientMetadata, "grant_types may only contain %s", strings.Join(r.registrationGrants, " "))
+	}
+
+	responseTypes := req.ResponseTypes
+	usesCode := subsetOf([]string{GrantAuthorizationCode}, cfg.GrantTypes)
+	if len(responseTypes) == 0 {
+		responseTypes = []string{}
+		if usesCode {
+			responseTypes = []string{ResponseTypeCode}
+		}
+	}
+	for _, responseType := range responseTypes {
+		if responseType != ResponseTypeCode {
+			return nil, NewError(ErrorInvalidClientMetadata, "unsupported response type %q", responseType)
+		}
+	}
+	if usesCode != (len(responseTypes) > 0) {
+		return nil, NewError(ErrorInvalidClientMetadata, "the code response type and the authorization_code grant must be registered together")
+	}
+
+	cfg.Scopes = r.registrationScopes
+	if req.Scope != "" {
+		cfg.Scopes = ParseScope(req.Scope)
+		if !subsetOf(cfg.Scopes, r.registrationScopes) {
+			return nil, NewError(ErrorInvalidClientMetadata, "scope may only contain %s", strings.Join(r.registrationScopes, " "))
+		}
+	}
+
+	client, secret, err := r.Create(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &RegistrationResponse{
+		ClientID:                client.ID,
+		ClientSecret:            secret,
+		ClientIDIssuedAt:        client.CreatedAt.Unix(),
+		RedirectURIs:            client.RedirectURIs,
+		TokenEndpointAuthMethod: client.TokenEndpointAuthMethod,
+		GrantTypes:              client.GrantTypes,
+		ResponseTypes:           responseTypes,
+		ClientName:              client.Name,
+		Scope:                   strings.Join(client.Scopes, " "),
+		JWKS:                    req.JWKS,
+	}
+	if secret != "" {
+		var neverExpires int64
+		resp.ClientSecretExpiresAt = &neverExpires
+	}
+	return resp, nil
+}
+
+// metadataError reports invalid client settings as a registration error
+func metadataError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, errInvalidRedirectURI) {
+		return NewError(ErrorInvalidRedirectURI, "%s", err.Error())
+	}
+	return NewError(ErrorInvalidClientMetadata, "%s", err.Error())
+}
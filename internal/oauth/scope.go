@@ -0,0 +1,43 @@
+package oauth
+
+import "strings"
+
+// ParseScope splits a space-delimited scope string, dropping duplicates
+func ParseScope(scope string) []string {
+	var scopes []string
+	seen := make(map[string]bool)
+	for _, s := range strings.Fields(scope) {
+		if !seen[s] {
+			seen[s] = true
+			scopes = append(scopes, s)
+		}
+	}
+	return scopes
+}
+
+// HasScope reports whether a space-delimited scope string contains scope
+func HasScope(scope, want string) bool {
+	for _, s := range strings.Fields(scope) {
+		if s == want {
+			return true
+		}
+	}
+	return false
+}
+
+// subsetOf reports whether every requested scope is in allowed
+func subsetOf(requested, allowed []string) bool {
+	for _, r := range requested {
+		found := false
+		for _, a := range allowed {
+			if r == a {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
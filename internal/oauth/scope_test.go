@@ -0,0 +1,21 @@
+package oauth
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseScope(t *testing.T) {
+	if got := ParseScope("  openid profile\topenid email "); !slices.Equal(got, []string{"openid", "profile", "email"}) {
+		t.Errorf("scopes %v, want openid profile email", got)
+	}
+	if got := ParseScope(""); got != nil {
+		t.Errorf("scopes of an empty string %v, want none", got)
+	}
+	if !HasScope("openid email", "email") || HasScope("openid emails", "email") {
+		t.Error("HasScope matched part of a scope or missed a whole one")
+	}
+	if !subsetOf([]string{"email"}, []string{"openid", "email"}) || subsetOf([]string{"email", "admin"}, []string{"openid", "email"}) {
+		t.Error("subsetOf allowed a scope outside the set or refused one inside it")
+	}
+}
@@ -0,0 +1,407 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/goldcast/gc_auth_service/internal/auth"
+	"github.com/goldcast/gc_auth_service/internal/models"
+	"github.com/goldcast/gc_auth_service/internal/repository"
+	"github.com/goldcast/gc_auth_service/pkg/jwt"
+	"github.com/goldcast/gc_auth_service/pkg/password"
+	"github.com/google/uuid"
+)
+
+// Grant and response types supported by the server
+const (
+	GrantAuthorizationCode = "authorization_code"
+	GrantRefreshToken      = "refresh_token"
+	GrantClientCredentials = "client_credentials"
+	ResponseTypeCode       = "code"
+)
+
+// codeTTL bounds how long an authorization code can be redeemed. RFC 6749
+// recommends a maximum of ten minutes; the redirect round trip needs seconds.
+const codeTTL = 2 * time.Minute
+
+// Server implements the OAuth 2.0 authorization code grant with PKCE and
+// OpenID Connect on top of the service's users, sessions and token issuance
+type Server struct {
+	clients     repository.ClientRepository
+	codes       repository.AuthorizationCodeRepository
+	deviceCodes repository.DeviceCodeRepository
+	users       repository.UserRepository
+	revocations repository.RevocationRepository
+	tokens      *auth.TokenService
+	jwt         *jwt.Service
+	issuer      string
+}
+
+// NewServer creates an authorization server. The issuer is the base URL the
+// service is reachable at and is used as the iss of ID tokens.
+func NewServer(clients repository.ClientRepository, codes repository.AuthorizationCodeRepository, deviceCodes repository.DeviceCodeRepository, users repository.UserRepository, revocations repository.RevocationRepository, tokens *auth.TokenService, jwtService *jwt.Service, issuer string) *Server {
+	return &Server{
+		clients:     clients,
+		codes:       codes,
+		deviceCodes: deviceCodes,
+		users:       users,
+		revocations: revocations,
+		tokens:      tokens,
+		jwt:         jwtService,
+		issuer:      issuer,
+	}
+}
+
+// Tokens is the result of a successful token request
+type Tokens struct {
+	*auth.TokenPair
+	IDToken         string // only set for openid requests
+	IssuedTokenType string // only set for token exchange
+}
+
+// AuthorizeRequest holds the parameters of an authorization request
+type AuthorizeRequest struct {
+	ResponseType        string `form:"response_type"`
+	ClientID            string `form:"client_id"`
+	RedirectURI         string `form:"redirect_uri"`
+	Scope               string `form:"scope"`
+	State               string `form:"state"`
+	CodeChallenge       string `form:"code_challenge"`
+	CodeChallengeMethod string `form:"code_challenge_method"`
+	Nonce               string `form:"nonce"`
+	Prompt              string `form:"prompt"`
+}
+
+// ClientCredentials are the credentials a client presented at the token endpoint
+type ClientCredentials struct {
+	ID        string
+	Secret    string
+	Assertion string // signed JWT for private_key_jwt
+	Method    string // one of the models.ClientAuth* methods
+}
+
+// LookupClient resolves the client and checks the redirect URI of an
+// authorization request. Errors from here must be shown to the user rather
+// than redirected, since the redirect target cannot be trusted.
+func (s *Server) LookupClient(ctx context.Context, clientID, redirectURI string) (*models.Client, error) {
+	if clientID == "" {
+		return nil, NewError(ErrorInvalidRequest, "client_id is required")
+	}
+	client, err := s.clients.GetByID(ctx, clientID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, NewError(ErrorInvalidClient, "unknown client")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if redirectURI == "" {
+		return nil, NewError(ErrorInvalidRequest, "redirect_uri is required")
+	}
+	if !client.AllowsRedirectURI(redirectURI) {
+		return nil, NewError(ErrorInvalidRequest, "redirect_uri is not registered for this client")
+	}
+	return client, nil
+}
+
+// ValidateAuthorizeRequest checks the remaining parameters of an authorization
+// request for a client returned by LookupClient. Errors from here are sent to
+// the client's redirect URI.
+func (s *Server) ValidateAuthorizeRequest(client *models.Client, req *AuthorizeRequest) *Error {
+	if req.ResponseType != ResponseTypeCode {
+		return NewError(ErrorUnsupportedResponseType, "response_type must be %q", ResponseTypeCode)
+	}
+	if !client.AllowsGrant(GrantAuthorizationCode) {
+		return NewError(ErrorUnauthorizedClient, "client may not use the authorization code grant")
+	}
+	if req.CodeChallenge == "" {
+		return NewError(ErrorInvalidRequest, "code_challenge is required")
+	}
+	if req.CodeChallengeMethod != CodeChallengeS256 {
+		return NewError(ErrorInvalidRequest, "code_challenge_method must be %q", CodeChallengeS256)
+	}
+	if !validVerifier(req.CodeChallenge) {
+		return NewError(ErrorInvalidRequest, "code_challenge is malformed")
+	}
+	if !subsetOf(ParseScope(req.Scope), client.Scopes) {
+		return NewError(ErrorInvalidScope, "requested scope is not allowed for this client")
+	}
+	return nil
+}
+
+// IssueCode creates an authorization code for a validated request that the
+// user has just authenticated using the given methods
+func (s *Server) IssueCode(ctx context.Context, client *models.Client, userID uuid.UUID, req *AuthorizeRequest, amr []string) (string, error) {
+	rawCode, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now().UTC()
+	code := &models.AuthorizationCode{
+		ID:                  uuid.New(),
+		CodeHash:            auth.HashToken(rawCode),
+		ClientID:            client.ID,
+		UserID:              userID,
+		RedirectURI:         req.RedirectURI,
+		Scope:               strings.Join(ParseScope(req.Scope), " "),
+		CodeChallenge:       req.CodeChallenge,
+		CodeChallengeMethod: req.CodeChallengeMethod,
+		Nonce:               req.Nonce,
+		AuthTime:            now,
+		AMR:                 amr,
+		CreatedAt:           now,
+		ExpiresAt:           now.Add(codeTTL),
+	}
+	if err := s.codes.Create(ctx, code); err != nil {
+		return "", err
+	}
+	return rawCode, nil
+}
+
+// AuthenticateClient verifies the credentials a client presented at the token endpoint
+func (s *Server) AuthenticateClient(ctx context.Context, creds ClientCredentials) (*models.Client, error) {
+	if creds.Method == models.ClientAuthPrivateKeyJWT && creds.ID == "" {
+		// client_id is optional with an assertion, whose subject names the client
+		creds.ID = assertionSubject(creds.Assertion)
+	}
+	if creds.ID == "" {
+		return nil, NewError(ErrorInvalidClient, "client authentication required")
+	}
+	client, err := s.clients.GetByID(ctx, creds.ID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, NewError(ErrorInvalidClient, "client authentication failed")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if client.IsPublic() {
+		if creds.Secret != "" {
+			return nil, NewError(ErrorInvalidClient, "public clients must not authenticate with a secret")
+		}
+		return client, nil
+	}
+	if creds.Method != client.TokenEndpointAuthMethod {
+		return nil, NewError(ErrorInvalidClient, "client authentication failed")
+	}
+	if creds.Method == models.ClientAuthPrivateKeyJWT {
+		if err := s.verifyAssertion(ctx, client, creds.Assertion); err != nil {
+			return nil, err
+		}
+		return client, nil
+	}
+	if !checkClientSecret(client, creds.Secret, time.Now()) {
+		return nil, NewError(ErrorInvalidClient, "client authentication failed")
+	}
+	return client, nil
+}
+
+// checkClientSecret verifies a client secret against the current secret and,
+// while the rotation overlap lasts, the previous one
+func checkClientSecret(client *models.Client, secret string, now time.Time) bool {
+	if password.CheckPasswordHash(secret, client.SecretHash) {
+		return true
+	}
+	return client.PreviousSecretHash != "" && client.PreviousSecretExpiresAt != nil &&
+		now.Before(*client.PreviousSecretExpiresAt) &&
+		password.CheckPasswordHash(secret, client.PreviousSecretHash)
+}
+
+// ClientCredentials issues an access token to a confidential client acting
+// on its own behalf. No refresh token is issued; the client simply requests
+// a new token. Without a scope parameter all registered scopes are granted.
+func (s *Server) ClientCredentials(ctx context.Context, client *models.Client, scope string) (*Tokens, error) {
+	if client.IsPublic() || !client.AllowsGrant(GrantClientCredentials) {
+		return nil, NewError(ErrorUnauthorizedClient, "client may not use the client credentials grant")
+	}
+
+	requested := ParseScope(scope)
+	if len(requested) == 0 {
+		requested = client.Scopes
+	}
+	if !subsetOf(requested, client.Scopes) || HasScope(strings.Join(requested, " "), ScopeOpenID) {
+		return nil, NewError(ErrorInvalidScope, "requested scope is not allowed for this client")
+	}
+	granted := strings.Join(requested, " ")
+
+	lifetime := s.jwt.Expiry()
+	if client.AccessTokenTTL > 0 {
+		lifetime = time.Duration(client.AccessTokenTTL) * time.Second
+	}
+	accessToken, err := s.jwt.GenerateClientToken(client.ID, jwt.WithScope(granted),
+		jwt.WithExpiry(lifetime), jwt.WithAudience(client.Audience...))
+	if err != nil {
+		return nil, err
+	}
+	return &Tokens{TokenPair: &auth.TokenPair{
+		AccessToken: accessToken,
+		ExpiresIn:   int(lifetime.Seconds()),
+		Scope:       granted,
+	}}, nil
+}
+
+// ExchangeCode redeems an authorization code for tokens. A code that is
+// presented twice revokes the tokens issued for it (RFC 6749 section 4.1.2).
+func (s *Server) ExchangeCode(ctx context.Context, client *models.Client, rawCode, redirectURI, verifier string, info auth.ClientInfo) (*Tokens, error) {
+	if !client.AllowsGrant(GrantAuthorizationCode) {
+		return nil, NewError(ErrorUnauthorizedClient, "client may not use the authorization code grant")
+	}
+	if rawCode == "" {
+		return nil, NewError(ErrorInvalidRequest, "code is required")
+	}
+	if verifier == "" {
+		return nil, NewError(ErrorInvalidRequest, "code_verifier is required")
+	}
+
+	code, err := s.codes.GetByHash(ctx, auth.HashToken(rawCode))
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, NewError(ErrorInvalidGrant, "invalid authorization code")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if code.ClientID != client.ID {
+		return nil, NewError(ErrorInvalidGrant, "invalid authorization code")
+	}
+	if code.UsedAt != nil {
+		if code.SessionID != nil {
+			if err := s.tokens.RevokeSession(ctx, *code.SessionID); err != nil {
+				return nil, err
+			}
+		}
+		return nil, NewError(ErrorInvalidGrant, "authorization code has already been used")
+	}
+	if !time.Now().Before(code.ExpiresAt) {
+		return nil, NewError(ErrorInvalidGrant, "authorization code has expired")
+	}
+	if redirectURI != code.RedirectURI {
+		return nil, NewError(ErrorInvalidGrant, "redirect_uri does not match the authorization request")
+	}
+	if !verifyCodeChallenge(verifier, code.CodeChallenge, code.CodeChallengeMethod) {
+		return nil, NewError(ErrorInvalidGrant, "code_verifier does not match the code challenge")
+	}
+
+	user, err := s.users.GetByID(ctx, code.UserID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, NewError(ErrorInvalidGrant, "invalid authorization code")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !user.IsActive {
+		return nil, NewError(ErrorInvalidGrant, "invalid authorization code")
+	}
+
+	tokens, err := s.tokens.StartSession(ctx, user, info, auth.Grant{
+		ClientID: client.ID,
+		Scope:    code.Scope,
+		AuthTime: code.AuthTime,
+		AMR:      code.AMR,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Claim the code atomically; losing the race means it was replayed, in
+	// which case neither redemption may keep its tokens
+	claimed, err := s.codes.MarkUsed(ctx, code.ID, time.Now().UTC(), tokens.Session.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !claimed {
+		if err := s.tokens.RevokeSession(ctx, tokens.Session.ID); err != nil {
+			return nil, err
+		}
+		if used, err := s.codes.GetByHash(ctx, code.CodeHash); err == nil && used.SessionID != nil {
+			if err := s.tokens.RevokeSession(ctx, *used.SessionID); err != nil {
+				return nil, err
+			}
+		}
+		return nil, NewError(ErrorInvalidGrant, "authorization code has already been used")
+	}
+
+	return s.withIDToken(client, tokens, code.Nonce)
+}
+
+// RefreshToken rotates a refresh token issued to the client
+func (s *Server) RefreshToken(ctx context.Context, client *models.Client, rawToken string) (*Tokens, error) {
+	if !client.AllowsGrant(GrantRefreshToken) {
+		return nil, NewError(ErrorUnauthorizedClient, "client may not use the refresh token grant")
+	}
+	if rawToken == "" {
+		return nil, NewError(ErrorInvalidRequest, "refresh_token is required")
+	}
+
+	tokens, err := s.tokens.Refresh(ctx, rawToken, client.ID)
+	if errors.Is(err, auth.ErrInvalidRefreshToken) || errors.Is(err, auth.ErrRefreshTokenReused) {
+		return nil, NewError(ErrorInvalidGrant, "invalid or expired refresh token")
+	}
+	if err != nil {
+		return nil, err
+	}
+	// A refreshed ID token keeps the original auth_time and omits the nonce
+	return s.withIDToken(client, tokens, "")
+}
+
+// withIDToken adds an ID token to the response when openid was granted
+func (s *Server) withIDToken(client *models.Client, pair *auth.TokenPair, nonce string) (*Tokens, error) {
+	tokens := &Tokens{TokenPair: pair}
+	if HasScope(pair.Scope, ScopeOpenID) {
+		idToken, err := s.idToken(client, pair.User, pair.Session, nonce)
+		if err != nil {
+			return nil, err
+		}
+		tokens.IDToken = idToken
+	}
+	return tokens, nil
+}
+
+// PurgeExpiredCodes removes authorization codes that can no longer be redeemed
+func (s *Server) PurgeExpiredCodes(ctx context.Context) (int64, error) {
+	return s.codes.DeleteExpired(ctx, time.Now())
+}
+
+// RedirectURL appends params to the query of a registered redirect URI
+func RedirectURL(redirectURI string, params url.Values) string {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		return redirectURI
+	}
+	query := u.Query()
+	for key, values := range params {
+		for _, value := range values {
+			if value != "" {
+				query.Add(key, value)
+			}
+		}
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// TokenResponse is the successful response of the token endpoint (RFC 6749 section 5.1)
+type TokenResponse struct {
+	AccessToken     string `json:"access_token"`
+	TokenType       string `json:"token_type"`
+	ExpiresIn       int    `json:"expires_in"`
+	RefreshToken    string `json:"refresh_token,omitempty"`
+	Scope           string `json:"scope,omitempty"`
+	IDToken         string `json:"id_token,omitempty"`
+	IssuedTokenType string `json:"issued_token_type,omitempty"` // token exchange only
+}
+
+// NewTokenResponse builds the token endpoint response for issued tokens
+func NewTokenResponse(tokens *Tokens) *TokenResponse {
+	return &TokenResponse{
+		AccessToken:     tokens.AccessToken,
+		TokenType:       "Bearer",
+		ExpiresIn:       tokens.ExpiresIn,
+		RefreshToken:    tokens.RefreshToken,
+		Scope:           tokens.Scope,
+		IDToken:         tokens.IDToken,
+		IssuedTokenType: tokens.IssuedTokenType,
+	}
+}
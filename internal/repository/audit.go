@@ -0,0 +1,15 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/goldcast/gc_auth_service/internal/models"
+	"github.com/google/uuid"
+)
+
+// AuditRepository persists the audit trail
+type AuditRepository interface {
+	Create(ctx context.Context, event *models.AuditEvent) error
+	// ListByUser returns up to limit of the user's most recent events, newest first
+	ListByUser(ctx context.Context, userID uuid.UUID, limit int) ([]models.AuditEvent, error)
+}
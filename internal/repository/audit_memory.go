@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"context"
+	"sync"
+
+	"github.com/goldcast/gc_auth_service/internal/models"
+	"github.com/google/uuid"
+)
+
+// MemoryAuditRepository is an in-memory AuditRepository, intended for tests and local development
+type MemoryAuditRepository struct {
+	mu     sync.Mutex
+	events []models.AuditEvent // oldest first
+}
+
+// NewMemoryAuditRepository creates an empty in-memory audit repository
+func NewMemoryAuditRepository() *MemoryAuditRepository {
+	return &MemoryAuditRepository{}
+}
+
+// Create stores a new event
+func (r *MemoryAuditRepository) Create(ctx context.Context, event *models.AuditEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.events = append(r.events, *event)
+	return nil
+}
+
+// ListByUser returns up to limit of the user's most recent events, newest first
+func (r *MemoryAuditRepository) ListByUser(ctx context.Context, userID uuid.UUID, limit int) ([]models.AuditEvent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	events := []models.AuditEvent{}
+	for i := len(r.events) - 1; i >= 0 && len(events) < limit; i-- {
+		if event := r.events[i]; event.UserID != nil && *event.UserID == userID {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/goldcast/gc_auth_service/internal/models"
+	"github.com/google/uuid"
+)
+
+const auditColumns = `id, user_id, event_type, ip_address, user_agent, metadata, created_at`
+
+// SQLAuditRepository is an AuditRepository backed by PostgreSQL or SQLite
+type SQLAuditRepository struct {
+	db *sql.DB
+}
+
+// NewSQLAuditRepository creates an audit repository using the given database connection
+func NewSQLAuditRepository(db *sql.DB) *SQLAuditRepository {
+	return &SQLAuditRepository{db: db}
+}
+
+// Create inserts a new event
+func (r *SQLAuditRepository) Create(ctx context.Context, event *models.AuditEvent) error {
+	metadata := event.Metadata
+	if metadata == nil {
+		metadata = map[string]interface{}{}
+	}
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.ExecContext(ctx,
+		`INSERT INTO audit_events (`+auditColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		event.ID, event.UserID, event.Type, event.IPAddress, event.UserAgent, string(data), event.CreatedAt,
+	)
+	return err
+}
+
+// ListByUser returns up to limit of the user's most recent events, newest first
+func (r *SQLAuditRepository) ListByUser(ctx context.Context, userID uuid.UUID, limit int) ([]models.AuditEvent, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+auditColumns+` FROM audit_events WHERE user_id = $1 ORDER BY created_at DESC LIMIT $2`,
+		userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []models.AuditEvent{}
+	for rows.Next() {
+		var event models.AuditEvent
+		var user uuid.NullUUID
+		var metadata string
+		if err := rows.Scan(&event.ID, &user, &event.Type, &event.IPAddress, &event.UserAgent, &metadata, &event.CreatedAt); err != nil {
+			return nil, err
+		}
+		if user.Valid {
+			event.UserID = &user.UUID
+		}
+		if err := json.Unmarshal([]byte(metadata), &event.Metadata); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
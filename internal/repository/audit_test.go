@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/goldcast/gc_auth_service/internal/models"
+	"github.com/google/uuid"
+)
+
+func TestAuditRepository(t *testing.T) {
+	sqlDB := openSQLite(t)
+	for name, repo := range map[string]AuditRepository{
+		"memory": NewMemoryAuditRepository(),
+		"sql":    NewSQLAuditRepository(sqlDB),
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			userID := createUser(t, sqlDB, name)
+			now := time.Now().UTC().Truncate(time.Second)
+
+			for i, event := range []*models.AuditEvent{
+				{Type: "password_changed", UserID: &userID, IPAddress: "192.0.2.1", UserAgent: "test", Metadata: map[string]interface{}{"signed_out_other_sessions": true}},
+				{Type: "users_imported", Metadata: map[string]interface{}{"total": 3}},
+				{Type: "password_reset", UserID: &userID},
+			} {
+				event.ID = uuid.New()
+				event.CreatedAt = now.Add(time.Duration(i) * time.Second)
+				if err := repo.Create(ctx, event); err != nil {
+					t.Fatalf("create %s: %v", event.Type, err)
+				}
+			}
+
+			events, err := repo.ListByUser(ctx, userID, 10)
+			if err != nil {
+				t.Fatalf("list: %v", err)
+			}
+			if len(events) != 2 || events[0].Type != "password_reset" || events[1].Type != "password_changed" {
+				t.Fatalf("events %+v, want password_reset and password_changed", events)
+			}
+			changed := events[1]
+			if changed.IPAddress != "192.0.2.1" || changed.UserAgent != "test" || changed.Metadata["signed_out_other_sessions"] != true {
+				t.Errorf("password_changed event %+v", changed)
+			}
+			if limited, _ := repo.ListByUser(ctx, userID, 1); len(limited) != 1 {
+				t.Errorf("list with limit 1 returned %d events", len(limited))
+			}
+		})
+	}
+}
@@ -0,0 +1,20 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/goldcast/gc_auth_service/internal/models"
+	"github.com/google/uuid"
+)
+
+// AuthorizationCodeRepository persists issued OAuth authorization codes
+type AuthorizationCodeRepository interface {
+	Create(ctx context.Context, code *models.AuthorizationCode) error
+	GetByHash(ctx context.Context, hash string) (*models.AuthorizationCode, error)
+	// MarkUsed atomically marks an unused code as redeemed by the given session.
+	// It reports false if the code had already been used, which indicates a replay.
+	MarkUsed(ctx context.Context, id uuid.UUID, at time.Time, sessionID uuid.UUID) (bool, error)
+	// DeleteExpired removes codes that expired before now
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+}
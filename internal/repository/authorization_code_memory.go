@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/goldcast/gc_auth_service/internal/models"
+	"github.com/google/uuid"
+)
+
+// MemoryAuthorizationCodeRepository is an in-memory AuthorizationCodeRepository, intended for tests and local development
+type MemoryAuthorizationCodeRepository struct {
+	mu     sync.Mutex
+	codes  map[uuid.UUID]models.AuthorizationCode
+	byHash map[string]uuid.UUID
+}
+
+// NewMemoryAuthorizationCodeRepository creates an empty in-memory authorization code repository
+func NewMemoryAuthorizationCodeRepository() *MemoryAuthorizationCodeRepository {
+	return &MemoryAuthorizationCodeRepository{
+		codes:  make(map[uuid.UUID]models.AuthorizationCode),
+		byHash: make(map[string]uuid.UUID),
+	}
+}
+
+// Create stores a new authorization code
+func (r *MemoryAuthorizationCodeRepository) Create(ctx context.Context, code *models.AuthorizationCode) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.byHash[code.CodeHash]; ok {
+		return ErrConflict
+	}
+	r.codes[code.ID] = *code
+	r.byHash[code.CodeHash] = code.ID
+	return nil
+}
+
+// GetByHash returns the authorization code with the given hash
+func (r *MemoryAuthorizationCodeRepository) GetByHash(ctx context.Context, hash string) (*models.AuthorizationCode, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id, ok := r.byHash[hash]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	code := r.codes[id]
+	return &code, nil
+}
+
+// MarkUsed atomically marks an unused code as redeemed by the given session
+func (r *MemoryAuthorizationCodeRepository) MarkUsed(ctx context.Context, id uuid.UUID, at time.Time, sessionID uuid.UUID) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	code, ok := r.codes[id]
+	if !ok {
+		return false, ErrNotFound
+	}
+	if code.UsedAt != nil {
+		return false, nil
+	}
+	code.UsedAt = &at
+	code.SessionID = &sessionID
+	r.codes[id] = code
+	return true, nil
+}
+
+// DeleteExpired removes codes that expired before now
+func (r *MemoryAuthorizationCodeRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var n int64
+	for id, code := range r.codes {
+		if !code.ExpiresAt.After(now) {
+			delete(r.codes, id)
+			delete(r.byHash, code.CodeHash)
+			n++
+		}
+	}
+	return n, nil
+}
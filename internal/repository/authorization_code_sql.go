@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/goldcast/gc_auth_service/internal/models"
+	"github.com/google/uuid"
+)
+
+const authorizationCodeColumns = `id, code_hash, client_id, user_id, redirect_uri, scope, code_challenge, code_challenge_method, nonce, auth_time, amr, created_at, expires_at, used_at, session_id`
+
+// SQLAuthorizationCodeRepository is an AuthorizationCodeRepository backed by PostgreSQL or SQLite
+type SQLAuthorizationCodeRepository struct {
+	db *sql.DB
+}
+
+// NewSQLAuthorizationCodeRepository creates an authorization code repository using the given database connection
+func NewSQLAuthorizationCodeRepository(db *sql.DB) *SQLAuthorizationCodeRepository {
+	return &SQLAuthorizationCodeRepository{db: db}
+}
+
+// Create inserts a new authorization code
+func (r *SQLAuthorizationCodeRepository) Create(ctx context.Context, code *models.AuthorizationCode) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO authorization_codes (`+authorizationCodeColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)`,
+		code.ID, code.CodeHash, code.ClientID, code.UserID, code.RedirectURI, code.Scope,
+		code.CodeChallenge, code.CodeChallengeMethod, code.Nonce, code.AuthTime, jsonList(code.AMR),
+		code.CreatedAt, code.ExpiresAt, code.UsedAt, code.SessionID,
+	)
+	if _, ok := uniqueViolation(err); ok {
+		return ErrConflict
+	}
+	return err
+}
+
+// GetByHash returns the authorization code with the given hash
+func (r *SQLAuthorizationCodeRepository) GetByHash(ctx context.Context, hash string) (*models.AuthorizationCode, error) {
+	var code models.AuthorizationCode
+	var amr string
+	err := r.db.QueryRowContext(ctx, `SELECT `+authorizationCodeColumns+` FROM authorization_codes WHERE code_hash = $1`, hash).Scan(
+		&code.ID, &code.CodeHash, &code.ClientID, &code.UserID, &code.RedirectURI, &code.Scope,
+		&code.CodeChallenge, &code.CodeChallengeMethod, &code.Nonce, &code.AuthTime, &amr,
+		&code.CreatedAt, &code.ExpiresAt, &code.UsedAt, &code.SessionID,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := scanJSONList(amr, &code.AMR); err != nil {
+		return nil, err
+	}
+	return &code, nil
+}
+
+// MarkUsed atomically marks an unused code as redeemed by the given session
+func (r *SQLAuthorizationCodeRepository) MarkUsed(ctx context.Context, id uuid.UUID, at time.Time, sessionID uuid.UUID) (bool, error) {
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE authorization_codes SET used_at = $2, session_id = $3 WHERE id = $1 AND used_at IS NULL`,
+		id, at, sessionID)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// DeleteExpired removes codes that expired before now
+func (r *SQLAuthorizationCodeRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM authorization_codes WHERE expires_at <= $1`, now.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
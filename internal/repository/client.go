@@ -0,0 +1,21 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/goldcast/gc_auth_service/internal/models"
+)
+
+// ErrDuplicateClient is returned when a client with the same ID already exists
+var ErrDuplicateClient = errors.New("client already exists")
+
+// ClientRepository persists registered OAuth clients
+type ClientRepository interface {
+	Create(ctx context.Context, client *models.Client) error
+	GetByID(ctx context.Context, id string) (*models.Client, error)
+	// List returns all clients, oldest first
+	List(ctx context.Context) ([]*models.Client, error)
+	Update(ctx context.Context, client *models.Client) error
+	Delete(ctx context.Context, id string) error
+}
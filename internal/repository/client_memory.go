@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/goldcast/gc_auth_service/internal/models"
+)
+
+// MemoryClientRepository is an in-memory ClientRepository, intended for tests and local development
+type MemoryClientRepository struct {
+	mu      sync.RWMutex
+	clients map[string]models.Client
+}
+
+// NewMemoryClientRepository creates an empty in-memory client repository
+func NewMemoryClientRepository() *MemoryClientRepository {
+	return &MemoryClientRepository{
+		clients: make(map[string]models.Client),
+	}
+}
+
+// Create stores a new client
+func (r *MemoryClientRepository) Create(ctx context.Context, client *models.Client) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.clients[client.ID]; ok {
+		return ErrDuplicateClient
+	}
+	r.clients[client.ID] = cloneClient(*client)
+	return nil
+}
+
+// GetByID returns the client with the given ID
+func (r *MemoryClientRepository) GetByID(ctx context.Context, id string) (*models.Client, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	client, ok := r.clients[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	client = cloneClient(client)
+	return &client, nil
+}
+
+// List returns all clients, oldest first
+func (r *MemoryClientRepository) List(ctx context.Context) ([]*models.Client, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	clients := make([]*models.Client, 0, len(r.clients))
+	for _, client := range r.clients {
+		client = cloneClient(client)
+		clients = append(clients, &client)
+	}
+	sort.Slice(clients, func(i, j int) bool {
+		if !clients[i].CreatedAt.Equal(clients[j].CreatedAt) {
+			return clients[i].CreatedAt.Before(clients[j].CreatedAt)
+		}
+		return clients[i].ID < clients[j].ID
+	})
+	return clients, nil
+}
+
+// Update replaces an existing client
+func (r *MemoryClientRepository) Update(ctx context.Context, client *models.Client) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.clients[client.ID]; !ok {
+		return ErrNotFound
+	}
+	r.clients[client.ID] = cloneClient(*client)
+	return nil
+}
+
+// Delete removes a client
+func (r *MemoryClientRepository) Delete(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.clients[id]; !ok {
+		return ErrNotFound
+	}
+	delete(r.clients, id)
+	return nil
+}
+
+// cloneClient copies the slices of a client so stored records cannot be mutated by callers
+func cloneClient(client models.Client) models.Client {
+	client.RedirectURIs = append([]string(nil), client.RedirectURIs...)
+	client.GrantTypes = append([]string(nil), client.GrantTypes...)
+	client.Scopes = append([]string(nil), client.Scopes...)
+	client.JWKS = append([]byte(nil), client.JWKS...)
+	client.Audience = append([]string(nil), client.Audience...)
+	client.TokenExchangeAudiences = append([]string(nil), client.TokenExchangeAudiences...)
+	if client.PreviousSecretExpiresAt != nil {
+		expiresAt := *client.PreviousSecretExpiresAt
+		client.PreviousSecretExpiresAt = &expiresAt
+	}
+	return client
+}
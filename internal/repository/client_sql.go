@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/goldcast/gc_auth_service/internal/models"
+)
+
+const clientColumns = `id, secret_hash, name, redirect_uris, grant_types, scopes, token_endpoint_auth_method, jwks,
+	access_token_ttl, refresh_token_ttl, audience, token_exchange_audiences, previous_secret_hash, previous_secret_expires_at,
+	created_at, updated_at`
+
+// SQLClientRepository is a ClientRepository backed by PostgreSQL or SQLite
+type SQLClientRepository struct {
+	db *sql.DB
+}
+
+// NewSQLClientRepository creates a client repository using the given database connection
+func NewSQLClientRepository(db *sql.DB) *SQLClientRepository {
+	return &SQLClientRepository{db: db}
+}
+
+// Create inserts a new client
+func (r *SQLClientRepository) Create(ctx context.Context, client *models.Client) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO oauth_clients (`+clientColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)`,
+		client.ID, client.SecretHash, client.Name,
+		jsonList(client.RedirectURIs), jsonList(client.GrantTypes), jsonList(client.Scopes),
+		client.TokenEndpointAuthMethod, nullJSON(client.JWKS),
+		client.AccessTokenTTL, client.RefreshTokenTTL, jsonList(client.Audience), jsonList(client.TokenExchangeAudiences),
+		client.PreviousSecretHash, client.PreviousSecretExpiresAt, client.CreatedAt, client.UpdatedAt,
+	)
+	if _, ok := uniqueViolation(err); ok {
+		return ErrDuplicateClient
+	}
+	return err
+}
+
+// GetByID returns the client with the given ID
+func (r *SQLClientRepository) GetByID(ctx context.Context, id string) (*models.Client, error) {
+	client, err := scanClient(r.db.QueryRowContext(ctx, `SELECT `+clientColumns+` FROM oauth_clients WHERE id = $1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return client, err
+}
+
+// List returns all clients, oldest first
+func (r *SQLClientRepository) List(ctx context.Context) ([]*models.Client, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+clientColumns+` FROM oauth_clients ORDER BY created_at, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	clients := []*models.Client{}
+	for rows.Next() {
+		client, err := scanClient(rows)
+		if err != nil {
+			return nil, err
+		}
+		clients = append(clients, client)
+	}
+	return clients, rows.Err()
+}
+
+// Update saves changes to an existing client
+func (r *SQLClientRepository) Update(ctx context.Context, client *models.Client) error {
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE oauth_clients SET secret_hash = $2, name = $3, redirect_uris = $4, grant_types = $5,
+			scopes = $6, token_endpoint_auth_method = $7, jwks = $8, access_token_ttl = $9,
+			refresh_token_ttl = $10, audience = $11, token_exchange_audiences = $12,
+			previous_secret_hash = $13, previous_secret_expires_at = $14, updated_at = $15
+		WHERE id = $1`,
+		client.ID, client.SecretHash, client.Name,
+		jsonList(client.RedirectURIs), jsonList(client.GrantTypes), jsonList(client.Scopes),
+		client.TokenEndpointAuthMethod, nullJSON(client.JWKS),
+		client.AccessTokenTTL, client.RefreshTokenTTL, jsonList(client.Audience), jsonList(client.TokenExchangeAudiences),
+		client.PreviousSecretHash, client.PreviousSecretExpiresAt, client.UpdatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	return expectAffected(result)
+}
+
+// Delete removes a client; its authorization and device codes are removed by
+// the foreign key cascade
+func (r *SQLClientRepository) Delete(ctx context.Context, id string) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM oauth_clients WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	return expectAffected(result)
+}
+
+// scanClient reads a client from a row selected with clientColumns
+func scanClient(row rowScanner) (*models.Client, error) {
+	var client models.Client
+	var redirectURIs, grantTypes, scopes, audience, exchangeAudiences string
+	var jwks sql.NullString
+	err := row.Scan(
+		&client.ID, &client.SecretHash, &client.Name, &redirectURIs, &grantTypes, &scopes,
+		&client.TokenEndpointAuthMethod, &jwks, &client.AccessTokenTTL, &client.RefreshTokenTTL,
+		&audience, &exchangeAudiences, &client.PreviousSecretHash, &client.PreviousSecretExpiresAt,
+		&client.CreatedAt, &client.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := scanJSONList(redirectURIs, &client.RedirectURIs); err != nil {
+		return nil, err
+	}
+	if err := scanJSONList(grantTypes, &client.GrantTypes); err != nil {
+		return nil, err
+	}
+	if err := scanJSONList(scopes, &client.Scopes); err != nil {
+		return nil, err
+	}
+	if err := scanJSONList(audience, &client.Audience); err != nil {
+		return nil, err
+	}
+	if err := scanJSONList(exchangeAudiences, &client.TokenExchangeAudiences); err != nil {
+		return nil, err
+	}
+	if jwks.Valid {
+		client.JWKS = []byte(jwks.String)
+	}
+	return &client, nil
+}
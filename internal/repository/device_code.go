@@ -0,0 +1,32 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/goldcast/gc_auth_service/internal/models"
+	"github.com/google/uuid"
+)
+
+// DeviceCodeRepository persists pending device authorizations
+type DeviceCodeRepository interface {
+	// Create stores a new device code. It returns ErrConflict if the device
+	// or user code is already taken.
+	Create(ctx context.Context, code *models.DeviceCode) error
+	GetByHash(ctx context.Context, hash string) (*models.DeviceCode, error)
+	GetByUserCode(ctx context.Context, userCode string) (*models.DeviceCode, error)
+	// RecordPoll stores when the device last polled and the interval it must
+	// wait before polling again
+	RecordPoll(ctx context.Context, id uuid.UUID, at time.Time, interval int) error
+	// Approve atomically grants a pending code to the user. It reports false
+	// if the code is no longer pending.
+	Approve(ctx context.Context, id uuid.UUID, userID uuid.UUID, authTime time.Time, amr []string) (bool, error)
+	// Deny atomically rejects a pending code. It reports false if the code is
+	// no longer pending.
+	Deny(ctx context.Context, id uuid.UUID) (bool, error)
+	// MarkUsed atomically marks an approved code as redeemed by the given
+	// session. It reports false if the code had already been redeemed.
+	MarkUsed(ctx context.Context, id uuid.UUID, at time.Time, sessionID uuid.UUID) (bool, error)
+	// DeleteExpired removes codes that expired before now
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+}
@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/goldcast/gc_auth_service/internal/models"
+	"github.com/google/uuid"
+)
+
+// MemoryDeviceCodeRepository is an in-memory DeviceCodeRepository, intended for tests and local development
+type MemoryDeviceCodeRepository struct {
+	mu         sync.Mutex
+	codes      map[uuid.UUID]models.DeviceCode
+	byHash     map[string]uuid.UUID
+	byUserCode map[string]uuid.UUID
+}
+
+// NewMemoryDeviceCodeRepository creates an empty in-memory device code repository
+func NewMemoryDeviceCodeRepository() *MemoryDeviceCodeRepository {
+	return &MemoryDeviceCodeRepository{
+		codes:      make(map[uuid.UUID]models.DeviceCode),
+		byHash:     make(map[string]uuid.UUID),
+		byUserCode: make(map[string]uuid.UUID),
+	}
+}
+
+// Create stores a new device code
+func (r *MemoryDeviceCodeRepository) Create(ctx context.Context, code *models.DeviceCode) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.byHash[code.DeviceCodeHash]; ok {
+		return ErrConflict
+	}
+	if _, ok := r.byUserCode[code.UserCode]; ok {
+		return ErrConflict
+	}
+	r.codes[code.ID] = *code
+	r.byHash[code.DeviceCodeHash] = code.ID
+	r.byUserCode[code.UserCode] = code.ID
+	return nil
+}
+
+// GetByHash returns the device code with the given hash
+func (r *MemoryDeviceCodeRepository) GetByHash(ctx context.Context, hash string) (*models.DeviceCode, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id, ok := r.byHash[hash]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	code := r.codes[id]
+	return &code, nil
+}
+
+// GetByUserCode returns the device code with the given user code
+func (r *MemoryDeviceCodeRepository) GetByUserCode(ctx context.Context, userCode string) (*models.DeviceCode, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id, ok := r.byUserCode[userCode]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	code := r.codes[id]
+	return &code, nil
+}
+
+// RecordPoll stores when the device last polled and its polling interval
+func (r *MemoryDeviceCodeRepository) RecordPoll(ctx context.Context, id uuid.UUID, at time.Time, interval int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	code, ok := r.codes[id]
+	if !ok {
+		return ErrNotFound
+	}
+	code.LastPolledAt = &at
+	code.Interval = interval
+	r.codes[id] = code
+	return nil
+}
+
+// Approve atomically grants a pending code to the user
+func (r *MemoryDeviceCodeRepository) Approve(ctx context.Context, id uuid.UUID, userID uuid.UUID, authTime time.Time, amr []string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	code, ok := r.codes[id]
+	if !ok {
+		return false, ErrNotFound
+	}
+	if code.Status != models.DeviceCodePending {
+		return false, nil
+	}
+	code.Status = models.DeviceCodeApproved
+	code.UserID = &userID
+	code.AuthTime = &authTime
+	code.AMR = amr
+	r.codes[id] = code
+	return true, nil
+}
+
+// Deny atomically rejects a pending code
+func (r *MemoryDeviceCodeRepository) Deny(ctx context.Context, id uuid.UUID) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	code, ok := r.codes[id]
+	if !ok {
+		return false, ErrNotFound
+	}
+	if code.Status != models.DeviceCodePending {
+		return false, nil
+	}
+	code.Status = models.DeviceCodeDenied
+	r.codes[id] = code
+	return true, nil
+}
+
+// MarkUsed atomically marks an approved code as redeemed by the given session
+func (r *MemoryDeviceCodeRepository) MarkUsed(ctx context.Context, id uuid.UUID, at time.Time, sessionID uuid.UUID) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	code, ok := r.codes[id]
+	if !ok {
+		return false, ErrNotFound
+	}
+	if code.Status != models.DeviceCodeApproved || code.UsedAt != nil {
+		return false, nil
+	}
+	code.UsedAt = &at
+	code.SessionID = &sessionID
+	r.codes[id] = code
+	return true, nil
+}
+
+// DeleteExpired removes codes that expired before now
+func (r *MemoryDeviceCodeRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var n int64
+	for id, code := range r.codes {
+		if !code.ExpiresAt.After(now) {
+			delete(r.codes, id)
+			delete(r.byHash, code.DeviceCodeHash)
+			delete(r.byUserCode, code.UserCode)
+			n++
+		}
+	}
+	return n, nil
+}
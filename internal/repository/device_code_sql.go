@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/goldcast/gc_auth_service/internal/models"
+	"github.com/google/uuid"
+)
+
+const deviceCodeColumns = `id, device_code_hash, user_code, client_id, scope, status, user_id, auth_time, amr, poll_interval, last_polled_at, created_at, expires_at, used_at, session_id`
+
+// SQLDeviceCodeRepository is a DeviceCodeRepository backed by PostgreSQL or SQLite
+type SQLDeviceCodeRepository struct {
+	db *sql.DB
+}
+
+// NewSQLDeviceCodeRepository creates a device code repository using the given database connection
+func NewSQLDeviceCodeRepository(db *sql.DB) *SQLDeviceCodeRepository {
+	return &SQLDeviceCodeRepository{db: db}
+}
+
+// Create inserts a new device code
+func (r *SQLDeviceCodeRepository) Create(ctx context.Context, code *models.DeviceCode) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO device_codes (`+deviceCodeColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)`,
+		code.ID, code.DeviceCodeHash, code.UserCode, code.ClientID, code.Scope, code.Status,
+		code.UserID, code.AuthTime, jsonList(code.AMR), code.Interval, code.LastPolledAt,
+		code.CreatedAt, code.ExpiresAt, code.UsedAt, code.SessionID,
+	)
+	if _, ok := uniqueViolation(err); ok {
+		return ErrConflict
+	}
+	return err
+}
+
+// GetByHash returns the device code with the given hash
+func (r *SQLDeviceCodeRepository) GetByHash(ctx context.Context, hash string) (*models.DeviceCode, error) {
+	return r.get(ctx, `device_code_hash = $1`, hash)
+}
+
+// GetByUserCode returns the device code with the given user code
+func (r *SQLDeviceCodeRepository) GetByUserCode(ctx context.Context, userCode string) (*models.DeviceCode, error) {
+	return r.get(ctx, `user_code = $1`, userCode)
+}
+
+// RecordPoll stores when the device last polled and its polling interval
+func (r *SQLDeviceCodeRepository) RecordPoll(ctx context.Context, id uuid.UUID, at time.Time, interval int) error {
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE device_codes SET last_polled_at = $2, poll_interval = $3 WHERE id = $1`,
+		id, at, interval)
+	if err != nil {
+		return err
+	}
+	return expectAffected(result)
+}
+
+// Approve atomically grants a pending code to the user
+func (r *SQLDeviceCodeRepository) Approve(ctx context.Context, id uuid.UUID, userID uuid.UUID, authTime time.Time, amr []string) (bool, error) {
+	return r.transition(ctx,
+		`UPDATE device_codes SET status = $2, user_id = $3, auth_time = $4, amr = $5 WHERE id = $1 AND status = $6`,
+		id, models.DeviceCodeApproved, userID, authTime, jsonList(amr), models.DeviceCodePending)
+}
+
+// Deny atomically rejects a pending code
+func (r *SQLDeviceCodeRepository) Deny(ctx context.Context, id uuid.UUID) (bool, error) {
+	return r.transition(ctx,
+		`UPDATE device_codes SET status = $2 WHERE id = $1 AND status = $3`,
+		id, models.DeviceCodeDenied, models.DeviceCodePending)
+}
+
+// MarkUsed atomically marks an approved code as redeemed by the given session
+func (r *SQLDeviceCodeRepository) MarkUsed(ctx context.Context, id uuid.UUID, at time.Time, sessionID uuid.UUID) (bool, error) {
+	return r.transition(ctx,
+		`UPDATE device_codes SET used_at = $2, session_id = $3 WHERE id = $1 AND status = $4 AND used_at IS NULL`,
+		id, at, sessionID, models.DeviceCodeApproved)
+}
+
+// DeleteExpired removes codes that expired before now
+func (r *SQLDeviceCodeRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM device_codes WHERE expires_at <= $1`, now.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// get returns the single device code matching the condition
+func (r *SQLDeviceCodeRepository) get(ctx context.Context, where string, arg interface{}) (*models.DeviceCode, error) {
+	var code models.DeviceCode
+	var amr string
+	err := r.db.QueryRowContext(ctx, `SELECT `+deviceCodeColumns+` FROM device_codes WHERE `+where, arg).Scan(
+		&code.ID, &code.DeviceCodeHash, &code.UserCode, &code.ClientID, &code.Scope, &code.Status,
+		&code.UserID, &code.AuthTime, &amr, &code.Interval, &code.LastPolledAt,
+		&code.CreatedAt, &code.ExpiresAt, &code.UsedAt, &code.SessionID,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := scanJSONList(amr, &code.AMR); err != nil {
+		return nil, err
+	}
+	return &code, nil
+}
+
+// transition runs a conditional update and reports whether it matched
+func (r *SQLDeviceCodeRepository) transition(ctx context.Context, query string, args ...interface{}) (bool, error) {
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
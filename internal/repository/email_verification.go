@@ -0,0 +1,22 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/goldcast/gc_auth_service/internal/models"
+	"github.com/google/uuid"
+)
+
+// EmailVerificationRepository persists the verification links sent to users
+type EmailVerificationRepository interface {
+	Create(ctx context.Context, verification *models.EmailVerification) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.EmailVerification, error)
+	// GetLatestByUser returns the most recent verification sent to the user
+	GetLatestByUser(ctx context.Context, userID uuid.UUID) (*models.EmailVerification, error)
+	// MarkUsed atomically marks a verification as used. It reports false if
+	// it had already been used.
+	MarkUsed(ctx context.Context, id uuid.UUID, at time.Time) (bool, error)
+	// DeleteExpired removes verifications that expired before now
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+}
@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/goldcast/gc_auth_service/internal/models"
+	"github.com/google/uuid"
+)
+
+// MemoryEmailVerificationRepository is an in-memory EmailVerificationRepository, intended for tests and local development
+type MemoryEmailVerificationRepository struct {
+	mu            sync.Mutex
+	verifications map[uuid.UUID]models.EmailVerification
+}
+
+// NewMemoryEmailVerificationRepository creates an empty in-memory email verification repository
+func NewMemoryEmailVerificationRepository() *MemoryEmailVerificationRepository {
+	return &MemoryEmailVerificationRepository{
+		verifications: make(map[uuid.UUID]models.EmailVerification),
+	}
+}
+
+// Create stores a new verification
+func (r *MemoryEmailVerificationRepository) Create(ctx context.Context, verification *models.EmailVerification) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.verifications[verification.ID]; ok {
+		return ErrConflict
+	}
+	r.verifications[verification.ID] = *verification
+	return nil
+}
+
+// GetByID returns the verification with the given ID
+func (r *MemoryEmailVerificationRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.EmailVerification, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	verification, ok := r.verifications[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &verification, nil
+}
+
+// GetLatestByUser returns the most recent verification sent to the user
+func (r *MemoryEmailVerificationRepository) GetLatestByUser(ctx context.Context, userID uuid.UUID) (*models.EmailVerification, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var latest *models.EmailVerification
+	for _, verification := range r.verifications {
+		if verification.UserID != userID {
+			continue
+		}
+		if latest == nil || verification.CreatedAt.After(latest.CreatedAt) {
+			verification := verification
+			latest = &verification
+		}
+	}
+	if latest == nil {
+		return nil, ErrNotFound
+	}
+	return latest, nil
+}
+
+// MarkUsed atomically marks a verification as used
+func (r *MemoryEmailVerificationRepository) MarkUsed(ctx context.Context, id uuid.UUID, at time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	verification, ok := r.verifications[id]
+	if !ok {
+		return false, ErrNotFound
+	}
+	if verification.UsedAt != nil {
+		return false, nil
+	}
+	verification.UsedAt = &at
+	r.verifications[id] = verification
+	return true, nil
+}
+
+// DeleteExpired removes verifications that expired before now
+func (r *MemoryEmailVerificationRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var n int64
+	for id, verification := range r.verifications {
+		if !verification.ExpiresAt.After(now) {
+			delete(r.verifications, id)
+			n++
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/goldcast/gc_auth_service/internal/models"
+	"github.com/google/uuid"
+)
+
+const emailVerificationColumns = `id, user_id, email, created_at, expires_at, used_at`
+
+// SQLEmailVerificationRepository is an EmailVerificationRepository backed by PostgreSQL or SQLite
+type SQLEmailVerificationRepository struct {
+	db *sql.DB
+}
+
+// NewSQLEmailVerificationRepository creates an email verification repository using the given database connection
+func NewSQLEmailVerificationRepository(db *sql.DB) *SQLEmailVerificationRepository {
+	return &SQLEmailVerificationRepository{db: db}
+}
+
+// Create inserts a new verification
+func (r *SQLEmailVerificationRepository) Create(ctx context.Context, verification *models.EmailVerification) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO email_verifications (`+emailVerificationColumns+`) VALUES ($1, $2, $3, $4, $5, $6)`,
+		verification.ID, verification.UserID, verification.Email,
+		verification.CreatedAt, verification.ExpiresAt, verification.UsedAt,
+	)
+	if _, ok := uniqueViolation(err); ok {
+		return ErrConflict
+	}
+	return err
+}
+
+// GetByID returns the verification with the given ID
+func (r *SQLEmailVerificationRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.EmailVerification, error) {
+	return r.getOne(ctx, `SELECT `+emailVerificationColumns+` FROM email_verifications WHERE id = $1`, id)
+}
+
+// GetLatestByUser returns the most recent verification sent to the user
+func (r *SQLEmailVerificationRepository) GetLatestByUser(ctx context.Context, userID uuid.UUID) (*models.EmailVerification, error) {
+	return r.getOne(ctx,
+		`SELECT `+emailVerificationColumns+` FROM email_verifications WHERE user_id = $1 ORDER BY created_at DESC LIMIT 1`, userID)
+}
+
+// MarkUsed atomically marks a verification as used
+func (r *SQLEmailVerificationRepository) MarkUsed(ctx context.Context, id uuid.UUID, at time.Time) (bool, error) {
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE email_verifications SET used_at = $2 WHERE id = $1 AND used_at IS NULL`, id, at)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// DeleteExpired removes verifications that expired before now
+func (r *SQLEmailVerificationRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM email_verifications WHERE expires_at <= $1`, now.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (r *SQLEmailVerificationRepository) getOne(ctx context.Context, query string, args ...interface{}) (*models.EmailVerification, error) {
+	var verification models.EmailVerification
+	err := r.db.QueryRowContext(ctx, query, args...).Scan(
+		&verification.ID, &verification.UserID, &verification.Email,
+		&verification.CreatedAt, &verification.ExpiresAt, &verification.UsedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &verification, nil
+}
@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/goldcast/gc_auth_service/internal/models"
+	"github.com/google/uuid"
+)
+
+// MFARepository persists users' second factors: their TOTP enrollment and
+// recovery codes
+type MFARepository interface {
+	// GetTOTP returns the user's TOTP enrollment, whether confirmed or not
+	GetTOTP(ctx context.Context, userID uuid.UUID) (*models.TOTPCredential, error)
+	// SaveTOTP stores a new TOTP enrollment, replacing any previous one
+	SaveTOTP(ctx context.Context, cred *models.TOTPCredential) error
+	// ConfirmTOTP atomically confirms a pending enrollment with the time step
+	// of the confirming code. It reports false if it was already confirmed.
+	ConfirmTOTP(ctx context.Context, userID uuid.UUID, at time.Time, step int64) (bool, error)
+	// RecordTOTPStep atomically advances the time step of the last accepted
+	// code. It reports false if the step is not newer, i.e. the code was
+	// already used.
+	RecordTOTPStep(ctx context.Context, userID uuid.UUID, step int64) (bool, error)
+	// DeleteTOTP removes the user's TOTP enrollment and recovery codes
+	DeleteTOTP(ctx context.Context, userID uuid.UUID) error
+	// ReplaceRecoveryCodes replaces all of the user's recovery codes
+	ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, codes []*models.RecoveryCode) error
+	// UseRecoveryCode atomically marks an unused recovery code as used. It
+	// reports false if the user has no such unused code.
+	UseRecoveryCode(ctx context.Context, userID uuid.UUID, hash string, at time.Time) (bool, error)
+	// CountRecoveryCodes returns the number of the user's unused recovery codes
+	CountRecoveryCodes(ctx context.Context, userID uuid.UUID) (int, error)
+}
+
+// MFAChallengeRepository persists pending second-factor verifications
+type MFAChallengeRepository interface {
+	// Create stores a new challenge. It returns ErrConflict if the token is
+	// already taken.
+	Create(ctx context.Context, challenge *models.MFAChallenge) error
+	GetByHash(ctx context.Context, hash string) (*models.MFAChallenge, error)
+	// RecordFailure counts a failed verification attempt and returns the
+	// number of attempts so far
+	RecordFailure(ctx context.Context, id uuid.UUID) (int, error)
+	// MarkUsed atomically marks a challenge as completed. It reports false if
+	// it had already been completed.
+	MarkUsed(ctx context.Context, id uuid.UUID, at time.Time) (bool, error)
+	// DeleteExpired removes challenges that expired before now
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+}
@@ -0,0 +1,221 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/goldcast/gc_auth_service/internal/models"
+	"github.com/google/uuid"
+)
+
+// MemoryMFARepository is an in-memory MFARepository, intended for tests and local development
+type MemoryMFARepository struct {
+	mu            sync.Mutex
+	totp          map[uuid.UUID]models.TOTPCredential
+	recoveryCodes map[uuid.UUID][]models.RecoveryCode
+}
+
+// NewMemoryMFARepository creates an empty in-memory MFA repository
+func NewMemoryMFARepository() *MemoryMFARepository {
+	return &MemoryMFARepository{
+		totp:          make(map[uuid.UUID]models.TOTPCredential),
+		recoveryCodes: make(map[uuid.UUID][]models.RecoveryCode),
+	}
+}
+
+// GetTOTP returns the user's TOTP enrollment
+func (r *MemoryMFARepository) GetTOTP(ctx context.Context, userID uuid.UUID) (*models.TOTPCredential, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cred, ok := r.totp[userID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &cred, nil
+}
+
+// SaveTOTP stores a new TOTP enrollment, replacing any previous one
+func (r *MemoryMFARepository) SaveTOTP(ctx context.Context, cred *models.TOTPCredential) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.totp[cred.UserID] = *cred
+	return nil
+}
+
+// ConfirmTOTP atomically confirms a pending enrollment
+func (r *MemoryMFARepository) ConfirmTOTP(ctx context.Context, userID uuid.UUID, at time.Time, step int64) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cred, ok := r.totp[userID]
+	if !ok {
+		return false, ErrNotFound
+	}
+	if cred.ConfirmedAt != nil {
+		return false, nil
+	}
+	cred.ConfirmedAt = &at
+	cred.LastUsedStep = step
+	r.totp[userID] = cred
+	return true, nil
+}
+
+// RecordTOTPStep atomically advances the time step of the last accepted code
+func (r *MemoryMFARepository) RecordTOTPStep(ctx context.Context, userID uuid.UUID, step int64) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cred, ok := r.totp[userID]
+	if !ok {
+		return false, ErrNotFound
+	}
+	if cred.LastUsedStep >= step {
+		return false, nil
+	}
+	cred.LastUsedStep = step
+	r.totp[userID] = cred
+	return true, nil
+}
+
+// DeleteTOTP removes the user's TOTP enrollment and recovery codes
+func (r *MemoryMFARepository) DeleteTOTP(ctx context.Context, userID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.totp, userID)
+	delete(r.recoveryCodes, userID)
+	return nil
+}
+
+// ReplaceRecoveryCodes replaces all of the user's recovery codes
+func (r *MemoryMFARepository) ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, codes []*models.RecoveryCode) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored := make([]models.RecoveryCode, 0, len(codes))
+	for _, code := range codes {
+		stored = append(stored, *code)
+	}
+	r.recoveryCodes[userID] = stored
+	return nil
+}
+
+// UseRecoveryCode atomically marks an unused recovery code as used
+func (r *MemoryMFARepository) UseRecoveryCode(ctx context.Context, userID uuid.UUID, hash string, at time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	codes := r.recoveryCodes[userID]
+	for i := range codes {
+		if codes[i].CodeHash == hash && codes[i].UsedAt == nil {
+			codes[i].UsedAt = &at
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// CountRecoveryCodes returns the number of the user's unused recovery codes
+func (r *MemoryMFARepository) CountRecoveryCodes(ctx context.Context, userID uuid.UUID) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var n int
+	for _, code := range r.recoveryCodes[userID] {
+		if code.UsedAt == nil {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// MemoryMFAChallengeRepository is an in-memory MFAChallengeRepository, intended for tests and local development
+type MemoryMFAChallengeRepository struct {
+	mu         sync.Mutex
+	challenges map[uuid.UUID]models.MFAChallenge
+	byHash     map[string]uuid.UUID
+}
+
+// NewMemoryMFAChallengeRepository creates an empty in-memory MFA challenge repository
+func NewMemoryMFAChallengeRepository() *MemoryMFAChallengeRepository {
+	return &MemoryMFAChallengeRepository{
+		challenges: make(map[uuid.UUID]models.MFAChallenge),
+		byHash:     make(map[string]uuid.UUID),
+	}
+}
+
+// Create stores a new challenge
+func (r *MemoryMFAChallengeRepository) Create(ctx context.Context, challenge *models.MFAChallenge) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.byHash[challenge.TokenHash]; ok {
+		return ErrConflict
+	}
+	r.challenges[challenge.ID] = *challenge
+	r.byHash[challenge.TokenHash] = challenge.ID
+	return nil
+}
+
+// GetByHash returns the challenge with the given token hash
+func (r *MemoryMFAChallengeRepository) GetByHash(ctx context.Context, hash string) (*models.MFAChallenge, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id, ok := r.byHash[hash]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	challenge := r.challenges[id]
+	return &challenge, nil
+}
+
+// RecordFailure counts a failed verification attempt
+func (r *MemoryMFAChallengeRepository) RecordFailure(ctx context.Context, id uuid.UUID) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	challenge, ok := r.challenges[id]
+	if !ok {
+		return 0, ErrNotFound
+	}
+	challenge.Attempts++
+	r.challenges[id] = challenge
+	return challenge.Attempts, nil
+}
+
+// MarkUsed atomically marks a challenge as completed
+func (r *MemoryMFAChallengeRepository) MarkUsed(ctx context.Context, id uuid.UUID, at time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	challenge, ok := r.challenges[id]
+	if !ok {
+		return false, ErrNotFound
+	}
+	if challenge.UsedAt != nil {
+		return false, nil
+	}
+	challenge.UsedAt = &at
+	r.challenges[id] = challenge
+	return true, nil
+}
+
+// DeleteExpired removes challenges that expired before now
+func (r *MemoryMFAChallengeRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var n int64
+	for id, challenge := range r.challenges {
+		if !challenge.ExpiresAt.After(now) {
+			delete(r.challenges, id)
+			delete(r.byHash, challenge.TokenHash)
+			n++
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,213 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/goldcast/gc_auth_service/internal/models"
+	"github.com/google/uuid"
+)
+
+const (
+	totpColumns         = `user_id, secret_encrypted, confirmed_at, last_used_step, created_at`
+	recoveryCodeColumns = `id, user_id, code_hash, created_at, used_at`
+	mfaChallengeColumns = `id, token_hash, user_id, amr, attempts, created_at, expires_at, used_at`
+)
+
+// SQLMFARepository is an MFARepository backed by PostgreSQL or SQLite
+type SQLMFARepository struct {
+	db *sql.DB
+}
+
+// NewSQLMFARepository creates an MFA repository using the given database connection
+func NewSQLMFARepository(db *sql.DB) *SQLMFARepository {
+	return &SQLMFARepository{db: db}
+}
+
+// GetTOTP returns the user's TOTP enrollment
+func (r *SQLMFARepository) GetTOTP(ctx context.Context, userID uuid.UUID) (*models.TOTPCredential, error) {
+	var cred models.TOTPCredential
+	err := r.db.QueryRowContext(ctx, `SELECT `+totpColumns+` FROM mfa_totp WHERE user_id = $1`, userID).Scan(
+		&cred.UserID, &cred.SecretEncrypted, &cred.ConfirmedAt, &cred.LastUsedStep, &cred.CreatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &cred, nil
+}
+
+// SaveTOTP stores a new TOTP enrollment, replacing any previous one
+func (r *SQLMFARepository) SaveTOTP(ctx context.Context, cred *models.TOTPCredential) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO mfa_totp (`+totpColumns+`) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id) DO UPDATE SET secret_encrypted = excluded.secret_encrypted,
+			confirmed_at = excluded.confirmed_at, last_used_step = excluded.last_used_step, created_at = excluded.created_at`,
+		cred.UserID, cred.SecretEncrypted, cred.ConfirmedAt, cred.LastUsedStep, cred.CreatedAt,
+	)
+	return err
+}
+
+// ConfirmTOTP atomically confirms a pending enrollment
+func (r *SQLMFARepository) ConfirmTOTP(ctx context.Context, userID uuid.UUID, at time.Time, step int64) (bool, error) {
+	return r.transition(ctx,
+		`UPDATE mfa_totp SET confirmed_at = $2, last_used_step = $3 WHERE user_id = $1 AND confirmed_at IS NULL`,
+		userID, at, step)
+}
+
+// RecordTOTPStep atomically advances the time step of the last accepted code
+func (r *SQLMFARepository) RecordTOTPStep(ctx context.Context, userID uuid.UUID, step int64) (bool, error) {
+	return r.transition(ctx,
+		`UPDATE mfa_totp SET last_used_step = $2 WHERE user_id = $1 AND last_used_step < $2`,
+		userID, step)
+}
+
+// DeleteTOTP removes the user's TOTP enrollment and recovery codes
+func (r *SQLMFARepository) DeleteTOTP(ctx context.Context, userID uuid.UUID) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM mfa_totp WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ReplaceRecoveryCodes replaces all of the user's recovery codes
+func (r *SQLMFARepository) ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, codes []*models.RecoveryCode) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	for _, code := range codes {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO mfa_recovery_codes (`+recoveryCodeColumns+`) VALUES ($1, $2, $3, $4, $5)`,
+			code.ID, code.UserID, code.CodeHash, code.CreatedAt, code.UsedAt,
+		); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// UseRecoveryCode atomically marks an unused recovery code as used
+func (r *SQLMFARepository) UseRecoveryCode(ctx context.Context, userID uuid.UUID, hash string, at time.Time) (bool, error) {
+	return r.transition(ctx,
+		`UPDATE mfa_recovery_codes SET used_at = $3 WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`,
+		userID, hash, at)
+}
+
+// CountRecoveryCodes returns the number of the user's unused recovery codes
+func (r *SQLMFARepository) CountRecoveryCodes(ctx context.Context, userID uuid.UUID) (int, error) {
+	var n int
+	err := r.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM mfa_recovery_codes WHERE user_id = $1 AND used_at IS NULL`, userID).Scan(&n)
+	return n, err
+}
+
+// transition runs a conditional update and reports whether it matched
+func (r *SQLMFARepository) transition(ctx context.Context, query string, args ...interface{}) (bool, error) {
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// SQLMFAChallengeRepository is an MFAChallengeRepository backed by PostgreSQL or SQLite
+type SQLMFAChallengeRepository struct {
+	db *sql.DB
+}
+
+// NewSQLMFAChallengeRepository creates an MFA challenge repository using the given database connection
+func NewSQLMFAChallengeRepository(db *sql.DB) *SQLMFAChallengeRepository {
+	return &SQLMFAChallengeRepository{db: db}
+}
+
+// Create inserts a new challenge
+func (r *SQLMFAChallengeRepository) Create(ctx context.Context, challenge *models.MFAChallenge) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO mfa_challenges (`+mfaChallengeColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		challenge.ID, challenge.TokenHash, challenge.UserID, jsonList(challenge.AMR), challenge.Attempts,
+		challenge.CreatedAt, challenge.ExpiresAt, challenge.UsedAt,
+	)
+	if _, ok := uniqueViolation(err); ok {
+		return ErrConflict
+	}
+	return err
+}
+
+// GetByHash returns the challenge with the given token hash
+func (r *SQLMFAChallengeRepository) GetByHash(ctx context.Context, hash string) (*models.MFAChallenge, error) {
+	var challenge models.MFAChallenge
+	var amr string
+	err := r.db.QueryRowContext(ctx, `SELECT `+mfaChallengeColumns+` FROM mfa_challenges WHERE token_hash = $1`, hash).Scan(
+		&challenge.ID, &challenge.TokenHash, &challenge.UserID, &amr, &challenge.Attempts,
+		&challenge.CreatedAt, &challenge.ExpiresAt, &challenge.UsedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := scanJSONList(amr, &challenge.AMR); err != nil {
+		return nil, err
+	}
+	return &challenge, nil
+}
+
+// RecordFailure counts a failed verification attempt
+func (r *SQLMFAChallengeRepository) RecordFailure(ctx context.Context, id uuid.UUID) (int, error) {
+	var attempts int
+	err := r.db.QueryRowContext(ctx,
+		`UPDATE mfa_challenges SET attempts = attempts + 1 WHERE id = $1 RETURNING attempts`, id).Scan(&attempts)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrNotFound
+	}
+	return attempts, err
+}
+
+// MarkUsed atomically marks a challenge as completed
+func (r *SQLMFAChallengeRepository) MarkUsed(ctx context.Context, id uuid.UUID, at time.Time) (bool, error) {
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE mfa_challenges SET used_at = $2 WHERE id = $1 AND used_at IS NULL`, id, at)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// DeleteExpired removes challenges that expired before now
+func (r *SQLMFAChallengeRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM mfa_challenges WHERE expires_at <= $1`, now.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/goldcast/gc_auth_service/internal/models"
+	"github.com/google/uuid"
+)
+
+// PasskeyRepository persists users' WebAuthn credentials
+type PasskeyRepository interface {
+	// Create stores a new passkey. It returns ErrConflict if the credential
+	// is already registered.
+	Create(ctx context.Context, passkey *models.Passkey) error
+	GetByCredentialID(ctx context.Context, credentialID []byte) (*models.Passkey, error)
+	// ListByUser returns the user's passkeys, oldest first
+	ListByUser(ctx context.Context, userID uuid.UUID) ([]*models.Passkey, error)
+	CountByUser(ctx context.Context, userID uuid.UUID) (int, error)
+	// Rename renames one of the user's passkeys. It returns ErrNotFound if
+	// the user has no such passkey.
+	Rename(ctx context.Context, userID, id uuid.UUID, name string) error
+	// Delete removes one of the user's passkeys. It returns ErrNotFound if
+	// the user has no such passkey.
+	Delete(ctx context.Context, userID, id uuid.UUID) error
+	// RecordUse atomically stores the signature counter and backup state of
+	// a successful login. It reports false if the counter did not increase,
+	// which is a sign of a cloned authenticator; authenticators that do not
+	// count always report zero.
+	RecordUse(ctx context.Context, id uuid.UUID, signCount uint32, backupState bool, at time.Time) (bool, error)
+}
+
+// WebAuthnSessionRepository persists pending WebAuthn ceremonies
+type WebAuthnSessionRepository interface {
+	// Create stores a new session. It returns ErrConflict if the token is
+	// already taken.
+	Create(ctx context.Context, session *models.WebAuthnSession) error
+	GetByHash(ctx context.Context, hash string) (*models.WebAuthnSession, error)
+	// MarkUsed atomically marks a session as consumed. It reports false if
+	// it had already been consumed.
+	MarkUsed(ctx context.Context, id uuid.UUID, at time.Time) (bool, error)
+	// DeleteExpired removes sessions that expired before now
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+}
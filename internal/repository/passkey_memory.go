@@ -0,0 +1,207 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/goldcast/gc_auth_service/internal/models"
+	"github.com/google/uuid"
+)
+
+// MemoryPasskeyRepository is an in-memory PasskeyRepository, intended for tests and local development
+type MemoryPasskeyRepository struct {
+	mu             sync.Mutex
+	passkeys       map[uuid.UUID]models.Passkey
+	byCredentialID map[string]uuid.UUID
+}
+
+// NewMemoryPasskeyRepository creates an empty in-memory passkey repository
+func NewMemoryPasskeyRepository() *MemoryPasskeyRepository {
+	return &MemoryPasskeyRepository{
+		passkeys:       make(map[uuid.UUID]models.Passkey),
+		byCredentialID: make(map[string]uuid.UUID),
+	}
+}
+
+// Create stores a new passkey
+func (r *MemoryPasskeyRepository) Create(ctx context.Context, passkey *models.Passkey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.byCredentialID[string(passkey.CredentialID)]; ok {
+		return ErrConflict
+	}
+	r.passkeys[passkey.ID] = *passkey
+	r.byCredentialID[string(passkey.CredentialID)] = passkey.ID
+	return nil
+}
+
+// GetByCredentialID returns the passkey with the given WebAuthn credential ID
+func (r *MemoryPasskeyRepository) GetByCredentialID(ctx context.Context, credentialID []byte) (*models.Passkey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id, ok := r.byCredentialID[string(credentialID)]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	passkey := r.passkeys[id]
+	return &passkey, nil
+}
+
+// ListByUser returns the user's passkeys, oldest first
+func (r *MemoryPasskeyRepository) ListByUser(ctx context.Context, userID uuid.UUID) ([]*models.Passkey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	passkeys := make([]*models.Passkey, 0)
+	for _, passkey := range r.passkeys {
+		if passkey.UserID == userID {
+			passkeys = append(passkeys, &passkey)
+		}
+	}
+	sort.Slice(passkeys, func(i, j int) bool {
+		if !passkeys[i].CreatedAt.Equal(passkeys[j].CreatedAt) {
+			return passkeys[i].CreatedAt.Before(passkeys[j].CreatedAt)
+		}
+		return passkeys[i].ID.String() < passkeys[j].ID.String()
+	})
+	return passkeys, nil
+}
+
+// CountByUser returns the number of the user's passkeys
+func (r *MemoryPasskeyRepository) CountByUser(ctx context.Context, userID uuid.UUID) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var n int
+	for _, passkey := range r.passkeys {
+		if passkey.UserID == userID {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// Rename renames one of the user's passkeys
+func (r *MemoryPasskeyRepository) Rename(ctx context.Context, userID, id uuid.UUID, name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	passkey, ok := r.passkeys[id]
+	if !ok || passkey.UserID != userID {
+		return ErrNotFound
+	}
+	passkey.Name = name
+	r.passkeys[id] = passkey
+	return nil
+}
+
+// Delete removes one of the user's passkeys
+func (r *MemoryPasskeyRepository) Delete(ctx context.Context, userID, id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	passkey, ok := r.passkeys[id]
+	if !ok || passkey.UserID != userID {
+		return ErrNotFound
+	}
+	delete(r.passkeys, id)
+	delete(r.byCredentialID, string(passkey.CredentialID))
+	return nil
+}
+
+// RecordUse atomically stores the signature counter and backup state of a login
+func (r *MemoryPasskeyRepository) RecordUse(ctx context.Context, id uuid.UUID, signCount uint32, backupState bool, at time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	passkey, ok := r.passkeys[id]
+	if !ok {
+		return false, ErrNotFound
+	}
+	if signCount <= passkey.SignCount && (signCount != 0 || passkey.SignCount != 0) {
+		return false, nil
+	}
+	passkey.SignCount = signCount
+	passkey.BackupState = backupState
+	passkey.LastUsedAt = &at
+	r.passkeys[id] = passkey
+	return true, nil
+}
+
+// MemoryWebAuthnSessionRepository is an in-memory WebAuthnSessionRepository, intended for tests and local development
+type MemoryWebAuthnSessionRepository struct {
+	mu       sync.Mutex
+	sessions map[uuid.UUID]models.WebAuthnSession
+	byHash   map[string]uuid.UUID
+}
+
+// NewMemoryWebAuthnSessionRepository creates an empty in-memory WebAuthn session repository
+func NewMemoryWebAuthnSessionRepository() *MemoryWebAuthnSessionRepository {
+	return &MemoryWebAuthnSessionRepository{
+		sessions: make(map[uuid.UUID]models.WebAuthnSession),
+		byHash:   make(map[string]uuid.UUID),
+	}
+}
+
+// Create stores a new session
+func (r *MemoryWebAuthnSessionRepository) Create(ctx context.Context, session *models.WebAuthnSession) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.byHash[session.TokenHash]; ok {
+		return ErrConflict
+	}
+	r.sessions[session.ID] = *session
+	r.byHash[session.TokenHash] = session.ID
+	return nil
+}
+
+// GetByHash returns the session with the given token hash
+func (r *MemoryWebAuthnSessionRepository) GetByHash(ctx context.Context, hash string) (*models.WebAuthnSession, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id, ok := r.byHash[hash]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	session := r.sessions[id]
+	return &session, nil
+}
+
+// MarkUsed atomically marks a session as consumed
+func (r *MemoryWebAuthnSessionRepository) MarkUsed(ctx context.Context, id uuid.UUID, at time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	session, ok := r.sessions[id]
+	if !ok {
+		return false, ErrNotFound
+	}
+	if session.UsedAt != nil {
+		return false, nil
+	}
+	session.UsedAt = &at
+	r.sessions[id] = session
+	return true, nil
+}
+
+// DeleteExpired removes sessions that expired before now
+func (r *MemoryWebAuthnSessionRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var n int64
+	for id, session := range r.sessions {
+		if !session.ExpiresAt.After(now) {
+			delete(r.sessions, id)
+			delete(r.byHash, session.TokenHash)
+			n++
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,209 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"github.com/goldcast/gc_auth_service/internal/models"
+	"github.com/google/uuid"
+)
+
+const (
+	passkeyColumns         = `id, user_id, credential_id, public_key, attestation_type, aaguid, sign_count, transports, user_verified, backup_eligible, backup_state, name, created_at, last_used_at`
+	webAuthnSessionColumns = `id, token_hash, user_id, ceremony, data, created_at, expires_at, used_at`
+)
+
+// credentialEncoding encodes the binary credential IDs and public keys of
+// passkeys for storage in text columns
+var credentialEncoding = base64.RawURLEncoding
+
+// SQLPasskeyRepository is a PasskeyRepository backed by PostgreSQL or SQLite
+type SQLPasskeyRepository struct {
+	db *sql.DB
+}
+
+// NewSQLPasskeyRepository creates a passkey repository using the given database connection
+func NewSQLPasskeyRepository(db *sql.DB) *SQLPasskeyRepository {
+	return &SQLPasskeyRepository{db: db}
+}
+
+// Create inserts a new passkey
+func (r *SQLPasskeyRepository) Create(ctx context.Context, passkey *models.Passkey) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO passkeys (`+passkeyColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)`,
+		passkey.ID, passkey.UserID, credentialEncoding.EncodeToString(passkey.CredentialID),
+		credentialEncoding.EncodeToString(passkey.PublicKey), passkey.AttestationType, passkey.AAGUID,
+		int64(passkey.SignCount), jsonList(passkey.Transports), passkey.UserVerified,
+		passkey.BackupEligible, passkey.BackupState, passkey.Name, passkey.CreatedAt, passkey.LastUsedAt,
+	)
+	if _, ok := uniqueViolation(err); ok {
+		return ErrConflict
+	}
+	return err
+}
+
+// GetByCredentialID returns the passkey with the given WebAuthn credential ID
+func (r *SQLPasskeyRepository) GetByCredentialID(ctx context.Context, credentialID []byte) (*models.Passkey, error) {
+	passkey, err := scanPasskey(r.db.QueryRowContext(ctx,
+		`SELECT `+passkeyColumns+` FROM passkeys WHERE credential_id = $1`,
+		credentialEncoding.EncodeToString(credentialID)))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return passkey, err
+}
+
+// ListByUser returns the user's passkeys, oldest first
+func (r *SQLPasskeyRepository) ListByUser(ctx context.Context, userID uuid.UUID) ([]*models.Passkey, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+passkeyColumns+` FROM passkeys WHERE user_id = $1 ORDER BY created_at, id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	passkeys := []*models.Passkey{}
+	for rows.Next() {
+		passkey, err := scanPasskey(rows)
+		if err != nil {
+			return nil, err
+		}
+		passkeys = append(passkeys, passkey)
+	}
+	return passkeys, rows.Err()
+}
+
+// CountByUser returns the number of the user's passkeys
+func (r *SQLPasskeyRepository) CountByUser(ctx context.Context, userID uuid.UUID) (int, error) {
+	var n int
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM passkeys WHERE user_id = $1`, userID).Scan(&n)
+	return n, err
+}
+
+// Rename renames one of the user's passkeys
+func (r *SQLPasskeyRepository) Rename(ctx context.Context, userID, id uuid.UUID, name string) error {
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE passkeys SET name = $3 WHERE id = $1 AND user_id = $2`, id, userID, name)
+	if err != nil {
+		return err
+	}
+	return expectAffected(result)
+}
+
+// Delete removes one of the user's passkeys
+func (r *SQLPasskeyRepository) Delete(ctx context.Context, userID, id uuid.UUID) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM passkeys WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return err
+	}
+	return expectAffected(result)
+}
+
+// RecordUse atomically stores the signature counter and backup state of a login
+func (r *SQLPasskeyRepository) RecordUse(ctx context.Context, id uuid.UUID, signCount uint32, backupState bool, at time.Time) (bool, error) {
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE passkeys SET sign_count = $2, backup_state = $3, last_used_at = $4
+		WHERE id = $1 AND (sign_count < $2 OR (sign_count = 0 AND $2 = 0))`,
+		id, int64(signCount), backupState, at)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// scanPasskey reads a passkey row selected with passkeyColumns
+func scanPasskey(row rowScanner) (*models.Passkey, error) {
+	var passkey models.Passkey
+	var credentialID, publicKey, transports string
+	var signCount int64
+	err := row.Scan(
+		&passkey.ID, &passkey.UserID, &credentialID, &publicKey, &passkey.AttestationType, &passkey.AAGUID,
+		&signCount, &transports, &passkey.UserVerified, &passkey.BackupEligible, &passkey.BackupState,
+		&passkey.Name, &passkey.CreatedAt, &passkey.LastUsedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if passkey.CredentialID, err = credentialEncoding.DecodeString(credentialID); err != nil {
+		return nil, err
+	}
+	if passkey.PublicKey, err = credentialEncoding.DecodeString(publicKey); err != nil {
+		return nil, err
+	}
+	passkey.SignCount = uint32(signCount)
+	if err := scanJSONList(transports, &passkey.Transports); err != nil {
+		return nil, err
+	}
+	return &passkey, nil
+}
+
+// SQLWebAuthnSessionRepository is a WebAuthnSessionRepository backed by PostgreSQL or SQLite
+type SQLWebAuthnSessionRepository struct {
+	db *sql.DB
+}
+
+// NewSQLWebAuthnSessionRepository creates a WebAuthn session repository using the given database connection
+func NewSQLWebAuthnSessionRepository(db *sql.DB) *SQLWebAuthnSessionRepository {
+	return &SQLWebAuthnSessionRepository{db: db}
+}
+
+// Create inserts a new session
+func (r *SQLWebAuthnSessionRepository) Create(ctx context.Context, session *models.WebAuthnSession) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO webauthn_sessions (`+webAuthnSessionColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		session.ID, session.TokenHash, session.UserID, session.Ceremony, string(session.Data),
+		session.CreatedAt, session.ExpiresAt, session.UsedAt,
+	)
+	if _, ok := uniqueViolation(err); ok {
+		return ErrConflict
+	}
+	return err
+}
+
+// GetByHash returns the session with the given token hash
+func (r *SQLWebAuthnSessionRepository) GetByHash(ctx context.Context, hash string) (*models.WebAuthnSession, error) {
+	var session models.WebAuthnSession
+	var data string
+	err := r.db.QueryRowContext(ctx, `SELECT `+webAuthnSessionColumns+` FROM webauthn_sessions WHERE token_hash = $1`, hash).Scan(
+		&session.ID, &session.TokenHash, &session.UserID, &session.Ceremony, &data,
+		&session.CreatedAt, &session.ExpiresAt, &session.UsedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	session.Data = []byte(data)
+	return &session, nil
+}
+
+// MarkUsed atomically marks a session as consumed
+func (r *SQLWebAuthnSessionRepository) MarkUsed(ctx context.Context, id uuid.UUID, at time.Time) (bool, error) {
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE webauthn_sessions SET used_at = $2 WHERE id = $1 AND used_at IS NULL`, id, at)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// DeleteExpired removes sessions that expired before now
+func (r *SQLWebAuthnSessionRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM webauthn_sessions WHERE expires_at <= $1`, now.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
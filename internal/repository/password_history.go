@@ -0,0 +1,17 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/goldcast/gc_auth_service/internal/models"
+	"github.com/google/uuid"
+)
+
+// PasswordHistoryRepository persists the previous password hashes of users
+type PasswordHistoryRepository interface {
+	Add(ctx context.Context, entry *models.PasswordHistoryEntry) error
+	// ListRecent returns up to limit of the user's most recent entries, newest first
+	ListRecent(ctx context.Context, userID uuid.UUID, limit int) ([]models.PasswordHistoryEntry, error)
+	// Prune removes all but the user's keep most recent entries
+	Prune(ctx context.Context, userID uuid.UUID, keep int) error
+}
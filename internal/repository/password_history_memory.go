@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/goldcast/gc_auth_service/internal/models"
+	"github.com/google/uuid"
+)
+
+// MemoryPasswordHistoryRepository is an in-memory PasswordHistoryRepository, intended for tests and local development
+type MemoryPasswordHistoryRepository struct {
+	mu      sync.Mutex
+	entries map[uuid.UUID][]models.PasswordHistoryEntry // by user, oldest first
+}
+
+// NewMemoryPasswordHistoryRepository creates an empty in-memory password history repository
+func NewMemoryPasswordHistoryRepository() *MemoryPasswordHistoryRepository {
+	return &MemoryPasswordHistoryRepository{
+		entries: make(map[uuid.UUID][]models.PasswordHistoryEntry),
+	}
+}
+
+// Add stores a new entry
+func (r *MemoryPasswordHistoryRepository) Add(ctx context.Context, entry *models.PasswordHistoryEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entries := append(r.entries[entry.UserID], *entry)
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].CreatedAt.Before(entries[j].CreatedAt)
+	})
+	r.entries[entry.UserID] = entries
+	return nil
+}
+
+// ListRecent returns up to limit of the user's most recent entries, newest first
+func (r *MemoryPasswordHistoryRepository) ListRecent(ctx context.Context, userID uuid.UUID, limit int) ([]models.PasswordHistoryEntry, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entries := r.entries[userID]
+	recent := []models.PasswordHistoryEntry{}
+	for i := len(entries) - 1; i >= 0 && len(recent) < limit; i-- {
+		recent = append(recent, entries[i])
+	}
+	return recent, nil
+}
+
+// Prune removes all but the user's keep most recent entries
+func (r *MemoryPasswordHistoryRepository) Prune(ctx context.Context, userID uuid.UUID, keep int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entries := r.entries[userID]
+	if keep <= 0 {
+		delete(r.entries, userID)
+		return nil
+	}
+	if len(entries) > keep {
+		r.entries[userID] = append([]models.PasswordHistoryEntry(nil), entries[len(entries)-keep:]...)
+	}
+	return nil
+}
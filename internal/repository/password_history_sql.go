@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/goldcast/gc_auth_service/internal/models"
+	"github.com/google/uuid"
+)
+
+const passwordHistoryColumns = `id, user_id, password_hash, created_at`
+
+// SQLPasswordHistoryRepository is a PasswordHistoryRepository backed by PostgreSQL or SQLite
+type SQLPasswordHistoryRepository struct {
+	db *sql.DB
+}
+
+// NewSQLPasswordHistoryRepository creates a password history repository using the given database connection
+func NewSQLPasswordHistoryRepository(db *sql.DB) *SQLPasswordHistoryRepository {
+	return &SQLPasswordHistoryRepository{db: db}
+}
+
+// Add inserts a new entry
+func (r *SQLPasswordHistoryRepository) Add(ctx context.Context, entry *models.PasswordHistoryEntry) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO password_history (`+passwordHistoryColumns+`) VALUES ($1, $2, $3, $4)`,
+		entry.ID, entry.UserID, entry.PasswordHash, entry.CreatedAt,
+	)
+	return err
+}
+
+// ListRecent returns up to limit of the user's most recent entries, newest first
+func (r *SQLPasswordHistoryRepository) ListRecent(ctx context.Context, userID uuid.UUID, limit int) ([]models.PasswordHistoryEntry, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+passwordHistoryColumns+` FROM password_history WHERE user_id = $1 ORDER BY created_at DESC LIMIT $2`,
+		userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []models.PasswordHistoryEntry{}
+	for rows.Next() {
+		var entry models.PasswordHistoryEntry
+		if err := rows.Scan(&entry.ID, &entry.UserID, &entry.PasswordHash, &entry.CreatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// Prune removes all but the user's keep most recent entries
+func (r *SQLPasswordHistoryRepository) Prune(ctx context.Context, userID uuid.UUID, keep int) error {
+	_, err := r.db.ExecContext(ctx,
+		`DELETE FROM password_history WHERE user_id = $1 AND id NOT IN (
+			SELECT id FROM password_history WHERE user_id = $1 ORDER BY created_at DESC LIMIT $2
+		)`, userID, keep)
+	return err
+}
@@ -0,0 +1,24 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/goldcast/gc_auth_service/internal/models"
+	"github.com/google/uuid"
+)
+
+// PasswordResetRepository persists the password reset links sent to users
+type PasswordResetRepository interface {
+	// Create stores a new reset. It returns ErrConflict if the token is
+	// already taken.
+	Create(ctx context.Context, reset *models.PasswordReset) error
+	GetByHash(ctx context.Context, hash string) (*models.PasswordReset, error)
+	// MarkUsed atomically marks a reset as used. It reports false if it had
+	// already been used.
+	MarkUsed(ctx context.Context, id uuid.UUID, at time.Time) (bool, error)
+	// InvalidateUser marks all of the user's unused resets as used
+	InvalidateUser(ctx context.Context, userID uuid.UUID, at time.Time) error
+	// DeleteExpired removes resets that expired before now
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+}
@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/goldcast/gc_auth_service/internal/models"
+	"github.com/google/uuid"
+)
+
+// MemoryPasswordResetRepository is an in-memory PasswordResetRepository, intended for tests and local development
+type MemoryPasswordResetRepository struct {
+	mu     sync.Mutex
+	resets map[uuid.UUID]models.PasswordReset
+	byHash map[string]uuid.UUID
+}
+
+// NewMemoryPasswordResetRepository creates an empty in-memory password reset repository
+func NewMemoryPasswordResetRepository() *MemoryPasswordResetRepository {
+	return &MemoryPasswordResetRepository{
+		resets: make(map[uuid.UUID]models.PasswordReset),
+		byHash: make(map[string]uuid.UUID),
+	}
+}
+
+// Create stores a new reset
+func (r *MemoryPasswordResetRepository) Create(ctx context.Context, reset *models.PasswordReset) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.resets[reset.ID]; ok {
+		return ErrConflict
+	}
+	if _, ok := r.byHash[reset.TokenHash]; ok {
+		return ErrConflict
+	}
+	r.resets[reset.ID] = *reset
+	r.byHash[reset.TokenHash] = reset.ID
+	return nil
+}
+
+// GetByHash returns the reset with the given token hash
+func (r *MemoryPasswordResetRepository) GetByHash(ctx context.Context, hash string) (*models.PasswordReset, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id, ok := r.byHash[hash]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	reset := r.resets[id]
+	return &reset, nil
+}
+
+// MarkUsed atomically marks a reset as used
+func (r *MemoryPasswordResetRepository) MarkUsed(ctx context.Context, id uuid.UUID, at time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	reset, ok := r.resets[id]
+	if !ok {
+		return false, ErrNotFound
+	}
+	if reset.UsedAt != nil {
+		return false, nil
+	}
+	reset.UsedAt = &at
+	r.resets[id] = reset
+	return true, nil
+}
+
+// InvalidateUser marks all of the user's unused resets as used
+func (r *MemoryPasswordResetRepository) InvalidateUser(ctx context.Context, userID uuid.UUID, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, reset := range r.resets {
+		if reset.UserID == userID && reset.UsedAt == nil {
+			reset.UsedAt = &at
+			r.resets[id] = reset
+		}
+	}
+	return nil
+}
+
+// DeleteExpired removes resets that expired before now
+func (r *MemoryPasswordResetRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var n int64
+	for id, reset := range r.resets {
+		if !reset.ExpiresAt.After(now) {
+			delete(r.byHash, reset.TokenHash)
+			delete(r.resets, id)
+			n++
+		}
+	}
+	return n, nil
+}
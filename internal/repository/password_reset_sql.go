@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/goldcast/gc_auth_service/internal/models"
+	"github.com/google/uuid"
+)
+
+const passwordResetColumns = `id, token_hash, user_id, email, created_at, expires_at, used_at`
+
+// SQLPasswordResetRepository is a PasswordResetRepository backed by PostgreSQL or SQLite
+type SQLPasswordResetRepository struct {
+	db *sql.DB
+}
+
+// NewSQLPasswordResetRepository creates a password reset repository using the given database connection
+func NewSQLPasswordResetRepository(db *sql.DB) *SQLPasswordResetRepository {
+	return &SQLPasswordResetRepository{db: db}
+}
+
+// Create inserts a new reset
+func (r *SQLPasswordResetRepository) Create(ctx context.Context, reset *models.PasswordReset) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO password_resets (`+passwordResetColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		reset.ID, reset.TokenHash, reset.UserID, reset.Email, reset.CreatedAt, reset.ExpiresAt, reset.UsedAt,
+	)
+	if _, ok := uniqueViolation(err); ok {
+		return ErrConflict
+	}
+	return err
+}
+
+// GetByHash returns the reset with the given token hash
+func (r *SQLPasswordResetRepository) GetByHash(ctx context.Context, hash string) (*models.PasswordReset, error) {
+	var reset models.PasswordReset
+	err := r.db.QueryRowContext(ctx, `SELECT `+passwordResetColumns+` FROM password_resets WHERE token_hash = $1`, hash).Scan(
+		&reset.ID, &reset.TokenHash, &reset.UserID, &reset.Email, &reset.CreatedAt, &reset.ExpiresAt, &reset.UsedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &reset, nil
+}
+
+// MarkUsed atomically marks a reset as used
+func (r *SQLPasswordResetRepository) MarkUsed(ctx context.Context, id uuid.UUID, at time.Time) (bool, error) {
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE password_resets SET used_at = $2 WHERE id = $1 AND used_at IS NULL`, id, at)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// InvalidateUser marks all of the user's unused resets as used
+func (r *SQLPasswordResetRepository) InvalidateUser(ctx context.Context, userID uuid.UUID, at time.Time) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE password_resets SET used_at = $2 WHERE user_id = $1 AND used_at IS NULL`, userID, at)
+	return err
+}
+
+// DeleteExpired removes resets that expired before now
+func (r *SQLPasswordResetRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM password_resets WHERE expires_at <= $1`, now.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
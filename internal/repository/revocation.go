@@ -0,0 +1,25 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RevocationRepository tracks revoked access tokens until they expire on their own
+type RevocationRepository interface {
+	// RevokeToken adds a token ID to the denylist until expiresAt
+	RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error
+	IsTokenRevoked(ctx context.Context, jti string, now time.Time) (bool, error)
+	// ClaimToken atomically adds a token ID to the denylist until expiresAt,
+	// unless it is already on it. It reports whether the ID was added, so
+	// that a single-use token is only accepted once.
+	ClaimToken(ctx context.Context, jti string, expiresAt, now time.Time) (bool, error)
+	// RevokeUserTokens invalidates every token issued to the user up to the given time
+	RevokeUserTokens(ctx context.Context, userID uuid.UUID, before time.Time) error
+	// UserTokensRevokedBefore returns the user's cutoff, or the zero time if there is none
+	UserTokensRevokedBefore(ctx context.Context, userID uuid.UUID) (time.Time, error)
+	// PurgeExpired removes denylist entries for tokens that have expired
+	PurgeExpired(ctx context.Context, now time.Time) (int64, error)
+}
@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MemoryRevocationRepository is an in-memory RevocationRepository, intended for tests and local development
+type MemoryRevocationRepository struct {
+	mu      sync.RWMutex
+	tokens  map[string]time.Time
+	cutoffs map[uuid.UUID]time.Time
+}
+
+// NewMemoryRevocationRepository creates an empty in-memory revocation repository
+func NewMemoryRevocationRepository() *MemoryRevocationRepository {
+	return &MemoryRevocationRepository{
+		tokens:  make(map[string]time.Time),
+		cutoffs: make(map[uuid.UUID]time.Time),
+	}
+}
+
+// RevokeToken adds a token ID to the denylist until expiresAt
+func (r *MemoryRevocationRepository) RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.tokens[jti] = expiresAt
+	return nil
+}
+
+// IsTokenRevoked reports whether the token ID is on the denylist
+func (r *MemoryRevocationRepository) IsTokenRevoked(ctx context.Context, jti string, now time.Time) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	expiresAt, ok := r.tokens[jti]
+	return ok && now.Before(expiresAt), nil
+}
+
+// ClaimToken atomically adds a token ID to the denylist until expiresAt,
+// unless it is already on it, and reports whether it was added
+func (r *MemoryRevocationRepository) ClaimToken(ctx context.Context, jti string, expiresAt, now time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if existing, ok := r.tokens[jti]; ok && now.Before(existing) {
+		return false, nil
+	}
+	r.tokens[jti] = expiresAt
+	return true, nil
+}
+
+// RevokeUserTokens invalidates every token issued to the user up to the given time
+func (r *MemoryRevocationRepository) RevokeUserTokens(ctx context.Context, userID uuid.UUID, before time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.cutoffs[userID] = before
+	return nil
+}
+
+// UserTokensRevokedBefore returns the user's cutoff, or the zero time if there is none
+func (r *MemoryRevocationRepository) UserTokensRevokedBefore(ctx context.Context, userID uuid.UUID) (time.Time, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.cutoffs[userID], nil
+}
+
+// PurgeExpired removes denylist entries for tokens that have expired
+func (r *MemoryRevocationRepository) PurgeExpired(ctx context.Context, now time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var purged int64
+	for jti, expiresAt := range r.tokens {
+		if !now.Before(expiresAt) {
+			delete(r.tokens, jti)
+			purged++
+		}
+	}
+	return purged, nil
+}
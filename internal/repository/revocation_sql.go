@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SQLRevocationRepository is a RevocationRepository backed by PostgreSQL or SQLite
+type SQLRevocationRepository struct {
+	db *sql.DB
+}
+
+// NewSQLRevocationRepository creates a revocation repository using the given database connection
+func NewSQLRevocationRepository(db *sql.DB) *SQLRevocationRepository {
+	return &SQLRevocationRepository{db: db}
+}
+
+// RevokeToken adds a token ID to the denylist until expiresAt
+func (r *SQLRevocationRepository) RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO revoked_tokens (jti, expires_at) VALUES ($1, $2)
+		ON CONFLICT (jti) DO UPDATE SET expires_at = excluded.expires_at`,
+		jti, expiresAt.UTC(),
+	)
+	return err
+}
+
+// IsTokenRevoked reports whether the token ID is on the denylist
+func (r *SQLRevocationRepository) IsTokenRevoked(ctx context.Context, jti string, now time.Time) (bool, error) {
+	var exists int
+	err := r.db.QueryRowContext(ctx,
+		`SELECT 1 FROM revoked_tokens WHERE jti = $1 AND expires_at > $2`, jti, now.UTC(),
+	).Scan(&exists)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// ClaimToken atomically adds a token ID to the denylist until expiresAt,
+// unless it is already on it, and reports whether it was added. An entry
+// that expired but was not purged yet is replaced.
+func (r *SQLRevocationRepository) ClaimToken(ctx context.Context, jti string, expiresAt, now time.Time) (bool, error) {
+	result, err := r.db.ExecContext(ctx,
+		`INSERT INTO revoked_tokens (jti, expires_at) VALUES ($1, $2)
+		ON CONFLICT (jti) DO UPDATE SET expires_at = excluded.expires_at
+		WHERE revoked_tokens.expires_at <= $3`,
+		jti, expiresAt.UTC(), now.UTC(),
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n == 1, err
+}
+
+// RevokeUserTokens invalidates every token issued to the user up to the given time
+func (r *SQLRevocationRepository) RevokeUserTokens(ctx context.Context, userID uuid.UUID, before time.Time) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO user_token_revocations (user_id, revoked_before) VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET revoked_before = excluded.revoked_before`,
+		userID, before.UTC(),
+	)
+	return err
+}
+
+// UserTokensRevokedBefore returns the user's cutoff, or the zero time if there is none
+func (r *SQLRevocationRepository) UserTokensRevokedBefore(ctx context.Context, userID uuid.UUID) (time.Time, error) {
+	var before time.Time
+	err := r.db.QueryRowContext(ctx,
+		`SELECT revoked_before FROM user_token_revocations WHERE user_id = $1`, userID,
+	).Scan(&before)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, nil
+	}
+	return before, err
+}
+
+// PurgeExpired removes denylist entries for tokens that have expired
+func (r *SQLRevocationRepository) PurgeExpired(ctx context.Context, now time.Time) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM revoked_tokens WHERE expires_at <= $1`, now.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,151 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// revocationRepositories returns each implementation of RevocationRepository,
+// along with a user whose tokens can be revoked
+func revocationRepositories(t *testing.T) (map[string]RevocationRepository, uuid.UUID) {
+	t.Helper()
+	db := openSQLite(t)
+	return map[string]RevocationRepository{
+		"memory": NewMemoryRevocationRepository(),
+		"sql":    NewSQLRevocationRepository(db),
+	}, createUser(t, db, "alice")
+}
+
+func TestRevokeToken(t *testing.T) {
+	repos, _ := revocationRepositories(t)
+	for name, repo := range repos {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			now := time.Now()
+
+			if err := repo.RevokeToken(ctx, "jti-short", now.Add(time.Minute)); err != nil {
+				t.Fatalf("revoke: %v", err)
+			}
+			if err := repo.RevokeToken(ctx, "jti-long", now.Add(time.Hour)); err != nil {
+				t.Fatalf("revoke: %v", err)
+			}
+			for _, tt := range []struct {
+				jti  string
+				at   time.Time
+				want bool
+			}{
+				{"jti-short", now, true},
+				{"jti-long", now, true},
+				{"jti-other", now, false},
+				// A token no longer needs to be on the denylist once it expired
+				{"jti-short", now.Add(2 * time.Minute), false},
+			} {
+				if revoked, err := repo.IsTokenRevoked(ctx, tt.jti, tt.at); err != nil || revoked != tt.want {
+					t.Errorf("%s revoked at %s: %v, %v; want %v", tt.jti, tt.at.Sub(now), revoked, err, tt.want)
+				}
+			}
+
+			purged, err := repo.PurgeExpired(ctx, now.Add(2*time.Minute))
+			if err != nil || purged != 1 {
+				t.Errorf("purged %d, %v; want 1", purged, err)
+			}
+			if revoked, err := repo.IsTokenRevoked(ctx, "jti-long", now); err != nil || !revoked {
+				t.Errorf("unexpired token revoked after purge: %v, %v; want true", revoked, err)
+			}
+		})
+	}
+}
+
+func TestRevokeUserTokens(t *testing.T) {
+	repos, userID := revocationRepositories(t)
+	for name, repo := range repos {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			if cutoff, err := repo.UserTokensRevokedBefore(ctx, userID); err != nil || !cutoff.IsZero() {
+				t.Fatalf("cutoff before logging out: %v, %v; want none", cutoff, err)
+			}
+
+			first := time.Now().UTC().Truncate(time.Second)
+			for _, before := range []time.Time{first, first.Add(time.Hour)} {
+				if err := repo.RevokeUserTokens(ctx, userID, before); err != nil {
+					t.Fatalf("revoke user tokens: %v", err)
+				}
+				cutoff, err := repo.UserTokensRevokedBefore(ctx, userID)
+				if err != nil || !cutoff.Equal(before) {
+					t.Errorf("cutoff %v, %v; want %v", cutoff, err, before)
+				}
+			}
+			if cutoff, err := repo.UserTokensRevokedBefore(ctx, uuid.New()); err != nil || !cutoff.IsZero() {
+				t.Errorf("cutoff of another user: %v, %v; want none", cutoff, err)
+			}
+		})
+	}
+}
+
+func TestClaimToken(t *testing.T) {
+	repos, _ := revocationRepositories(t)
+	for name, repo := range repos {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			now := time.Now()
+
+			claimed, err := repo.ClaimToken(ctx, "jti-1", now.Add(time.Minute), now)
+			if err != nil || !claimed {
+				t.Fatalf("first claim: %v, %v; want true", claimed, err)
+			}
+			if revoked, err := repo.IsTokenRevoked(ctx, "jti-1", now); err != nil || !revoked {
+				t.Errorf("claimed token revoked: %v, %v; want true", revoked, err)
+			}
+			claimed, err = repo.ClaimToken(ctx, "jti-1", now.Add(time.Minute), now)
+			if err != nil || claimed {
+				t.Errorf("second claim: %v, %v; want false", claimed, err)
+			}
+
+			// An entry that expired but was not purged yet can be claimed again
+			later := now.Add(2 * time.Minute)
+			claimed, err = repo.ClaimToken(ctx, "jti-1", later.Add(time.Minute), later)
+			if err != nil || !claimed {
+				t.Errorf("claim after expiry: %v, %v; want true", claimed, err)
+			}
+		})
+	}
+}
+
+func TestClaimTokenConcurrent(t *testing.T) {
+	const requests = 20
+	repos, _ := revocationRepositories(t)
+	for name, repo := range repos {
+		t.Run(name, func(t *testing.T) {
+			now := time.Now()
+			var wg sync.WaitGroup
+			results := make(chan bool, requests)
+			for i := 0; i < requests; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					claimed, err := repo.ClaimToken(context.Background(), "jti-concurrent", now.Add(time.Minute), now)
+					if err != nil {
+						t.Errorf("claim: %v", err)
+					}
+					results <- claimed
+				}()
+			}
+			wg.Wait()
+			close(results)
+
+			accepted := 0
+			for claimed := range results {
+				if claimed {
+					accepted++
+				}
+			}
+			if accepted != 1 {
+				t.Errorf("%d of %d concurrent claims accepted, want 1", accepted, requests)
+			}
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/goldcast/gc_auth_service/internal/models"
+	"github.com/google/uuid"
+)
+
+// SessionRepository persists login sessions and the refresh tokens rotated within them
+type SessionRepository interface {
+	CreateSession(ctx context.Context, session *models.Session) error
+	GetSession(ctx context.Context, id uuid.UUID) (*models.Session, error)
+	// TouchSession records that the session was used and extends its expiry
+	TouchSession(ctx context.Context, id uuid.UUID, usedAt, expiresAt time.Time) error
+	// RevokeSession revokes a session together with all of its refresh tokens
+	RevokeSession(ctx context.Context, id uuid.UUID, at time.Time) error
+	// RevokeUserSessions revokes every session of a user together with their
+	// refresh tokens, except the session keep unless it is uuid.Nil
+	RevokeUserSessions(ctx context.Context, userID, keep uuid.UUID, at time.Time) error
+
+	CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error
+	GetRefreshTokenByHash(ctx context.Context, hash string) (*models.RefreshToken, error)
+	// MarkRefreshTokenUsed atomically marks an unused token as used. It reports
+	// false if the token had already been used, which indicates a replay.
+	MarkRefreshTokenUsed(ctx context.Context, id uuid.UUID, at time.Time) (bool, error)
+}
@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/goldcast/gc_auth_service/internal/models"
+	"github.com/google/uuid"
+)
+
+// MemorySessionRepository is an in-memory SessionRepository, intended for tests and local development
+type MemorySessionRepository struct {
+	mu       sync.RWMutex
+	sessions map[uuid.UUID]models.Session
+	tokens   map[uuid.UUID]models.RefreshToken
+	byHash   map[string]uuid.UUID
+}
+
+// NewMemorySessionRepository creates an empty in-memory session repository
+func NewMemorySessionRepository() *MemorySessionRepository {
+	return &MemorySessionRepository{
+		sessions: make(map[uuid.UUID]models.Session),
+		tokens:   make(map[uuid.UUID]models.RefreshToken),
+		byHash:   make(map[string]uuid.UUID),
+	}
+}
+
+// CreateSession stores a new session
+func (r *MemorySessionRepository) CreateSession(ctx context.Context, session *models.Session) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.sessions[session.ID] = *session
+	return nil
+}
+
+// GetSession returns the session with the given ID
+func (r *MemorySessionRepository) GetSession(ctx context.Context, id uuid.UUID) (*models.Session, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	session, ok := r.sessions[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &session, nil
+}
+
+// TouchSession records that the session was used and extends its expiry
+func (r *MemorySessionRepository) TouchSession(ctx context.Context, id uuid.UUID, usedAt, expiresAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	session, ok := r.sessions[id]
+	if !ok {
+		return ErrNotFound
+	}
+	session.LastUsedAt = usedAt
+	session.ExpiresAt = expiresAt
+	r.sessions[id] = session
+	return nil
+}
+
+// RevokeSession revokes a session together with all of its refresh tokens
+func (r *MemorySessionRepository) RevokeSession(ctx context.Context, id uuid.UUID, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	session, ok := r.sessions[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if session.RevokedAt == nil {
+		session.RevokedAt = &at
+		r.sessions[id] = session
+	}
+
+	for tokenID, token := range r.tokens {
+		if token.SessionID == id && token.RevokedAt == nil {
+			token.RevokedAt = &at
+			r.tokens[tokenID] = token
+		}
+	}
+	return nil
+}
+
+// RevokeUserSessions revokes every session of a user but keep together with their refresh tokens
+func (r *MemorySessionRepository) RevokeUserSessions(ctx context.Context, userID, keep uuid.UUID, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, session := range r.sessions {
+		if session.UserID == userID && id != keep && session.RevokedAt == nil {
+			session.RevokedAt = &at
+			r.sessions[id] = session
+		}
+	}
+	for id, token := range r.tokens {
+		if token.UserID == userID && token.SessionID != keep && token.RevokedAt == nil {
+			token.RevokedAt = &at
+			r.tokens[id] = token
+		}
+	}
+	return nil
+}
+
+// CreateRefreshToken stores a new refresh token
+func (r *MemorySessionRepository) CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.tokens[token.ID] = *token
+	r.byHash[token.TokenHash] = token.ID
+	return nil
+}
+
+// GetRefreshTokenByHash returns the refresh token with the given hash
+func (r *MemorySessionRepository) GetRefreshTokenByHash(ctx context.Context, hash string) (*models.RefreshToken, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	id, ok := r.byHash[hash]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	token := r.tokens[id]
+	return &token, nil
+}
+
+// MarkRefreshTokenUsed atomically marks an unused token as used
+func (r *MemorySessionRepository) MarkRefreshTokenUsed(ctx context.Context, id uuid.UUID, at time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	token, ok := r.tokens[id]
+	if !ok {
+		return false, ErrNotFound
+	}
+	if token.UsedAt != nil {
+		return false, nil
+	}
+	token.UsedAt = &at
+	r.tokens[id] = token
+	return true, nil
+}
@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/goldcast/gc_auth_service/internal/models"
+	"github.com/google/uuid"
+)
+
+const (
+	sessionColumns      = `id, user_id, client_id, scope, auth_time, amr, user_agent, ip_address, created_at, last_used_at, expires_at, revoked_at`
+	refreshTokenColumns = `id, session_id, user_id, token_hash, created_at, expires_at, used_at, revoked_at`
+)
+
+// SQLSessionRepository is a SessionRepository backed by PostgreSQL or SQLite
+type SQLSessionRepository struct {
+	db *sql.DB
+}
+
+// NewSQLSessionRepository creates a session repository using the given database connection
+func NewSQLSessionRepository(db *sql.DB) *SQLSessionRepository {
+	return &SQLSessionRepository{db: db}
+}
+
+// CreateSession inserts a new session
+func (r *SQLSessionRepository) CreateSession(ctx context.Context, session *models.Session) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO sessions (`+sessionColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
+		session.ID, session.UserID, session.ClientID, session.Scope, session.AuthTime, jsonList(session.AMR),
+		session.UserAgent, session.IPAddress,
+		session.CreatedAt, session.LastUsedAt, session.ExpiresAt, session.RevokedAt,
+	)
+	return err
+}
+
+// GetSession returns the session with the given ID
+func (r *SQLSessionRepository) GetSession(ctx context.Context, id uuid.UUID) (*models.Session, error) {
+	var session models.Session
+	var amr string
+	err := r.db.QueryRowContext(ctx, `SELECT `+sessionColumns+` FROM sessions WHERE id = $1`, id).Scan(
+		&session.ID, &session.UserID, &session.ClientID, &session.Scope, &session.AuthTime, &amr,
+		&session.UserAgent, &session.IPAddress,
+		&session.CreatedAt, &session.LastUsedAt, &session.ExpiresAt, &session.RevokedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := scanJSONList(amr, &session.AMR); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// TouchSession records that the session was used and extends its expiry
+func (r *SQLSessionRepository) TouchSession(ctx context.Context, id uuid.UUID, usedAt, expiresAt time.Time) error {
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE sessions SET last_used_at = $2, expires_at = $3 WHERE id = $1`,
+		id, usedAt, expiresAt,
+	)
+	if err != nil {
+		return err
+	}
+	return expectAffected(result)
+}
+
+// RevokeSession revokes a session together with all of its refresh tokens
+func (r *SQLSessionRepository) RevokeSession(ctx context.Context, id uuid.UUID, at time.Time) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx,
+		`UPDATE sessions SET revoked_at = COALESCE(revoked_at, $2) WHERE id = $1`, id, at)
+	if err != nil {
+		return err
+	}
+	if err := expectAffected(result); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE refresh_tokens SET revoked_at = $2 WHERE session_id = $1 AND revoked_at IS NULL`, id, at); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RevokeUserSessions revokes every session of a user but keep together with their refresh tokens
+func (r *SQLSessionRepository) RevokeUserSessions(ctx context.Context, userID, keep uuid.UUID, at time.Time) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE sessions SET revoked_at = $2 WHERE user_id = $1 AND id <> $3 AND revoked_at IS NULL`, userID, at, keep); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE refresh_tokens SET revoked_at = $2 WHERE user_id = $1 AND session_id <> $3 AND revoked_at IS NULL`, userID, at, keep); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// CreateRefreshToken inserts a new refresh token
+func (r *SQLSessionRepository) CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO refresh_tokens (`+refreshTokenColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		token.ID, token.SessionID, token.UserID, token.TokenHash,
+		token.CreatedAt, token.ExpiresAt, token.UsedAt, token.RevokedAt,
+	)
+	return err
+}
+
+// GetRefreshTokenByHash returns the refresh token with the given hash
+func (r *SQLSessionRepository) GetRefreshTokenByHash(ctx context.Context, hash string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	err := r.db.QueryRowContext(ctx, `SELECT `+refreshTokenColumns+` FROM refresh_tokens WHERE token_hash = $1`, hash).Scan(
+		&token.ID, &token.SessionID, &token.UserID, &token.TokenHash,
+		&token.CreatedAt, &token.ExpiresAt, &token.UsedAt, &token.RevokedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// MarkRefreshTokenUsed atomically marks an unused token as used
+func (r *SQLSessionRepository) MarkRefreshTokenUsed(ctx context.Context, id uuid.UUID, at time.Time) (bool, error) {
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE refresh_tokens SET used_at = $2 WHERE id = $1 AND used_at IS NULL`, id, at)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
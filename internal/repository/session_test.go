@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/goldcast/gc_auth_service/internal/models"
+	"github.com/google/uuid"
+)
+
+// sessionRepositories returns each implementation of SessionRepository,
+// along with a user sessions can be created for
+func sessionRepositories(t *testing.T) (map[string]SessionRepository, uuid.UUID) {
+	t.Helper()
+	db := openSQLite(t)
+	return map[string]SessionRepository{
+		"memory": NewMemorySessionRepository(),
+		"sql":    NewSQLSessionRepository(db),
+	}, createUser(t, db, "alice")
+}
+
+// createSession adds a session with one refresh token to the repository
+func createSession(t *testing.T, repo SessionRepository, userID uuid.UUID, tokenHash string) (*models.Session, *models.RefreshToken) {
+	t.Helper()
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+	session := &models.Session{
+		ID: uuid.New(), UserID: userID, AuthTime: now, AMR: []string{"pwd"},
+		CreatedAt: now, LastUsedAt: now, ExpiresAt: now.Add(time.Hour),
+	}
+	if err := repo.CreateSession(ctx, session); err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+	token := &models.RefreshToken{
+		ID: uuid.New(), SessionID: session.ID, UserID: userID, TokenHash: tokenHash,
+		CreatedAt: now, ExpiresAt: now.Add(time.Hour),
+	}
+	if err := repo.CreateRefreshToken(ctx, token); err != nil {
+		t.Fatalf("create refresh token: %v", err)
+	}
+	return session, token
+}
+
+func TestMarkRefreshTokenUsed(t *testing.T) {
+	repos, userID := sessionRepositories(t)
+	for name, repo := range repos {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			_, token := createSession(t, repo, userID, "hash-"+name)
+
+			now := time.Now().UTC()
+			if used, err := repo.MarkRefreshTokenUsed(ctx, token.ID, now); err != nil || !used {
+				t.Fatalf("first use: %v, %v; want true", used, err)
+			}
+			if used, err := repo.MarkRefreshTokenUsed(ctx, token.ID, now); err != nil || used {
+				t.Errorf("second use: %v, %v; want false", used, err)
+			}
+			stored, err := repo.GetRefreshTokenByHash(ctx, "hash-"+name)
+			if err != nil {
+				t.Fatalf("get refresh token: %v", err)
+			}
+			if stored.UsedAt == nil {
+				t.Error("used token has no used_at")
+			}
+			if _, err := repo.GetRefreshTokenByHash(ctx, "unknown"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("get unknown token: %v, want %v", err, ErrNotFound)
+			}
+		})
+	}
+}
+
+func TestMarkRefreshTokenUsedConcurrent(t *testing.T) {
+	const requests = 20
+	repos, userID := sessionRepositories(t)
+	for name, repo := range repos {
+		t.Run(name, func(t *testing.T) {
+			_, token := createSession(t, repo, userID, "concurrent-"+name)
+			now := time.Now().UTC()
+
+			var wg sync.WaitGroup
+			results := make(chan bool, requests)
+			for i := 0; i < requests; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					used, err := repo.MarkRefreshTokenUsed(context.Background(), token.ID, now)
+					if err != nil {
+						t.Errorf("mark used: %v", err)
+					}
+					results <- used
+				}()
+			}
+			wg.Wait()
+			close(results)
+
+			accepted := 0
+			for used := range results {
+				if used {
+					accepted++
+				}
+			}
+			if accepted != 1 {
+				t.Errorf("%d of %d concurrent uses accepted, want 1", accepted, requests)
+			}
+		})
+	}
+}
+
+func TestRevokeSessionRevokesRefreshTokens(t *testing.T) {
+	repos, userID := sessionRepositories(t)
+	for name, repo := range repos {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			session, _ := createSession(t, repo, userID, "revoke-"+name)
+			kept, _ := createSession(t, repo, userID, "keep-"+name)
+			other, _ := createSession(t, repo, userID, "other-"+name)
+
+			now := time.Now().UTC()
+			if err := repo.RevokeSession(ctx, session.ID, now); err != nil {
+				t.Fatalf("revoke session: %v", err)
+			}
+			if err := repo.RevokeUserSessions(ctx, userID, kept.ID, now); err != nil {
+				t.Fatalf("revoke user sessions: %v", err)
+			}
+
+			for hash, wantRevoked := range map[string]bool{"revoke-" + name: true, "keep-" + name: false, "other-" + name: true} {
+				token, err := repo.GetRefreshTokenByHash(ctx, hash)
+				if err != nil {
+					t.Fatalf("get refresh token %s: %v", hash, err)
+				}
+				if revoked := token.RevokedAt != nil; revoked != wantRevoked {
+					t.Errorf("refresh token %s revoked = %v, want %v", hash, revoked, wantRevoked)
+				}
+			}
+			stored, err := repo.GetSession(ctx, other.ID)
+			if err != nil || stored.RevokedAt == nil {
+				t.Errorf("other session after revoking the user's sessions: %+v, %v; want revoked", stored, err)
+			}
+		})
+	}
+}
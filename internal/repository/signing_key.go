@@ -0,0 +1,24 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/goldcast/gc_auth_service/internal/models"
+)
+
+// ErrConflict is returned when a write lost a race against a concurrent change
+var ErrConflict = errors.New("conflicting concurrent update")
+
+// SigningKeyRepository persists managed JWT signing keys
+type SigningKeyRepository interface {
+	// List returns all keys that have not expired, oldest first
+	List(ctx context.Context, now time.Time) ([]models.SigningKey, error)
+	// Rotate retires the key currently active under previousKID (if any) and
+	// activates next in one step. It returns ErrConflict if previousKID is no
+	// longer the active key, i.e. another instance rotated first.
+	Rotate(ctx context.Context, next *models.SigningKey, previousKID string, retiredAt, expiresAt time.Time) error
+	// DeleteExpired removes retired keys past their expiry
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+}
@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/goldcast/gc_auth_service/internal/models"
+)
+
+// MemorySigningKeyRepository is an in-memory SigningKeyRepository, intended for tests and local development
+type MemorySigningKeyRepository struct {
+	mu   sync.Mutex
+	keys map[string]models.SigningKey
+}
+
+// NewMemorySigningKeyRepository creates an empty in-memory signing key repository
+func NewMemorySigningKeyRepository() *MemorySigningKeyRepository {
+	return &MemorySigningKeyRepository{
+		keys: make(map[string]models.SigningKey),
+	}
+}
+
+// List returns all keys that have not expired, oldest first
+func (r *MemorySigningKeyRepository) List(ctx context.Context, now time.Time) ([]models.SigningKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	keys := make([]models.SigningKey, 0, len(r.keys))
+	for _, key := range r.keys {
+		if key.ExpiresAt == nil || now.Before(*key.ExpiresAt) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+	})
+	return keys, nil
+}
+
+// Rotate retires the active key and activates next
+func (r *MemorySigningKeyRepository) Rotate(ctx context.Context, next *models.SigningKey, previousKID string, retiredAt, expiresAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var active *models.SigningKey
+	for _, key := range r.keys {
+		if key.Status == models.SigningKeyActive {
+			key := key
+			active = &key
+		}
+	}
+
+	switch {
+	case active == nil && previousKID != "":
+		return ErrConflict
+	case active != nil && active.ID != previousKID:
+		return ErrConflict
+	case active != nil:
+		active.Status = models.SigningKeyRetired
+		active.RetiredAt = &retiredAt
+		active.ExpiresAt = &expiresAt
+		r.keys[active.ID] = *active
+	}
+
+	r.keys[next.ID] = *next
+	return nil
+}
+
+// DeleteExpired removes retired keys past their expiry
+func (r *MemorySigningKeyRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var deleted int64
+	for kid, key := range r.keys {
+		if key.ExpiresAt != nil && !now.Before(*key.ExpiresAt) {
+			delete(r.keys, kid)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/goldcast/gc_auth_service/internal/models"
+)
+
+const signingKeyColumns = `kid, algorithm, private_key, status, created_at, retired_at, expires_at`
+
+// SQLSigningKeyRepository is a SigningKeyRepository backed by PostgreSQL or SQLite
+type SQLSigningKeyRepository struct {
+	db *sql.DB
+}
+
+// NewSQLSigningKeyRepository creates a signing key repository using the given database connection
+func NewSQLSigningKeyRepository(db *sql.DB) *SQLSigningKeyRepository {
+	return &SQLSigningKeyRepository{db: db}
+}
+
+// List returns all keys that have not expired, oldest first
+func (r *SQLSigningKeyRepository) List(ctx context.Context, now time.Time) ([]models.SigningKey, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+signingKeyColumns+` FROM signing_keys
+		WHERE expires_at IS NULL OR expires_at > $1
+		ORDER BY created_at`, now.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []models.SigningKey
+	for rows.Next() {
+		var key models.SigningKey
+		if err := rows.Scan(&key.ID, &key.Algorithm, &key.PrivateKey, &key.Status,
+			&key.CreatedAt, &key.RetiredAt, &key.ExpiresAt); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// Rotate retires the active key and activates next
+func (r *SQLSigningKeyRepository) Rotate(ctx context.Context, next *models.SigningKey, previousKID string, retiredAt, expiresAt time.Time) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if previousKID != "" {
+		result, err := tx.ExecContext(ctx,
+			`UPDATE signing_keys SET status = $2, retired_at = $3, expires_at = $4
+			WHERE kid = $1 AND status = $5`,
+			previousKID, models.SigningKeyRetired, retiredAt.UTC(), expiresAt.UTC(), models.SigningKeyActive,
+		)
+		if err != nil {
+			return err
+		}
+		if n, err := result.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			return ErrConflict
+		}
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO signing_keys (`+signingKeyColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		next.ID, next.Algorithm, next.PrivateKey, next.Status, next.CreatedAt, next.RetiredAt, next.ExpiresAt,
+	)
+	if _, ok := uniqueViolation(err); ok {
+		// Another instance activated a key first
+		return ErrConflict
+	}
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DeleteExpired removes retired keys past their expiry
+func (r *SQLSigningKeyRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	result, err := r.db.ExecContext(ctx,
+		`DELETE FROM signing_keys WHERE expires_at IS NOT NULL AND expires_at <= $1`, now.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"github.com/lib/pq"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// uniqueViolation reports whether err is a unique constraint violation and,
+// if so, the name of the violated constraint
+func uniqueViolation(err error) (string, bool) {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return pqErr.Constraint, true
+	}
+
+	// SQLite reports the violated column or index in the message, e.g.
+	// "UNIQUE constraint failed: users.username", and uses a separate code
+	// for primary keys
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) && (sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE || sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY) {
+		return sqliteErr.Error(), true
+	}
+	return "", false
+}
+
+// expectAffected returns ErrNotFound when a statement matched no rows
+func expectAffected(result sql.Result) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// jsonList encodes a string list for storage in a JSON column
+func jsonList(values []string) string {
+	if values == nil {
+		values = []string{}
+	}
+	data, _ := json.Marshal(values)
+	return string(data)
+}
+
+// scanJSONList decodes a string list stored in a JSON column
+func scanJSONList(data string, dest *[]string) error {
+	if data == "" {
+		*dest = nil
+		return nil
+	}
+	return json.Unmarshal([]byte(data), dest)
+}
+
+// nullJSON stores an empty JSON document as NULL
+func nullJSON(data []byte) interface{} {
+	if len(data) == 0 {
+		return nil
+	}
+	return string(data)
+}
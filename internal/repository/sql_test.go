@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/goldcast/gc_auth_service/internal/database"
+	"github.com/goldcast/gc_auth_service/internal/models"
+	"github.com/google/uuid"
+)
+
+// openSQLite returns an in-memory SQLite database with every migration
+// applied
+func openSQLite(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := database.Open("sqlite::memory:")
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	migrator, err := database.NewMigrator(db)
+	if err != nil {
+		t.Fatalf("new migrator: %v", err)
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db.DB
+}
+
+// createUser adds a user with the given username to the database, for rows
+// referencing users
+func createUser(t *testing.T, db *sql.DB, username string) uuid.UUID {
+	t.Helper()
+	now := time.Now().UTC().Truncate(time.Second)
+	user := &models.User{
+		ID: uuid.New(), Email: username + "@example.com", Username: username, Password: "x",
+		IsActive: true, CreatedAt: now, UpdatedAt: now,
+	}
+	if err := NewSQLUserRepository(db).Create(context.Background(), user); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return user.ID
+}
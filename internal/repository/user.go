@@ -0,0 +1,34 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/goldcast/gc_auth_service/internal/models"
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrNotFound is returned when a requested record does not exist
+	ErrNotFound = errors.New("record not found")
+	// ErrDuplicateEmail is returned when a user with the same email already exists
+	ErrDuplicateEmail = errors.New("email already registered")
+	// ErrDuplicateUsername is returned when a user with the same username already exists
+	ErrDuplicateUsername = errors.New("username already taken")
+)
+
+// UserRepository persists and retrieves users
+type UserRepository interface {
+	Create(ctx context.Context, user *models.User) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.User, error)
+	GetByEmail(ctx context.Context, email string) (*models.User, error)
+	GetByUsername(ctx context.Context, username string) (*models.User, error)
+	Update(ctx context.Context, user *models.User) error
+	// UpdatePassword replaces the user's password hash
+	UpdatePassword(ctx context.Context, id uuid.UUID, hash string, at time.Time) error
+	// MarkEmailVerified marks the user's email as verified, provided it is
+	// still the given address. It reports false if the email has changed.
+	MarkEmailVerified(ctx context.Context, id uuid.UUID, email string, at time.Time) (bool, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+}
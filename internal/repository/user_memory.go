@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goldcast/gc_auth_service/internal/models"
+	"github.com/google/uuid"
+)
+
+// MemoryUserRepository is an in-memory UserRepository, intended for tests and local development
+type MemoryUserRepository struct {
+	mu    sync.RWMutex
+	users map[uuid.UUID]models.User
+}
+
+// NewMemoryUserRepository creates an empty in-memory user repository
+func NewMemoryUserRepository() *MemoryUserRepository {
+	return &MemoryUserRepository{
+		users: make(map[uuid.UUID]models.User),
+	}
+}
+
+// Create stores a new user
+func (r *MemoryUserRepository) Create(ctx context.Context, user *models.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.checkUnique(user); err != nil {
+		return err
+	}
+
+	r.users[user.ID] = *user
+	return nil
+}
+
+// GetByID returns the user with the given ID
+func (r *MemoryUserRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &user, nil
+}
+
+// GetByEmail returns the user with the given email
+func (r *MemoryUserRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, user := range r.users {
+		if strings.EqualFold(user.Email, email) {
+			return &user, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// GetByUsername returns the user with the given username
+func (r *MemoryUserRepository) GetByUsername(ctx context.Context, username string) (*models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, user := range r.users {
+		if user.Username == username {
+			return &user, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// Update replaces an existing user
+func (r *MemoryUserRepository) Update(ctx context.Context, user *models.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.users[user.ID]; !ok {
+		return ErrNotFound
+	}
+	if err := r.checkUnique(user); err != nil {
+		return err
+	}
+
+	r.users[user.ID] = *user
+	return nil
+}
+
+// UpdatePassword replaces the user's password hash
+func (r *MemoryUserRepository) UpdatePassword(ctx context.Context, id uuid.UUID, hash string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		return ErrNotFound
+	}
+	user.Password = hash
+	user.UpdatedAt = at
+	r.users[id] = user
+	return nil
+}
+
+// MarkEmailVerified marks the user's email as verified if it is unchanged
+func (r *MemoryUserRepository) MarkEmailVerified(ctx context.Context, id uuid.UUID, email string, at time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[id]
+	if !ok || !strings.EqualFold(user.Email, email) {
+		return false, nil
+	}
+	user.EmailVerified = true
+	user.UpdatedAt = at
+	r.users[id] = user
+	return true, nil
+}
+
+// Delete removes the user with the given ID
+func (r *MemoryUserRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.users[id]; !ok {
+		return ErrNotFound
+	}
+	delete(r.users, id)
+	return nil
+}
+
+// checkUnique reports whether another user already holds the email or username
+func (r *MemoryUserRepository) checkUnique(user *models.User) error {
+	for id, existing := range r.users {
+		if id == user.ID {
+			continue
+		}
+		if strings.EqualFold(existing.Email, user.Email) {
+			return ErrDuplicateEmail
+		}
+		if existing.Username == user.Username {
+			return ErrDuplicateUsername
+		}
+	}
+	return nil
+}
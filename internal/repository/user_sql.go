@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/goldcast/gc_auth_service/internal/models"
+	"github.com/google/uuid"
+)
+
+const userColumns = `id, email, username, password, first_name, last_name, is_active, email_verified, created_at, updated_at`
+
+// SQLUserRepository is a UserRepository backed by PostgreSQL or SQLite
+type SQLUserRepository struct {
+	db *sql.DB
+}
+
+// NewSQLUserRepository creates a user repository using the given database connection
+func NewSQLUserRepository(db *sql.DB) *SQLUserRepository {
+	return &SQLUserRepository{db: db}
+}
+
+// Create inserts a new user
+func (r *SQLUserRepository) Create(ctx context.Context, user *models.User) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO users (`+userColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		user.ID, user.Email, user.Username, user.Password, user.FirstName, user.LastName,
+		user.IsActive, user.EmailVerified, user.CreatedAt, user.UpdatedAt,
+	)
+	return mapUserError(err)
+}
+
+// GetByID returns the user with the given ID
+func (r *SQLUserRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	return r.getOne(ctx, `SELECT `+userColumns+` FROM users WHERE id = $1`, id)
+}
+
+// GetByEmail returns the user with the given email
+func (r *SQLUserRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	return r.getOne(ctx, `SELECT `+userColumns+` FROM users WHERE LOWER(email) = LOWER($1)`, email)
+}
+
+// GetByUsername returns the user with the given username
+func (r *SQLUserRepository) GetByUsername(ctx context.Context, username string) (*models.User, error) {
+	return r.getOne(ctx, `SELECT `+userColumns+` FROM users WHERE username = $1`, username)
+}
+
+// Update saves changes to an existing user
+func (r *SQLUserRepository) Update(ctx context.Context, user *models.User) error {
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE users SET email = $2, username = $3, password = $4, first_name = $5, last_name = $6,
+			is_active = $7, email_verified = $8, updated_at = $9
+		WHERE id = $1`,
+		user.ID, user.Email, user.Username, user.Password, user.FirstName, user.LastName,
+		user.IsActive, user.EmailVerified, user.UpdatedAt,
+	)
+	if err != nil {
+		return mapUserError(err)
+	}
+	return expectAffected(result)
+}
+
+// UpdatePassword replaces the user's password hash
+func (r *SQLUserRepository) UpdatePassword(ctx context.Context, id uuid.UUID, hash string, at time.Time) error {
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE users SET password = $2, updated_at = $3 WHERE id = $1`, id, hash, at)
+	if err != nil {
+		return err
+	}
+	return expectAffected(result)
+}
+
+// MarkEmailVerified marks the user's email as verified if it is unchanged
+func (r *SQLUserRepository) MarkEmailVerified(ctx context.Context, id uuid.UUID, email string, at time.Time) (bool, error) {
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE users SET email_verified = TRUE, updated_at = $3 WHERE id = $1 AND LOWER(email) = LOWER($2)`,
+		id, email, at)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// Delete removes the user with the given ID
+func (r *SQLUserRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM users WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	return expectAffected(result)
+}
+
+func (r *SQLUserRepository) getOne(ctx context.Context, query string, args ...interface{}) (*models.User, error) {
+	var user models.User
+	err := r.db.QueryRowContext(ctx, query, args...).Scan(
+		&user.ID, &user.Email, &user.Username, &user.Password, &user.FirstName, &user.LastName,
+		&user.IsActive, &user.EmailVerified, &user.CreatedAt, &user.UpdatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// mapUserError translates unique constraint violations into repository errors
+func mapUserError(err error) error {
+	column, ok := uniqueViolation(err)
+	if !ok {
+		return err
+	}
+	switch {
+	case strings.Contains(column, "email"):
+		return ErrDuplicateEmail
+	case strings.Contains(column, "username"):
+		return ErrDuplicateUsername
+	}
+	return err
+}
@@ -2,14 +2,19 @@ package routes
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/goldcast/gc_auth_service/internal/app"
 	"github.com/goldcast/gc_auth_service/internal/handlers"
 	"github.com/goldcast/gc_auth_service/internal/middleware"
 	"github.com/goldcast/gc_auth_service/pkg/jwt"
-	"github.com/goldcast/gc_auth_service/pkg/logger"
 )
 
 // SetupRoutes configures all the routes for the application
-func SetupRoutes(router *gin.Engine, authHandler *handlers.AuthHandler) {
+func SetupRoutes(router *gin.Engine, c *app.Container) {
+	authHandler := handlers.NewAuthHandler(c)
+	wellKnownHandler := handlers.NewWellKnownHandler(c)
+	adminHandler := handlers.NewAdminHandler(c)
+	oauthHandler := handlers.NewOAuthHandler(c)
+
 	// Health check endpoint
 	router.GET("/health", func(c *gin.Context) {
 		c.JSON(200, gin.H{
@@ -18,6 +23,32 @@ func SetupRoutes(router *gin.Engine, authHandler *handlers.AuthHandler) {
 		})
 	})
 
+	// Discovery documents
+	wellKnown := router.Group("/.well-known")
+	{
+		wellKnown.GET("/jwks.json", wellKnownHandler.JWKS)
+		wellKnown.GET("/openid-configuration", wellKnownHandler.OpenIDConfiguration)
+	}
+
+	// OAuth 2.0 authorization server
+	oauth := router.Group("/oauth")
+	{
+		oauth.GET("/authorize", oauthHandler.Authorize)
+		oauth.POST("/authorize", oauthHandler.AuthorizeSubmit)
+		oauth.POST("/token", oauthHandler.Token)
+		oauth.POST("/introspect", oauthHandler.Introspect)
+		oauth.POST("/revoke", oauthHandler.Revoke)
+		oauth.POST("/register", oauthHandler.Register)
+		oauth.POST("/device_authorization", oauthHandler.DeviceAuthorization)
+		oauth.GET("/device", oauthHandler.Device)
+		oauth.POST("/device", oauthHandler.DeviceSubmit)
+
+		// Any user token with the openid scope may read userinfo, whatever its audience
+		userInfo := []gin.HandlerFunc{middleware.AuthMiddleware(c.Logger, c.JWT, c.Tokens, ""), middleware.RequireUser(), oauthHandler.UserInfo}
+		oauth.GET("/userinfo", userInfo...)
+		oauth.POST("/userinfo", userInfo...)
+	}
+
 	// API v1 routes
 	v1 := router.Group("/api/v1")
 	{
@@ -27,20 +58,57 @@ func SetupRoutes(router *gin.Engine, authHandler *handlers.AuthHandler) {
 			auth.POST("/register", authHandler.Register)
 			auth.POST("/login", authHandler.Login)
 			auth.POST("/refresh", authHandler.RefreshToken)
+			auth.POST("/mfa/verify", authHandler.VerifyMFA)
+			auth.POST("/mfa/passkey/begin", authHandler.BeginMFAPasskey)
+			auth.POST("/passkey/begin", authHandler.BeginPasskeyLogin)
+			auth.POST("/passkey/finish", authHandler.FinishPasskeyLogin)
+			auth.GET("/verify-email", authHandler.VerifyEmailPage)
+			auth.POST("/verify-email", authHandler.VerifyEmail)
+			auth.POST("/resend-verification", authHandler.ResendVerification)
+			auth.GET("/password/policy", authHandler.PasswordPolicy)
+			auth.POST("/password/forgot", authHandler.ForgotPassword)
+			auth.GET("/password/reset", authHandler.ResetPasswordPage)
+			auth.POST("/password/reset", authHandler.ResetPassword)
 		}
 
-		// Protected routes (authentication required)
+		// Protected routes (first-party login required; tokens issued to
+		// OAuth clients are refused)
 		protected := v1.Group("/")
 		{
-			// Initialize JWT service for middleware
-			jwtService := jwt.New("your-secret-key-change-in-production", 24) // TODO: Get from config
-			logger := logger.New("info") // TODO: Get from config
-			
-			protected.Use(middleware.AuthMiddleware(logger, jwtService))
+			protected.Use(middleware.AuthMiddleware(c.Logger, c.JWT, c.Tokens, jwt.AudienceFirstParty), middleware.RequireUser())
 			{
 				protected.GET("/profile", authHandler.GetProfile)
 				protected.POST("/logout", authHandler.Logout)
+				protected.POST("/logout-all", authHandler.LogoutAll)
+				protected.POST("/password", authHandler.ChangePassword)
+				protected.POST("/device", oauthHandler.ApproveDevice)
+				protected.GET("/mfa", authHandler.MFAStatus)
+				protected.POST("/mfa/totp", authHandler.EnrollTOTP)
+				protected.POST("/mfa/totp/confirm", authHandler.ConfirmTOTP)
+				protected.DELETE("/mfa/totp", authHandler.DisableTOTP)
+				protected.POST("/mfa/recovery-codes", authHandler.RegenerateRecoveryCodes)
+				protected.GET("/passkeys", authHandler.ListPasskeys)
+				protected.POST("/passkeys/register/begin", authHandler.BeginPasskeyRegistration)
+				protected.POST("/passkeys/register/finish", authHandler.FinishPasskeyRegistration)
+				protected.PATCH("/passkeys/:id", authHandler.RenamePasskey)
+				protected.DELETE("/passkeys/:id", authHandler.DeletePasskey)
 			}
 		}
+
+		// Admin routes (admin API token required)
+		admin := v1.Group("/admin")
+		{
+			admin.Use(middleware.AdminAuth(c.Logger, c.Config.AdminToken))
+			admin.GET("/keys", adminHandler.ListKeys)
+			admin.POST("/keys/rotate", adminHandler.RotateKey)
+			admin.GET("/clients", adminHandler.ListClients)
+			admin.POST("/clients", adminHandler.CreateClient)
+			admin.GET("/clients/:id", adminHandler.GetClient)
+			admin.PUT("/clients/:id", adminHandler.UpdateClient)
+			admin.DELETE("/clients/:id", adminHandler.DeleteClient)
+			admin.POST("/clients/:id/secret", adminHandler.RotateClientSecret)
+			admin.GET("/mail", adminHandler.ListMail)
+			admin.POST("/users/import", adminHandler.ImportUsers)
+		}
 	}
 }
@@ -1,12 +1,14 @@
 package main
 
 import (
+	"fmt"
 	"log"
 	"os"
 
 	"github.com/gin-gonic/gin"
+	"github.com/goldcast/gc_auth_service/internal/app"
+	"github.com/goldcast/gc_auth_service/internal/cli"
 	"github.com/goldcast/gc_auth_service/internal/config"
-	"github.com/goldcast/gc_auth_service/internal/handlers"
 	"github.com/goldcast/gc_auth_service/internal/middleware"
 	"github.com/goldcast/gc_auth_service/internal/routes"
 	"github.com/goldcast/gc_auth_service/pkg/logger"
@@ -17,35 +19,62 @@ func main() {
 	cfg := config.Load()
 
 	// Initialize logger
-	logger := logger.New(cfg.LogLevel)
+	logger := logger.New(cfg.LogLevel, cfg.Environment)
+
+	// Refuse to start with an invalid or unsafe configuration, whether
+	// serving or running a maintenance subcommand
+	if err := cfg.Validate(); err != nil {
+		log.Fatal(err)
+	}
+
+	// Run a maintenance subcommand instead of the server if one was given
+	if len(os.Args) > 1 {
+		os.Exit(cli.Run(cfg, logger, os.Args[1:], os.Stdout))
+	}
+
+	if err := serve(cfg, logger); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// serve runs the server until it fails. Errors are returned rather than
+// fatal so that the container is closed on the way out.
+func serve(cfg *config.Config, logger *logger.Logger) error {
+	// Build shared dependencies and start their background work
+	container, err := app.New(cfg, logger)
+	if err != nil {
+		return fmt.Errorf("failed to initialize service: %w", err)
+	}
+	defer container.Close()
+	if err := container.Start(); err != nil {
+		return fmt.Errorf("failed to start service: %w", err)
+	}
 
 	// Set Gin mode
 	if cfg.Environment == "production" {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
-	// Initialize Gin router
+	// Initialize Gin router. Client addresses are only taken from
+	// X-Forwarded-For when the request comes from a trusted proxy, as they
+	// key rate limits and are recorded on sessions.
 	router := gin.New()
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		return fmt.Errorf("invalid trusted proxies: %w", err)
+	}
 
 	// Add middleware
 	router.Use(middleware.Logger(logger))
 	router.Use(middleware.Recovery(logger))
 	router.Use(middleware.CORS())
 
-	// Initialize handlers
-	authHandler := handlers.NewAuthHandler(logger)
-
 	// Setup routes
-	routes.SetupRoutes(router, authHandler)
+	routes.SetupRoutes(router, container)
 
 	// Start server
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
-	}
-
-	logger.Infof("Starting server on port %s", port)
-	if err := router.Run(":" + port); err != nil {
-		log.Fatal("Failed to start server:", err)
+	logger.Infof("Starting server on port %s", cfg.Port)
+	if err := router.Run(":" + cfg.Port); err != nil {
+		return fmt.Errorf("failed to start server: %w", err)
 	}
+	return nil
 }
@@ -0,0 +1,50 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"errors"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ParseWithJWKS verifies a token signed by a third party, such as an OAuth
+// client assertion, against the public keys in jwks and decodes its claims.
+// The algorithm must match the type of the key selected by kid, and the
+// token must carry an expiry.
+func ParseWithJWKS(tokenString string, jwks JWKS, claims jwt.Claims) error {
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		jwk, ok := jwks.Lookup(kid)
+		if !ok {
+			return nil, errors.New("unknown signing key")
+		}
+		key, err := jwk.PublicKey()
+		if err != nil {
+			return nil, err
+		}
+
+		var matches bool
+		switch key := key.(type) {
+		case *rsa.PublicKey:
+			_, matches = token.Method.(*jwt.SigningMethodRSA)
+		case *ecdsa.PublicKey:
+			method, ok := token.Method.(*jwt.SigningMethodECDSA)
+			matches = ok && method.CurveBits == key.Curve.Params().BitSize
+		case ed25519.PublicKey:
+			_, matches = token.Method.(*jwt.SigningMethodEd25519)
+		}
+		if !matches || (jwk.Algorithm != "" && jwk.Algorithm != token.Method.Alg()) {
+			return nil, errors.New("unexpected signing method")
+		}
+		return key, nil
+	}, jwt.WithExpirationRequired())
+	if err != nil {
+		return err
+	}
+	if !token.Valid {
+		return errors.New("invalid token")
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package jwt
+
+import (
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// IDTokenClaims are the claims of an OpenID Connect ID token. Profile and
+// email claims are only set when the corresponding scopes were granted.
+type IDTokenClaims struct {
+	Nonce             string           `json:"nonce,omitempty"`
+	AuthTime          *jwt.NumericDate `json:"auth_time,omitempty"`
+	AMR               []string         `json:"amr,omitempty"`
+	ACR               string           `json:"acr,omitempty"`
+	AuthorizedParty   string           `json:"azp,omitempty"`
+	Email             string           `json:"email,omitempty"`
+	EmailVerified     *bool            `json:"email_verified,omitempty"`
+	Name              string           `json:"name,omitempty"`
+	GivenName         string           `json:"given_name,omitempty"`
+	FamilyName        string           `json:"family_name,omitempty"`
+	PreferredUsername string           `json:"preferred_username,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// SignIDToken signs an ID token with the active key. The caller is
+// responsible for the registered claims, since the issuer and audience of
+// ID tokens differ from those of access tokens.
+func (s *Service) SignIDToken(claims *IDTokenClaims) (string, error) {
+	return s.sign(claims)
+}
+
+// Algorithm returns the algorithm of the active signing key
+func (s *Service) Algorithm() string {
+	return s.keys.Active().Algorithm
+}
@@ -8,55 +8,230 @@ import (
 	"github.com/google/uuid"
 )
 
-// Claims represents the JWT claims
+// TokenTypeAccess marks access tokens, so that other tokens signed with the
+// same key cannot be presented in their place
+const TokenTypeAccess = "access"
+
+// TokenTypeEmailVerification marks the tokens of email verification links
+const TokenTypeEmailVerification = "email_verification"
+
+// Issuer is the iss of the tokens the service signs, other than ID tokens
+// which are issued by the public issuer URL
+const Issuer = "gc_auth_service"
+
+// AudienceFirstParty is the audience of access tokens issued by the JSON
+// API's own login, the only tokens accepted by its protected endpoints.
+// Tokens issued to OAuth clients never carry it.
+const AudienceFirstParty = "gc_auth_service/api"
+
+// Principal types an access token can be issued to
+const (
+	PrincipalUser   = "user"
+	PrincipalClient = "client"
+)
+
+// ErrWrongTokenType is returned when a valid token of another type is presented
+var ErrWrongTokenType = errors.New("wrong token type")
+
+// Claims represents the JWT claims. Tokens issued to an OAuth client acting
+// on its own behalf carry no user claims and have the client as subject.
 type Claims struct {
-	UserID   uuid.UUID `json:"user_id"`
-	Email    string    `json:"email"`
-	Username string    `json:"username"`
+	UserID    uuid.UUID `json:"user_id,omitzero"`
+	Email     string    `json:"email,omitempty"`
+	Username  string    `json:"username,omitempty"`
+	SessionID uuid.UUID `json:"sid,omitzero"`
+	ClientID  string    `json:"client_id,omitempty"`
+	Scope     string    `json:"scope,omitempty"`
+	Actor     *Actor    `json:"act,omitempty"`
+	TokenType string    `json:"token_type"`
 	jwt.RegisteredClaims
 }
 
+// Actor identifies the party acting on behalf of the token's subject after a
+// token exchange (RFC 8693 section 4.1). Earlier actors in a delegation chain
+// are nested in Actor.
+type Actor struct {
+	Subject  string `json:"sub"`
+	ClientID string `json:"client_id,omitempty"`
+	Actor    *Actor `json:"act,omitempty"`
+}
+
+// Principal reports whether the token was issued to a user or to a client
+func (c *Claims) Principal() string {
+	if c.UserID == uuid.Nil && c.ClientID != "" {
+		return PrincipalClient
+	}
+	return PrincipalUser
+}
+
+// TokenOption customises the claims of a generated token
+type TokenOption func(*Claims)
+
+// WithSessionID binds the token to a login session
+func WithSessionID(sessionID uuid.UUID) TokenOption {
+	return func(c *Claims) {
+		c.SessionID = sessionID
+	}
+}
+
+// WithClientID records the OAuth client the token was issued to
+func WithClientID(clientID string) TokenOption {
+	return func(c *Claims) {
+		c.ClientID = clientID
+	}
+}
+
+// WithScope sets the space-delimited scopes granted to the token
+func WithScope(scope string) TokenOption {
+	return func(c *Claims) {
+		c.Scope = scope
+	}
+}
+
+// WithActor records who is acting on behalf of the subject
+func WithActor(actor *Actor) TokenOption {
+	return func(c *Claims) {
+		c.Actor = actor
+	}
+}
+
+// WithExpiry overrides the lifetime of the token. A zero lifetime keeps the
+// service default.
+func WithExpiry(lifetime time.Duration) TokenOption {
+	return func(c *Claims) {
+		if lifetime > 0 {
+			c.ExpiresAt = jwt.NewNumericDate(c.IssuedAt.Add(lifetime))
+		}
+	}
+}
+
+// WithAudience sets the recipients the token is intended for
+func WithAudience(audience ...string) TokenOption {
+	return func(c *Claims) {
+		if len(audience) > 0 {
+			c.Audience = audience
+		}
+	}
+}
+
 // Service handles JWT operations
 type Service struct {
-	secretKey []byte
-	expiry    time.Duration
+	keys   *Keyring
+	expiry time.Duration
 }
 
-// New creates a new JWT service
+// New creates a new JWT service signing with an HS256 shared secret
 func New(secretKey string, expiryHours int) *Service {
+	return NewWithKey(NewHMACKey("default", secretKey), expiryHours)
+}
+
+// NewWithKey creates a new JWT service signing with the given key
+func NewWithKey(key *Key, expiryHours int) *Service {
+	return NewWithKeyring(NewKeyring(key), expiryHours)
+}
+
+// NewWithKeyring creates a new JWT service signing with the keyring's active
+// key and verifying with any key in the keyring
+func NewWithKeyring(keys *Keyring, expiryHours int) *Service {
 	return &Service{
-		secretKey: []byte(secretKey),
-		expiry:    time.Duration(expiryHours) * time.Hour,
+		keys:   keys,
+		expiry: time.Duration(expiryHours) * time.Hour,
 	}
 }
 
-// GenerateToken generates a new JWT token for a user
-func (s *Service) GenerateToken(userID uuid.UUID, email, username string) (string, error) {
+// Keyring returns the keys used by the service
+func (s *Service) Keyring() *Keyring {
+	return s.keys
+}
+
+// Expiry returns the lifetime of access tokens issued by the service
+func (s *Service) Expiry() time.Duration {
+	return s.expiry
+}
+
+// GenerateToken generates a new access token for a user
+func (s *Service) GenerateToken(userID uuid.UUID, email, username string, opts ...TokenOption) (string, error) {
+	now := time.Now()
 	claims := &Claims{
-		UserID:   userID,
-		Email:    email,
-		Username: username,
+		UserID:    userID,
+		Email:     email,
+		Username:  username,
+		TokenType: TokenTypeAccess,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(s.expiry)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
-			Issuer:    "gc_auth_service",
+			ID:        uuid.NewString(),
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.expiry)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			Issuer:    Issuer,
 			Subject:   userID.String(),
 		},
 	}
+	for _, opt := range opts {
+		opt(claims)
+	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(s.secretKey)
+	return s.sign(claims)
+}
+
+// GenerateEmailVerificationToken generates the token of a link proving that
+// the user receives mail at the given address. The ID identifies the link, so
+// that it can only be used once.
+func (s *Service) GenerateEmailVerificationToken(userID uuid.UUID, email string, id uuid.UUID, expiresAt time.Time) (string, error) {
+	now := time.Now()
+	return s.sign(&Claims{
+		UserID:    userID,
+		Email:     email,
+		TokenType: TokenTypeEmailVerification,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        id.String(),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			Issuer:    Issuer,
+			Subject:   userID.String(),
+		},
+	})
+}
+
+// GenerateClientToken generates a new access token for an OAuth client
+// acting on its own behalf, as in the client credentials grant
+func (s *Service) GenerateClientToken(clientID string, opts ...TokenOption) (string, error) {
+	now := time.Now()
+	claims := &Claims{
+		ClientID:  clientID,
+		TokenType: TokenTypeAccess,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.expiry)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			Issuer:    Issuer,
+			Subject:   clientID,
+		},
+	}
+	for _, opt := range opts {
+		opt(claims)
+	}
+
+	return s.sign(claims)
+}
+
+// sign signs the claims with the active key and sets the kid header
+func (s *Service) sign(claims jwt.Claims) (string, error) {
+	key := s.keys.Active()
+	token := jwt.NewWithClaims(key.method(), claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.signingKey)
 }
 
 // ValidateToken validates a JWT token and returns the claims
 func (s *Service) ValidateToken(tokenString string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
-		}
-		return s.secretKey, nil
-	})
+	return s.parse(tokenString)
+}
+
+// parse validates a JWT token with additional checks on its claims
+func (s *Service) parse(tokenString string, opts ...jwt.ParserOption) (*Claims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, s.keyFunc, opts...)
 
 	if err != nil {
 		return nil, err
@@ -69,19 +244,60 @@ func (s *Service) ValidateToken(tokenString string) (*Claims, error) {
 	return nil, errors.New("invalid token")
 }
 
-// GenerateRefreshToken generates a refresh token (longer expiry)
-func (s *Service) GenerateRefreshToken(userID uuid.UUID) (string, error) {
-	claims := &Claims{
-		UserID: userID,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(7 * 24 * time.Hour)), // 7 days
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
-			Issuer:    "gc_auth_service",
-			Subject:   userID.String(),
-		},
+// keyFunc selects the verification key for a token by its kid header and
+// rejects tokens whose algorithm does not match that key
+func (s *Service) keyFunc(token *jwt.Token) (interface{}, error) {
+	// Tokens issued before key IDs were introduced carry no kid
+	key := s.keys.Active()
+	if kid, ok := token.Header["kid"].(string); ok {
+		if key, ok = s.keys.Lookup(kid); !ok {
+			return nil, errors.New("unknown signing key")
+		}
+	}
+	if token.Method.Alg() != key.Algorithm {
+		return nil, errors.New("unexpected signing method")
+	}
+	return key.verificationKey, nil
+}
+
+// JWKS returns the public keys that consumers can use to verify tokens,
+// including keys that have been rotated out but may still verify live tokens.
+// Symmetric keys are never published.
+func (s *Service) JWKS() JWKS {
+	jwks := JWKS{Keys: []JWK{}}
+	for _, key := range s.keys.Keys() {
+		if key.IsAsymmetric() {
+			jwks.Keys = append(jwks.Keys, key.PublicJWK())
+		}
 	}
+	return jwks
+}
+
+// ValidateAccessToken validates a token and checks that it is an access token
+// issued by the service for the audience. An empty audience accepts tokens
+// issued for any audience, for callers that report or check the audience
+// themselves, such as introspection and token exchange.
+func (s *Service) ValidateAccessToken(tokenString, audience string) (*Claims, error) {
+	if audience == "" {
+		return s.validateType(tokenString, TokenTypeAccess)
+	}
+	return s.validateType(tokenString, TokenTypeAccess, jwt.WithAudience(audience))
+}
+
+// ValidateEmailVerificationToken validates a token and checks that it is the
+// token of an email verification link
+func (s *Service) ValidateEmailVerificationToken(tokenString string) (*Claims, error) {
+	return s.validateType(tokenString, TokenTypeEmailVerification)
+}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(s.secretKey)
+// validateType validates a token issued by the service and checks its type
+func (s *Service) validateType(tokenString, tokenType string, opts ...jwt.ParserOption) (*Claims, error) {
+	claims, err := s.parse(tokenString, append(opts, jwt.WithIssuer(Issuer))...)
+	if err != nil {
+		return nil, err
+	}
+	if claims.TokenType != tokenType {
+		return nil, ErrWrongTokenType
+	}
+	return claims, nil
 }
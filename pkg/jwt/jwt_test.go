@@ -0,0 +1,236 @@
+package jwt
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const testSecret = "test-secret-of-at-least-32-bytes!"
+
+// generateKey returns a new key for the algorithm
+func generateKey(t *testing.T, algorithm string) *Key {
+	t.Helper()
+	if algorithm == AlgHS256 {
+		return NewHMACKey("hmac", testSecret)
+	}
+	key, err := GenerateKey(algorithm)
+	if err != nil {
+		t.Fatalf("generate %s key: %v", algorithm, err)
+	}
+	return key
+}
+
+// header returns the header of a signed token
+func header(t *testing.T, token string) map[string]interface{} {
+	t.Helper()
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, &Claims{})
+	if err != nil {
+		t.Fatalf("parse token: %v", err)
+	}
+	return parsed.Header
+}
+
+func TestTokenRoundTrip(t *testing.T) {
+	for _, algorithm := range []string{AlgHS256, AlgRS256, AlgES256, AlgES384, AlgES512, AlgEdDSA} {
+		t.Run(algorithm, func(t *testing.T) {
+			key := generateKey(t, algorithm)
+			s := NewWithKey(key, 1)
+			userID, sessionID := uuid.New(), uuid.New()
+
+			token, err := s.GenerateToken(userID, "alice@example.com", "alice",
+				WithSessionID(sessionID), WithScope("openid profile"), WithAudience(AudienceFirstParty))
+			if err != nil {
+				t.Fatalf("generate token: %v", err)
+			}
+			if h := header(t, token); h["alg"] != algorithm || h["kid"] != key.ID {
+				t.Errorf("header alg %v kid %v, want %s %s", h["alg"], h["kid"], algorithm, key.ID)
+			}
+
+			claims, err := s.ValidateAccessToken(token, AudienceFirstParty)
+			if err != nil {
+				t.Fatalf("validate token: %v", err)
+			}
+			if claims.UserID != userID || claims.SessionID != sessionID || claims.Email != "alice@example.com" || claims.Scope != "openid profile" {
+				t.Errorf("claims %+v do not match the token issued", claims)
+			}
+			if claims.Subject != userID.String() || claims.Issuer != Issuer || claims.ID == "" {
+				t.Errorf("registered claims sub %q iss %q jti %q", claims.Subject, claims.Issuer, claims.ID)
+			}
+			if claims.Principal() != PrincipalUser {
+				t.Errorf("principal %s, want %s", claims.Principal(), PrincipalUser)
+			}
+		})
+	}
+}
+
+func TestClientToken(t *testing.T) {
+	s := New(testSecret, 1)
+	token, err := s.GenerateClientToken("reporting", WithScope("reports:read"), WithExpiry(5*time.Minute))
+	if err != nil {
+		t.Fatalf("generate token: %v", err)
+	}
+	claims, err := s.ValidateAccessToken(token, "")
+	if err != nil {
+		t.Fatalf("validate token: %v", err)
+	}
+	if claims.Principal() != PrincipalClient || claims.Subject != "reporting" || claims.UserID != uuid.Nil {
+		t.Errorf("principal %s sub %q user %s, want the client", claims.Principal(), claims.Subject, claims.UserID)
+	}
+	if lifetime := claims.ExpiresAt.Sub(claims.IssuedAt.Time); lifetime != 5*time.Minute {
+		t.Errorf("lifetime %v, want 5m", lifetime)
+	}
+}
+
+func TestValidateAccessTokenRejects(t *testing.T) {
+	s := New(testSecret, 1)
+	userID := uuid.New()
+
+	firstParty, err := s.GenerateToken(userID, "alice@example.com", "alice", WithAudience(AudienceFirstParty))
+	if err != nil {
+		t.Fatalf("generate token: %v", err)
+	}
+	client, err := s.GenerateToken(userID, "alice@example.com", "alice", WithClientID("app"), WithAudience("app"))
+	if err != nil {
+		t.Fatalf("generate token: %v", err)
+	}
+	verification, err := s.GenerateEmailVerificationToken(userID, "alice@example.com", uuid.New(), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("generate token: %v", err)
+	}
+	expired, err := s.sign(&Claims{
+		UserID:    userID,
+		TokenType: TokenTypeAccess,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(-time.Minute)),
+			Issuer:    Issuer,
+		},
+	})
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	foreign, err := s.sign(&Claims{
+		UserID:           userID,
+		TokenType:        TokenTypeAccess,
+		RegisteredClaims: jwt.RegisteredClaims{Issuer: "someone-else"},
+	})
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	other, err := New(strings.Repeat("x", 32), 1).GenerateToken(userID, "alice@example.com", "alice", WithAudience(AudienceFirstParty))
+	if err != nil {
+		t.Fatalf("generate token: %v", err)
+	}
+
+	if _, err := s.ValidateAccessToken(firstParty, AudienceFirstParty); err != nil {
+		t.Fatalf("validate first-party token: %v", err)
+	}
+	for name, token := range map[string]string{
+		"token for another audience":  client,
+		"email verification token":    verification,
+		"expired token":               expired,
+		"token from another issuer":   foreign,
+		"token signed by another key": other,
+		"tampered token":              firstParty[:len(firstParty)-2] + "xx",
+	} {
+		if _, err := s.ValidateAccessToken(token, AudienceFirstParty); err == nil {
+			t.Errorf("%s validated", name)
+		}
+	}
+	if _, err := s.ValidateAccessToken(verification, ""); !errors.Is(err, ErrWrongTokenType) {
+		t.Errorf("validate an email verification token: %v, want %v", err, ErrWrongTokenType)
+	}
+	if _, err := s.ValidateEmailVerificationToken(firstParty); !errors.Is(err, ErrWrongTokenType) {
+		t.Errorf("validate an access token as email verification: %v, want %v", err, ErrWrongTokenType)
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	old, current := generateKey(t, AlgES256), generateKey(t, AlgES256)
+	keys := NewKeyring(old)
+	s := NewWithKeyring(keys, 1)
+
+	token, err := s.GenerateToken(uuid.New(), "alice@example.com", "alice")
+	if err != nil {
+		t.Fatalf("generate token: %v", err)
+	}
+
+	// Tokens signed by the previous key keep validating after rotation
+	keys.Replace(current, old)
+	if _, err := s.ValidateToken(token); err != nil {
+		t.Errorf("validate token of the previous key: %v", err)
+	}
+	rotated, err := s.GenerateToken(uuid.New(), "alice@example.com", "alice")
+	if err != nil {
+		t.Fatalf("generate token: %v", err)
+	}
+	if kid := header(t, rotated)["kid"]; kid != current.ID {
+		t.Errorf("new token signed by %v, want %s", kid, current.ID)
+	}
+	if jwks := s.JWKS(); len(jwks.Keys) != 2 {
+		t.Errorf("JWKS holds %d keys, want both", len(jwks.Keys))
+	}
+
+	// and are refused once the key is dropped
+	keys.Replace(current)
+	if _, err := s.ValidateToken(token); err == nil {
+		t.Error("token of a dropped key validated")
+	}
+
+	// An unknown kid gives the owner a chance to reload rotated keys
+	var missed []string
+	keys.OnMiss(func(kid string) {
+		missed = append(missed, kid)
+		keys.Replace(current, old)
+	})
+	if _, err := s.ValidateToken(token); err != nil {
+		t.Errorf("validate token after reloading keys: %v", err)
+	}
+	if len(missed) != 1 || missed[0] != old.ID {
+		t.Errorf("missed kids %v, want %s", missed, old.ID)
+	}
+}
+
+func TestAlgorithmMismatch(t *testing.T) {
+	key := generateKey(t, AlgRS256)
+	s := NewWithKey(key, 1)
+
+	// An HS256 token keyed with the public key and naming the RSA key must
+	// not verify
+	jwk := key.PublicJWK()
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, &Claims{
+		TokenType:        TokenTypeAccess,
+		RegisteredClaims: jwt.RegisteredClaims{Issuer: Issuer},
+	})
+	forged.Header["kid"] = key.ID
+	token, err := forged.SignedString([]byte(jwk.N))
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	if _, err := s.ValidateAccessToken(token, ""); err == nil {
+		t.Error("token with another algorithm than its key validated")
+	}
+
+	unsigned, err := jwt.NewWithClaims(jwt.SigningMethodNone, &Claims{TokenType: TokenTypeAccess}).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	if _, err := s.ValidateToken(unsigned); err == nil {
+		t.Error("unsigned token validated")
+	}
+}
+
+func TestJWKSOmitsSymmetricKeys(t *testing.T) {
+	s := NewWithKeyring(NewKeyring(NewHMACKey("hmac", testSecret), generateKey(t, AlgEdDSA)), 1)
+	jwks := s.JWKS()
+	if len(jwks.Keys) != 1 || jwks.Keys[0].KeyType != "OKP" {
+		t.Errorf("JWKS %+v, want only the Ed25519 key", jwks)
+	}
+	if jwk := NewHMACKey("hmac", testSecret).PublicJWK(); jwk != (JWK{}) {
+		t.Errorf("symmetric key published as %+v", jwk)
+	}
+}
@@ -0,0 +1,87 @@
+package jwt
+
+import (
+	"sort"
+	"sync"
+)
+
+// Keyring holds the active signing key together with verification-only keys,
+// selected by kid. Keys can be swapped at runtime when they are rotated.
+type Keyring struct {
+	mu     sync.RWMutex
+	active *Key
+	keys   map[string]*Key
+	onMiss func(kid string)
+}
+
+// NewKeyring creates a keyring signing with active and additionally
+// accepting tokens signed by any of the verification keys
+func NewKeyring(active *Key, verification ...*Key) *Keyring {
+	k := &Keyring{}
+	k.Replace(active, verification...)
+	return k
+}
+
+// Replace atomically swaps the keys held by the keyring
+func (k *Keyring) Replace(active *Key, verification ...*Key) {
+	keys := make(map[string]*Key, len(verification)+1)
+	for _, key := range verification {
+		keys[key.ID] = key
+	}
+	keys[active.ID] = active
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.active = active
+	k.keys = keys
+}
+
+// OnMiss registers a callback invoked when a token references an unknown kid,
+// giving the owner a chance to reload keys rotated elsewhere before the
+// lookup is retried
+func (k *Keyring) OnMiss(fn func(kid string)) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.onMiss = fn
+}
+
+// Active returns the key new tokens are signed with
+func (k *Keyring) Active() *Key {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.active
+}
+
+// Lookup returns the key with the given kid
+func (k *Keyring) Lookup(kid string) (*Key, bool) {
+	k.mu.RLock()
+	key, ok := k.keys[kid]
+	onMiss := k.onMiss
+	k.mu.RUnlock()
+
+	if ok || onMiss == nil {
+		return key, ok
+	}
+
+	onMiss(kid)
+
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	key, ok = k.keys[kid]
+	return key, ok
+}
+
+// Keys returns every key in the keyring ordered by kid
+func (k *Keyring) Keys() []*Key {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	keys := make([]*Key, 0, len(k.keys))
+	for _, key := range k.keys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].ID < keys[j].ID
+	})
+	return keys
+}
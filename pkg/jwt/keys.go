@@ -0,0 +1,172 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Supported signing algorithms
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgES256 = "ES256"
+	AlgES384 = "ES384"
+	AlgES512 = "ES512"
+	AlgEdDSA = "EdDSA"
+)
+
+// Key is a signing key identified by its kid
+type Key struct {
+	ID        string
+	Algorithm string
+
+	signingKey      interface{}
+	verificationKey interface{}
+}
+
+// NewHMACKey creates a symmetric HS256 key from a shared secret
+func NewHMACKey(kid, secret string) *Key {
+	return &Key{
+		ID:              kid,
+		Algorithm:       AlgHS256,
+		signingKey:      []byte(secret),
+		verificationKey: []byte(secret),
+	}
+}
+
+// NewKey wraps an RSA, ECDSA or Ed25519 private key. The algorithm is derived
+// from the key type and, when kid is empty, the RFC 7638 thumbprint of the
+// public key is used as key ID.
+func NewKey(kid string, privateKey crypto.Signer) (*Key, error) {
+	key := &Key{signingKey: privateKey, verificationKey: privateKey.Public()}
+
+	switch k := privateKey.(type) {
+	case *rsa.PrivateKey:
+		if k.N.BitLen() < 2048 {
+			return nil, fmt.Errorf("RSA keys must be at least 2048 bits, got %d", k.N.BitLen())
+		}
+		key.Algorithm = AlgRS256
+	case *ecdsa.PrivateKey:
+		switch k.Curve {
+		case elliptic.P256():
+			key.Algorithm = AlgES256
+		case elliptic.P384():
+			key.Algorithm = AlgES384
+		case elliptic.P521():
+			key.Algorithm = AlgES512
+		default:
+			return nil, errors.New("unsupported elliptic curve")
+		}
+	case ed25519.PrivateKey:
+		key.Algorithm = AlgEdDSA
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", privateKey)
+	}
+
+	if kid == "" {
+		thumbprint, err := key.PublicJWK().Thumbprint()
+		if err != nil {
+			return nil, err
+		}
+		kid = thumbprint
+	}
+	key.ID = kid
+
+	return key, nil
+}
+
+// GenerateKey creates a new random key for an asymmetric algorithm
+func GenerateKey(algorithm string) (*Key, error) {
+	var signer crypto.Signer
+	var err error
+	switch algorithm {
+	case AlgRS256:
+		signer, err = rsa.GenerateKey(rand.Reader, 2048)
+	case AlgES256:
+		signer, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case AlgES384:
+		signer, err = ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	case AlgES512:
+		signer, err = ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	case AlgEdDSA:
+		_, signer, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("cannot generate keys for algorithm %q", algorithm)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return NewKey("", signer)
+}
+
+// MarshalPrivateKeyPEM encodes the private key as PKCS#8 PEM
+func (k *Key) MarshalPrivateKeyPEM() ([]byte, error) {
+	if !k.IsAsymmetric() {
+		return nil, errors.New("symmetric keys cannot be exported")
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(k.signingKey)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// ParsePrivateKeyPEM parses a PEM encoded PKCS#8, PKCS#1 (RSA) or SEC 1 (EC) private key
+func ParsePrivateKeyPEM(kid string, data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var parsed interface{}
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		parsed, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type %q", block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse private key: %w", err)
+	}
+
+	signer, ok := parsed.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T", parsed)
+	}
+	return NewKey(kid, signer)
+}
+
+// LoadPrivateKeyFile reads a PEM encoded private key from disk
+func LoadPrivateKeyFile(kid, path string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePrivateKeyPEM(kid, data)
+}
+
+// IsAsymmetric reports whether the key can be published as a JWK
+func (k *Key) IsAsymmetric() bool {
+	return k.Algorithm != AlgHS256
+}
+
+// method returns the jwt signing method for the key's algorithm
+func (k *Key) method() jwt.SigningMethod {
+	return jwt.GetSigningMethod(k.Algorithm)
+}
@@ -0,0 +1,159 @@
+package jwt
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// TestThumbprint checks the example of RFC 7638 section 3.1
+func TestThumbprint(t *testing.T) {
+	jwk := JWK{
+		KeyType:   "RSA",
+		KeyID:     "2011-04-29",
+		Algorithm: "RS256",
+		N:         "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw",
+		E:         "AQAB",
+	}
+	thumbprint, err := jwk.Thumbprint()
+	if err != nil {
+		t.Fatalf("thumbprint: %v", err)
+	}
+	if want := "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs"; thumbprint != want {
+		t.Errorf("thumbprint %s, want %s", thumbprint, want)
+	}
+	if _, err := (JWK{KeyType: "oct"}).Thumbprint(); err == nil {
+		t.Error("computed the thumbprint of a symmetric key")
+	}
+}
+
+func TestKeyPEMRoundTrip(t *testing.T) {
+	for _, algorithm := range []string{AlgRS256, AlgES256, AlgES384, AlgES512, AlgEdDSA} {
+		t.Run(algorithm, func(t *testing.T) {
+			key := generateKey(t, algorithm)
+			if key.Algorithm != algorithm || !key.IsAsymmetric() {
+				t.Fatalf("generated %s key, want %s", key.Algorithm, algorithm)
+			}
+			// Generated keys are named by their thumbprint
+			if thumbprint, _ := key.PublicJWK().Thumbprint(); key.ID != thumbprint {
+				t.Errorf("kid %s, want the thumbprint %s", key.ID, thumbprint)
+			}
+
+			data, err := key.MarshalPrivateKeyPEM()
+			if err != nil {
+				t.Fatalf("marshal key: %v", err)
+			}
+			path := filepath.Join(t.TempDir(), "signing.pem")
+			if err := os.WriteFile(path, data, 0o600); err != nil {
+				t.Fatalf("write key: %v", err)
+			}
+			loaded, err := LoadPrivateKeyFile("", path)
+			if err != nil {
+				t.Fatalf("load key: %v", err)
+			}
+			if loaded.ID != key.ID || loaded.PublicJWK() != key.PublicJWK() {
+				t.Errorf("loaded key %+v, want %+v", loaded.PublicJWK(), key.PublicJWK())
+			}
+			if named, err := ParsePrivateKeyPEM("2026-10", data); err != nil || named.ID != "2026-10" {
+				t.Errorf("parse key with a kid: %v, %v", named, err)
+			}
+
+			// The published JWK decodes to a key verifying the service's tokens
+			token, err := NewWithKey(key, 1).GenerateToken(uuid.New(), "alice@example.com", "alice")
+			if err != nil {
+				t.Fatalf("generate token: %v", err)
+			}
+			if err := ParseWithJWKS(token, JWKS{Keys: []JWK{key.PublicJWK()}}, &Claims{}); err != nil {
+				t.Errorf("verify with the JWKS: %v", err)
+			}
+		})
+	}
+
+	if _, err := NewHMACKey("hmac", testSecret).MarshalPrivateKeyPEM(); err == nil {
+		t.Error("exported a symmetric key")
+	}
+	if _, err := ParsePrivateKeyPEM("", []byte("not a key")); err == nil {
+		t.Error("parsed a key without a PEM block")
+	}
+}
+
+func TestNewKeyRejectsShortRSA(t *testing.T) {
+	short, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	if _, err := NewKey("", short); err == nil {
+		t.Error("accepted a 1024-bit RSA key")
+	}
+	if _, err := GenerateKey(AlgHS256); err == nil {
+		t.Error("generated a symmetric key")
+	}
+}
+
+func TestParseWithJWKS(t *testing.T) {
+	es256, es384, ed := generateKey(t, AlgES256), generateKey(t, AlgES384), generateKey(t, AlgEdDSA)
+	jwks := JWKS{Keys: []JWK{es256.PublicJWK(), ed.PublicJWK()}}
+
+	sign := func(key *Key, kid string, expires bool) string {
+		t.Helper()
+		claims := &jwt.RegisteredClaims{Subject: "client"}
+		if expires {
+			claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(time.Minute))
+		}
+		token := jwt.NewWithClaims(key.method(), claims)
+		token.Header["kid"] = kid
+		signed, err := token.SignedString(key.signingKey)
+		if err != nil {
+			t.Fatalf("sign token: %v", err)
+		}
+		return signed
+	}
+
+	for _, key := range []*Key{es256, ed} {
+		if err := ParseWithJWKS(sign(key, key.ID, true), jwks, &jwt.RegisteredClaims{}); err != nil {
+			t.Errorf("verify %s token: %v", key.Algorithm, err)
+		}
+	}
+	for name, token := range map[string]string{
+		"unknown kid":         sign(es384, es384.ID, true),
+		"key of another type": sign(ed, es256.ID, true),
+		"no expiry":           sign(es256, es256.ID, false),
+		"signed by other key": sign(generateKey(t, AlgES256), es256.ID, true),
+	} {
+		if err := ParseWithJWKS(token, jwks, &jwt.RegisteredClaims{}); err == nil {
+			t.Errorf("%s verified", name)
+		}
+	}
+
+	// A set of one key matches tokens without a kid
+	single := JWKS{Keys: []JWK{ed.PublicJWK()}}
+	if err := ParseWithJWKS(sign(ed, "", true), single, &jwt.RegisteredClaims{}); err != nil {
+		t.Errorf("verify token without a kid: %v", err)
+	}
+	if err := ParseWithJWKS(sign(ed, "", true), jwks, &jwt.RegisteredClaims{}); err == nil {
+		t.Error("token without a kid verified against several keys")
+	}
+}
+
+func TestJWKPublicKeyRejects(t *testing.T) {
+	jwk := generateKey(t, AlgES256).PublicJWK()
+	offCurve := jwk
+	offCurve.Y = jwk.X
+	for name, bad := range map[string]JWK{
+		"point off the curve": offCurve,
+		"unknown curve":       {KeyType: "EC", Curve: "P-192", X: jwk.X, Y: jwk.Y},
+		"short Ed25519 key":   {KeyType: "OKP", Curve: "Ed25519", X: "AAAA"},
+		"small RSA exponent":  {KeyType: "RSA", N: "AQAB", E: "AQ"},
+		"symmetric key":       {KeyType: "oct"},
+	} {
+		if _, err := bad.PublicKey(); err == nil {
+			t.Errorf("decoded a JWK with %s", name)
+		}
+	}
+}
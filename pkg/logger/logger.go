@@ -11,8 +11,8 @@ type Logger struct {
 	*logrus.Logger
 }
 
-// New creates a new logger instance
-func New(level string) *Logger {
+// New creates a new logger instance for the given level and environment
+func New(level, environment string) *Logger {
 	log := logrus.New()
 
 	// Set log level
@@ -30,7 +30,7 @@ func New(level string) *Logger {
 	}
 
 	// Set JSON formatter for production
-	if os.Getenv("ENVIRONMENT") == "production" {
+	if environment == "production" {
 		log.SetFormatter(&logrus.JSONFormatter{})
 	} else {
 		log.SetFormatter(&logrus.TextFormatter{
@@ -49,6 +49,16 @@ func (l *Logger) WithField(key string, value interface{}) *logrus.Entry {
 	return l.Logger.WithField(key, value)
 }
 
+// Audit logs a security-relevant event, such as a password change. Audit
+// entries carry audit=true and the event name, so that they can be routed to
+// an audit trail.
+func (l *Logger) Audit(event string, fields logrus.Fields) {
+	l.Logger.WithFields(fields).WithFields(logrus.Fields{
+		"audit": true,
+		"event": event,
+	}).Info("Audit event")
+}
+
 // WithFields creates a new logger entry with multiple fields
 func (l *Logger) WithFields(fields logrus.Fields) *logrus.Entry {
 	return l.Logger.WithFields(fields)
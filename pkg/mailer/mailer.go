@@ -0,0 +1,115 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"net/mail"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goldcast/gc_auth_service/pkg/logger"
+)
+
+// ErrInvalidMessage is returned for messages whose recipient or subject
+// cannot be put in a mail header
+var ErrInvalidMessage = errors.New("mailer: invalid message")
+
+// Message is a plain text email to a single recipient
+type Message struct {
+	To      string    `json:"to"`
+	Subject string    `json:"subject"`
+	Body    string    `json:"body"`
+	SentAt  time.Time `json:"sent_at"`
+}
+
+// Mailer delivers email
+type Mailer interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// validate rejects recipients that are not a single address, and subjects
+// that would inject headers
+func validate(msg *Message) error {
+	addr, err := mail.ParseAddress(msg.To)
+	if err != nil || addr.Address != strings.TrimSpace(msg.To) {
+		return ErrInvalidMessage
+	}
+	if strings.ContainsAny(msg.Subject, "\r\n") {
+		return ErrInvalidMessage
+	}
+	return nil
+}
+
+// LogMailer writes messages to the log instead of delivering them, for
+// development. Messages may contain secrets such as verification links, so
+// it must not be used in production.
+type LogMailer struct {
+	logger *logger.Logger
+}
+
+// NewLogMailer creates a mailer that logs every message
+func NewLogMailer(log *logger.Logger) *LogMailer {
+	return &LogMailer{logger: log}
+}
+
+// Send logs the message
+func (m *LogMailer) Send(ctx context.Context, msg *Message) error {
+	if err := validate(msg); err != nil {
+		return err
+	}
+	m.logger.WithFields(map[string]interface{}{
+		"to":      msg.To,
+		"subject": msg.Subject,
+		"body":    msg.Body,
+	}).Info("Email not delivered (log mailer)")
+	return nil
+}
+
+// memoryMailboxSize is the number of messages a MemoryMailer keeps
+const memoryMailboxSize = 100
+
+// MemoryMailer keeps the most recent messages in memory instead of
+// delivering them, for tests and local development
+type MemoryMailer struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+// NewMemoryMailer creates a mailer with an empty mailbox
+func NewMemoryMailer() *MemoryMailer {
+	return &MemoryMailer{}
+}
+
+// Send stores the message, dropping the oldest one once the mailbox is full
+func (m *MemoryMailer) Send(ctx context.Context, msg *Message) error {
+	if err := validate(msg); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored := *msg
+	stored.SentAt = time.Now()
+	m.messages = append(m.messages, stored)
+	if len(m.messages) > memoryMailboxSize {
+		m.messages = m.messages[len(m.messages)-memoryMailboxSize:]
+	}
+	return nil
+}
+
+// Messages returns the stored messages to the given recipient, or all of
+// them if to is empty, newest first
+func (m *MemoryMailer) Messages(to string) []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	messages := []Message{}
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if to == "" || strings.EqualFold(m.messages[i].To, to) {
+			messages = append(messages, m.messages[i])
+		}
+	}
+	return messages
+}
@@ -0,0 +1,153 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// smtpTimeout bounds a delivery when the context has no deadline
+const smtpTimeout = 30 * time.Second
+
+// SMTPConfig describes the SMTP server to deliver mail through. Port 465 uses
+// implicit TLS; other ports require STARTTLS unless Insecure is set.
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string // no authentication when empty
+	Password string
+	From     string // sender, e.g. "GC Auth <no-reply@example.com>"
+	Insecure bool   // deliver in plain text to servers that do not offer STARTTLS
+}
+
+// SMTPMailer delivers mail through an SMTP server
+type SMTPMailer struct {
+	cfg  SMTPConfig
+	from *mail.Address
+}
+
+// NewSMTPMailer creates a mailer for the given server
+func NewSMTPMailer(cfg SMTPConfig) (*SMTPMailer, error) {
+	from, err := mail.ParseAddress(cfg.From)
+	if err != nil {
+		return nil, fmt.Errorf("mailer: invalid sender %q: %w", cfg.From, err)
+	}
+	return &SMTPMailer{cfg: cfg, from: from}, nil
+}
+
+// Send delivers the message
+func (m *SMTPMailer) Send(ctx context.Context, msg *Message) error {
+	if err := validate(msg); err != nil {
+		return err
+	}
+	data, err := m.compose(msg)
+	if err != nil {
+		return err
+	}
+
+	addr := net.JoinHostPort(m.cfg.Host, strconv.Itoa(m.cfg.Port))
+	tlsConfig := &tls.Config{ServerName: m.cfg.Host, MinVersion: tls.VersionTLS12}
+	dialer := &net.Dialer{Timeout: smtpTimeout}
+	var conn net.Conn
+	if m.cfg.Port == 465 {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("mailer: connect to %s: %w", addr, err)
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(smtpTimeout)
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		conn.Close()
+		return err
+	}
+
+	client, err := smtp.NewClient(conn, m.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("mailer: %w", err)
+	}
+	defer client.Close()
+
+	if m.cfg.Port != 465 {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(tlsConfig); err != nil {
+				return fmt.Errorf("mailer: starttls: %w", err)
+			}
+		} else if !m.cfg.Insecure {
+			// Without TLS, messages and their reset links could be read or altered on the way
+			return fmt.Errorf("mailer: %s does not offer STARTTLS", addr)
+		}
+	}
+	if m.cfg.Username != "" {
+		// PlainAuth refuses to send credentials without TLS, except to localhost
+		if err := client.Auth(smtp.PlainAuth("", m.cfg.Username, m.cfg.Password, m.cfg.Host)); err != nil {
+			return fmt.Errorf("mailer: authenticate: %w", err)
+		}
+	}
+	if err := client.Mail(m.from.Address); err != nil {
+		return fmt.Errorf("mailer: %w", err)
+	}
+	if err := client.Rcpt(msg.To); err != nil {
+		return fmt.Errorf("mailer: %w", err)
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("mailer: %w", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("mailer: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("mailer: %w", err)
+	}
+	return client.Quit()
+}
+
+// compose renders the message as a quoted-printable UTF-8 text email
+func (m *SMTPMailer) compose(msg *Message) ([]byte, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	domain := m.from.Address[strings.LastIndex(m.from.Address, "@")+1:]
+
+	var buf bytes.Buffer
+	header := func(name, value string) {
+		buf.WriteString(name + ": " + value + "\r\n")
+	}
+	header("From", m.from.String())
+	header("To", msg.To)
+	header("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("Message-ID", "<"+hex.EncodeToString(id)+"@"+domain+">")
+	header("MIME-Version", "1.0")
+	header("Content-Type", "text/plain; charset=utf-8")
+	header("Content-Transfer-Encoding", "quoted-printable")
+	buf.WriteString("\r\n")
+
+	// The writer ends lines with CRLF as SMTP requires
+	qp := quotedprintable.NewWriter(&buf)
+	if _, err := qp.Write([]byte(msg.Body)); err != nil {
+		return nil, err
+	}
+	if err := qp.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
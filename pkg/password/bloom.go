@@ -0,0 +1,155 @@
+package password
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// bloomMagic starts Bloom filter files, followed by the number of hash
+// functions, the number of bits and the number of passwords added, then the
+// bits
+var bloomMagic = []byte("GCBLOOM1")
+
+// bloomHeaderSize is the size of a Bloom filter file before the bits
+const bloomHeaderSize = 8 + 4 + 8 + 8
+
+// BloomFilter is a compact set of breached password hashes. It can report
+// a password that was never breached as breached, at a rate chosen when it
+// is built, but never misses a breached one. Lookups do not touch the disk.
+type BloomFilter struct {
+	bits   []byte
+	m      uint64 // number of bits
+	k      uint32 // number of hash functions
+	length uint64 // number of passwords added
+}
+
+// NewBloomFilter creates an empty Bloom filter sized for n passwords with
+// the given false positive rate
+func NewBloomFilter(n uint64, falsePositiveRate float64) (*BloomFilter, error) {
+	if falsePositiveRate <= 0 || falsePositiveRate >= 1 {
+		return nil, fmt.Errorf("false positive rate must be between 0 and 1, got %g", falsePositiveRate)
+	}
+	n = max(n, 1)
+	m := uint64(math.Ceil(-float64(n) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2)))
+	m = (m + 7) / 8 * 8
+	k := uint32(max(1, math.Round(float64(m)/float64(n)*math.Ln2)))
+	return &BloomFilter{bits: make([]byte, m/8), m: m, k: k}, nil
+}
+
+// LoadBloomFilter reads a Bloom filter written by WriteTo into memory
+func LoadBloomFilter(path string) (*BloomFilter, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < bloomHeaderSize || !bytes.Equal(data[:len(bloomMagic)], bloomMagic) {
+		return nil, fmt.Errorf("%s is not a Bloom filter file", path)
+	}
+	header := data[len(bloomMagic):bloomHeaderSize]
+	f := &BloomFilter{
+		k:      binary.BigEndian.Uint32(header[0:4]),
+		m:      binary.BigEndian.Uint64(header[4:12]),
+		length: binary.BigEndian.Uint64(header[12:20]),
+		bits:   data[bloomHeaderSize:],
+	}
+	if f.k == 0 || f.m == 0 || uint64(len(f.bits)) != (f.m+7)/8 {
+		return nil, fmt.Errorf("%s is a truncated or corrupt Bloom filter file", path)
+	}
+	return f, nil
+}
+
+// BuildBloomFilter builds a Bloom filter from the passwords of a Pwned
+// Passwords corpus seen at least minCount times, see ReadCorpus. The corpus
+// is read twice, first to count the passwords.
+func BuildBloomFilter(corpus string, minCount int, falsePositiveRate float64) (*BloomFilter, error) {
+	var n uint64
+	if err := ReadCorpus(corpus, minCount, func([sha1.Size]byte) error {
+		n++
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, errors.New("the corpus holds no passwords")
+	}
+
+	f, err := NewBloomFilter(n, falsePositiveRate)
+	if err != nil {
+		return nil, err
+	}
+	if err := ReadCorpus(corpus, minCount, func(sum [sha1.Size]byte) error {
+		f.AddHash(sum)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Add adds a password to the filter
+func (f *BloomFilter) Add(password string) {
+	f.AddHash(sha1.Sum([]byte(password)))
+}
+
+// AddHash adds a password to the filter by its SHA-1 hash
+func (f *BloomFilter) AddHash(sum [sha1.Size]byte) {
+	h1, h2 := bloomHashes(sum)
+	for i := uint64(0); i < uint64(f.k); i++ {
+		bit := (h1 + i*h2) % f.m
+		f.bits[bit/8] |= 1 << (bit % 8)
+	}
+	f.length++
+}
+
+// Contains reports whether the password is probably in the filter
+func (f *BloomFilter) Contains(password string) (bool, error) {
+	h1, h2 := bloomHashes(sha1.Sum([]byte(password)))
+	for i := uint64(0); i < uint64(f.k); i++ {
+		bit := (h1 + i*h2) % f.m
+		if f.bits[bit/8]&(1<<(bit%8)) == 0 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// Len returns the number of passwords added to the filter
+func (f *BloomFilter) Len() uint64 {
+	return f.length
+}
+
+// Size returns the size of the filter's bits in bytes
+func (f *BloomFilter) Size() int {
+	return len(f.bits)
+}
+
+// WriteTo writes the filter in the format LoadBloomFilter reads
+func (f *BloomFilter) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+	header := make([]byte, bloomHeaderSize)
+	copy(header, bloomMagic)
+	binary.BigEndian.PutUint32(header[8:12], f.k)
+	binary.BigEndian.PutUint64(header[12:20], f.m)
+	binary.BigEndian.PutUint64(header[20:28], f.length)
+	if _, err := bw.Write(header); err != nil {
+		return 0, err
+	}
+	if _, err := bw.Write(f.bits); err != nil {
+		return int64(len(header)), err
+	}
+	return int64(len(header) + len(f.bits)), bw.Flush()
+}
+
+// bloomHashes derives the two hashes the bit positions of a password are
+// computed from. SHA-1 output is uniform, so its leading bytes serve
+// directly; the second hash is odd so that positions do not repeat early.
+func bloomHashes(sum [sha1.Size]byte) (uint64, uint64) {
+	return binary.BigEndian.Uint64(sum[0:8]), binary.BigEndian.Uint64(sum[8:16]) | 1
+}
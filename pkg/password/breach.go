@@ -0,0 +1,152 @@
+package password
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// BreachList tells whether passwords appeared in known data breaches
+type BreachList interface {
+	Contains(password string) (bool, error)
+}
+
+// OpenBreachList opens a list of breached passwords at path, which is either
+// a directory holding the SHA-1 "Pwned Passwords" corpus split by hash
+// prefix, or a Bloom filter file built from it with BuildBloomFilter
+func OpenBreachList(path string) (BreachList, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return OpenRangeDir(path)
+	}
+	return LoadBloomFilter(path)
+}
+
+// prefixLength is the number of hex digits of the SHA-1 hash that partition
+// the corpus, as in the range API of Pwned Passwords
+const prefixLength = 5
+
+// RangeDir is the Pwned Passwords corpus as downloaded by range: a directory
+// holding a file per 5 hex digit prefix of the SHA-1 hash, such as
+// 21BD1.txt, whose lines hold the rest of the hash and how often it was
+// seen, as in "0018A45C4D1DEF81644B54AB7F969B88D65:21". Lookups read one
+// file of roughly 30 KB.
+type RangeDir struct {
+	dir string
+}
+
+// OpenRangeDir opens a directory of range files
+func OpenRangeDir(dir string) (*RangeDir, error) {
+	if _, err := os.Stat(filepath.Join(dir, "00000.txt")); err != nil {
+		return nil, fmt.Errorf("%s is not a Pwned Passwords range directory: %w", dir, err)
+	}
+	return &RangeDir{dir: dir}, nil
+}
+
+// Contains reports whether the password's hash is in its range file
+func (d *RangeDir) Contains(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	data, err := os.ReadFile(filepath.Join(d.dir, hash[:prefixLength]+".txt"))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	suffix := []byte(hash[prefixLength:])
+	for len(data) > 0 {
+		line := data
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			line, data = data[:i], data[i+1:]
+		} else {
+			data = nil
+		}
+		if len(line) >= len(suffix) && bytes.EqualFold(line[:len(suffix)], suffix) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ReadCorpus calls fn with the SHA-1 hash of every password in a Pwned
+// Passwords corpus seen at least minCount times. The corpus is either a
+// directory of range files, or a single file of full hashes with their
+// counts, one per line.
+func ReadCorpus(path string, minCount int, fn func(sum [sha1.Size]byte) error) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return readCorpusFile(path, "", minCount, fn)
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		prefix := strings.TrimSuffix(name, ".txt")
+		if entry.IsDir() || prefix == name || len(prefix) != prefixLength || !isHex(prefix) {
+			continue
+		}
+		if err := readCorpusFile(filepath.Join(path, name), prefix, minCount, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readCorpusFile reads the hashes of a corpus file, whose lines hold the
+// hash without prefix
+func readCorpusFile(path, prefix string, minCount int, fn func(sum [sha1.Size]byte) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		hash, count, _ := strings.Cut(line, ":")
+		decoded, err := hex.DecodeString(prefix + hash)
+		if err != nil || len(decoded) != sha1.Size {
+			return fmt.Errorf("%s:%d: not a SHA-1 hash", path, n)
+		}
+		// Lines without a count are always included
+		if seen, err := strconv.Atoi(count); err == nil && seen < minCount {
+			continue
+		}
+		if err := fn([sha1.Size]byte(decoded)); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// isHex reports whether s consists of hex digits
+func isHex(s string) bool {
+	for _, r := range s {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", r) {
+			return false
+		}
+	}
+	return true
+}
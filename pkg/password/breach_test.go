@@ -0,0 +1,224 @@
+package password
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// breached are passwords of a test corpus with how often they were seen
+var breached = map[string]int{
+	"password":      9545824,
+	"hunter2":       40000,
+	"correcthorse":  120,
+	"Tr0ub4dor&3":   3,
+	"once-breached": 1,
+}
+
+// pwnedHash returns the uppercase hex SHA-1 hash of a password, as used by
+// Pwned Passwords
+func pwnedHash(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+// writeRangeDir writes the passwords as a directory of range files, with
+// CRLF line endings like the files served by the range API
+func writeRangeDir(t *testing.T, passwords map[string]int) string {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{"00000": "0005AD76BD555C1D6D771DE417A4B87E4B4:10\r\n"}
+	for password, count := range passwords {
+		hash := pwnedHash(password)
+		files[hash[:prefixLength]] += fmt.Sprintf("%s:%d\r\n", hash[prefixLength:], count)
+	}
+	for prefix, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, prefix+".txt"), []byte(content), 0o600); err != nil {
+			t.Fatalf("write range file: %v", err)
+		}
+	}
+	return dir
+}
+
+func TestRangeDir(t *testing.T) {
+	dir := writeRangeDir(t, breached)
+	list, err := OpenRangeDir(dir)
+	if err != nil {
+		t.Fatalf("open range dir: %v", err)
+	}
+
+	for password := range breached {
+		if found, err := list.Contains(password); err != nil || !found {
+			t.Errorf("breached %q found: %v, %v; want true", password, found, err)
+		}
+	}
+	for _, password := range []string{"Kx9#vTq2mW", "PASSWORD", "hunter3"} {
+		if found, err := list.Contains(password); err != nil || found {
+			t.Errorf("%q found: %v, %v; want false", password, found, err)
+		}
+	}
+
+	// Range files may use lowercase hashes
+	hash := pwnedHash("lowercase")
+	if err := os.WriteFile(filepath.Join(dir, hash[:prefixLength]+".txt"), []byte(strings.ToLower(hash[prefixLength:])+":2\n"), 0o600); err != nil {
+		t.Fatalf("write range file: %v", err)
+	}
+	if found, err := list.Contains("lowercase"); err != nil || !found {
+		t.Errorf("password with a lowercase hash found: %v, %v; want true", found, err)
+	}
+
+	if _, err := OpenRangeDir(t.TempDir()); err == nil {
+		t.Error("opened an empty directory as a range dir")
+	}
+}
+
+func TestBloomFilter(t *testing.T) {
+	const rate = 0.01
+	filter, err := NewBloomFilter(1000, rate)
+	if err != nil {
+		t.Fatalf("new Bloom filter: %v", err)
+	}
+	for i := 0; i < 1000; i++ {
+		filter.Add(fmt.Sprintf("breached-%d", i))
+	}
+	if filter.Len() != 1000 {
+		t.Errorf("filter holds %d passwords, want 1000", filter.Len())
+	}
+
+	// A Bloom filter never misses a password that was added
+	for i := 0; i < 1000; i++ {
+		if found, _ := filter.Contains(fmt.Sprintf("breached-%d", i)); !found {
+			t.Fatalf("added password breached-%d not found", i)
+		}
+	}
+	falsePositives := 0
+	for i := 0; i < 10000; i++ {
+		if found, _ := filter.Contains(fmt.Sprintf("never-breached-%d", i)); found {
+			falsePositives++
+		}
+	}
+	if got := float64(falsePositives) / 10000; got > 3*rate {
+		t.Errorf("false positive rate %.4f, want about %.2f", got, rate)
+	}
+
+	// The filter reads back from its file as written
+	path := filepath.Join(t.TempDir(), "breached.bloom")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("create file: %v", err)
+	}
+	if _, err := filter.WriteTo(f); err != nil {
+		t.Fatalf("write filter: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("close file: %v", err)
+	}
+	loaded, err := LoadBloomFilter(path)
+	if err != nil {
+		t.Fatalf("load filter: %v", err)
+	}
+	if loaded.Len() != filter.Len() || loaded.Size() != filter.Size() {
+		t.Errorf("loaded filter of %d passwords in %d bytes, want %d in %d", loaded.Len(), loaded.Size(), filter.Len(), filter.Size())
+	}
+	if found, _ := loaded.Contains("breached-42"); !found {
+		t.Error("added password not found in the loaded filter")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read filter: %v", err)
+	}
+	truncated := filepath.Join(t.TempDir(), "truncated.bloom")
+	if err := os.WriteFile(truncated, data[:len(data)-1], 0o600); err != nil {
+		t.Fatalf("write filter: %v", err)
+	}
+	if _, err := LoadBloomFilter(truncated); err == nil {
+		t.Error("loaded a truncated filter")
+	}
+	other := filepath.Join(t.TempDir(), "other.txt")
+	if err := os.WriteFile(other, []byte("not a Bloom filter, but long enough to hold a header"), 0o600); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	if _, err := LoadBloomFilter(other); err == nil {
+		t.Error("loaded a file that is not a Bloom filter")
+	}
+}
+
+func TestBuildBloomFilter(t *testing.T) {
+	// The corpus is either a range directory or one file of full hashes
+	file := filepath.Join(t.TempDir(), "pwned-passwords-sha1.txt")
+	var lines []string
+	for password, count := range breached {
+		lines = append(lines, fmt.Sprintf("%s:%d", pwnedHash(password), count))
+	}
+	if err := os.WriteFile(file, []byte(strings.Join(lines, "\n")+"\n"), 0o600); err != nil {
+		t.Fatalf("write corpus: %v", err)
+	}
+
+	for name, corpus := range map[string]string{"range dir": writeRangeDir(t, breached), "file": file} {
+		t.Run(name, func(t *testing.T) {
+			filter, err := BuildBloomFilter(corpus, 10, 0.001)
+			if err != nil {
+				t.Fatalf("build filter: %v", err)
+			}
+			for password, count := range breached {
+				found, _ := filter.Contains(password)
+				if count >= 10 && !found {
+					t.Errorf("%q seen %d times not in the filter", password, count)
+				}
+			}
+			// Passwords seen fewer times are left out; the filter has no
+			// false positives at this size in practice
+			if found, _ := filter.Contains("once-breached"); found {
+				t.Error("password seen once is in the filter")
+			}
+		})
+	}
+
+	corrupt := filepath.Join(t.TempDir(), "corrupt.txt")
+	if err := os.WriteFile(corrupt, []byte("not-a-hash:3\n"), 0o600); err != nil {
+		t.Fatalf("write corpus: %v", err)
+	}
+	if _, err := BuildBloomFilter(corrupt, 1, 0.001); err == nil || !strings.Contains(err.Error(), ":1:") {
+		t.Errorf("build from a corrupt corpus: %v, want the line reported", err)
+	}
+}
+
+func TestOpenBreachList(t *testing.T) {
+	dir := writeRangeDir(t, breached)
+	list, err := OpenBreachList(dir)
+	if err != nil {
+		t.Fatalf("open range dir: %v", err)
+	}
+	if _, ok := list.(*RangeDir); !ok {
+		t.Errorf("directory opened as %T, want *RangeDir", list)
+	}
+
+	filter, err := BuildBloomFilter(dir, 1, 0.001)
+	if err != nil {
+		t.Fatalf("build filter: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "breached.bloom")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("create file: %v", err)
+	}
+	if _, err := filter.WriteTo(f); err != nil {
+		t.Fatalf("write filter: %v", err)
+	}
+	f.Close()
+	if list, err = OpenBreachList(path); err != nil {
+		t.Fatalf("open filter: %v", err)
+	}
+	if _, ok := list.(*BloomFilter); !ok {
+		t.Errorf("file opened as %T, want *BloomFilter", list)
+	}
+
+	if _, err := OpenBreachList(filepath.Join(dir, "missing")); err == nil {
+		t.Error("opened a missing path")
+	}
+}
@@ -0,0 +1,76 @@
+package password
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+)
+
+//go:embed common.txt
+var commonList string
+
+// CommonList is a set of commonly used passwords
+type CommonList struct {
+	set map[string]bool
+}
+
+// builtinCommon is the built-in list of commonly used passwords
+var builtinCommon = func() *CommonList {
+	l := &CommonList{set: make(map[string]bool)}
+	l.add(commonList)
+	return l
+}()
+
+// LoadCommonList reads a file of commonly used passwords, one per line, and
+// returns them along with those of the built-in list. Lines starting with #
+// are ignored, and case does not matter.
+func LoadCommonList(path string) (*CommonList, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	l := &CommonList{set: make(map[string]bool, len(builtinCommon.set))}
+	for password := range builtinCommon.set {
+		l.set[password] = true
+	}
+	if l.add(string(data)) == 0 {
+		return nil, fmt.Errorf("%s lists no passwords", path)
+	}
+	return l, nil
+}
+
+// add adds the passwords listed in text and returns how many lines held one
+func (l *CommonList) add(text string) int {
+	n := 0
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			l.set[strings.ToLower(line)] = true
+			n++
+		}
+	}
+	return n
+}
+
+// Len returns the number of passwords on the list
+func (l *CommonList) Len() int {
+	return len(l.set)
+}
+
+// Contains reports whether the password is on the list, ignoring case and
+// trailing digits and symbols
+func (l *CommonList) Contains(password string) bool {
+	lower := strings.ToLower(password)
+	if l.set[lower] {
+		return true
+	}
+	base := strings.TrimRightFunc(lower, func(r rune) bool { return !unicode.IsLetter(r) })
+	return base != "" && l.set[base]
+}
+
+// IsCommon reports whether the password is on the built-in list of commonly
+// used passwords, ignoring case and trailing digits and symbols
+func IsCommon(password string) bool {
+	return builtinCommon.Contains(password)
+}
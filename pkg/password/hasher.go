@@ -0,0 +1,283 @@
+package password
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Hash algorithms new passwords can be hashed with
+const (
+	Argon2id = "argon2id"
+	Bcrypt   = "bcrypt"
+)
+
+// BcryptMaxBytes is the length in bytes of the longest password bcrypt can
+// hash. Longer passwords are refused rather than truncated.
+const BcryptMaxBytes = 72
+
+const (
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+)
+
+// Upper limits of the cost of hashes the hasher makes or verifies. Verifying
+// a hash takes as much memory and time as its parameters ask for, so that
+// one bad hash, such as an imported one, could otherwise take down the
+// service every time its user signs in.
+const (
+	MaxArgon2Memory      = 1 << 20 // in KiB, 1 GiB
+	MaxArgon2Iterations  = 10
+	MaxArgon2Parallelism = 16
+	MaxBcryptCost        = 16
+)
+
+// ErrUnsupportedHash is returned for password hashes in a format the hasher
+// does not know
+var ErrUnsupportedHash = errors.New("unsupported password hash format")
+
+// ErrPepperMismatch is returned for password hashes made with a pepper other
+// than the configured one
+var ErrPepperMismatch = errors.New("password hash was made with another pepper")
+
+// Argon2Params are the cost parameters of argon2id
+type Argon2Params struct {
+	Memory      uint32 // in KiB
+	Iterations  uint32
+	Parallelism uint8
+}
+
+// withinLimits reports whether the parameters are at most the Max limits
+func (p Argon2Params) withinLimits() bool {
+	return p.Memory <= MaxArgon2Memory && p.Iterations <= MaxArgon2Iterations && p.Parallelism <= MaxArgon2Parallelism
+}
+
+// HasherConfig sets how new passwords are hashed
+type HasherConfig struct {
+	Algorithm  string // Argon2id or Bcrypt
+	Argon2     Argon2Params
+	BcryptCost int
+	// Pepper is a secret kept out of the database that argon2id hashes are
+	// keyed with, so that leaked hashes cannot be cracked without it. Hashes
+	// record which pepper they were made with.
+	Pepper string
+	// Firebase holds the parameters of imported Firebase scrypt hashes, see
+	// Import
+	Firebase *FirebaseScrypt
+}
+
+// Hasher hashes passwords with the configured algorithm and verifies hashes
+// of every supported algorithm. Hashes are self-describing: argon2id hashes
+// use the PHC string format, as in
+// $argon2id$v=19$m=19456,t=2,p=1$<salt>$<hash>, and bcrypt hashes the usual
+// $2b$<cost>$ format. Hashes made with another algorithm or with other
+// parameters keep verifying, and NeedsRehash reports them so that they can be
+// upgraded once the password is known. Hashes imported from other systems
+// are verified the same way, see Import.
+type Hasher struct {
+	cfg      HasherConfig
+	pepperID string
+}
+
+// NewHasher creates a hasher
+func NewHasher(cfg HasherConfig) (*Hasher, error) {
+	switch cfg.Algorithm {
+	case Argon2id:
+		if cfg.Argon2.Memory < 8*uint32(cfg.Argon2.Parallelism) || cfg.Argon2.Iterations < 1 || cfg.Argon2.Parallelism < 1 || !cfg.Argon2.withinLimits() {
+			return nil, fmt.Errorf("invalid argon2id parameters m=%d,t=%d,p=%d", cfg.Argon2.Memory, cfg.Argon2.Iterations, cfg.Argon2.Parallelism)
+		}
+	case Bcrypt:
+		if cfg.BcryptCost < bcrypt.MinCost || cfg.BcryptCost > MaxBcryptCost {
+			return nil, fmt.Errorf("bcrypt cost must be between %d and %d, got %d", bcrypt.MinCost, MaxBcryptCost, cfg.BcryptCost)
+		}
+		if cfg.Pepper != "" {
+			return nil, errors.New("a pepper is only supported with argon2id")
+		}
+	default:
+		return nil, fmt.Errorf("unknown password hash algorithm %q", cfg.Algorithm)
+	}
+
+	h := &Hasher{cfg: cfg}
+	if cfg.Pepper != "" {
+		sum := sha256.Sum256([]byte("gc_auth_service pepper id:" + cfg.Pepper))
+		h.pepperID = base64.RawStdEncoding.EncodeToString(sum[:6])
+	}
+	return h, nil
+}
+
+// MaxBytes returns the length in bytes of the longest password the hasher
+// can hash, or 0 if there is no limit
+func (h *Hasher) MaxBytes() int {
+	if h.cfg.Algorithm == Bcrypt {
+		return BcryptMaxBytes
+	}
+	return 0
+}
+
+// Hash hashes a password with the configured algorithm and parameters
+func (h *Hasher) Hash(password string) (string, error) {
+	if h.cfg.Algorithm == Bcrypt {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), h.cfg.BcryptCost)
+		return string(hash), err
+	}
+
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	p := h.cfg.Argon2
+	key := argon2.IDKey(h.pepper(password, h.pepperID), salt, p.Iterations, p.Memory, p.Parallelism, argon2KeyLength)
+
+	params := fmt.Sprintf("m=%d,t=%d,p=%d", p.Memory, p.Iterations, p.Parallelism)
+	if h.pepperID != "" {
+		params += ",keyid=" + h.pepperID
+	}
+	return fmt.Sprintf("$%s$v=%d$%s$%s$%s", Argon2id, argon2.Version, params,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// Verify reports whether the password matches the hash. It returns
+// ErrUnsupportedHash for hashes it cannot read or whose cost exceeds the
+// limits, and ErrPepperMismatch for hashes made with another pepper.
+func (h *Hasher) Verify(password, encoded string) (bool, error) {
+	if isBcrypt(encoded) {
+		if err := checkBcrypt(encoded); err != nil {
+			return false, err
+		}
+		if len(password) > BcryptMaxBytes {
+			return false, nil
+		}
+		err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+	if !strings.HasPrefix(encoded, "$"+Argon2id+"$") {
+		return h.verifyLegacy(password, encoded)
+	}
+
+	a, err := parseArgon2(encoded)
+	if err != nil {
+		return false, err
+	}
+	if a.keyID != "" && a.keyID != h.pepperID {
+		return false, ErrPepperMismatch
+	}
+	key := argon2.IDKey(h.pepper(password, a.keyID), a.salt, a.Iterations, a.Memory, a.Parallelism, uint32(len(a.key)))
+	return subtle.ConstantTimeCompare(key, a.key) == 1, nil
+}
+
+// NeedsRehash reports whether a hash was made with another algorithm, other
+// parameters or another pepper than the hasher now uses
+func (h *Hasher) NeedsRehash(encoded string) bool {
+	if isBcrypt(encoded) {
+		if h.cfg.Algorithm != Bcrypt {
+			return true
+		}
+		cost, err := bcrypt.Cost([]byte(encoded))
+		return err != nil || cost != h.cfg.BcryptCost
+	}
+
+	a, err := parseArgon2(encoded)
+	if err != nil || h.cfg.Algorithm != Argon2id {
+		return true
+	}
+	return a.Argon2Params != h.cfg.Argon2 || a.keyID != h.pepperID ||
+		len(a.salt) < argon2SaltLength || len(a.key) != argon2KeyLength
+}
+
+// pepper keys the password with the pepper when the hash records one
+func (h *Hasher) pepper(password, keyID string) []byte {
+	if keyID == "" {
+		return []byte(password)
+	}
+	mac := hmac.New(sha256.New, []byte(h.cfg.Pepper))
+	mac.Write([]byte(password))
+	return mac.Sum(nil)
+}
+
+// isBcrypt reports whether a hash is in the bcrypt format
+func isBcrypt(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") || strings.HasPrefix(encoded, "$2b$") || strings.HasPrefix(encoded, "$2y$")
+}
+
+// checkBcrypt returns ErrUnsupportedHash for bcrypt hashes that cannot be
+// read or whose cost exceeds MaxBcryptCost
+func checkBcrypt(encoded string) error {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	if err != nil {
+		return ErrUnsupportedHash
+	}
+	if cost > MaxBcryptCost {
+		return fmt.Errorf("%w: bcrypt cost %d exceeds %d", ErrUnsupportedHash, cost, MaxBcryptCost)
+	}
+	return nil
+}
+
+// argon2Hash is a parsed argon2id hash
+type argon2Hash struct {
+	Argon2Params
+	keyID string
+	salt  []byte
+	key   []byte
+}
+
+// parseArgon2 parses an argon2id hash in the PHC string format, refusing
+// hashes whose parameters exceed the Max limits
+func parseArgon2(encoded string) (*argon2Hash, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[0] != "" || parts[1] != Argon2id || parts[2] != "v="+strconv.Itoa(argon2.Version) {
+		return nil, ErrUnsupportedHash
+	}
+
+	a := &argon2Hash{}
+	for _, param := range strings.Split(parts[3], ",") {
+		name, value, _ := strings.Cut(param, "=")
+		var n uint64
+		var err error
+		switch name {
+		case "m":
+			n, err = strconv.ParseUint(value, 10, 32)
+			a.Memory = uint32(n)
+		case "t":
+			n, err = strconv.ParseUint(value, 10, 32)
+			a.Iterations = uint32(n)
+		case "p":
+			n, err = strconv.ParseUint(value, 10, 8)
+			a.Parallelism = uint8(n)
+		case "keyid":
+			a.keyID = value
+		default:
+			err = ErrUnsupportedHash
+		}
+		if err != nil {
+			return nil, ErrUnsupportedHash
+		}
+	}
+	if a.Memory == 0 || a.Iterations == 0 || a.Parallelism == 0 {
+		return nil, ErrUnsupportedHash
+	}
+	if !a.withinLimits() {
+		return nil, fmt.Errorf("%w: argon2id parameters m=%d,t=%d,p=%d exceed m=%d,t=%d,p=%d", ErrUnsupportedHash,
+			a.Memory, a.Iterations, a.Parallelism, MaxArgon2Memory, MaxArgon2Iterations, MaxArgon2Parallelism)
+	}
+
+	var err error
+	if a.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return nil, ErrUnsupportedHash
+	}
+	if a.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(a.key) == 0 {
+		return nil, ErrUnsupportedHash
+	}
+	return a, nil
+}
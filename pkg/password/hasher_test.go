@@ -0,0 +1,179 @@
+package password
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestHasherRoundTrip(t *testing.T) {
+	pepper := strings.Repeat("p", 32)
+	for name, cfg := range map[string]HasherConfig{
+		"argon2id":        {Algorithm: Argon2id, Argon2: testArgon2},
+		"argon2id pepper": {Algorithm: Argon2id, Argon2: testArgon2, Pepper: pepper},
+		"bcrypt":          {Algorithm: Bcrypt, BcryptCost: 4},
+	} {
+		t.Run(name, func(t *testing.T) {
+			h := newTestHasher(t, cfg)
+			hash, err := h.Hash("correct horse")
+			if err != nil {
+				t.Fatalf("hash: %v", err)
+			}
+			if other, _ := h.Hash("correct horse"); other == hash {
+				t.Error("two hashes of the same password are the same")
+			}
+			if ok, err := h.Verify("correct horse", hash); err != nil || !ok {
+				t.Errorf("verify the password: %v, %v; want true", ok, err)
+			}
+			if ok, err := h.Verify("correct horse!", hash); err != nil || ok {
+				t.Errorf("verify another password: %v, %v; want false", ok, err)
+			}
+			if h.NeedsRehash(hash) {
+				t.Error("fresh hash needs a rehash")
+			}
+		})
+	}
+}
+
+func TestArgon2Format(t *testing.T) {
+	hash, err := newTestHasher(t, HasherConfig{Algorithm: Argon2id, Argon2: testArgon2}).Hash("secret")
+	if err != nil {
+		t.Fatalf("hash: %v", err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=64,t=1,p=1$") {
+		t.Errorf("hash %s is not in the PHC format", hash)
+	}
+
+	peppered, err := newTestHasher(t, HasherConfig{Algorithm: Argon2id, Argon2: testArgon2, Pepper: strings.Repeat("p", 32)}).Hash("secret")
+	if err != nil {
+		t.Fatalf("hash: %v", err)
+	}
+	if !strings.Contains(peppered, ",keyid=") {
+		t.Errorf("peppered hash %s does not record its pepper", peppered)
+	}
+	if strings.Contains(peppered, strings.Repeat("p", 8)) {
+		t.Errorf("peppered hash %s contains the pepper", peppered)
+	}
+}
+
+// TestArgon2Vector verifies a hash from the test vectors of the argon2
+// reference implementation
+func TestArgon2Vector(t *testing.T) {
+	h := newTestHasher(t, HasherConfig{Algorithm: Argon2id, Argon2: testArgon2})
+	const hash = "$argon2id$v=19$m=65536,t=2,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc"
+	if ok, err := h.Verify("password", hash); err != nil || !ok {
+		t.Errorf("verify the reference hash: %v, %v; want true", ok, err)
+	}
+	if ok, err := h.Verify("passwore", hash); err != nil || ok {
+		t.Errorf("verify another password against the reference hash: %v, %v; want false", ok, err)
+	}
+}
+
+func TestPepper(t *testing.T) {
+	plain := newTestHasher(t, HasherConfig{Algorithm: Argon2id, Argon2: testArgon2})
+	peppered := newTestHasher(t, HasherConfig{Algorithm: Argon2id, Argon2: testArgon2, Pepper: strings.Repeat("p", 32)})
+	other := newTestHasher(t, HasherConfig{Algorithm: Argon2id, Argon2: testArgon2, Pepper: strings.Repeat("q", 32)})
+
+	hash, err := peppered.Hash("secret")
+	if err != nil {
+		t.Fatalf("hash: %v", err)
+	}
+	for name, h := range map[string]*Hasher{"without a pepper": plain, "with another pepper": other} {
+		if ok, err := h.Verify("secret", hash); ok || !errors.Is(err, ErrPepperMismatch) {
+			t.Errorf("verify %s: %v, %v; want %v", name, ok, err, ErrPepperMismatch)
+		}
+		if !h.NeedsRehash(hash) {
+			t.Errorf("hash needs no rehash %s", name)
+		}
+	}
+
+	// Adding a pepper keeps hashes made without one verifying, and upgrades
+	// them at the next login
+	unpeppered, err := plain.Hash("secret")
+	if err != nil {
+		t.Fatalf("hash: %v", err)
+	}
+	if ok, err := peppered.Verify("secret", unpeppered); err != nil || !ok {
+		t.Errorf("verify a hash without a pepper: %v, %v; want true", ok, err)
+	}
+	if !peppered.NeedsRehash(unpeppered) {
+		t.Error("hash without a pepper needs no rehash")
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	current := HasherConfig{Algorithm: Argon2id, Argon2: testArgon2}
+	h := newTestHasher(t, current)
+
+	hashes := make(map[string]string)
+	for name, cfg := range map[string]HasherConfig{
+		"current":          current,
+		"less memory":      {Algorithm: Argon2id, Argon2: Argon2Params{Memory: 32, Iterations: 1, Parallelism: 1}},
+		"more iterations":  {Algorithm: Argon2id, Argon2: Argon2Params{Memory: 64, Iterations: 2, Parallelism: 1}},
+		"more parallelism": {Algorithm: Argon2id, Argon2: Argon2Params{Memory: 64, Iterations: 1, Parallelism: 2}},
+		"bcrypt":           {Algorithm: Bcrypt, BcryptCost: 4},
+	} {
+		hash, err := newTestHasher(t, cfg).Hash("secret")
+		if err != nil {
+			t.Fatalf("hash %s: %v", name, err)
+		}
+		// Hashes made with other settings keep verifying
+		if ok, err := h.Verify("secret", hash); err != nil || !ok {
+			t.Errorf("verify %s hash: %v, %v; want true", name, ok, err)
+		}
+		hashes[name] = hash
+	}
+	hashes["unknown format"] = "plaintext"
+
+	for name, hash := range hashes {
+		if got, want := h.NeedsRehash(hash), name != "current"; got != want {
+			t.Errorf("%s hash needs rehash = %v, want %v", name, got, want)
+		}
+	}
+
+	// A bcrypt hasher upgrades bcrypt hashes of another cost and argon2id hashes
+	b := newTestHasher(t, HasherConfig{Algorithm: Bcrypt, BcryptCost: 5})
+	for name, want := range map[string]bool{"bcrypt": true, "current": true} {
+		if got := b.NeedsRehash(hashes[name]); got != want {
+			t.Errorf("bcrypt hasher: %s hash needs rehash = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestBcryptMaxBytes(t *testing.T) {
+	h := newTestHasher(t, HasherConfig{Algorithm: Bcrypt, BcryptCost: 4})
+	if h.MaxBytes() != BcryptMaxBytes {
+		t.Errorf("max bytes %d, want %d", h.MaxBytes(), BcryptMaxBytes)
+	}
+	long := strings.Repeat("a", BcryptMaxBytes)
+	hash, err := h.Hash(long)
+	if err != nil {
+		t.Fatalf("hash: %v", err)
+	}
+	// Longer passwords are not truncated to match
+	if ok, _ := h.Verify(long+"b", hash); ok {
+		t.Error("password longer than bcrypt takes matched its truncation")
+	}
+	if _, err := h.Hash(long + "b"); err == nil {
+		t.Error("hashed a password longer than bcrypt takes")
+	}
+	if argon := newTestHasher(t, HasherConfig{Algorithm: Argon2id, Argon2: testArgon2}); argon.MaxBytes() != 0 {
+		t.Errorf("argon2id max bytes %d, want none", argon.MaxBytes())
+	}
+}
+
+func TestNewHasherLimits(t *testing.T) {
+	for name, cfg := range map[string]HasherConfig{
+		"unknown algorithm":    {Algorithm: "md5"},
+		"no iterations":        {Algorithm: Argon2id, Argon2: Argon2Params{Memory: 64, Parallelism: 1}},
+		"too little memory":    {Algorithm: Argon2id, Argon2: Argon2Params{Memory: 8, Iterations: 1, Parallelism: 2}},
+		"too much memory":      {Algorithm: Argon2id, Argon2: Argon2Params{Memory: MaxArgon2Memory + 1, Iterations: 1, Parallelism: 1}},
+		"bcrypt cost too low":  {Algorithm: Bcrypt, BcryptCost: 3},
+		"bcrypt cost too high": {Algorithm: Bcrypt, BcryptCost: MaxBcryptCost + 1},
+		"bcrypt pepper":        {Algorithm: Bcrypt, BcryptCost: 4, Pepper: strings.Repeat("p", 32)},
+	} {
+		if _, err := NewHasher(cfg); err == nil {
+			t.Errorf("%s: created a hasher", name)
+		}
+	}
+}
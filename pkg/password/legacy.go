@@ -0,0 +1,238 @@
+package password
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Formats of password hashes exported from other systems that Import
+// accepts
+const (
+	FormatArgon2id       = "argon2id"
+	FormatBcrypt         = "bcrypt"
+	FormatFirebaseScrypt = "firebase_scrypt"
+	FormatDjangoPBKDF2   = "django_pbkdf2"
+	FormatSaltedSHA256   = "sha256_salted"
+)
+
+// ImportFormats lists the formats Import accepts
+var ImportFormats = []string{FormatArgon2id, FormatBcrypt, FormatFirebaseScrypt, FormatDjangoPBKDF2, FormatSaltedSHA256}
+
+// Hash identifiers of imported hashes that are not in a format of their own
+const (
+	firebaseScryptID = "firebase-scrypt"
+	saltedSHA256ID   = "sha256-salted"
+	djangoPBKDF2ID   = "pbkdf2_sha256"
+)
+
+// MaxPBKDF2Iterations is the highest iteration count of Django hashes the
+// hasher imports or verifies, several times what current Django versions use
+const MaxPBKDF2Iterations = 10_000_000
+
+// FirebaseScrypt holds the password hash parameters of a Firebase project,
+// shown in the console of the project when exporting its users
+type FirebaseScrypt struct {
+	SignerKey     []byte
+	SaltSeparator []byte
+	Rounds        int
+	MemCost       int
+}
+
+// ImportedHash is a password hash exported from another system
+type ImportedHash struct {
+	Format string // one of ImportFormats
+	Hash   string
+	// Salt is the base64 salt of Firebase scrypt hashes, and the salt of
+	// salted SHA-256 hashes as is
+	Salt string
+	// SaltPosition tells whether the salt of a salted SHA-256 hash comes
+	// before the password, "prefix" (the default), or after it, "suffix"
+	SaltPosition string
+}
+
+// Import converts a password hash exported from another system into a hash
+// the hasher verifies. Hashes in formats other than the configured one keep
+// working until their user signs in, when they are replaced with a hash in
+// the current format.
+//
+// Django hashes are taken as exported, as in
+// pbkdf2_sha256$<iterations>$<salt>$<hash>. Firebase hashes are base64, and
+// need the FirebaseScrypt parameters of the hasher. Salted SHA-256 hashes
+// are hex or base64.
+//
+// Hashes whose cost exceeds the Max limits are refused, as are argon2id
+// hashes keyed with a pepper other than the configured one.
+func (h *Hasher) Import(imported ImportedHash) (string, error) {
+	switch imported.Format {
+	case FormatArgon2id:
+		a, err := parseArgon2(imported.Hash)
+		if err != nil {
+			return "", err
+		}
+		if a.keyID != "" && a.keyID != h.pepperID {
+			return "", fmt.Errorf("%w: unknown keyid %q", ErrPepperMismatch, a.keyID)
+		}
+		return imported.Hash, nil
+	case FormatBcrypt:
+		if !isBcrypt(imported.Hash) {
+			return "", ErrUnsupportedHash
+		}
+		if err := checkBcrypt(imported.Hash); err != nil {
+			return "", err
+		}
+		return imported.Hash, nil
+	case FormatDjangoPBKDF2:
+		if _, err := parseDjango(imported.Hash); err != nil {
+			return "", err
+		}
+		return imported.Hash, nil
+	case FormatFirebaseScrypt:
+		if h.cfg.Firebase == nil {
+			return "", errors.New("firebase_scrypt hashes need the Firebase hash parameters to be configured")
+		}
+		salt, err := base64.StdEncoding.DecodeString(imported.Salt)
+		if err != nil || len(salt) == 0 {
+			return "", errors.New("salt must be base64")
+		}
+		hash, err := base64.StdEncoding.DecodeString(imported.Hash)
+		if err != nil || len(hash) != len(h.cfg.Firebase.SignerKey) {
+			return "", errors.New("hash must be base64 and as long as the signer key")
+		}
+		return fmt.Sprintf("$%s$m=%d,r=%d$%s$%s", firebaseScryptID, h.cfg.Firebase.MemCost, h.cfg.Firebase.Rounds,
+			base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(hash)), nil
+	case FormatSaltedSHA256:
+		position := imported.SaltPosition
+		if position == "" {
+			position = "prefix"
+		}
+		if position != "prefix" && position != "suffix" {
+			return "", fmt.Errorf("salt position must be prefix or suffix, got %q", position)
+		}
+		hash, err := hex.DecodeString(imported.Hash)
+		if err != nil {
+			hash, err = base64.StdEncoding.DecodeString(imported.Hash)
+		}
+		if err != nil || len(hash) != sha256.Size {
+			return "", errors.New("hash must be a hex or base64 SHA-256 digest")
+		}
+		return fmt.Sprintf("$%s$pos=%s$%s$%s", saltedSHA256ID, position,
+			base64.RawStdEncoding.EncodeToString([]byte(imported.Salt)), base64.RawStdEncoding.EncodeToString(hash)), nil
+	default:
+		return "", fmt.Errorf("unknown hash format %q, expected one of %s", imported.Format, strings.Join(ImportFormats, ", "))
+	}
+}
+
+// verifyLegacy verifies a password against an imported hash that is neither
+// argon2id nor bcrypt
+func (h *Hasher) verifyLegacy(password, encoded string) (bool, error) {
+	switch {
+	case strings.HasPrefix(encoded, djangoPBKDF2ID+"$"):
+		d, err := parseDjango(encoded)
+		if err != nil {
+			return false, err
+		}
+		key := pbkdf2.Key([]byte(password), []byte(d.salt), d.iterations, sha256.Size, sha256.New)
+		return subtle.ConstantTimeCompare(key, d.hash) == 1, nil
+	case strings.HasPrefix(encoded, "$"+firebaseScryptID+"$"):
+		return h.verifyFirebase(password, encoded)
+	case strings.HasPrefix(encoded, "$"+saltedSHA256ID+"$"):
+		parts := strings.Split(encoded, "$")
+		if len(parts) != 5 || (parts[2] != "pos=prefix" && parts[2] != "pos=suffix") {
+			return false, ErrUnsupportedHash
+		}
+		salt, err := base64.RawStdEncoding.DecodeString(parts[3])
+		if err != nil {
+			return false, ErrUnsupportedHash
+		}
+		hash, err := base64.RawStdEncoding.DecodeString(parts[4])
+		if err != nil {
+			return false, ErrUnsupportedHash
+		}
+		input := string(salt) + password
+		if parts[2] == "pos=suffix" {
+			input = password + string(salt)
+		}
+		sum := sha256.Sum256([]byte(input))
+		return subtle.ConstantTimeCompare(sum[:], hash) == 1, nil
+	default:
+		return false, ErrUnsupportedHash
+	}
+}
+
+// verifyFirebase verifies a password against a Firebase scrypt hash, which
+// is the project's signer key encrypted with AES-256-CTR under a scrypt key
+// of the password
+func (h *Hasher) verifyFirebase(password, encoded string) (bool, error) {
+	if h.cfg.Firebase == nil {
+		return false, errors.New("the Firebase hash parameters are not configured")
+	}
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 5 {
+		return false, ErrUnsupportedHash
+	}
+	var memCost, rounds int
+	if _, err := fmt.Sscanf(parts[2], "m=%d,r=%d", &memCost, &rounds); err != nil || memCost < 1 || memCost > 20 || rounds < 1 {
+		return false, ErrUnsupportedHash
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return false, ErrUnsupportedHash
+	}
+	hash, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, ErrUnsupportedHash
+	}
+
+	fb := h.cfg.Firebase
+	key, err := scrypt.Key([]byte(password), append(salt, fb.SaltSeparator...), 1<<memCost, rounds, 1, 32)
+	if err != nil {
+		return false, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return false, err
+	}
+	derived := make([]byte, len(fb.SignerKey))
+	cipher.NewCTR(block, make([]byte, aes.BlockSize)).XORKeyStream(derived, fb.SignerKey)
+	return subtle.ConstantTimeCompare(derived, hash) == 1, nil
+}
+
+// djangoHash is a parsed Django PBKDF2-SHA256 hash
+type djangoHash struct {
+	iterations int
+	salt       string
+	hash       []byte
+}
+
+// parseDjango parses a hash in the Django format
+// pbkdf2_sha256$<iterations>$<salt>$<base64 hash>, refusing hashes with more
+// than MaxPBKDF2Iterations
+func parseDjango(encoded string) (*djangoHash, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 4 || parts[0] != djangoPBKDF2ID {
+		return nil, ErrUnsupportedHash
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations < 1 || parts[2] == "" {
+		return nil, ErrUnsupportedHash
+	}
+	if iterations > MaxPBKDF2Iterations {
+		return nil, fmt.Errorf("%w: %d PBKDF2 iterations exceed %d", ErrUnsupportedHash, iterations, MaxPBKDF2Iterations)
+	}
+	hash, err := base64.StdEncoding.DecodeString(parts[3])
+	if err != nil || len(hash) != sha256.Size {
+		return nil, ErrUnsupportedHash
+	}
+	return &djangoHash{iterations: iterations, salt: parts[2], hash: hash}, nil
+}
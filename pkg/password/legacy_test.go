@@ -0,0 +1,164 @@
+package password
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func newTestHasher(t *testing.T, cfg HasherConfig) *Hasher {
+	t.Helper()
+	h, err := NewHasher(cfg)
+	if err != nil {
+		t.Fatalf("new hasher: %v", err)
+	}
+	return h
+}
+
+// testArgon2 are cheap argon2id parameters for tests
+var testArgon2 = Argon2Params{Memory: 64, Iterations: 1, Parallelism: 1}
+
+func TestImportCostLimits(t *testing.T) {
+	h := newTestHasher(t, HasherConfig{Algorithm: Argon2id, Argon2: testArgon2})
+	bcryptHash, err := newTestHasher(t, HasherConfig{Algorithm: Bcrypt, BcryptCost: 4}).Hash("secret")
+	if err != nil {
+		t.Fatalf("hash: %v", err)
+	}
+	key := "Y9lHu7UH0EemGPaEZmGcEdvsKRBVnqCv5v4Z5QiQyjw" // 32 bytes
+
+	tests := []struct {
+		name string
+		hash ImportedHash
+	}{
+		{"argon2id memory", ImportedHash{Format: FormatArgon2id, Hash: "$argon2id$v=19$m=4294967295,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA$" + key}},
+		{"argon2id iterations", ImportedHash{Format: FormatArgon2id, Hash: "$argon2id$v=19$m=64,t=1000,p=1$c2FsdHNhbHRzYWx0c2FsdA$" + key}},
+		{"argon2id parallelism", ImportedHash{Format: FormatArgon2id, Hash: "$argon2id$v=19$m=4096,t=1,p=255$c2FsdHNhbHRzYWx0c2FsdA$" + key}},
+		{"bcrypt cost", ImportedHash{Format: FormatBcrypt, Hash: strings.Replace(bcryptHash, "$04$", "$31$", 1)}},
+		{"django iterations", ImportedHash{Format: FormatDjangoPBKDF2, Hash: "pbkdf2_sha256$100000000$salt$" + key + "="}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := h.Import(tt.hash); !errors.Is(err, ErrUnsupportedHash) || !strings.Contains(err.Error(), "exceed") {
+				t.Errorf("import: %v, want the cost refused", err)
+			}
+			// Hashes that made it into the database some other way are not
+			// verified either
+			if _, err := h.Verify("secret", tt.hash.Hash); !errors.Is(err, ErrUnsupportedHash) {
+				t.Errorf("verify: %v, want %v", err, ErrUnsupportedHash)
+			}
+		})
+	}
+}
+
+func TestImportUnknownPepper(t *testing.T) {
+	peppered := newTestHasher(t, HasherConfig{Algorithm: Argon2id, Argon2: testArgon2, Pepper: strings.Repeat("p", 32)})
+	hash, err := peppered.Hash("secret")
+	if err != nil {
+		t.Fatalf("hash: %v", err)
+	}
+
+	if _, err := peppered.Import(ImportedHash{Format: FormatArgon2id, Hash: hash}); err != nil {
+		t.Errorf("import with the configured pepper: %v", err)
+	}
+	other := newTestHasher(t, HasherConfig{Algorithm: Argon2id, Argon2: testArgon2, Pepper: strings.Repeat("q", 32)})
+	if _, err := other.Import(ImportedHash{Format: FormatArgon2id, Hash: hash}); !errors.Is(err, ErrPepperMismatch) {
+		t.Errorf("import with another pepper: %v, want %v", err, ErrPepperMismatch)
+	}
+	unpeppered := newTestHasher(t, HasherConfig{Algorithm: Argon2id, Argon2: testArgon2})
+	if _, err := unpeppered.Import(ImportedHash{Format: FormatArgon2id, Hash: hash}); !errors.Is(err, ErrPepperMismatch) {
+		t.Errorf("import without a pepper: %v, want %v", err, ErrPepperMismatch)
+	}
+}
+
+// firebaseParams are the hash parameters of the example project in the
+// documentation of Firebase's scrypt fork
+var firebaseParams = &FirebaseScrypt{
+	SignerKey:     mustDecodeBase64("jxspr8Ki0RYycVU8zykbdLGjFQ3McFUH0uiiTvC8pVMXAn210wjLNmdZJzxUECKbm0QsEmYUSDzZvpjeJ9WmXA=="),
+	SaltSeparator: mustDecodeBase64("Bw=="),
+	Rounds:        8,
+	MemCost:       14,
+}
+
+func mustDecodeBase64(s string) []byte {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// TestImportVectors imports hashes made by the systems they come from, or by
+// independent implementations of their formats, and verifies them
+func TestImportVectors(t *testing.T) {
+	h := newTestHasher(t, HasherConfig{Algorithm: Argon2id, Argon2: testArgon2, Firebase: firebaseParams})
+
+	tests := []struct {
+		name     string
+		hash     ImportedHash
+		password string
+	}{
+		{
+			"django",
+			ImportedHash{Format: FormatDjangoPBKDF2, Hash: "pbkdf2_sha256$1000$seasalt$JgZryXe2Ga8ysg6XbzkLpTdyPQrHqsinbL9BnnhgX4A="},
+			"lètmein",
+		},
+		{
+			"firebase",
+			ImportedHash{Format: FormatFirebaseScrypt, Hash: "lSrfV15cpx95/sZS2W9c9Kp6i/LVgQNDNC/qzrCnh1SAyZvqmZqAjTdn3aoItz+VHjoZilo78198JAdRuid5lQ==", Salt: "42xEC+ixf3L2lw=="},
+			"user1password",
+		},
+		{
+			"salted sha-256 prefix",
+			ImportedHash{Format: FormatSaltedSHA256, Hash: "0169e15ca1e5a303359aeea03ec9554b826550b997fa9ec06ac5c090bb851aa3", Salt: "pepper-salt"},
+			"hunter2",
+		},
+		{
+			"salted sha-256 suffix",
+			ImportedHash{Format: FormatSaltedSHA256, Hash: "tFzirGKMLB+B+W2/WfgCEy00JfO779eX9/D0FC7Cz3M=", Salt: "pepper-salt", SaltPosition: "suffix"},
+			"hunter2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded, err := h.Import(tt.hash)
+			if err != nil {
+				t.Fatalf("import: %v", err)
+			}
+			if ok, err := h.Verify(tt.password, encoded); err != nil || !ok {
+				t.Errorf("verify the password: %v, %v; want true", ok, err)
+			}
+			if ok, err := h.Verify(tt.password+"x", encoded); err != nil || ok {
+				t.Errorf("verify another password: %v, %v; want false", ok, err)
+			}
+			// Imported hashes are replaced at the next login
+			if !h.NeedsRehash(encoded) {
+				t.Error("imported hash needs no rehash")
+			}
+		})
+	}
+}
+
+func TestImportInvalid(t *testing.T) {
+	h := newTestHasher(t, HasherConfig{Algorithm: Argon2id, Argon2: testArgon2, Firebase: firebaseParams})
+	for name, hash := range map[string]ImportedHash{
+		"unknown format":        {Format: "md5", Hash: "5f4dcc3b5aa765d61d8327deb882cf99"},
+		"bcrypt not bcrypt":     {Format: FormatBcrypt, Hash: "$argon2id$v=19$m=64,t=1,p=1$c2FsdA$a2V5"},
+		"django other hasher":   {Format: FormatDjangoPBKDF2, Hash: "pbkdf2_sha1$1000$seasalt$JgZryXe2Ga8ysg6XbzkLpTdyPQrHqsinbL9BnnhgX4A="},
+		"django short hash":     {Format: FormatDjangoPBKDF2, Hash: "pbkdf2_sha256$1000$seasalt$c2hvcnQ="},
+		"firebase no salt":      {Format: FormatFirebaseScrypt, Hash: "lSrfV15cpx95/sZS2W9c9Kp6i/LVgQNDNC/qzrCnh1SAyZvqmZqAjTdn3aoItz+VHjoZilo78198JAdRuid5lQ=="},
+		"firebase short hash":   {Format: FormatFirebaseScrypt, Hash: "c2hvcnQ=", Salt: "42xEC+ixf3L2lw=="},
+		"sha-256 short digest":  {Format: FormatSaltedSHA256, Hash: "0169e15ca1e5a303", Salt: "pepper-salt"},
+		"sha-256 salt position": {Format: FormatSaltedSHA256, Hash: "0169e15ca1e5a303359aeea03ec9554b826550b997fa9ec06ac5c090bb851aa3", SaltPosition: "middle"},
+	} {
+		if encoded, err := h.Import(hash); err == nil {
+			t.Errorf("%s: imported as %s", name, encoded)
+		}
+	}
+
+	// Firebase hashes need the project's parameters
+	plain := newTestHasher(t, HasherConfig{Algorithm: Argon2id, Argon2: testArgon2})
+	if _, err := plain.Import(ImportedHash{Format: FormatFirebaseScrypt, Hash: "c2hvcnQ=", Salt: "c2FsdA=="}); err == nil {
+		t.Error("imported a Firebase hash without the Firebase parameters")
+	}
+}
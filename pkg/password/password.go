@@ -4,14 +4,19 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
-// HashPassword hashes a password using bcrypt
+// HashPassword hashes a secret using bcrypt, as for OAuth client secrets.
+// User passwords are hashed by a Hasher, whose algorithm is configurable.
 func HashPassword(password string) (string, error) {
 	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	return string(bytes), err
 }
 
-// CheckPasswordHash compares a password with its hash
+// CheckPasswordHash compares a secret with its bcrypt hash. Secrets longer
+// than bcrypt can hash never match, rather than being truncated.
 func CheckPasswordHash(password, hash string) bool {
+	if len(password) > BcryptMaxBytes {
+		return false
+	}
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 	return err == nil
 }
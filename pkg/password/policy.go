@@ -0,0 +1,303 @@
+package password
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// CharClass is a class of characters a policy can require passwords to
+// contain
+type CharClass string
+
+// Character classes
+const (
+	Lowercase CharClass = "lower"
+	Uppercase CharClass = "upper"
+	Digit     CharClass = "digit"
+	Symbol    CharClass = "symbol"
+)
+
+// CharClasses lists the character classes a policy can require
+var CharClasses = []CharClass{Lowercase, Uppercase, Digit, Symbol}
+
+// Violation codes, which clients can rely on to explain which requirements a
+// password does not meet
+const (
+	CodeTooShort          = "too_short"
+	CodeTooLong           = "too_long"
+	CodeTooManyBytes      = "too_many_bytes"
+	CodeMissingLowercase  = "missing_lower"
+	CodeMissingUppercase  = "missing_upper"
+	CodeMissingDigit      = "missing_digit"
+	CodeMissingSymbol     = "missing_symbol"
+	CodeRepeatedCharacter = "repeated_characters"
+	CodeContainsUsername  = "contains_username"
+	CodeContainsEmail     = "contains_email"
+	CodeContainsName      = "contains_name"
+	CodeCommon            = "common_password"
+	CodeBreached          = "breached_password"
+	CodeTooWeak           = "too_weak"
+)
+
+// Policy sets the requirements for new passwords
+type Policy struct {
+	MinLength       int         `json:"min_length"`       // in characters
+	MaxLength       int         `json:"max_length"`       // in characters
+	RequiredClasses []CharClass `json:"required_classes"` // classes a password must contain a character of
+	// MaxRepeated is how many times in a row a character may appear, 0 allows
+	// any number
+	MaxRepeated int `json:"max_repeated"`
+	// RejectPersonal refuses passwords containing the username, email address
+	// or name of the user
+	RejectPersonal bool `json:"reject_personal_info"`
+	// RejectCommon refuses passwords from the list of commonly used ones
+	RejectCommon bool `json:"reject_common"`
+	// Common is the list of commonly used passwords, the built-in one when
+	// nil
+	Common *CommonList `json:"-"`
+	// MinEntropy is the estimated strength in bits a password needs, see
+	// Entropy
+	MinEntropy int `json:"min_entropy"`
+	// MaxBytes is the length in bytes of the longest password the hasher
+	// accepts, 0 for any, see Hasher.MaxBytes
+	MaxBytes int `json:"-"`
+	// Breached refuses passwords that appeared in data breaches, when set
+	Breached BreachList `json:"-"`
+}
+
+// MarshalJSON encodes the policy, reporting whether breached passwords are
+// refused as reject_breached
+func (p Policy) MarshalJSON() ([]byte, error) {
+	type policy Policy
+	return json.Marshal(struct {
+		policy
+		RejectBreached bool `json:"reject_breached"`
+	}{policy(p), p.Breached != nil})
+}
+
+// UserInfo is what a policy knows about the user choosing a password
+type UserInfo struct {
+	Username  string
+	Email     string
+	FirstName string
+	LastName  string
+}
+
+// Violation is a requirement of the policy a password does not meet
+type Violation struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// PolicyError is returned for passwords that do not meet the policy, with
+// every requirement they fail
+type PolicyError struct {
+	Violations []Violation `json:"violations"`
+}
+
+func (e *PolicyError) Error() string {
+	codes := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		codes[i] = v.Code
+	}
+	return "password does not meet the policy: " + strings.Join(codes, ", ")
+}
+
+// Validate checks a new password of the user against the policy. It returns
+// a *PolicyError listing every requirement the password does not meet, nil
+// if it meets them all, or the error looking it up in the breach list.
+func (p Policy) Validate(password string, user UserInfo) error {
+	violations, err := p.Check(password, user)
+	if err != nil {
+		return err
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+	return &PolicyError{Violations: violations}
+}
+
+// Check returns the requirements of the policy a new password of the user
+// does not meet
+func (p Policy) Check(password string, user UserInfo) ([]Violation, error) {
+	var violations []Violation
+	add := func(code, format string, args ...interface{}) {
+		violations = append(violations, Violation{Code: code, Message: fmt.Sprintf(format, args...)})
+	}
+
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		add(CodeTooShort, "Use at least %d characters", p.MinLength)
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		add(CodeTooLong, "Use at most %d characters", p.MaxLength)
+	} else if p.MaxBytes > 0 && len(password) > p.MaxBytes {
+		add(CodeTooManyBytes, "Use a shorter password; it may be at most %d bytes long, and accented letters and emoji take up to 4 bytes each", p.MaxBytes)
+	}
+
+	present := classesOf(password)
+	for _, class := range p.RequiredClasses {
+		if present[class] {
+			continue
+		}
+		switch class {
+		case Lowercase:
+			add(CodeMissingLowercase, "Include a lowercase letter")
+		case Uppercase:
+			add(CodeMissingUppercase, "Include an uppercase letter")
+		case Digit:
+			add(CodeMissingDigit, "Include a digit")
+		case Symbol:
+			add(CodeMissingSymbol, "Include a symbol")
+		}
+	}
+
+	if p.MaxRepeated > 0 && longestRun(password) > p.MaxRepeated {
+		add(CodeRepeatedCharacter, "Do not repeat a character more than %d times in a row", p.MaxRepeated)
+	}
+
+	if p.RejectPersonal {
+		lower := strings.ToLower(password)
+		local, _, _ := strings.Cut(user.Email, "@")
+		if containsPart(lower, user.Username) {
+			add(CodeContainsUsername, "Do not include your username")
+		}
+		if containsPart(lower, local) {
+			add(CodeContainsEmail, "Do not include your email address")
+		}
+		if containsPart(lower, user.FirstName) || containsPart(lower, user.LastName) {
+			add(CodeContainsName, "Do not include your name")
+		}
+	}
+
+	if p.RejectCommon {
+		common := p.Common
+		if common == nil {
+			common = builtinCommon
+		}
+		if common.Contains(password) {
+			add(CodeCommon, "Avoid commonly used passwords")
+		}
+	}
+
+	if p.Breached != nil {
+		breached, err := p.Breached.Contains(password)
+		if err != nil {
+			return nil, fmt.Errorf("look up breached password: %w", err)
+		}
+		if breached {
+			add(CodeBreached, "Avoid passwords that appeared in data breaches")
+		}
+	}
+
+	if p.MinEntropy > 0 && Entropy(password) < float64(p.MinEntropy) {
+		add(CodeTooWeak, "Make your password harder to guess, for example by making it longer")
+	}
+
+	return violations, nil
+}
+
+// Entropy estimates the strength of a password in bits. Each character adds
+// as many bits as it takes to pick it from the classes of characters the
+// password uses, except that characters repeating or continuing a sequence
+// with the previous one, as in "aa", "ab" or "21", add one bit.
+func Entropy(password string) float64 {
+	pool := 0
+	for class := range classesOf(password) {
+		switch class {
+		case Lowercase, Uppercase:
+			pool += 26
+		case Digit:
+			pool += 10
+		case Symbol:
+			pool += 33
+		default:
+			pool += 100
+		}
+	}
+	if pool == 0 {
+		return 0
+	}
+
+	perChar := math.Log2(float64(pool))
+	bits := 0.0
+	prev := rune(-1)
+	for _, r := range password {
+		r = unicode.ToLower(r)
+		if prev >= 0 && (r == prev || r == prev+1 || r == prev-1) {
+			bits++
+		} else {
+			bits += perChar
+		}
+		prev = r
+	}
+	return bits
+}
+
+// otherClass marks characters outside the classes of CharClasses, such as
+// letters of other alphabets
+const otherClass CharClass = "other"
+
+// classesOf returns the character classes the password uses
+func classesOf(password string) map[CharClass]bool {
+	classes := make(map[CharClass]bool)
+	for _, r := range password {
+		switch {
+		case r >= 'a' && r <= 'z':
+			classes[Lowercase] = true
+		case r >= 'A' && r <= 'Z':
+			classes[Uppercase] = true
+		case r >= '0' && r <= '9':
+			classes[Digit] = true
+		case r < utf8.RuneSelf:
+			classes[Symbol] = true
+		case unicode.IsLower(r):
+			classes[Lowercase] = true
+			classes[otherClass] = true
+		case unicode.IsUpper(r):
+			classes[Uppercase] = true
+			classes[otherClass] = true
+		case unicode.IsDigit(r):
+			classes[Digit] = true
+		case unicode.IsLetter(r):
+			classes[otherClass] = true
+		default:
+			classes[Symbol] = true
+		}
+	}
+	return classes
+}
+
+// longestRun returns the length of the longest run of the same character
+func longestRun(password string) int {
+	longest, run := 0, 0
+	prev := rune(-1)
+	for _, r := range password {
+		if r == prev {
+			run++
+		} else {
+			run = 1
+		}
+		prev = r
+		longest = max(longest, run)
+	}
+	return longest
+}
+
+// minPersonalLength is the length below which parts of the user's details
+// are too short to be recognized in a password
+const minPersonalLength = 3
+
+// containsPart reports whether the lowercased password contains part, ignoring
+// case and parts too short to be meaningful
+func containsPart(lowerPassword, part string) bool {
+	part = strings.ToLower(strings.TrimSpace(part))
+	if utf8.RuneCountInString(part) < minPersonalLength {
+		return false
+	}
+	return strings.Contains(lowerPassword, part)
+}
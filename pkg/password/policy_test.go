@@ -0,0 +1,175 @@
+package password
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// codes returns the codes of the violations
+func codes(violations []Violation) []string {
+	list := make([]string, len(violations))
+	for i, v := range violations {
+		list[i] = v.Code
+	}
+	return list
+}
+
+func TestPolicyViolations(t *testing.T) {
+	policy := Policy{
+		MinLength:       8,
+		MaxLength:       20,
+		RequiredClasses: CharClasses,
+		MaxRepeated:     3,
+		RejectPersonal:  true,
+		RejectCommon:    true,
+		MinEntropy:      35,
+	}
+	user := UserInfo{Username: "orbit77", Email: "alice.smith@example.com", FirstName: "Alice", LastName: "Smith"}
+
+	tests := []struct {
+		password string
+		want     []string
+	}{
+		{"Kx9#vTq2mW", nil},
+		{"Kx9#vT", []string{CodeTooShort}},
+		{"Kx9#vTq2mW-Kx9#vTq2mW", []string{CodeTooLong}},
+		{"KX9#VTQ2MW", []string{CodeMissingLowercase}},
+		{"kx9#vtq2mw", []string{CodeMissingUppercase}},
+		{"Kxz#vTqYmW", []string{CodeMissingDigit}},
+		{"Kx9jvTq2mW", []string{CodeMissingSymbol}},
+		{"Kx9#vTqqqqW", []string{CodeRepeatedCharacter}},
+		{"Kx9#Orbit77W", []string{CodeContainsUsername}},
+		{"Kx9#Alice.smith", []string{CodeContainsEmail, CodeContainsName}},
+		{"Kx9#vSmithW", []string{CodeContainsName}},
+		{"Dragon2024!", []string{CodeCommon}},
+		{"Bcde345#", []string{CodeTooWeak}},
+		{"abc", []string{CodeTooShort, CodeMissingUppercase, CodeMissingDigit, CodeMissingSymbol, CodeTooWeak}},
+	}
+	for _, tt := range tests {
+		violations, err := policy.Check(tt.password, user)
+		if err != nil {
+			t.Fatalf("check %q: %v", tt.password, err)
+		}
+		if got := codes(violations); !slices.Equal(got, tt.want) {
+			t.Errorf("violations of %q: %v, want %v", tt.password, got, tt.want)
+		}
+		for _, v := range violations {
+			if v.Message == "" {
+				t.Errorf("violation %s of %q has no message", v.Code, tt.password)
+			}
+		}
+	}
+}
+
+func TestPolicyValidate(t *testing.T) {
+	policy := Policy{MinLength: 8, RejectCommon: true}
+	if err := policy.Validate("Kx9#vTq2mW", UserInfo{}); err != nil {
+		t.Errorf("validate a password meeting the policy: %v", err)
+	}
+
+	err := policy.Validate("password", UserInfo{})
+	var policyErr *PolicyError
+	if !errors.As(err, &policyErr) {
+		t.Fatalf("validate a common password: %v, want a PolicyError", err)
+	}
+	if got := codes(policyErr.Violations); !slices.Equal(got, []string{CodeCommon}) {
+		t.Errorf("violations %v, want %s", got, CodeCommon)
+	}
+}
+
+func TestPolicyBreached(t *testing.T) {
+	policy := Policy{MinLength: 8, Breached: breachList{"hunter2hunter2": true}}
+	violations, err := policy.Check("hunter2hunter2", UserInfo{})
+	if err != nil {
+		t.Fatalf("check: %v", err)
+	}
+	if got := codes(violations); !slices.Equal(got, []string{CodeBreached}) {
+		t.Errorf("violations %v, want %s", got, CodeBreached)
+	}
+
+	failing := Policy{Breached: breachList(nil)}
+	if _, err := failing.Check("anything", UserInfo{}); !errors.Is(err, errLookup) {
+		t.Errorf("check with a failing breach list: %v, want %v", err, errLookup)
+	}
+}
+
+// errLookup is the error of a breach list that cannot be read
+var errLookup = errors.New("lookup failed")
+
+// breachList is a BreachList of the passwords set to true. A nil list fails
+// every lookup.
+type breachList map[string]bool
+
+func (l breachList) Contains(password string) (bool, error) {
+	if l == nil {
+		return false, errLookup
+	}
+	return l[password], nil
+}
+
+func TestPolicyByteLimit(t *testing.T) {
+	policy := Policy{MinLength: 8, MaxLength: 72, MaxBytes: 72}
+
+	// 30 characters of 4 bytes each fit the length but not the hasher
+	violations, err := policy.Check(strings.Repeat("🔑", 30), UserInfo{})
+	if err != nil {
+		t.Fatalf("check: %v", err)
+	}
+	if got := codes(violations); !slices.Equal(got, []string{CodeTooManyBytes}) {
+		t.Fatalf("violations %v, want %s", got, CodeTooManyBytes)
+	}
+	if msg := violations[0].Message; !strings.Contains(msg, "72 bytes") {
+		t.Errorf("message %q does not mention the byte limit", msg)
+	}
+
+	violations, err = policy.Check(strings.Repeat("ab", 40), UserInfo{})
+	if err != nil {
+		t.Fatalf("check: %v", err)
+	}
+	if got := codes(violations); !slices.Equal(got, []string{CodeTooLong}) {
+		t.Errorf("violations of a password too long %v, want only %s", got, CodeTooLong)
+	}
+}
+
+func TestLoadCommonList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "common.txt")
+	if err := os.WriteFile(path, []byte("# local additions\nGoldcast\n\n  quarterdeck \n"), 0o600); err != nil {
+		t.Fatalf("write list: %v", err)
+	}
+	list, err := LoadCommonList(path)
+	if err != nil {
+		t.Fatalf("load list: %v", err)
+	}
+	if list.Len() != builtinCommon.Len()+2 {
+		t.Errorf("%d passwords on the list, want the %d built-in ones and 2", list.Len(), builtinCommon.Len())
+	}
+	for _, password := range []string{"goldcast", "Quarterdeck2024!", "dragon"} {
+		if !list.Contains(password) {
+			t.Errorf("%q not on the list", password)
+		}
+	}
+	if IsCommon("goldcast") {
+		t.Error("loading a list changed the built-in one")
+	}
+
+	policy := Policy{MinLength: 8, RejectCommon: true, Common: list}
+	violations, err := policy.Check("Goldcast#1", UserInfo{})
+	if err != nil {
+		t.Fatalf("check: %v", err)
+	}
+	if got := codes(violations); !slices.Equal(got, []string{CodeCommon}) {
+		t.Errorf("violations %v, want %s", got, CodeCommon)
+	}
+
+	empty := filepath.Join(t.TempDir(), "empty.txt")
+	if err := os.WriteFile(empty, []byte("# nothing\n"), 0o600); err != nil {
+		t.Fatalf("write list: %v", err)
+	}
+	if _, err := LoadCommonList(empty); err == nil {
+		t.Error("loaded a list without passwords")
+	}
+}
@@ -0,0 +1,92 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Limiter allows a number of events per key within a sliding window, such as
+// requests per client IP. State is kept in memory, so each instance of the
+// service enforces its own limits.
+type Limiter struct {
+	mu     sync.Mutex
+	limit  int
+	window time.Duration
+	events map[string][]time.Time // oldest first
+}
+
+// New creates a limiter allowing limit events per key within window. A limit
+// of zero or less disables limiting.
+func New(limit int, window time.Duration) *Limiter {
+	return &Limiter{
+		limit:  limit,
+		window: window,
+		events: make(map[string][]time.Time),
+	}
+}
+
+// Allow records an event for the key and reports whether it is within the
+// limit. Events over the limit are not recorded; for them, Allow also
+// returns how long until the key is allowed another event.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	if l.limit <= 0 {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	events := l.recent(key, now)
+	if len(events) >= l.limit {
+		return false, events[0].Add(l.window).Sub(now)
+	}
+	l.events[key] = append(events, now)
+	return true, 0
+}
+
+// Refund removes the key's latest event, for an attempt that turned out not
+// to count against the limit, such as a correct code. Recording the event
+// with Allow first means that concurrent attempts all count until refunded.
+func (l *Limiter) Refund(key string) {
+	if l.limit <= 0 {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if events := l.events[key]; len(events) > 0 {
+		l.events[key] = events[:len(events)-1]
+	}
+}
+
+// Prune forgets keys without events in the current window and returns how
+// many it dropped
+func (l *Limiter) Prune() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	n := 0
+	for key := range l.events {
+		if len(l.recent(key, now)) == 0 {
+			delete(l.events, key)
+			n++
+		}
+	}
+	return n
+}
+
+// recent drops the key's events that left the window and returns the rest
+func (l *Limiter) recent(key string, now time.Time) []time.Time {
+	events := l.events[key]
+	cutoff := now.Add(-l.window)
+	i := 0
+	for i < len(events) && !events[i].After(cutoff) {
+		i++
+	}
+	events = events[i:]
+	l.events[key] = events
+	return events
+}
@@ -0,0 +1,110 @@
+package totp
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 key of the RFC 6238 test vectors,
+// "12345678901234567890" in base32
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// rfcVectors are the SHA-1 test vectors of RFC 6238 appendix B. The RFC
+// lists 8 digit codes; 6 digit codes are their last 6 digits.
+var rfcVectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "94287082"},
+	{1111111109, "07081804"},
+	{1111111111, "14050471"},
+	{1234567890, "89005924"},
+	{2000000000, "69279037"},
+	{20000000000, "65353130"},
+}
+
+func TestCodeRFC6238(t *testing.T) {
+	for _, v := range rfcVectors {
+		code, err := Code(rfcSecret, Step(time.Unix(v.unix, 0)))
+		if err != nil {
+			t.Fatalf("code at %d: %v", v.unix, err)
+		}
+		if want := v.code[len(v.code)-Digits:]; code != want {
+			t.Errorf("code at %d = %s, want %s", v.unix, code, want)
+		}
+	}
+
+	// Authenticator apps may show the secret in lowercase
+	if code, err := Code(strings.ToLower(rfcSecret), Step(time.Unix(59, 0))); err != nil || code != "287082" {
+		t.Errorf("code with a lowercase secret = %s, %v; want 287082", code, err)
+	}
+	if _, err := Code("not base32!", 1); err != ErrInvalidSecret {
+		t.Errorf("code with an invalid secret: %v, want %v", err, ErrInvalidSecret)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := Step(now)
+
+	for _, tt := range []struct {
+		name string
+		code string
+		want bool
+		step int64
+	}{
+		{"current step", "050471", true, current},
+		{"previous step", "081804", true, current - 1},
+		{"wrong code", "123456", false, 0},
+		{"8 digits", "14050471", false, 0},
+		{"too short", "05047", false, 0},
+	} {
+		step, ok, err := Validate(rfcSecret, tt.code, now, 1)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if ok != tt.want || step != tt.step {
+			t.Errorf("%s: step %d, %v; want %d, %v", tt.name, step, ok, tt.step, tt.want)
+		}
+	}
+
+	// Without skew only the current step is accepted
+	if _, ok, _ := Validate(rfcSecret, "081804", now, 0); ok {
+		t.Error("code of the previous step accepted without skew")
+	}
+	if _, ok, _ := Validate(rfcSecret, "081804", now.Add(10*Period*time.Second), 1); ok {
+		t.Error("code accepted long after its step")
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatalf("generate secret: %v", err)
+	}
+	key, err := encoding.DecodeString(secret)
+	if err != nil || len(key) != secretSize {
+		t.Fatalf("secret %q decodes to %d bytes, %v; want %d", secret, len(key), err, secretSize)
+	}
+	if other, _ := GenerateSecret(); other == secret {
+		t.Error("two secrets are the same")
+	}
+}
+
+func TestURI(t *testing.T) {
+	uri, err := url.Parse(URI("Goldcast", "alice@example.com", rfcSecret))
+	if err != nil {
+		t.Fatalf("parse URI: %v", err)
+	}
+	if uri.Scheme != "otpauth" || uri.Host != "totp" || uri.Path != "/Goldcast:alice@example.com" {
+		t.Errorf("URI %s, want otpauth://totp/Goldcast:alice@example.com", uri)
+	}
+	query := uri.Query()
+	for key, want := range map[string]string{"secret": rfcSecret, "issuer": "Goldcast", "algorithm": "SHA1", "digits": "6", "period": "30"} {
+		if got := query.Get(key); got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+}